@@ -57,24 +57,94 @@ func LoadMailAccountMap() error {
 
 	rows, err := db.Pool.Query(db.Ctx, `
 		SELECT id, oauth_client_id, name, mode, auth_method, username,
-			password, start_tls, send_as, host_name, host_port
+			password, start_tls, send_as, host_name, host_port,
+			imap_folders, imap_action, imap_folder_move, dkim_domain,
+			dkim_selector, dkim_key, smime_cert, smime_key, smime_sign,
+			smime_encrypt, bounce_pg_function_id
 		FROM instance.mail_account
 	`)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 
 	for rows.Next() {
 		var ma types.MailAccount
 
 		if err := rows.Scan(&ma.Id, &ma.OauthClientId, &ma.Name, &ma.Mode,
 			&ma.AuthMethod, &ma.Username, &ma.Password, &ma.StartTls,
-			&ma.SendAs, &ma.HostName, &ma.HostPort); err != nil {
+			&ma.SendAs, &ma.HostName, &ma.HostPort, &ma.ImapFolders,
+			&ma.ImapAction, &ma.ImapFolderMove, &ma.DkimDomain,
+			&ma.DkimSelector, &ma.DkimKey, &ma.SmimeCert, &ma.SmimeKey,
+			&ma.SmimeSign, &ma.SmimeEncrypt, &ma.BouncePgFunctionId); err != nil {
 
+			rows.Close()
 			return err
 		}
 		mailAccountIdMap[ma.Id] = ma
 	}
+	rows.Close()
+
+	for id, ma := range mailAccountIdMap {
+		ma.Rules, err = getMailRules(id)
+		if err != nil {
+			return err
+		}
+		mailAccountIdMap[id] = ma
+	}
 	return nil
 }
+
+func getMailRules(mailAccountId int32) ([]types.MailRule, error) {
+	rules := make([]types.MailRule, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT id, name, match_from, match_to, match_subject, action,
+			pg_function_id, relation_id, attribute_id_from, attribute_id_to,
+			attribute_id_subject, attribute_id_body, attribute_id_date,
+			attribute_id_files
+		FROM instance.mail_rule
+		WHERE mail_account_id = $1
+		ORDER BY position ASC
+	`, mailAccountId)
+	if err != nil {
+		return rules, err
+	}
+
+	for rows.Next() {
+		var r types.MailRule
+		if err := rows.Scan(&r.Id, &r.Name, &r.MatchFrom, &r.MatchTo,
+			&r.MatchSubject, &r.Action, &r.PgFunctionId, &r.RelationId,
+			&r.AttributeIdFrom, &r.AttributeIdTo, &r.AttributeIdSubject,
+			&r.AttributeIdBody, &r.AttributeIdDate, &r.AttributeIdFiles); err != nil {
+
+			rows.Close()
+			return rules, err
+		}
+		rules = append(rules, r)
+	}
+	rows.Close()
+
+	for i, r := range rules {
+		rules[i].MatchHeaders = make([]types.MailRuleHeader, 0)
+
+		rows, err := db.Pool.Query(db.Ctx, `
+			SELECT name, match
+			FROM instance.mail_rule_header
+			WHERE mail_rule_id = $1
+			ORDER BY position ASC
+		`, r.Id)
+		if err != nil {
+			return rules, err
+		}
+		for rows.Next() {
+			var h types.MailRuleHeader
+			if err := rows.Scan(&h.Name, &h.Match); err != nil {
+				rows.Close()
+				return rules, err
+			}
+			rules[i].MatchHeaders = append(rules[i].MatchHeaders, h)
+		}
+		rows.Close()
+	}
+	return rules, nil
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"errors"
+	"r3/oidc"
+	"r3/types"
+	"sync"
+)
+
+var (
+	oidc_mx   sync.Mutex
+	oidcIdMap map[int32]types.Oidc
+)
+
+func GetOidcIdMap() map[int32]types.Oidc {
+	oidc_mx.Lock()
+	defer oidc_mx.Unlock()
+	return oidcIdMap
+}
+
+func GetOidc(id int32) (types.Oidc, error) {
+	oidc_mx.Lock()
+	defer oidc_mx.Unlock()
+
+	o, exists := oidcIdMap[id]
+	if !exists {
+		return o, errors.New("unknown OpenID Connect provider")
+	}
+	return o, nil
+}
+
+func LoadOidcMap() error {
+
+	oidc_mx.Lock()
+	defer oidc_mx.Unlock()
+
+	oidcs, err := oidc.Get()
+	if err != nil {
+		return err
+	}
+
+	oidcIdMap = make(map[int32]types.Oidc)
+
+	for _, o := range oidcs {
+		oidcIdMap[o.Id] = o
+	}
+	return nil
+}
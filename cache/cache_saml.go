@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"errors"
+	"r3/saml"
+	"r3/types"
+	"sync"
+)
+
+var (
+	saml_mx   sync.Mutex
+	samlIdMap map[int32]types.Saml
+)
+
+func GetSamlIdMap() map[int32]types.Saml {
+	saml_mx.Lock()
+	defer saml_mx.Unlock()
+	return samlIdMap
+}
+
+func GetSaml(id int32) (types.Saml, error) {
+	saml_mx.Lock()
+	defer saml_mx.Unlock()
+
+	s, exists := samlIdMap[id]
+	if !exists {
+		return s, errors.New("unknown SAML identity provider")
+	}
+	return s, nil
+}
+
+func LoadSamlMap() error {
+
+	saml_mx.Lock()
+	defer saml_mx.Unlock()
+
+	samls, err := saml.Get()
+	if err != nil {
+		return err
+	}
+
+	samlIdMap = make(map[int32]types.Saml)
+
+	for _, s := range samls {
+		samlIdMap[s.Id] = s
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ import (
 	"r3/schema/icon"
 	"r3/schema/jsFunction"
 	"r3/schema/loginForm"
+	"r3/schema/mailTemplate"
 	"r3/schema/menu"
 	"r3/schema/module"
 	"r3/schema/pgFunction"
@@ -44,14 +45,15 @@ var (
 	moduleIdMapMeta = make(map[uuid.UUID]types.ModuleMeta) // ID map of module meta data
 
 	// cached entities for regular use during normal operation
-	ModuleIdMap        = make(map[uuid.UUID]types.Module)      // all modules by ID
-	ModuleApiNameMapId = make(map[string]map[string]uuid.UUID) // all API IDs by module+API name
-	RelationIdMap      = make(map[uuid.UUID]types.Relation)    // all relations by ID
-	AttributeIdMap     = make(map[uuid.UUID]types.Attribute)   // all attributes by ID
-	RoleIdMap          = make(map[uuid.UUID]types.Role)        // all roles by ID
-	PgFunctionIdMap    = make(map[uuid.UUID]types.PgFunction)  // all PG functions by ID
-	ApiIdMap           = make(map[uuid.UUID]types.Api)         // all APIs by ID
-	ClientEventIdMap   = make(map[uuid.UUID]types.ClientEvent) // all client events by ID
+	ModuleIdMap        = make(map[uuid.UUID]types.Module)       // all modules by ID
+	ModuleApiNameMapId = make(map[string]map[string]uuid.UUID)  // all API IDs by module+API name
+	RelationIdMap      = make(map[uuid.UUID]types.Relation)     // all relations by ID
+	AttributeIdMap     = make(map[uuid.UUID]types.Attribute)    // all attributes by ID
+	RoleIdMap          = make(map[uuid.UUID]types.Role)         // all roles by ID
+	PgFunctionIdMap    = make(map[uuid.UUID]types.PgFunction)   // all PG functions by ID
+	ApiIdMap           = make(map[uuid.UUID]types.Api)          // all APIs by ID
+	ClientEventIdMap   = make(map[uuid.UUID]types.ClientEvent)  // all client events by ID
+	MailTemplateIdMap  = make(map[uuid.UUID]types.MailTemplate) // all mail templates by ID
 )
 
 func GetModuleIdMapMeta() map[uuid.UUID]types.ModuleMeta {
@@ -174,6 +176,7 @@ func updateSchemaCache(moduleIds []uuid.UUID) error {
 		mod.Apis = make([]types.Api, 0)
 		mod.ClientEvents = make([]types.ClientEvent, 0)
 		mod.Widgets = make([]types.Widget, 0)
+		mod.MailTemplates = make([]types.MailTemplate, 0)
 		ModuleApiNameMapId[mod.Name] = make(map[string]uuid.UUID)
 
 		// get articles
@@ -332,6 +335,17 @@ func updateSchemaCache(moduleIds []uuid.UUID) error {
 			return err
 		}
 
+		// get mail templates
+		log.Info("cache", "load mail templates")
+
+		mod.MailTemplates, err = mailTemplate.Get(mod.Id)
+		if err != nil {
+			return err
+		}
+		for _, t := range mod.MailTemplates {
+			MailTemplateIdMap[t.Id] = t
+		}
+
 		// update cache map with parsed module
 		ModuleIdMap[mod.Id] = mod
 	}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"r3/db"
+	"r3/types"
+	"sync"
+
+	"github.com/gofrs/uuid"
+)
+
+var (
+	webhook_mx              sync.RWMutex
+	webhookIdMap            map[int32]types.Webhook
+	webhookRelationIdMapIds map[uuid.UUID][]int32 // active webhooks by relation ID
+)
+
+func GetWebhookMap() map[int32]types.Webhook {
+	webhook_mx.RLock()
+	defer webhook_mx.RUnlock()
+
+	return webhookIdMap
+}
+
+// returns active webhooks for given relation
+func GetWebhooksByRelationId(relationId uuid.UUID) []types.Webhook {
+	webhook_mx.RLock()
+	defer webhook_mx.RUnlock()
+
+	webhooks := make([]types.Webhook, 0)
+	for _, id := range webhookRelationIdMapIds[relationId] {
+		webhooks = append(webhooks, webhookIdMap[id])
+	}
+	return webhooks
+}
+
+func LoadWebhookMap() error {
+	webhook_mx.Lock()
+	defer webhook_mx.Unlock()
+
+	webhookIdMap = make(map[int32]types.Webhook)
+	webhookRelationIdMapIds = make(map[uuid.UUID][]int32)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT id, relation_id, name, url, secret, on_insert,
+			on_update, on_delete, skip_verify, active
+		FROM instance.webhook
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w types.Webhook
+
+		if err := rows.Scan(&w.Id, &w.RelationId, &w.Name, &w.Url, &w.Secret,
+			&w.OnInsert, &w.OnUpdate, &w.OnDelete, &w.SkipVerify, &w.Active); err != nil {
+
+			return err
+		}
+		webhookIdMap[w.Id] = w
+
+		if w.Active {
+			webhookRelationIdMapIds[w.RelationId] = append(webhookRelationIdMapIds[w.RelationId], w.Id)
+		}
+	}
+	return nil
+}
@@ -250,6 +250,16 @@ func MasterAssigned(state bool) error {
 	SchedulerRestart <- true
 	return nil
 }
+func SessionRevoked(updateNodes bool, loginId int64, sessionId uuid.UUID) error {
+	target := types.ClusterEventTarget{LoginId: loginId}
+	if updateNodes {
+		if err := createEventsForOtherNodes("sessionRevoked", sessionId, target); err != nil {
+			return err
+		}
+	}
+	WebsocketClientEvents <- types.ClusterEvent{Content: "kickSession", Payload: sessionId, Target: target}
+	return nil
+}
 func SchemaChanged(updateNodes bool, moduleIds []uuid.UUID) error {
 	target := types.ClusterEventTarget{Device: types.WebsocketClientDeviceBrowser}
 
@@ -30,6 +30,7 @@ func Get(id pgtype.UUID, target string) (types.CaptionMapsAll, error) {
 	caps.FormActionIdMap = make(map[uuid.UUID]types.CaptionMap)
 	caps.JsFunctionIdMap = make(map[uuid.UUID]types.CaptionMap)
 	caps.LoginFormIdMap = make(map[uuid.UUID]types.CaptionMap)
+	caps.MailTemplateIdMap = make(map[uuid.UUID]types.CaptionMap)
 	caps.MenuIdMap = make(map[uuid.UUID]types.CaptionMap)
 	caps.ModuleIdMap = make(map[uuid.UUID]types.CaptionMap)
 	caps.PgFunctionIdMap = make(map[uuid.UUID]types.CaptionMap)
@@ -48,6 +49,7 @@ func Get(id pgtype.UUID, target string) (types.CaptionMapsAll, error) {
 		WHEN form_id         IS NOT NULL THEN 'form'
 		WHEN js_function_id  IS NOT NULL THEN 'jsFunction'
 		WHEN login_form_id   IS NOT NULL THEN 'loginForm'
+		WHEN mail_template_id IS NOT NULL THEN 'mailTemplate'
 		WHEN menu_id         IS NOT NULL THEN 'menu'
 		WHEN module_id       IS NOT NULL THEN 'module'
 		WHEN pg_function_id  IS NOT NULL THEN 'pgFunction'
@@ -66,6 +68,7 @@ func Get(id pgtype.UUID, target string) (types.CaptionMapsAll, error) {
 		form_action_id,
 		js_function_id,
 		login_form_id,
+		mail_template_id,
 		menu_id,
 		module_id,
 		pg_function_id,
@@ -144,7 +147,8 @@ func Get(id pgtype.UUID, target string) (types.CaptionMapsAll, error) {
 			OR pg_function_id  IN (SELECT id FROM app.pg_function  WHERE module_id = $16)
 			OR role_id         IN (SELECT id FROM app.role         WHERE module_id = $17)
 			OR widget_id       IN (SELECT id FROM app.widget       WHERE module_id = $18)
-		`, sqlSelect, target), id, id, id, id, id, id, id, id, id, id, id, id, id, id, id, id, id, id)
+			OR mail_template_id IN (SELECT id FROM app.mail_template WHERE module_id = $19)
+		`, sqlSelect, target), id, id, id, id, id, id, id, id, id, id, id, id, id, id, id, id, id, id, id)
 	}
 
 	if err != nil {
@@ -183,6 +187,8 @@ func Get(id pgtype.UUID, target string) (types.CaptionMapsAll, error) {
 			captionMap, exists = caps.JsFunctionIdMap[entityId]
 		case "loginForm":
 			captionMap, exists = caps.LoginFormIdMap[entityId]
+		case "mailTemplate":
+			captionMap, exists = caps.MailTemplateIdMap[entityId]
 		case "menu":
 			captionMap, exists = caps.MenuIdMap[entityId]
 		case "module":
@@ -223,6 +229,8 @@ func Get(id pgtype.UUID, target string) (types.CaptionMapsAll, error) {
 			caps.JsFunctionIdMap[entityId] = captionMap
 		case "loginForm":
 			caps.LoginFormIdMap[entityId] = captionMap
+		case "mailTemplate":
+			caps.MailTemplateIdMap[entityId] = captionMap
 		case "menu":
 			caps.MenuIdMap[entityId] = captionMap
 		case "module":
@@ -22,22 +22,28 @@ var (
 		"companyLogo", "companyLogoUrl", "companyName", "companyWelcome", "css",
 		"dbVersionCut", "exportPrivateKey", "iconPwa1", "iconPwa2",
 		"instanceId", "licenseFile", "publicHostName", "proxyUrl", "repoPass",
-		"repoPublicKeys", "repoUrl", "repoUser", "tokenSecret",
+		"repoPublicKeys", "repoUrl", "repoUser", "restSuccessCodes", "tokenSecret",
 		"updateCheckUrl", "updateCheckVersion"}
 
-	NamesUint64 = []string{"backupDaily", "backupMonthly", "backupWeekly",
-		"backupCountDaily", "backupCountMonthly", "backupCountWeekly",
+	NamesUint64 = []string{"apiIdempotencyHours", "backupDaily",
+		"backupMonthly", "backupWeekly", "backupCountDaily", "backupCountMonthly", "backupCountWeekly",
 		"bruteforceAttempts", "bruteforceProtection", "builderMode",
 		"clusterNodeMissingAfter", "dbTimeoutCsv", "dbTimeoutDataRest",
-		"dbTimeoutDataWs", "dbTimeoutIcs", "filesKeepDaysDeleted",
+		"dbTimeoutDataStream", "dbTimeoutDataWs", "dbTimeoutIcs", "filesKeepDaysDeleted",
 		"fileVersionsKeepCount", "fileVersionsKeepDays", "icsDaysPost",
 		"icsDaysPre", "icsDownload", "imagerThumbWidth", "logApi", "logBackup",
 		"logCache", "logCluster", "logCsv", "logImager", "logLdap", "logMail",
 		"logModule", "logServer", "logScheduler", "logTransfer", "logWebsocket",
-		"logsKeepDays", "mailTrafficKeepDays", "productionMode", "pwForceDigit",
-		"pwForceLower", "pwForceSpecial", "pwForceUpper", "pwLengthMin",
-		"repoChecked", "repoFeedback", "repoSkipVerify", "tokenExpiryHours",
-		"tokenKeepEnable", "tokenReauthHours"}
+		"loginLockAttempts", "loginLockMinutes", "logsKeepDays",
+		"mailTrafficKeepDays", "productionMode", "pwArgon2Iterations",
+		"pwArgon2Memory", "pwArgon2Threads", "pwForceDigit",
+		"pwForceLower", "pwForceSpecial", "pwForceUpper", "pwHistoryCount",
+		"pwLengthMin", "pwMaxAgeDays", "pwResetEnable", "pwResetExpiryMinutes",
+		"repoChecked", "repoFeedback", "repoSkipVerify", "restAttempts",
+		"restBackoffSeconds", "restHostConcurrency", "restHostRateLimit",
+		"restTimeoutSeconds", "restWorkers", "tokenExpiryHours",
+		"tokenKeepEnable", "tokenReauthHours", "webauthnPasswordless",
+		"webhookDeliveriesKeepDays"}
 
 	NamesUint64Slice = []string{"loginBackgrounds"}
 )
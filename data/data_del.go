@@ -47,12 +47,15 @@ func Del_tx(ctx context.Context, tx pgx.Tx, relationId uuid.UUID,
 		return err
 	}
 
-	_, err = tx.Exec(ctx, fmt.Sprintf(`
+	tag, err := tx.Exec(ctx, fmt.Sprintf(`
 		DELETE FROM "%s"."%s" AS "%s"
 		WHERE "%s"."%s" = $1
 		%s
 	`, mod.Name, rel.Name, tableAlias, tableAlias,
 		schema.PkName, policyFilter), recordId)
 
-	return err
+	if err != nil || tag.RowsAffected() == 0 {
+		return err
+	}
+	return setWebhookDeliveries_tx(ctx, tx, relationId, "delete", recordId, nil)
 }
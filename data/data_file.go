@@ -224,7 +224,7 @@ func FileApplyVersion_tx(ctx context.Context, tx pgx.Tx, isNewFile bool,
 	for _, recordId := range recordIds {
 		if err := setLog_tx(db.Ctx, tx, relationId, logAttributes,
 			logAttributeFileIndexes, false, logValuesOld, recordId,
-			loginId); err != nil {
+			pgtype.Int8{Int64: loginId, Valid: loginId != -1}); err != nil {
 
 			return err
 		}
@@ -17,6 +17,7 @@ import (
 
 	"github.com/gofrs/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -30,6 +31,14 @@ func Get_tx(ctx context.Context, tx pgx.Tx, data types.DataGet, loginId int64,
 	cache.Schema_mx.RLock()
 	defer cache.Schema_mx.RUnlock()
 
+	return get_tx(ctx, tx, data, loginId, false, query)
+}
+
+// get data, schema cache must be locked by caller
+// system access (without login) is not restricted by roles or policies
+func get_tx(ctx context.Context, tx pgx.Tx, data types.DataGet, loginId int64,
+	system bool, query *string) ([]types.DataGetResult, int, error) {
+
 	var err error
 	indexRelationIds := make(map[int]uuid.UUID) // map of accessed relation IDs, key: relation index
 	relationIndexesEnc := make([]int, 0)        // indexes of relations from encrypted attributes within expressions
@@ -40,7 +49,7 @@ func Get_tx(ctx context.Context, tx pgx.Tx, data types.DataGet, loginId int64,
 
 	// prepare SQL query for data GET request
 	*query, queryCount, err = prepareQuery(data, indexRelationIds,
-		&queryArgs, &queryCountArgs, loginId, 0)
+		&queryArgs, &queryCountArgs, loginId, system, 0)
 
 	if err != nil {
 		return results, 0, err
@@ -54,44 +63,11 @@ func Get_tx(ctx context.Context, tx pgx.Tx, data types.DataGet, loginId int64,
 	columns := rows.FieldDescriptions()
 
 	for rows.Next() {
-		valuesAll, err := rows.Values()
+		result, err := getResultFromRow(rows, columns, len(data.Expressions))
 		if err != nil {
 			return results, 0, err
 		}
-
-		indexRecordIds := make(map[int]interface{}) // ID for each relation tupel by index
-		indexRecordEncKeys := make(map[int]string)  // encrypted key for each relation tupel by index
-		values := make([]interface{}, 0)            // final values for selected attributes
-
-		// collect values for expressions
-		for i := 0; i < len(data.Expressions); i++ {
-			values = append(values, valuesAll[i])
-		}
-
-		// collect relation tupel IDs
-		// relation ID columns start after expressions
-		for i, j := len(data.Expressions), len(columns); i < j; i++ {
-
-			matches := regexRelId.FindStringSubmatch(string(columns[i].Name))
-
-			if len(matches) == 2 {
-
-				// column provides relation ID
-				relIndex, err := strconv.Atoi(matches[1])
-				if err != nil {
-					return results, 0, err
-				}
-				indexRecordIds[relIndex] = valuesAll[i]
-			}
-		}
-
-		results = append(results, types.DataGetResult{
-			IndexRecordIds:     indexRecordIds,
-			IndexRecordEncKeys: indexRecordEncKeys,
-			IndexesPermNoDel:   make([]int, 0),
-			IndexesPermNoSet:   make([]int, 0),
-			Values:             values,
-		})
+		results = append(results, result)
 	}
 	if err := rows.Err(); err != nil {
 		return results, 0, err
@@ -268,16 +244,83 @@ func Get_tx(ctx context.Context, tx pgx.Tx, data types.DataGet, loginId int64,
 	return results, count, nil
 }
 
+// get data row by row, each result is passed to the given function without collecting results
+// used for large result sets, memory use is independent of result count
+// relation policy permissions (GetPerm) and data keys of encrypted attributes are not resolved, no total count is retrieved
+// updates SQL query pointer value (for error logging)
+func GetEach_tx(ctx context.Context, tx pgx.Tx, data types.DataGet, loginId int64,
+	query *string, fn func(result types.DataGetResult) error) error {
+
+	var err error
+	indexRelationIds := make(map[int]uuid.UUID)
+	queryArgs := make([]interface{}, 0)
+	queryCountArgs := make([]interface{}, 0)
+
+	// schema is only required to build the query, rows can take long to be processed
+	cache.Schema_mx.RLock()
+	*query, _, err = prepareQuery(data, indexRelationIds,
+		&queryArgs, &queryCountArgs, loginId, false, 0)
+	cache.Schema_mx.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, *query, queryArgs...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns := rows.FieldDescriptions()
+
+	for rows.Next() {
+		result, err := getResultFromRow(rows, columns, len(data.Expressions))
+		if err != nil {
+			return err
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// get total count of data GET request, limit and offset are ignored
+// updates SQL query pointer value (for error logging)
+func GetCount_tx(ctx context.Context, tx pgx.Tx, data types.DataGet, loginId int64,
+	query *string) (int, error) {
+
+	cache.Schema_mx.RLock()
+	defer cache.Schema_mx.RUnlock()
+
+	var err error
+	indexRelationIds := make(map[int]uuid.UUID)
+	queryArgs := make([]interface{}, 0)
+	queryCountArgs := make([]interface{}, 0)
+
+	_, *query, err = prepareQuery(data, indexRelationIds,
+		&queryArgs, &queryCountArgs, loginId, false, 0)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = tx.QueryRow(ctx, *query, queryCountArgs...).Scan(&count)
+	return count, err
+}
+
 // build SQL call from data GET request
 // also used for sub queries, a nesting level is included for separation (0 = main query)
 // returns data + count SQL query strings
 func prepareQuery(data types.DataGet, indexRelationIds map[int]uuid.UUID,
 	queryArgs *[]interface{}, queryCountArgs *[]interface{}, loginId int64,
-	nestingLevel int) (string, string, error) {
+	system bool, nestingLevel int) (string, string, error) {
 
 	// check for authorized access, READ(1) for GET
 	for _, expr := range data.Expressions {
-		if expr.AttributeId.Valid &&
+		if expr.AttributeId.Valid && !system &&
 			!authorizedAttribute(loginId, expr.AttributeId.Bytes, 1) {
 
 			return "", "", errors.New(handler.ErrUnauthorized)
@@ -312,7 +355,7 @@ func prepareQuery(data types.DataGet, indexRelationIds map[int]uuid.UUID,
 			continue
 		}
 
-		if err := addJoin(indexRelationIds, join, &inJoin, loginId, nestingLevel); err != nil {
+		if err := addJoin(indexRelationIds, join, &inJoin, loginId, system, nestingLevel); err != nil {
 			return "", "", err
 		}
 	}
@@ -334,21 +377,23 @@ func prepareQuery(data types.DataGet, indexRelationIds map[int]uuid.UUID,
 		}
 
 		if err := addWhere(filter, queryArgs, queryCountArgs,
-			loginId, &inWhere, nestingLevel); err != nil {
+			loginId, system, &inWhere, nestingLevel); err != nil {
 
 			return "", "", err
 		}
 	}
 
 	// add filter for base relation policy if applicable
-	policyFilter, err := getPolicyFilter(loginId, "select",
-		getRelationCode(data.IndexSource, nestingLevel), rel.Policies)
+	if !system {
+		policyFilter, err := getPolicyFilter(loginId, "select",
+			getRelationCode(data.IndexSource, nestingLevel), rel.Policies)
 
-	if err != nil {
-		return "", "", err
-	}
-	if policyFilter != "" {
-		inWhere = append(inWhere, policyFilter)
+		if err != nil {
+			return "", "", err
+		}
+		if policyFilter != "" {
+			inWhere = append(inWhere, policyFilter)
+		}
 	}
 
 	// add filters to query, replacing first AND with WHERE
@@ -379,7 +424,7 @@ func prepareQuery(data types.DataGet, indexRelationIds map[int]uuid.UUID,
 			indexRelationIdsSub := make(map[int]uuid.UUID)
 
 			subQuery, _, err := prepareQuery(expr.Query, indexRelationIdsSub,
-				queryArgs, queryCountArgsOptional, loginId, nestingLevel+1)
+				queryArgs, queryCountArgsOptional, loginId, system, nestingLevel+1)
 
 			if err != nil {
 				return "", "", err
@@ -594,7 +639,7 @@ func addSelect(exprPos int, expr types.DataGetExpression,
 }
 
 func addJoin(indexRelationIds map[int]uuid.UUID, join types.DataGetJoin,
-	inJoin *[]string, loginId int64, nestingLevel int) error {
+	inJoin *[]string, loginId int64, system bool, nestingLevel int) error {
 
 	// check join attribute
 	atr, exists := cache.AttributeIdMap[join.AttributeId]
@@ -640,11 +685,15 @@ func addJoin(indexRelationIds map[int]uuid.UUID, join types.DataGetJoin,
 	}
 
 	// apply filter policy to JOIN if applicable
-	policyFilter, err := getPolicyFilter(loginId, "select",
-		getRelationCode(join.Index, nestingLevel), relTarget.Policies)
+	policyFilter := ""
+	if !system {
+		var err error
+		policyFilter, err = getPolicyFilter(loginId, "select",
+			getRelationCode(join.Index, nestingLevel), relTarget.Policies)
 
-	if err != nil {
-		return err
+		if err != nil {
+			return err
+		}
 	}
 
 	*inJoin = append(*inJoin, fmt.Sprintf("\n"+`%s JOIN "%s"."%s" AS "%s" ON "%s"."%s" = "%s"."%s" %s`,
@@ -658,7 +707,7 @@ func addJoin(indexRelationIds map[int]uuid.UUID, join types.DataGetJoin,
 
 // parses filters to generate query lines and arguments
 func addWhere(filter types.DataGetFilter, queryArgs *[]interface{},
-	queryCountArgs *[]interface{}, loginId int64, inWhere *[]string,
+	queryCountArgs *[]interface{}, loginId int64, system bool, inWhere *[]string,
 	nestingLevel int) error {
 
 	if !slices.Contains(types.QueryFilterConnectors, filter.Connector) {
@@ -681,7 +730,7 @@ func addWhere(filter types.DataGetFilter, queryArgs *[]interface{},
 			indexRelationIdsSub := make(map[int]uuid.UUID)
 
 			subQuery, _, err := prepareQuery(s.Query, indexRelationIdsSub,
-				queryArgs, queryCountArgs, loginId, nestingLevel+1)
+				queryArgs, queryCountArgs, loginId, system, nestingLevel+1)
 
 			if err != nil {
 				return err
@@ -872,6 +921,50 @@ func addOrderBy(data types.DataGet, nestingLevel int) (string, error) {
 
 // helpers
 
+// returns data GET result from current row of data GET SQL query
+// expression values come first, relation record IDs follow (columns named like '_r3id')
+func getResultFromRow(rows pgx.Rows, columns []pgconn.FieldDescription, expressionCount int) (types.DataGetResult, error) {
+
+	valuesAll, err := rows.Values()
+	if err != nil {
+		return types.DataGetResult{}, err
+	}
+
+	indexRecordIds := make(map[int]interface{}) // ID for each relation tupel by index
+	indexRecordEncKeys := make(map[int]string)  // encrypted key for each relation tupel by index
+	values := make([]interface{}, 0)            // final values for selected attributes
+
+	// collect values for expressions
+	for i := 0; i < expressionCount; i++ {
+		values = append(values, valuesAll[i])
+	}
+
+	// collect relation tupel IDs
+	// relation ID columns start after expressions
+	for i, j := expressionCount, len(columns); i < j; i++ {
+
+		matches := regexRelId.FindStringSubmatch(string(columns[i].Name))
+
+		if len(matches) == 2 {
+
+			// column provides relation ID
+			relIndex, err := strconv.Atoi(matches[1])
+			if err != nil {
+				return types.DataGetResult{}, err
+			}
+			indexRecordIds[relIndex] = valuesAll[i]
+		}
+	}
+
+	return types.DataGetResult{
+		IndexRecordIds:     indexRecordIds,
+		IndexRecordEncKeys: indexRecordEncKeys,
+		IndexesPermNoDel:   make([]int, 0),
+		IndexesPermNoSet:   make([]int, 0),
+		Values:             values,
+	}, nil
+}
+
 // relation codes exist to uniquely reference a joined relation, even if the same relation is joined multiple times
 // example: relation 'person' can be joined twice as '_r0' and '_r1' as 'person' can be joined to itself as 'supervisor to'
 // a relation is referenced by '_r' + an integer (relation join index) + optionally '_l' + an integer for nesting (if sub query)
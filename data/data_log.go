@@ -152,7 +152,7 @@ func getLogValues_tx(ctx context.Context, tx pgx.Tx, logId uuid.UUID,
 func setLog_tx(ctx context.Context, tx pgx.Tx, relationId uuid.UUID,
 	attributes []types.DataSetAttribute, fileAttributeIndexes []int,
 	wasCreated bool, valuesOld []interface{}, recordId int64,
-	loginId int64) error {
+	loginId pgtype.Int8) error {
 
 	// new record, apply logs for record and its attribute values
 	if wasCreated {
@@ -242,7 +242,7 @@ func setLog_tx(ctx context.Context, tx pgx.Tx, relationId uuid.UUID,
 	return nil
 }
 func setLogRecord_tx(ctx context.Context, tx pgx.Tx, relationId uuid.UUID,
-	loginId int64, recordId int64) (uuid.UUID, error) {
+	loginId pgtype.Int8, recordId int64) (uuid.UUID, error) {
 
 	logId, err := uuid.NewV4()
 	if err != nil {
@@ -28,13 +28,26 @@ import (
 func Set_tx(ctx context.Context, tx pgx.Tx, dataSetsByIndex map[int]types.DataSet,
 	loginId int64) (map[int]int64, error) {
 
+	return set_tx(ctx, tx, dataSetsByIndex, loginId, false)
+}
+
+// sets data as system (e. g. for directory imports or mail rules)
+// system access is not restricted by roles or policies, data changes are logged without login
+func SetSystem_tx(ctx context.Context, tx pgx.Tx, dataSetsByIndex map[int]types.DataSet) (map[int]int64, error) {
+	return set_tx(ctx, tx, dataSetsByIndex, 0, true)
+}
+
+func set_tx(ctx context.Context, tx pgx.Tx, dataSetsByIndex map[int]types.DataSet,
+	loginId int64, system bool) (map[int]int64, error) {
+
 	cache.Schema_mx.RLock()
 	defer cache.Schema_mx.RUnlock()
 
 	var err error
-	var indexes = make([]int, 0)                 // all relation indexes
-	var indexRecordIds = make(map[int]int64)     // record IDs by index
-	var indexRecordsCreated = make(map[int]bool) // created record IDs by index
+	var indexes = make([]int, 0)                   // all relation indexes
+	var indexRecordIds = make(map[int]int64)       // record IDs by index
+	var indexRecordsCreated = make(map[int]bool)   // created record IDs by index
+	var indexRecordsUnchanged = make(map[int]bool) // record IDs by index, which were not updated (policy filter)
 
 	// sort relation indexes, starting with source relation (index:0)
 	for index, _ := range dataSetsByIndex {
@@ -55,14 +68,14 @@ func Set_tx(ctx context.Context, tx pgx.Tx, dataSetsByIndex map[int]types.DataSe
 		}
 
 		// check write access for tupel creation
-		if isNewRecord && !authorizedRelation(loginId, dataSet.RelationId, 2) {
+		if isNewRecord && !system && !authorizedRelation(loginId, dataSet.RelationId, 2) {
 			return indexRecordIds, errors.New(handler.ErrUnauthorized)
 		}
 
 		// check write access for updating attribute values
 		for _, attribute := range dataSet.Attributes {
 
-			if !authorizedAttribute(loginId, attribute.AttributeId, 2) {
+			if !system && !authorizedAttribute(loginId, attribute.AttributeId, 2) {
 				return indexRecordIds, errors.New(handler.ErrUnauthorized)
 			}
 
@@ -113,7 +126,7 @@ func Set_tx(ctx context.Context, tx pgx.Tx, dataSetsByIndex map[int]types.DataSe
 		if useLog && !isNewRecord {
 			logRecordOld, err = collectCurrentValuesForLog_tx(ctx, tx,
 				dataSet.RelationId, dataSet.Attributes, fileAttributeIndexes,
-				dataSet.RecordId, loginId)
+				dataSet.RecordId, loginId, system)
 
 			if err != nil {
 				return indexRecordIds, err
@@ -121,8 +134,8 @@ func Set_tx(ctx context.Context, tx pgx.Tx, dataSetsByIndex map[int]types.DataSe
 		}
 
 		// set data for index
-		if err := setForIndex_tx(ctx, tx, index, dataSetsByIndex,
-			indexRecordIds, indexRecordsCreated, loginId); err != nil {
+		if err := setForIndex_tx(ctx, tx, index, dataSetsByIndex, indexRecordIds,
+			indexRecordsCreated, indexRecordsUnchanged, loginId, system); err != nil {
 
 			return indexRecordIds, err
 		}
@@ -140,11 +153,34 @@ func Set_tx(ctx context.Context, tx pgx.Tx, dataSetsByIndex map[int]types.DataSe
 		if useLog {
 			if err := setLog_tx(ctx, tx, dataSet.RelationId, dataSet.Attributes,
 				fileAttributeIndexes, isNewRecord, logRecordOld.Values,
-				indexRecordIds[index], loginId); err != nil {
+				indexRecordIds[index], pgtype.Int8{Int64: loginId, Valid: !system}); err != nil {
 
 				return indexRecordIds, fmt.Errorf("failed to set data log, %v", err)
 			}
 		}
+
+		// add webhook deliveries, not if record was not updated because of policy filter
+		event := "update"
+		if isNewRecord {
+			event = "insert"
+		}
+		if indexRecordsUnchanged[index] {
+			continue
+		}
+
+		// on update, only changed values are delivered if old values are known from data log
+		webhookAttributes := dataSet.Attributes
+		if useLog && !isNewRecord {
+			webhookAttributes, err = getAttributesChanged(dataSet.Attributes, logRecordOld.Values)
+			if err != nil {
+				return indexRecordIds, err
+			}
+		}
+		if err := setWebhookDeliveries_tx(ctx, tx, dataSet.RelationId, event,
+			indexRecordIds[index], webhookAttributes); err != nil {
+
+			return indexRecordIds, fmt.Errorf("failed to set webhook deliveries, %v", err)
+		}
 	}
 	return indexRecordIds, nil
 }
@@ -153,7 +189,8 @@ func Set_tx(ctx context.Context, tx pgx.Tx, dataSetsByIndex map[int]types.DataSe
 // recursive call, if relationship tupel must be created first
 func setForIndex_tx(ctx context.Context, tx pgx.Tx, index int,
 	dataSetsByIndex map[int]types.DataSet, indexRecordIds map[int]int64,
-	indexRecordsCreated map[int]bool, loginId int64) error {
+	indexRecordsCreated map[int]bool, indexRecordsUnchanged map[int]bool, loginId int64,
+	system bool) error {
 
 	if _, exists := indexRecordsCreated[index]; exists {
 		return nil
@@ -243,22 +280,30 @@ func setForIndex_tx(ctx context.Context, tx pgx.Tx, index int,
 
 		// get policy filter if applicable
 		tableAlias := "t"
-		policyFilter, err := getPolicyFilter(loginId, "update", tableAlias, rel.Policies)
-		if err != nil {
-			return err
+		policyFilter := ""
+		if !system {
+			var err error
+			policyFilter, err = getPolicyFilter(loginId, "update", tableAlias, rel.Policies)
+			if err != nil {
+				return err
+			}
 		}
 
 		values = append(values, dataSet.RecordId)
-		if _, err := tx.Exec(ctx, fmt.Sprintf(`
+		tag, err := tx.Exec(ctx, fmt.Sprintf(`
 			UPDATE "%s"."%s" AS "%s" SET %s
 			WHERE "%s"."%s" = %s
 			%s
 		`, mod.Name, rel.Name, tableAlias, strings.Join(params, `, `), tableAlias,
 			schema.PkName, fmt.Sprintf("$%d", len(values)), policyFilter),
-			values...); err != nil {
+			values...)
 
+		if err != nil {
 			return err
 		}
+		if tag.RowsAffected() == 0 {
+			indexRecordsUnchanged[index] = true
+		}
 	} else if isNewRecord {
 		// insert new record
 		// first check whether this relation is part of any joined relationship
@@ -283,8 +328,8 @@ func setForIndex_tx(ctx context.Context, tx pgx.Tx, index int,
 				if relAtrOther.RelationId == dataSet.RelationId {
 
 					// the other relation has a higher index, so its tupel might not exist yet
-					if err := setForIndex_tx(ctx, tx, indexOther, dataSetsByIndex,
-						indexRecordIds, indexRecordsCreated, loginId); err != nil {
+					if err := setForIndex_tx(ctx, tx, indexOther, dataSetsByIndex, indexRecordIds,
+						indexRecordsCreated, indexRecordsUnchanged, loginId, system); err != nil {
 
 						return err
 					}
@@ -496,7 +541,7 @@ func setForIndex_tx(ctx context.Context, tx pgx.Tx, index int,
 
 func collectCurrentValuesForLog_tx(ctx context.Context, tx pgx.Tx,
 	relationId uuid.UUID, attributes []types.DataSetAttribute,
-	fileAttributeIndexes []int, recordId int64, loginId int64) (types.DataGetResult, error) {
+	fileAttributeIndexes []int, recordId int64, loginId int64, system bool) (types.DataGetResult, error) {
 
 	var result types.DataGetResult
 	rel, exists := cache.RelationIdMap[relationId]
@@ -545,7 +590,7 @@ func collectCurrentValuesForLog_tx(ctx context.Context, tx pgx.Tx,
 	// use transaction to get data - otherwise larger tasks (like CSV import)
 	//  will fail as created records cannot be retrieved
 	var query string
-	results, _, err := Get_tx(ctx, tx, dataGet, loginId, &query)
+	results, _, err := get_tx(ctx, tx, dataGet, loginId, system, &query)
 	if err != nil {
 		return result, err
 	}
@@ -0,0 +1,149 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"r3/cache"
+	"r3/handler"
+	"r3/schema"
+	"r3/tools"
+	"r3/types"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type webhookPayload struct {
+	Webhook    string                 `json:"webhook"`    // webhook name
+	Event      string                 `json:"event"`      // insert, update, delete
+	DeliveryId int64                  `json:"deliveryId"` // unique ID of delivery, can be used to recognize repeated deliveries
+	Relation   string                 `json:"relation"`   // module + relation name, such as 'lsw_invoices.contract'
+	RelationId uuid.UUID              `json:"relationId"`
+	RecordId   int64                  `json:"recordId"`
+	Date       int64                  `json:"date"`   // date of change (unix)
+	Values     map[string]interface{} `json:"values"` // changed attribute values, by attribute name (empty for deletion)
+}
+
+// returns attributes with values different from given old values (same order as attributes)
+func getAttributesChanged(attributes []types.DataSetAttribute,
+	valuesOld []interface{}) ([]types.DataSetAttribute, error) {
+
+	changed := make([]types.DataSetAttribute, 0)
+	for i, a := range attributes {
+		if i >= len(valuesOld) {
+			changed = append(changed, a)
+			continue
+		}
+		jsonOld, err := json.Marshal(valuesOld[i])
+		if err != nil {
+			return changed, err
+		}
+		jsonNew, err := json.Marshal(a.Value)
+		if err != nil {
+			return changed, err
+		}
+		if string(jsonOld) != string(jsonNew) {
+			changed = append(changed, a)
+		}
+	}
+	return changed, nil
+}
+
+// adds deliveries for active webhooks of relation, matching the record change event, to the REST spooler
+// only regular attribute values of the relation are included; encrypted and files attributes are skipped
+func setWebhookDeliveries_tx(ctx context.Context, tx pgx.Tx, relationId uuid.UUID,
+	event string, recordId int64, attributes []types.DataSetAttribute) error {
+
+	webhooks := cache.GetWebhooksByRelationId(relationId)
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	rel, exists := cache.RelationIdMap[relationId]
+	if !exists {
+		return handler.ErrSchemaUnknownRelation(relationId)
+	}
+	mod, exists := cache.ModuleIdMap[rel.ModuleId]
+	if !exists {
+		return handler.ErrSchemaUnknownModule(rel.ModuleId)
+	}
+
+	values := make(map[string]interface{})
+	for _, a := range attributes {
+		if a.OutsideIn || a.AttributeIdNm.Valid {
+			continue
+		}
+		atr, exists := cache.AttributeIdMap[a.AttributeId]
+		if !exists {
+			return handler.ErrSchemaUnknownAttribute(a.AttributeId)
+		}
+		if atr.Encrypted || schema.IsContentFiles(atr.Content) {
+			continue
+		}
+		values[atr.Name] = a.Value
+	}
+
+	// updates without any deliverable values are skipped
+	if event == "update" && len(values) == 0 {
+		return nil
+	}
+
+	for _, w := range webhooks {
+		switch event {
+		case "delete":
+			if !w.OnDelete {
+				continue
+			}
+		case "insert":
+			if !w.OnInsert {
+				continue
+			}
+		case "update":
+			if !w.OnUpdate {
+				continue
+			}
+		default:
+			return fmt.Errorf("unknown webhook event '%s'", event)
+		}
+
+		payload := webhookPayload{
+			Webhook:    w.Name,
+			Event:      event,
+			Relation:   fmt.Sprintf("%s.%s", mod.Name, rel.Name),
+			RelationId: relationId,
+			RecordId:   recordId,
+			Date:       tools.GetTimeUnix(),
+			Values:     values,
+		}
+
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO instance.webhook_delivery (webhook_id, event, record_id, date_added)
+			VALUES ($1,$2,$3,$4)
+			RETURNING id
+		`, w.Id, event, recordId, payload.Date).Scan(&payload.DeliveryId); err != nil {
+			return err
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+
+		// timestamp & signature headers are added by REST spooler on each attempt
+		headers := map[string]string{
+			"Content-Type":       "application/json",
+			"X-Webhook-Delivery": fmt.Sprintf("%d", payload.DeliveryId),
+			"X-Webhook-Event":    event,
+		}
+
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO instance.rest_spool (webhook_delivery_id, method, headers,
+				url, body, skip_verify, date_added)
+			VALUES ($1,'POST',$2,$3,$4,$5,$6)
+		`, payload.DeliveryId, headers, w.Url, string(body), w.SkipVerify, payload.Date); err != nil {
+			return err
+		}
+	}
+	return nil
+}
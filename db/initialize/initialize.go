@@ -146,6 +146,7 @@ func initInstanceValues_tx(tx pgx.Tx) error {
 			('companyWelcome',''),
 			('dbTimeoutCsv','120'),
 			('dbTimeoutDataRest','60'),
+			('dbTimeoutDataStream','3600'),
 			('dbTimeoutDataWs','300'),
 			('dbTimeoutIcs','30'),
 			('dbVersionCut','3.0'),
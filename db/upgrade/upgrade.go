@@ -102,6 +102,945 @@ var upgradeFunctions = map[string]func(tx pgx.Tx) (string, error){
 			TYPE app.column_style[] USING styles::CHARACTER VARYING(12)[]::app.column_style[];
 	*/
 
+	"3.8": func(tx pgx.Tx) (string, error) {
+		_, err := tx.Exec(db.Ctx, `
+			-- idempotency keys for REST API write requests
+			CREATE TABLE IF NOT EXISTS instance.api_idempotency (
+				login_id integer NOT NULL,
+				key character varying(255) COLLATE pg_catalog."default" NOT NULL,
+				request_hash character(64) COLLATE pg_catalog."default" NOT NULL,
+				response_code smallint,
+				response_body text COLLATE pg_catalog."default",
+				date_created bigint NOT NULL,
+				CONSTRAINT api_idempotency_pkey PRIMARY KEY (login_id, key),
+				CONSTRAINT api_idempotency_login_id_fkey FOREIGN KEY (login_id)
+					REFERENCES instance.login (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS ind_api_idempotency_date_created
+				ON instance.api_idempotency USING btree (date_created ASC NULLS LAST);
+
+			INSERT INTO instance.config (name,value) VALUES ('apiIdempotencyHours','24');
+			INSERT INTO instance.config (name,value) VALUES ('dbTimeoutDataStream','3600');
+
+			INSERT INTO instance.task (
+				name,interval_seconds,cluster_master_only,
+				embedded_only,active_only,active
+			) VALUES ('cleanupApiIdempotency',3600,true,false,false,true);
+
+			INSERT INTO instance.schedule (task_name,date_attempt,date_success)
+			VALUES ('cleanupApiIdempotency',0,0);
+
+			-- webhooks on record changes
+			CREATE TYPE instance.webhook_event AS ENUM ('delete','insert','update');
+
+			CREATE TABLE IF NOT EXISTS instance.webhook (
+				id SERIAL NOT NULL,
+				relation_id uuid NOT NULL,
+				name character varying(64) COLLATE pg_catalog."default" NOT NULL,
+				url text COLLATE pg_catalog."default" NOT NULL,
+				secret text COLLATE pg_catalog."default" NOT NULL,
+				on_insert boolean NOT NULL,
+				on_update boolean NOT NULL,
+				on_delete boolean NOT NULL,
+				skip_verify boolean NOT NULL,
+				active boolean NOT NULL,
+				CONSTRAINT webhook_pkey PRIMARY KEY (id),
+				CONSTRAINT webhook_relation_id_fkey FOREIGN KEY (relation_id)
+					REFERENCES app.relation (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_webhook_relation_id_fkey
+				ON instance.webhook USING btree (relation_id ASC NULLS LAST);
+
+			CREATE TABLE IF NOT EXISTS instance.webhook_delivery (
+				id BIGSERIAL NOT NULL,
+				webhook_id integer NOT NULL,
+				event instance.webhook_event NOT NULL,
+				record_id bigint NOT NULL,
+				date_added bigint NOT NULL,
+				date_done bigint,
+				attempt_count integer NOT NULL DEFAULT 0,
+				response_code integer,
+				error text COLLATE pg_catalog."default",
+				CONSTRAINT webhook_delivery_pkey PRIMARY KEY (id),
+				CONSTRAINT webhook_delivery_webhook_id_fkey FOREIGN KEY (webhook_id)
+					REFERENCES instance.webhook (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_webhook_delivery_webhook_id_fkey
+				ON instance.webhook_delivery USING btree (webhook_id ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS ind_webhook_delivery_date_added
+				ON instance.webhook_delivery USING btree (date_added DESC NULLS LAST);
+
+			ALTER TABLE instance.rest_spool ADD COLUMN webhook_delivery_id bigint;
+			ALTER TABLE instance.rest_spool ADD CONSTRAINT rest_spool_webhook_delivery_id_fkey
+				FOREIGN KEY (webhook_delivery_id)
+				REFERENCES instance.webhook_delivery (id) MATCH SIMPLE
+				ON UPDATE CASCADE
+				ON DELETE CASCADE
+				DEFERRABLE INITIALLY DEFERRED;
+			CREATE INDEX IF NOT EXISTS fki_rest_spool_webhook_delivery_id_fkey
+				ON instance.rest_spool USING btree (webhook_delivery_id ASC NULLS LAST);
+
+			INSERT INTO instance.config (name,value) VALUES ('webhookDeliveriesKeepDays','30');
+
+			INSERT INTO instance.task (
+				name,interval_seconds,cluster_master_only,
+				embedded_only,active_only,active
+			) VALUES ('cleanupWebhookDeliveries',86400,true,false,false,true);
+
+			INSERT INTO instance.schedule (task_name,date_attempt,date_success)
+			VALUES ('cleanupWebhookDeliveries',0,0);
+
+			-- REST spooler backoff, success codes, timeouts & dead letters
+			ALTER TABLE instance.rest_spool ADD COLUMN date_attempt bigint NOT NULL DEFAULT 0;
+			ALTER TABLE instance.rest_spool ADD COLUMN next_attempt bigint NOT NULL DEFAULT 0;
+			ALTER TABLE instance.rest_spool ADD COLUMN dead_letter boolean NOT NULL DEFAULT false;
+			ALTER TABLE instance.rest_spool ADD COLUMN timeout_seconds integer;
+			ALTER TABLE instance.rest_spool ADD COLUMN success_codes text COLLATE pg_catalog."default";
+			ALTER TABLE instance.rest_spool ADD COLUMN response_code integer;
+			ALTER TABLE instance.rest_spool ADD COLUMN response_body text COLLATE pg_catalog."default";
+			ALTER TABLE instance.rest_spool ADD COLUMN error text COLLATE pg_catalog."default";
+			ALTER TABLE instance.rest_spool ADD COLUMN request_done boolean NOT NULL DEFAULT false;
+
+			CREATE INDEX IF NOT EXISTS ind_rest_spool_next_attempt
+				ON instance.rest_spool USING btree (next_attempt ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS ind_rest_spool_dead_letter
+				ON instance.rest_spool USING btree (dead_letter ASC NULLS LAST);
+
+			-- REST spooler workers, FIFO groups & cluster-wide claims
+			ALTER TABLE instance.rest_spool ADD COLUMN position bigserial NOT NULL;
+			ALTER TABLE instance.rest_spool ADD COLUMN group_key text COLLATE pg_catalog."default";
+			ALTER TABLE instance.rest_spool ADD COLUMN claim_node_id uuid;
+			ALTER TABLE instance.rest_spool ADD COLUMN claim_until bigint NOT NULL DEFAULT 0;
+
+			CREATE INDEX IF NOT EXISTS ind_rest_spool_position
+				ON instance.rest_spool USING btree (position ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS ind_rest_spool_group_key
+				ON instance.rest_spool USING btree (group_key ASC NULLS LAST);
+
+			-- spooled calls are claimed by nodes, every node can execute them
+			UPDATE instance.task SET cluster_master_only = false WHERE name = 'restExecute';
+
+			-- calls that reached the attempt limit before are kept as dead letters
+			UPDATE instance.rest_spool SET dead_letter = true WHERE attempt_count >= 5;
+
+			DROP FUNCTION instance.rest_call(TEXT,TEXT,TEXT,JSONB,BOOLEAN,UUID,TEXT);
+			CREATE OR REPLACE FUNCTION instance.rest_call(http_method TEXT, url TEXT, body TEXT, headers JSONB DEFAULT NULL, tls_skip_verify BOOLEAN DEFAULT FALSE, callback_function_id UUID DEFAULT NULL, callback_value TEXT DEFAULT NULL, timeout_seconds INTEGER DEFAULT NULL, success_codes TEXT DEFAULT NULL, group_key TEXT DEFAULT NULL)
+				RETURNS integer
+				LANGUAGE 'plpgsql'
+				COST 100
+				VOLATILE PARALLEL UNSAFE
+			AS $BODY$
+				DECLARE
+				BEGIN
+					INSERT INTO instance.rest_spool(pg_function_id_callback, method, headers, url, body, date_added, skip_verify, callback_value, timeout_seconds, success_codes, group_key)
+					VALUES (callback_function_id, http_method::instance.rest_method, headers, url, body, EXTRACT(EPOCH FROM NOW()), tls_skip_verify, callback_value, timeout_seconds, success_codes, group_key);
+					
+					RETURN 0;
+				END;
+			$BODY$;
+
+			INSERT INTO instance.config (name,value) VALUES ('restAttempts','5');
+			INSERT INTO instance.config (name,value) VALUES ('restBackoffSeconds','60');
+			INSERT INTO instance.config (name,value) VALUES ('restHostConcurrency','2');
+			INSERT INTO instance.config (name,value) VALUES ('restHostRateLimit','0');
+			INSERT INTO instance.config (name,value) VALUES ('restSuccessCodes','200-299');
+			INSERT INTO instance.config (name,value) VALUES ('restTimeoutSeconds','30');
+			INSERT INTO instance.config (name,value) VALUES ('restWorkers','10');
+
+			-- Argon2id password hashes, legacy hashes are replaced on next login
+			ALTER TABLE instance.login ALTER COLUMN hash TYPE TEXT;
+
+			INSERT INTO instance.config (name,value) VALUES ('pwArgon2Iterations','3');
+			INSERT INTO instance.config (name,value) VALUES ('pwArgon2Memory','65536');
+			INSERT INTO instance.config (name,value) VALUES ('pwArgon2Threads','2');
+
+			-- OpenID Connect logins
+			CREATE TABLE IF NOT EXISTS instance.oidc (
+				id SERIAL NOT NULL,
+				login_template_id integer,
+				name CHARACTER VARYING(64) NOT NULL,
+				issuer_url TEXT NOT NULL,
+				client_id TEXT NOT NULL,
+				client_secret TEXT NOT NULL,
+				scopes TEXT[] NOT NULL,
+				claim_username TEXT NOT NULL,
+				claim_roles TEXT NOT NULL,
+				assign_roles BOOLEAN NOT NULL,
+				active BOOLEAN NOT NULL,
+				CONSTRAINT oidc_pkey PRIMARY KEY (id),
+				CONSTRAINT oidc_name_key UNIQUE (name),
+				CONSTRAINT oidc_login_template_id_fkey FOREIGN KEY (login_template_id)
+					REFERENCES instance.login_template (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_oidc_login_template_id_fkey
+				ON instance.oidc USING btree (login_template_id ASC NULLS LAST);
+
+			CREATE TABLE IF NOT EXISTS instance.oidc_role (
+				oidc_id integer NOT NULL,
+				role_id uuid NOT NULL,
+				claim_value TEXT NOT NULL,
+				CONSTRAINT oidc_role_oidc_id_fkey FOREIGN KEY (oidc_id)
+					REFERENCES instance.oidc (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT oidc_role_role_id_fkey FOREIGN KEY (role_id)
+					REFERENCES app.role (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_oidc_role_oidc_id_fkey
+				ON instance.oidc_role USING btree (oidc_id ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS fki_oidc_role_role_id_fkey
+				ON instance.oidc_role USING btree (role_id ASC NULLS LAST);
+
+			ALTER TABLE instance.login ADD COLUMN oidc_id integer;
+			ALTER TABLE instance.login ADD COLUMN oidc_key TEXT;
+			ALTER TABLE instance.login ADD CONSTRAINT login_oidc_id_fkey
+				FOREIGN KEY (oidc_id)
+				REFERENCES instance.oidc (id) MATCH SIMPLE
+				ON UPDATE CASCADE
+				ON DELETE CASCADE
+				DEFERRABLE INITIALLY DEFERRED;
+			CREATE INDEX IF NOT EXISTS fki_login_oidc_id_fkey
+				ON instance.login USING btree (oidc_id ASC NULLS LAST);
+
+			-- pending authorization requests, started by login and completed by callback
+			CREATE TABLE IF NOT EXISTS instance.oidc_state (
+				state TEXT NOT NULL,
+				oidc_id integer NOT NULL,
+				code_verifier TEXT NOT NULL,
+				nonce TEXT NOT NULL,
+				redirect_uri TEXT NOT NULL,
+				date_expiry bigint NOT NULL,
+				CONSTRAINT oidc_state_pkey PRIMARY KEY (state),
+				CONSTRAINT oidc_state_oidc_id_fkey FOREIGN KEY (oidc_id)
+					REFERENCES instance.oidc (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+
+			-- one-time codes, handing over successful authentication to the client
+			CREATE TABLE IF NOT EXISTS instance.oidc_code (
+				code TEXT NOT NULL,
+				login_id integer NOT NULL,
+				date_expiry bigint NOT NULL,
+				CONSTRAINT oidc_code_pkey PRIMARY KEY (code),
+				CONSTRAINT oidc_code_login_id_fkey FOREIGN KEY (login_id)
+					REFERENCES instance.login (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+
+			-- SAML 2.0 logins
+			CREATE TABLE IF NOT EXISTS instance.saml (
+				id SERIAL NOT NULL,
+				login_template_id integer,
+				name CHARACTER VARYING(64) NOT NULL,
+				idp_entity_id TEXT NOT NULL,
+				idp_sso_url TEXT NOT NULL,
+				idp_certificate TEXT NOT NULL,
+				sp_certificate TEXT NOT NULL,
+				sp_private_key TEXT NOT NULL,
+				attribute_username TEXT NOT NULL,
+				attribute_groups TEXT NOT NULL,
+				assign_roles BOOLEAN NOT NULL,
+				active BOOLEAN NOT NULL,
+				CONSTRAINT saml_pkey PRIMARY KEY (id),
+				CONSTRAINT saml_name_key UNIQUE (name),
+				CONSTRAINT saml_login_template_id_fkey FOREIGN KEY (login_template_id)
+					REFERENCES instance.login_template (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_saml_login_template_id_fkey
+				ON instance.saml USING btree (login_template_id ASC NULLS LAST);
+
+			CREATE TABLE IF NOT EXISTS instance.saml_role (
+				saml_id integer NOT NULL,
+				role_id uuid NOT NULL,
+				group_name TEXT NOT NULL,
+				CONSTRAINT saml_role_saml_id_fkey FOREIGN KEY (saml_id)
+					REFERENCES instance.saml (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT saml_role_role_id_fkey FOREIGN KEY (role_id)
+					REFERENCES app.role (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_saml_role_saml_id_fkey
+				ON instance.saml_role USING btree (saml_id ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS fki_saml_role_role_id_fkey
+				ON instance.saml_role USING btree (role_id ASC NULLS LAST);
+
+			ALTER TABLE instance.login ADD COLUMN saml_id integer;
+			ALTER TABLE instance.login ADD COLUMN saml_key TEXT;
+			ALTER TABLE instance.login ADD CONSTRAINT login_saml_id_fkey
+				FOREIGN KEY (saml_id)
+				REFERENCES instance.saml (id) MATCH SIMPLE
+				ON UPDATE CASCADE
+				ON DELETE CASCADE
+				DEFERRABLE INITIALLY DEFERRED;
+			CREATE INDEX IF NOT EXISTS fki_login_saml_id_fkey
+				ON instance.login USING btree (saml_id ASC NULLS LAST);
+
+			-- issued authentication requests, responses must refer to one (single use)
+			CREATE TABLE IF NOT EXISTS instance.saml_request (
+				id TEXT NOT NULL,
+				saml_id integer NOT NULL,
+				date_expiry bigint NOT NULL,
+				CONSTRAINT saml_request_pkey PRIMARY KEY (id),
+				CONSTRAINT saml_request_saml_id_fkey FOREIGN KEY (saml_id)
+					REFERENCES instance.saml (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+
+			-- one-time codes, handing over successful authentication to the client
+			CREATE TABLE IF NOT EXISTS instance.saml_code (
+				code TEXT NOT NULL,
+				login_id integer NOT NULL,
+				date_expiry bigint NOT NULL,
+				CONSTRAINT saml_code_pkey PRIMARY KEY (code),
+				CONSTRAINT saml_code_login_id_fkey FOREIGN KEY (login_id)
+					REFERENCES instance.login (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+
+			-- WebAuthn credentials (security keys, passkeys)
+			CREATE TABLE IF NOT EXISTS instance.login_webauthn (
+				id SERIAL NOT NULL,
+				login_id integer NOT NULL,
+				name CHARACTER VARYING(64) NOT NULL,
+				credential_id bytea NOT NULL,
+				public_key bytea NOT NULL,
+				algorithm integer NOT NULL,
+				sign_count bigint NOT NULL,
+				date_create bigint NOT NULL,
+				date_used bigint,
+				CONSTRAINT login_webauthn_pkey PRIMARY KEY (id),
+				CONSTRAINT login_webauthn_credential_id_key UNIQUE (credential_id),
+				CONSTRAINT login_webauthn_login_id_fkey FOREIGN KEY (login_id)
+					REFERENCES instance.login (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_login_webauthn_login_id_fkey
+				ON instance.login_webauthn USING btree (login_id ASC NULLS LAST);
+			
+			-- random user handle for WebAuthn credentials, identifies login without revealing its ID
+			ALTER TABLE instance.login ADD COLUMN webauthn_handle TEXT;
+			ALTER TABLE instance.login ADD CONSTRAINT login_webauthn_handle_key UNIQUE (webauthn_handle);
+
+			-- open WebAuthn challenges, login is empty for passwordless login
+			CREATE TABLE IF NOT EXISTS instance.login_webauthn_challenge (
+				challenge TEXT NOT NULL,
+				login_id integer,
+				context CHARACTER VARYING(8) NOT NULL,
+				date_expiry bigint NOT NULL,
+				CONSTRAINT login_webauthn_challenge_pkey PRIMARY KEY (challenge),
+				CONSTRAINT login_webauthn_challenge_context_check CHECK (context IN ('login','mfa','register')),
+				CONSTRAINT login_webauthn_challenge_login_id_fkey FOREIGN KEY (login_id)
+					REFERENCES instance.login (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+
+			INSERT INTO instance.config (name,value) VALUES ('webauthnPasswordless','0');
+
+			-- self-service password reset
+			ALTER TABLE instance.login ADD COLUMN mail TEXT;
+
+			CREATE TABLE IF NOT EXISTS instance.login_password_reset (
+				id uuid NOT NULL,
+				login_id integer NOT NULL,
+				date_expiry bigint NOT NULL,
+				CONSTRAINT login_password_reset_pkey PRIMARY KEY (id),
+				CONSTRAINT login_password_reset_login_id_fkey FOREIGN KEY (login_id)
+					REFERENCES instance.login (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_login_password_reset_login_id_fkey
+				ON instance.login_password_reset USING btree (login_id ASC NULLS LAST);
+
+			INSERT INTO instance.config (name,value) VALUES ('pwResetEnable','0');
+			INSERT INTO instance.config (name,value) VALUES ('pwResetExpiryMinutes','60');
+
+			-- server-side login sessions, referenced by token ID (JWT ID)
+			CREATE TYPE instance.login_session_device AS ENUM ('api','browser','fatClient');
+			CREATE TABLE IF NOT EXISTS instance.login_session (
+				id uuid NOT NULL,
+				login_id integer NOT NULL,
+				address text NOT NULL,
+				device instance.login_session_device NOT NULL,
+				user_agent text NOT NULL,
+				date_create bigint NOT NULL,
+				date_expiry bigint NOT NULL,
+				date_last_seen bigint NOT NULL,
+				CONSTRAINT login_session_pkey PRIMARY KEY (id),
+				CONSTRAINT login_session_login_id_fkey FOREIGN KEY (login_id)
+					REFERENCES instance.login (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_login_session_login_id_fkey
+				ON instance.login_session USING btree (login_id ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS ind_login_session_date_expiry
+				ON instance.login_session USING btree (date_expiry ASC NULLS LAST);
+
+			ALTER TYPE instance_cluster.node_event_content ADD VALUE 'sessionRevoked';
+
+			-- personal access tokens for REST API, only token hashes are stored
+			CREATE TABLE IF NOT EXISTS instance.login_token_api (
+				id SERIAL NOT NULL,
+				login_id integer NOT NULL,
+				name CHARACTER VARYING(64) NOT NULL,
+				token_hash bytea NOT NULL,
+				api_ids uuid[] NOT NULL,
+				methods text[] NOT NULL,
+				addresses text[] NOT NULL,
+				date_create bigint NOT NULL,
+				date_expiry bigint NOT NULL,
+				date_used bigint,
+				address_used text,
+				CONSTRAINT login_token_api_pkey PRIMARY KEY (id),
+				CONSTRAINT login_token_api_token_hash_key UNIQUE (token_hash),
+				CONSTRAINT login_token_api_login_id_fkey FOREIGN KEY (login_id)
+					REFERENCES instance.login (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_login_token_api_login_id_fkey
+				ON instance.login_token_api USING btree (login_id ASC NULLS LAST);
+
+			-- login lockout after failed authentication attempts
+			ALTER TABLE instance.login ADD COLUMN auth_fail_count integer NOT NULL DEFAULT 0;
+			ALTER TABLE instance.login ADD COLUMN date_locked_until bigint;
+
+			-- password age & history
+			ALTER TABLE instance.login ADD COLUMN date_pw_change bigint;
+			ALTER TABLE instance.login ADD COLUMN pw_change_required boolean NOT NULL DEFAULT FALSE;
+
+			UPDATE instance.login
+			SET date_pw_change = EXTRACT(EPOCH FROM NOW())::bigint
+			WHERE hash IS NOT NULL;
+
+			CREATE TABLE IF NOT EXISTS instance.login_password_history (
+				id SERIAL NOT NULL,
+				login_id integer NOT NULL,
+				salt text,
+				hash text NOT NULL,
+				date_create bigint NOT NULL,
+				CONSTRAINT login_password_history_pkey PRIMARY KEY (id),
+				CONSTRAINT login_password_history_login_id_fkey FOREIGN KEY (login_id)
+					REFERENCES instance.login (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_login_password_history_login_id_fkey
+				ON instance.login_password_history USING btree (login_id ASC NULLS LAST);
+
+			INSERT INTO instance.config (name,value) VALUES ('loginLockAttempts','0');
+			INSERT INTO instance.config (name,value) VALUES ('loginLockMinutes','15');
+			INSERT INTO instance.config (name,value) VALUES ('pwHistoryCount','0');
+			INSERT INTO instance.config (name,value) VALUES ('pwMaxAgeDays','0');
+
+			-- LDAP attribute sync into module records & group based login templates
+			ALTER TABLE instance.ldap ADD COLUMN login_form_id uuid;
+			ALTER TABLE instance.ldap ADD CONSTRAINT ldap_login_form_id_fkey
+				FOREIGN KEY (login_form_id)
+				REFERENCES app.login_form (id) MATCH SIMPLE
+				ON UPDATE SET NULL
+				ON DELETE SET NULL
+				DEFERRABLE INITIALLY DEFERRED;
+			CREATE INDEX IF NOT EXISTS fki_ldap_login_form_id_fkey
+				ON instance.ldap USING btree (login_form_id ASC NULLS LAST);
+
+			CREATE TABLE IF NOT EXISTS instance.ldap_attribute (
+				ldap_id integer NOT NULL,
+				attribute_id uuid NOT NULL,
+				ldap_attribute text NOT NULL,
+				CONSTRAINT ldap_attribute_pkey PRIMARY KEY (ldap_id, attribute_id),
+				CONSTRAINT ldap_attribute_ldap_id_fkey FOREIGN KEY (ldap_id)
+					REFERENCES instance.ldap (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT ldap_attribute_attribute_id_fkey FOREIGN KEY (attribute_id)
+					REFERENCES app.attribute (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_ldap_attribute_attribute_id_fkey
+				ON instance.ldap_attribute USING btree (attribute_id ASC NULLS LAST);
+
+			CREATE TABLE IF NOT EXISTS instance.ldap_template (
+				ldap_id integer NOT NULL,
+				login_template_id integer NOT NULL,
+				group_dn text NOT NULL,
+				position smallint NOT NULL,
+				CONSTRAINT ldap_template_pkey PRIMARY KEY (ldap_id, position),
+				CONSTRAINT ldap_template_ldap_id_fkey FOREIGN KEY (ldap_id)
+					REFERENCES instance.ldap (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT ldap_template_login_template_id_fkey FOREIGN KEY (login_template_id)
+					REFERENCES instance.login_template (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_ldap_template_login_template_id_fkey
+				ON instance.ldap_template USING btree (login_template_id ASC NULLS LAST);
+
+			-- incremental LDAP import with periodic full reconciliation
+			ALTER TABLE instance.ldap ADD COLUMN sync_incremental boolean NOT NULL DEFAULT FALSE;
+			ALTER TABLE instance.ldap ALTER COLUMN sync_incremental DROP DEFAULT;
+			ALTER TABLE instance.ldap ADD COLUMN sync_full_days integer NOT NULL DEFAULT 1;
+			ALTER TABLE instance.ldap ALTER COLUMN sync_full_days DROP DEFAULT;
+			ALTER TABLE instance.ldap ADD COLUMN sync_mark text;
+			ALTER TABLE instance.ldap ADD COLUMN date_sync_full bigint;
+
+			-- data changes by the system itself (directory imports, mail rules) are logged without login
+			ALTER TABLE instance.data_log ALTER COLUMN login_id_wofk DROP NOT NULL;
+
+			-- SCIM 2.0 provisioning
+			CREATE TABLE IF NOT EXISTS instance.scim (
+				id SERIAL NOT NULL,
+				login_template_id integer,
+				name CHARACTER VARYING(64) NOT NULL,
+				token_hash bytea,
+				assign_roles BOOLEAN NOT NULL,
+				active BOOLEAN NOT NULL,
+				CONSTRAINT scim_pkey PRIMARY KEY (id),
+				CONSTRAINT scim_name_key UNIQUE (name),
+				CONSTRAINT scim_token_hash_key UNIQUE (token_hash),
+				CONSTRAINT scim_login_template_id_fkey FOREIGN KEY (login_template_id)
+					REFERENCES instance.login_template (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_scim_login_template_id_fkey
+				ON instance.scim USING btree (login_template_id ASC NULLS LAST);
+
+			CREATE TABLE IF NOT EXISTS instance.scim_role (
+				scim_id integer NOT NULL,
+				role_id uuid NOT NULL,
+				group_name TEXT NOT NULL,
+				CONSTRAINT scim_role_scim_id_fkey FOREIGN KEY (scim_id)
+					REFERENCES instance.scim (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT scim_role_role_id_fkey FOREIGN KEY (role_id)
+					REFERENCES app.role (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_scim_role_scim_id_fkey
+				ON instance.scim_role USING btree (scim_id ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS fki_scim_role_role_id_fkey
+				ON instance.scim_role USING btree (role_id ASC NULLS LAST);
+
+			ALTER TABLE instance.login ADD COLUMN scim_id integer;
+			ALTER TABLE instance.login ADD COLUMN scim_key TEXT;
+			ALTER TABLE instance.login ADD CONSTRAINT login_scim_id_fkey
+				FOREIGN KEY (scim_id)
+				REFERENCES instance.scim (id) MATCH SIMPLE
+				ON UPDATE CASCADE
+				ON DELETE CASCADE
+				DEFERRABLE INITIALLY DEFERRED;
+			CREATE INDEX IF NOT EXISTS fki_login_scim_id_fkey
+				ON instance.login USING btree (scim_id ASC NULLS LAST);
+
+			-- groups pushed by identity provider, mapped to roles by name
+			CREATE TABLE IF NOT EXISTS instance.scim_group (
+				id uuid NOT NULL DEFAULT gen_random_uuid(),
+				scim_id integer NOT NULL,
+				display_name TEXT NOT NULL,
+				external_id TEXT,
+				date_create bigint NOT NULL,
+				date_change bigint NOT NULL,
+				CONSTRAINT scim_group_pkey PRIMARY KEY (id),
+				CONSTRAINT scim_group_scim_id_fkey FOREIGN KEY (scim_id)
+					REFERENCES instance.scim (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_scim_group_scim_id_fkey
+				ON instance.scim_group USING btree (scim_id ASC NULLS LAST);
+
+			CREATE TABLE IF NOT EXISTS instance.scim_group_member (
+				scim_group_id uuid NOT NULL,
+				login_id integer NOT NULL,
+				CONSTRAINT scim_group_member_pkey PRIMARY KEY (scim_group_id, login_id),
+				CONSTRAINT scim_group_member_scim_group_id_fkey FOREIGN KEY (scim_group_id)
+					REFERENCES instance.scim_group (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT scim_group_member_login_id_fkey FOREIGN KEY (login_id)
+					REFERENCES instance.login (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_scim_group_member_login_id_fkey
+				ON instance.scim_group_member USING btree (login_id ASC NULLS LAST);
+
+			-- IMAP folders, post-processing of retrieved messages and UID watermarks
+			CREATE TYPE instance.mail_account_imap_action AS ENUM ('delete','move','seen');
+			ALTER TABLE instance.mail_account ADD COLUMN imap_folders TEXT[] NOT NULL DEFAULT '{INBOX}';
+			ALTER TABLE instance.mail_account ALTER COLUMN imap_folders DROP DEFAULT;
+			ALTER TABLE instance.mail_account ADD COLUMN imap_action instance.mail_account_imap_action NOT NULL DEFAULT 'delete';
+			ALTER TABLE instance.mail_account ALTER COLUMN imap_action DROP DEFAULT;
+			ALTER TABLE instance.mail_account ADD COLUMN imap_folder_move TEXT;
+
+			CREATE TABLE IF NOT EXISTS instance.mail_account_imap_folder (
+				mail_account_id integer NOT NULL,
+				folder TEXT NOT NULL,
+				uid_validity bigint NOT NULL,
+				uid_last bigint NOT NULL,
+				uid_failed bigint,
+				uid_failed_attempts smallint NOT NULL DEFAULT 0,
+				CONSTRAINT mail_account_imap_folder_pkey PRIMARY KEY (mail_account_id, folder),
+				CONSTRAINT mail_account_imap_folder_mail_account_id_fkey FOREIGN KEY (mail_account_id)
+					REFERENCES instance.mail_account (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+
+			-- routing rules for received mails
+			CREATE TYPE instance.mail_rule_action AS ENUM ('function','record');
+			CREATE TABLE IF NOT EXISTS instance.mail_rule (
+				id uuid NOT NULL DEFAULT gen_random_uuid(),
+				mail_account_id integer NOT NULL,
+				position smallint NOT NULL,
+				name CHARACTER VARYING(64) NOT NULL,
+				match_from TEXT,
+				match_to TEXT,
+				match_subject TEXT,
+				action instance.mail_rule_action NOT NULL,
+				pg_function_id uuid,
+				relation_id uuid,
+				attribute_id_from uuid,
+				attribute_id_to uuid,
+				attribute_id_subject uuid,
+				attribute_id_body uuid,
+				attribute_id_date uuid,
+				attribute_id_files uuid,
+				CONSTRAINT mail_rule_pkey PRIMARY KEY (id),
+				CONSTRAINT mail_rule_mail_account_id_fkey FOREIGN KEY (mail_account_id)
+					REFERENCES instance.mail_account (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_rule_pg_function_id_fkey FOREIGN KEY (pg_function_id)
+					REFERENCES app.pg_function (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_rule_relation_id_fkey FOREIGN KEY (relation_id)
+					REFERENCES app.relation (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_rule_attribute_id_from_fkey FOREIGN KEY (attribute_id_from)
+					REFERENCES app.attribute (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_rule_attribute_id_to_fkey FOREIGN KEY (attribute_id_to)
+					REFERENCES app.attribute (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_rule_attribute_id_subject_fkey FOREIGN KEY (attribute_id_subject)
+					REFERENCES app.attribute (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_rule_attribute_id_body_fkey FOREIGN KEY (attribute_id_body)
+					REFERENCES app.attribute (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_rule_attribute_id_date_fkey FOREIGN KEY (attribute_id_date)
+					REFERENCES app.attribute (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_rule_attribute_id_files_fkey FOREIGN KEY (attribute_id_files)
+					REFERENCES app.attribute (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_rule_action_check CHECK (
+					(action = 'function' AND pg_function_id IS NOT NULL) OR
+					(action = 'record'   AND relation_id    IS NOT NULL)
+				)
+			);
+			CREATE INDEX IF NOT EXISTS fki_mail_rule_mail_account_id_fkey
+				ON instance.mail_rule USING btree (mail_account_id ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS fki_mail_rule_pg_function_id_fkey
+				ON instance.mail_rule USING btree (pg_function_id ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS fki_mail_rule_relation_id_fkey
+				ON instance.mail_rule USING btree (relation_id ASC NULLS LAST);
+
+			CREATE TABLE IF NOT EXISTS instance.mail_rule_header (
+				mail_rule_id uuid NOT NULL,
+				position smallint NOT NULL,
+				name TEXT NOT NULL,
+				match TEXT NOT NULL,
+				CONSTRAINT mail_rule_header_mail_rule_id_fkey FOREIGN KEY (mail_rule_id)
+					REFERENCES instance.mail_rule (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_mail_rule_header_mail_rule_id_fkey
+				ON instance.mail_rule_header USING btree (mail_rule_id ASC NULLS LAST);
+
+			-- mail templates
+			CREATE TABLE IF NOT EXISTS app.mail_template (
+				id uuid NOT NULL,
+				module_id uuid NOT NULL,
+				relation_id uuid,
+				name character varying(64) COLLATE pg_catalog."default" NOT NULL,
+				CONSTRAINT mail_template_pkey PRIMARY KEY (id),
+				CONSTRAINT mail_template_name_unique UNIQUE (module_id, name)
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_template_module_id_fkey FOREIGN KEY (module_id)
+					REFERENCES app.module (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+					DEFERRABLE INITIALLY DEFERRED,
+				CONSTRAINT mail_template_relation_id_fkey FOREIGN KEY (relation_id)
+					REFERENCES app.relation (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+					DEFERRABLE INITIALLY DEFERRED
+			);
+			CREATE INDEX IF NOT EXISTS fki_mail_template_module_id_fkey
+				ON app.mail_template USING btree (module_id ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS fki_mail_template_relation_id_fkey
+				ON app.mail_template USING btree (relation_id ASC NULLS LAST);
+
+			ALTER TABLE app.caption ADD COLUMN mail_template_id uuid;
+			ALTER TABLE app.caption ADD CONSTRAINT caption_mail_template_id_fkey FOREIGN KEY (mail_template_id)
+				REFERENCES app.mail_template (id) MATCH SIMPLE
+				ON UPDATE CASCADE
+				ON DELETE CASCADE
+				DEFERRABLE INITIALLY DEFERRED;
+			CREATE INDEX IF NOT EXISTS fki_caption_mail_template_id_fkey
+				ON app.caption USING btree (mail_template_id ASC NULLS LAST);
+
+			ALTER TABLE instance.caption ADD COLUMN mail_template_id uuid;
+			ALTER TABLE instance.caption ADD CONSTRAINT caption_mail_template_id_fkey FOREIGN KEY (mail_template_id)
+				REFERENCES app.mail_template (id) MATCH SIMPLE
+				ON UPDATE CASCADE
+				ON DELETE CASCADE
+				DEFERRABLE INITIALLY DEFERRED;
+			CREATE INDEX IF NOT EXISTS fki_caption_mail_template_id_fkey
+				ON instance.caption USING btree (mail_template_id ASC NULLS LAST);
+
+			ALTER TYPE app.caption_content ADD VALUE 'mailTemplateSubject';
+			ALTER TYPE app.caption_content ADD VALUE 'mailTemplateBody';
+
+			-- spooled mails from templates, template captions are stored as subject & body and rendered when being sent
+			ALTER TABLE instance.mail_spool ADD COLUMN mail_template_id uuid;
+			ALTER TABLE instance.mail_spool ADD COLUMN mail_template_relation_id uuid;
+			ALTER TABLE instance.mail_spool ADD COLUMN mail_template_record_id bigint;
+			ALTER TABLE instance.mail_spool ADD COLUMN mail_template_render BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE instance.mail_spool ADD CONSTRAINT mail_spool_mail_template_id_fkey FOREIGN KEY (mail_template_id)
+				REFERENCES app.mail_template (id) MATCH SIMPLE
+				ON UPDATE CASCADE
+				ON DELETE SET NULL
+				DEFERRABLE INITIALLY DEFERRED;
+			ALTER TABLE instance.mail_spool ADD CONSTRAINT mail_spool_mail_template_relation_id_fkey FOREIGN KEY (mail_template_relation_id)
+				REFERENCES app.relation (id) MATCH SIMPLE
+				ON UPDATE CASCADE
+				ON DELETE SET NULL
+				DEFERRABLE INITIALLY DEFERRED;
+			CREATE INDEX IF NOT EXISTS fki_mail_spool_mail_template_id_fkey
+				ON instance.mail_spool USING btree (mail_template_id ASC NULLS LAST);
+			CREATE INDEX IF NOT EXISTS fki_mail_spool_mail_template_relation_id_fkey
+				ON instance.mail_spool USING btree (mail_template_relation_id ASC NULLS LAST);
+
+			CREATE OR REPLACE FUNCTION instance.mail_send_template(
+				module_name text,
+				template_name text,
+				record_id bigint,
+				to_list text,
+				cc_list text DEFAULT ''::text,
+				bcc_list text DEFAULT ''::text,
+				account_name text DEFAULT NULL,
+				language_code text DEFAULT NULL,
+				attach_record_id integer DEFAULT NULL,
+				attach_attribute_id uuid DEFAULT NULL)
+			    RETURNS integer
+			    LANGUAGE 'plpgsql'
+			AS $BODY$
+			DECLARE
+				account_id int;
+				template_id uuid;
+				template_relation_id uuid;
+				template_subject text;
+				template_body text;
+				module_language text;
+			BEGIN
+				SELECT t.id, t.relation_id, m.language_main
+				INTO template_id, template_relation_id, module_language
+				FROM app.mail_template AS t
+				JOIN app.module        AS m ON m.id = t.module_id
+				WHERE m.name = module_name
+				AND   t.name = template_name;
+				
+				IF template_id IS NULL THEN
+					RAISE EXCEPTION 'unknown mail template "%" in module "%"', template_name, module_name;
+				END IF;
+				
+				IF account_name IS NOT NULL THEN
+					SELECT id INTO account_id
+					FROM instance.mail_account
+					WHERE name = account_name;
+				END IF;
+				
+				-- template captions are chosen by language of current login, if not defined
+				IF language_code IS NULL THEN
+					language_code := instance.get_login_language_code();
+				END IF;
+				
+				-- template captions are stored with mail, changes to or deletion of template do not affect spooled mails
+				-- custom instance captions overwrite module captions, fallback to main module language, then to any language
+				SELECT c.value INTO template_subject
+				FROM (
+					SELECT ic.value, ic.language_code AS code, 0 AS source_order
+					FROM instance.caption AS ic
+					WHERE ic.mail_template_id = template_id
+					AND   ic.content = 'mailTemplateSubject'
+					UNION ALL
+					SELECT ac.value, ac.language_code, 1
+					FROM app.caption AS ac
+					WHERE ac.mail_template_id = template_id
+					AND   ac.content = 'mailTemplateSubject'
+				) AS c
+				WHERE c.value <> ''
+				ORDER BY c.code NOT IN (language_code, module_language), c.source_order, c.code <> language_code
+				LIMIT 1;
+				
+				SELECT c.value INTO template_body
+				FROM (
+					SELECT ic.value, ic.language_code AS code, 0 AS source_order
+					FROM instance.caption AS ic
+					WHERE ic.mail_template_id = template_id
+					AND   ic.content = 'mailTemplateBody'
+					UNION ALL
+					SELECT ac.value, ac.language_code, 1
+					FROM app.caption AS ac
+					WHERE ac.mail_template_id = template_id
+					AND   ac.content = 'mailTemplateBody'
+				) AS c
+				WHERE c.value <> ''
+				ORDER BY c.code NOT IN (language_code, module_language), c.source_order, c.code <> language_code
+				LIMIT 1;
+				
+				IF cc_list IS NULL THEN
+					cc_list := '';
+				END IF;
+				
+				IF bcc_list IS NULL THEN
+					bcc_list := '';
+				END IF;
+				
+				INSERT INTO instance.mail_spool (to_list,cc_list,bcc_list,
+					subject,body,outgoing,date,mail_account_id,record_id_wofk,attribute_id,
+					mail_template_id,mail_template_relation_id,mail_template_record_id,mail_template_render)
+				VALUES (to_list,cc_list,bcc_list,COALESCE(template_subject,''),COALESCE(template_body,''),
+					TRUE,EXTRACT(epoch from now()),account_id,attach_record_id,attach_attribute_id,
+					template_id,template_relation_id,record_id,TRUE);
+			
+				RETURN 0;
+			END;
+			$BODY$;
+			
+			-- DKIM signing and S/MIME for outgoing mails
+			ALTER TABLE instance.mail_account ADD COLUMN dkim_domain TEXT;
+			ALTER TABLE instance.mail_account ADD COLUMN dkim_selector TEXT;
+			ALTER TABLE instance.mail_account ADD COLUMN dkim_key TEXT;
+			ALTER TABLE instance.mail_account ADD COLUMN smime_cert TEXT;
+			ALTER TABLE instance.mail_account ADD COLUMN smime_key TEXT;
+			ALTER TABLE instance.mail_account ADD COLUMN smime_sign BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE instance.mail_account ALTER COLUMN smime_sign DROP DEFAULT;
+			ALTER TABLE instance.mail_account ADD COLUMN smime_encrypt BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE instance.mail_account ALTER COLUMN smime_encrypt DROP DEFAULT;
+			
+			-- bounce handling, failed recipients of a mail are stored with their status & diagnostic at the same array index
+			ALTER TABLE instance.mail_traffic ADD COLUMN message_id TEXT;
+			ALTER TABLE instance.mail_traffic ADD COLUMN bounce_date BIGINT;
+			ALTER TABLE instance.mail_traffic ADD COLUMN bounce_recipients TEXT[];
+			ALTER TABLE instance.mail_traffic ADD COLUMN bounce_statuses TEXT[];
+			ALTER TABLE instance.mail_traffic ADD COLUMN bounce_diagnostics TEXT[];
+			CREATE INDEX IF NOT EXISTS ind_mail_traffic_message_id
+				ON instance.mail_traffic USING btree (message_id ASC NULLS LAST);
+			
+			ALTER TABLE instance.mail_account ADD COLUMN bounce_pg_function_id UUID;
+			ALTER TABLE instance.mail_account ADD CONSTRAINT mail_account_bounce_pg_function_id_fkey
+				FOREIGN KEY (bounce_pg_function_id)
+				REFERENCES app.pg_function (id) MATCH SIMPLE
+				ON UPDATE CASCADE
+				ON DELETE SET NULL
+				DEFERRABLE INITIALLY DEFERRED;
+			CREATE INDEX IF NOT EXISTS fki_mail_account_bounce_pg_function_id_fkey
+				ON instance.mail_account USING btree (bounce_pg_function_id ASC NULLS LAST);
+			
+			CREATE TYPE instance.mail_bounce AS (
+				message_id text,
+				recipient text,
+				status text,
+				diagnostic text,
+				to_list text,
+				subject text,
+				date bigint
+			);
+		`)
+		return "3.9", err
+	},
 	"3.7": func(tx pgx.Tx) (string, error) {
 		_, err := tx.Exec(db.Ctx, `
 			-- cleanup from last release
@@ -1,10 +1,13 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"r3/bruteforce"
 	"r3/cache"
@@ -16,6 +19,7 @@ import (
 	"r3/handler"
 	"r3/log"
 	"r3/login/login_auth"
+	"r3/login/login_tokenApi"
 	"r3/schema"
 	"r3/types"
 	"regexp"
@@ -24,6 +28,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -47,26 +52,155 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// check token
+	loginId, tokenApi, err := authenticate(r, token)
+	if err != nil {
+		abort(http.StatusUnauthorized, err, handler.ErrUnauthorized)
+		bruteforce.BadAttempt(r)
+		return
+	}
+
+	// OpenAPI documents are served separately
+	if strings.HasSuffix(r.URL.Path, "/"+openApiFileName) {
+		handlerOpenApi(w, r, loginId, tokenApi, abort)
+		return
+	}
+
+	// execute request
+	timeout := config.GetUint64("dbTimeoutDataRest")
+	if isStreamRequest(r) {
+		timeout = config.GetUint64("dbTimeoutDataStream")
+	}
+	ctx, ctxCancel := context.WithTimeout(context.Background(),
+		time.Duration(int64(timeout))*time.Second)
+
+	defer ctxCancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// idempotent write requests, repeated requests with the same key receive the stored response
+	idempotencyKey := r.Header.Get(headerIdempotencyKey)
+	if idempotencyKey != "" && r.Method != "GET" && config.GetUint64("apiIdempotencyHours") != 0 {
+		if len(idempotencyKey) > idempotencyKeyLengthMax {
+			abort(http.StatusBadRequest, nil, fmt.Sprintf("idempotency key must not exceed %d characters", idempotencyKeyLengthMax))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			abort(http.StatusBadRequest, err, "failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestHash := getIdempotencyRequestHash(r.Method, r.URL.RequestURI(), body)
+		claimed, requestHashStored, responseCode, responseBody, err := claimIdempotencyKey_tx(
+			ctx, tx, loginId, idempotencyKey, requestHash)
+
+		if err != nil {
+			abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+			return
+		}
+		if !claimed {
+			if requestHashStored != requestHash {
+				abort(http.StatusUnprocessableEntity, nil, "idempotency key was already used for a different request")
+				return
+			}
+			w.Header().Set(headerIdempotencyReplayed, "true")
+			w.WriteHeader(responseCode)
+			w.Write(responseBody)
+			return
+		}
+
+		// keep response until it is stored and changes are applied
+		wIdem := bufferedResponseWriter{header: w.Header()}
+		ok := call_tx(ctx, tx, &wIdem, r, loginId, tokenApi, false)
+		if wIdem.status == 0 {
+			wIdem.status = http.StatusOK
+		}
+		if ok {
+			if err := setIdempotencyResponse_tx(ctx, tx, loginId, idempotencyKey,
+				wIdem.status, wIdem.body.Bytes()); err != nil {
+
+				abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+				return
+			}
+			if err := tx.Commit(ctx); err != nil {
+				abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+				return
+			}
+		}
+		w.WriteHeader(wIdem.status)
+		w.Write(wIdem.body.Bytes())
+		return
+	}
+
+	if ok := call_tx(ctx, tx, w, r, loginId, tokenApi, true); !ok {
+		return
+	}
+
+	// apply changes
+	if err := tx.Commit(ctx); err != nil {
+		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+		return
+	}
+}
+
+// authenticates request by personal access token (if prefixed) or by session token
+// returns login ID and personal access token, which is nil if session token was used
+func authenticate(r *http.Request, token string) (int64, *types.LoginTokenApi, error) {
 	var loginId int64
+
+	if strings.HasPrefix(token, login_tokenApi.Prefix) {
+		var tokenApi types.LoginTokenApi
+		address, _, _ := net.SplitHostPort(r.RemoteAddr)
+		if err := login_auth.TokenApi(token, address, &loginId, &tokenApi); err != nil {
+			return 0, nil, err
+		}
+		return loginId, &tokenApi, nil
+	}
+
 	var admin bool
 	var noAuth bool
 	if _, err := login_auth.Token(token, &loginId, &admin, &noAuth); err != nil {
-		abort(http.StatusUnauthorized, err, handler.ErrUnauthorized)
-		bruteforce.BadAttempt(r)
-		return
+		return 0, nil, err
 	}
+	return loginId, nil, nil
+}
+
+// executes API call within given transaction, writes response (or error) to response writer
+// calls with personal access token are limited to its allowed APIs & methods
+// returns false if call was aborted
+func call_tx(ctx context.Context, tx pgx.Tx, w http.ResponseWriter, r *http.Request,
+	loginId int64, tokenApi *types.LoginTokenApi, allowStream bool) bool {
 
-	var isDelete, isGet, isPost bool
+	var abort = func(httpCode int, errToLog error, errMsgUser string) {
+		// if not other error is prepared for log, use user error
+		if errToLog == nil {
+			errToLog = errors.New(errMsgUser)
+		}
+		handler.AbortRequestWithCode(w, "api", httpCode, errToLog, errMsgUser)
+	}
+
+	var isDelete, isGet, isPatch, isPost, isPut bool
 	switch r.Method {
 	case "DELETE":
 		isDelete = true
 	case "GET":
 		isGet = true
+	case "PATCH":
+		isPatch = true
 	case "POST":
 		isPost = true
+	case "PUT":
+		isPut = true
 	default:
 		abort(http.StatusBadRequest, nil, "invalid HTTP method")
-		return
+		return false
 	}
 
 	/*
@@ -74,23 +208,25 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		GET /api/lsw_invoices/contracts/v1?limit=10
 		GET /api/lsw_invoices/contracts/v1/45
 		DELETE /api/lsw_invoices/contracts/v1/45
+		PATCH /api/lsw_invoices/contracts/v1/45
 
 		Rules:
 		Path must contain 5-6 elements (see examples above, split by '/')
-		6th element is the record ID, required by DELETE
+		6th element is the record ID, required by DELETE, PATCH and PUT
 		GET can also have record ID (single record lookup)
 	*/
 	elements := strings.Split(r.URL.Path, "/")
 	recordIdProvided := len(elements) == 6
+	recordIdRequired := isDelete || isPatch || isPut
 
-	if len(elements) < 5 || len(elements) > 6 || (isDelete && !recordIdProvided) {
+	if len(elements) < 5 || len(elements) > 6 || (recordIdRequired && !recordIdProvided) {
 
 		examplePostfix := ""
-		if isDelete {
+		if recordIdRequired {
 			examplePostfix = "/RECORD_ID"
 		}
 		abort(http.StatusBadRequest, nil, fmt.Sprintf("invalid URL, expected: /api/APP_NAME/API_NAME/VERSION%s", examplePostfix))
-		return
+		return false
 	}
 
 	// process path elements
@@ -100,7 +236,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	version, err := strconv.Atoi(elements[4][1:]) // expected format: "v3"
 	if err != nil {
 		abort(http.StatusBadRequest, err, fmt.Sprintf("invalid API version format '%s', expected: 'v12'", elements[4]))
-		return
+		return false
 	}
 
 	var recordId int64
@@ -108,7 +244,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		recordId, err = strconv.ParseInt(elements[5], 10, 64)
 		if err != nil {
 			abort(http.StatusBadRequest, err, fmt.Sprintf("invalid API record ID '%s', integer expected", elements[5]))
-			return
+			return false
 		}
 	}
 
@@ -117,61 +253,84 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		modName, apiName, version, r.Method, recordId))
 
 	// resolve API by module+API names
+	// schema lock is released early for streamed responses, which can take a long time
 	cache.Schema_mx.RLock()
-	defer cache.Schema_mx.RUnlock()
+	schemaLocked := true
+	var schemaUnlock = func() {
+		if schemaLocked {
+			cache.Schema_mx.RUnlock()
+			schemaLocked = false
+		}
+	}
+	defer schemaUnlock()
 
 	apiId, exists := cache.ModuleApiNameMapId[modName][fmt.Sprintf("%s.v%d", apiName, version)]
 	if !exists {
 		abort(http.StatusNotFound, nil, fmt.Sprintf("API '%s.%s' (v%d) does not exist", modName, apiName, version))
-		return
+		return false
 	}
 	api := cache.ApiIdMap[apiId]
 
 	// check supported API methods
+	// updates of known records (PATCH/PUT) are enabled together with POST
 	if (isDelete && !api.HasDelete) ||
 		(isGet && !api.HasGet) ||
-		(isPost && !api.HasPost) {
+		((isPost || isPatch || isPut) && !api.HasPost) {
 		abort(http.StatusBadRequest, nil, fmt.Sprintf("HTTP method '%s' is not supported by this API", r.Method))
-		return
+		return false
 	}
 
 	if !api.Query.RelationId.Valid {
 		abort(http.StatusServiceUnavailable, nil, "query has no base relation")
-		return
+		return false
 	}
 
 	// check role access
 	access, err := cache.GetAccessById(loginId)
 	if err != nil {
 		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
-		return
+		return false
 	}
 	if _, exists := access.Api[api.Id]; !exists {
 		abort(http.StatusForbidden, nil, handler.ErrUnauthorized)
-		return
+		return false
+	}
+
+	// check scope of personal access token
+	if tokenApi != nil && (!login_tokenApi.IsApiAllowed(*tokenApi, api.Id) ||
+		!login_tokenApi.IsMethodAllowed(*tokenApi, r.Method)) {
+
+		abort(http.StatusForbidden, nil, handler.ErrUnauthorized)
+		return false
 	}
 
 	// parse general getters
 	var getters struct {
+		bulk    int // POST only, -1 = detect by body, 0 = single row, 1 = multiple rows
 		limit   int
 		offset  int
 		verbose bool
 	}
+	getters.bulk = -1
 	getters.limit = api.LimitDef
 	getters.verbose = api.VerboseDef
 
 	for getter, value := range r.URL.Query() {
-		if len(value) == 1 && getter == "limit" || getter == "offset" || getter == "verbose" {
+		if len(value) == 1 && (getter == "bulk" || getter == "limit" || getter == "offset" || getter == "stream" || getter == "verbose") {
 			n, err := strconv.Atoi(value[0])
 			if err != nil {
 				abort(http.StatusBadRequest, err, fmt.Sprintf("invalid value '%s' for %s", value[0], getter))
-				return
+				return false
 			}
 			switch getter {
+			case "bulk":
+				getters.bulk = n
 			case "limit":
 				getters.limit = n
 			case "offset":
 				getters.offset = n
+			case "stream":
+				// validated only, streamed responses are detected before the request is executed (isStreamRequest)
 			case "verbose":
 				getters.verbose = n == 1
 			}
@@ -179,14 +338,10 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get login language code (for filters)
-	var languageCode string
-	if err := db.Pool.QueryRow(db.Ctx, `
-		SELECT language_code
-		FROM instance.login_setting
-		WHERE login_id = $1
-	`, loginId).Scan(&languageCode); err != nil {
+	languageCode, err := getLoginLanguageCode(loginId)
+	if err != nil {
 		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
-		return
+		return false
 	}
 
 	// get valid module language code (for captions)
@@ -196,23 +351,10 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		languageCodeModule = mod.LanguageMain
 	}
 
-	// execute request
-	ctx, ctxCancel := context.WithTimeout(context.Background(),
-		time.Duration(int64(config.GetUint64("dbTimeoutDataRest")))*time.Second)
-
-	defer ctxCancel()
-
-	tx, err := db.Pool.Begin(ctx)
-	if err != nil {
-		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
-		return
-	}
-	defer tx.Rollback(ctx)
-
 	if isDelete {
 		if recordId < 1 {
 			abort(http.StatusBadRequest, nil, "record ID must be > 0")
-			return
+			return false
 		}
 
 		// look up all records from joined relations
@@ -236,7 +378,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 				abort(http.StatusServiceUnavailable, nil,
 					handler.ErrSchemaUnknownAttribute(join.AttributeId.Bytes).Error())
 
-				return
+				return false
 			}
 
 			var atrNameLookup, atrNameFilter string
@@ -265,7 +407,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 				relationIndexMapRecordIds[join.IndexFrom]).Scan(&ids); err != nil {
 
 				abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
-				return
+				return false
 			}
 			relationIndexMapRecordIds[join.Index] = ids
 		}
@@ -283,138 +425,135 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 			for _, id := range relationIndexMapRecordIds[join.Index] {
 				if err := data.Del_tx(ctx, tx, join.RelationId, id, loginId); err != nil {
 					abort(http.StatusConflict, nil, err.Error())
-					return
+					return false
 				}
 			}
 		}
 	}
 
 	if isGet {
-		dataGet := types.DataGet{
-			RelationId:  api.Query.RelationId.Bytes,
-			IndexSource: 0,
-			Limit:       getters.limit,
-			Offset:      getters.offset,
-		}
-
 		// abort if requested limit exceeds max limit
 		// better to abort as smaller than requested result count might suggest the absence of more data
-		if api.LimitMax < dataGet.Limit {
+		if api.LimitMax < getters.limit {
 			abort(http.StatusBadRequest, nil, fmt.Sprintf("max. result limit is: %d", api.LimitMax))
-			return
+			return false
 		}
 
-		// resolve relation joins
-		for _, join := range api.Query.Joins {
-			if join.Index == 0 {
-				continue
-			}
-			dataGet.Joins = append(dataGet.Joins, types.DataGetJoin{
-				AttributeId: join.AttributeId.Bytes,
-				Index:       join.Index,
-				IndexFrom:   join.IndexFrom,
-				Connector:   join.Connector,
-			})
+		// apply column selection, filters and orders from URL query
+		columns, colRefs, filters, orders, err := parseGetGetters(r.URL.Query(),
+			api.Columns, getColumnRefs(api.Columns, languageCodeModule))
+
+		if err != nil {
+			abort(http.StatusBadRequest, nil, err.Error())
+			return false
 		}
 
-		// build expressions from columns
-		for _, column := range api.Columns {
-			dataGet.Expressions = append(dataGet.Expressions,
-				data_query.ConvertColumnToExpression(column, loginId, languageCode))
-		}
-
-		// apply query filters
-		dataGet.Filters = data_query.ConvertQueryToDataFilter(
-			api.Query.Filters, loginId, languageCode)
-
-		// add record filter
-		if recordId != 0 {
-			dataGet.Filters = append(dataGet.Filters, types.DataGetFilter{
-				Connector: "AND",
-				Operator:  "=",
-				Side0: types.DataGetFilterSide{
-					AttributeId: pgtype.UUID{
-						Bytes: cache.RelationIdMap[api.Query.RelationId.Bytes].AttributeIdPk,
-						Valid: true,
-					},
-				},
-				Side1: types.DataGetFilterSide{Value: recordId},
-			})
+		dataGet := getDataGet(api, columns, loginId, languageCode, recordId)
+		dataGet.Filters = append(dataGet.Filters, filters...)
+		dataGet.Limit = getters.limit
+		dataGet.Offset = getters.offset
+
+		if len(orders) != 0 {
+			dataGet.Orders = orders
 		}
 
-		// apply query sorting
-		dataGet.Orders = data_query.ConvertQueryToDataOrders(api.Query.Orders)
+		// keyset pagination, starts with empty cursor
+		// total count must include records before the cursor, it is retrieved without the cursor filters
+		var ks *keyset
+		dataGetCount := dataGet
+		if r.URL.Query().Has(getterCursor) {
+			if getters.offset != 0 {
+				abort(http.StatusBadRequest, nil, "offset cannot be used together with cursor")
+				return false
+			}
+			k, err := applyKeyset(&dataGet, api, r.URL.Query().Get(getterCursor))
+			if err != nil {
+				abort(http.StatusBadRequest, nil, err.Error())
+				return false
+			}
+			ks = &k
+		}
+
+		// stream rows directly from database, no total count is available
+		ndjson := strings.Contains(r.Header.Get("Accept"), contentTypeNdjson)
+		if allowStream && isStreamRequest(r) {
+			var relRefs []string
+			if getters.verbose {
+				relRefs = getRelationRefs(columns)
+			}
+			schemaUnlock()
+
+			started, err := streamRows_tx(ctx, tx, w, dataGet, loginId,
+				colRefs, relRefs, len(columns), getters.verbose, ndjson, ks)
+
+			if err != nil {
+				if started {
+					// response is incomplete, abort connection to inform client
+					log.Error("api", "failed to stream rows", err)
+					panic(http.ErrAbortHandler)
+				}
+				if err.Error() == handler.ErrUnauthorized {
+					abort(http.StatusUnauthorized, err, handler.ErrUnauthorized)
+					return false
+				}
+				abort(http.StatusServiceUnavailable, nil, err.Error())
+				return false
+			}
+			return true
+		}
 
 		// get data
 		var query string
-		results, _, err := data.Get_tx(ctx, tx, dataGet, loginId, &query)
+		results, count, err := data.Get_tx(ctx, tx, dataGet, loginId, &query)
 		if err != nil {
 			if err.Error() == handler.ErrUnauthorized {
 				abort(http.StatusUnauthorized, err, handler.ErrUnauthorized)
-				return
+				return false
 			}
 			abort(http.StatusServiceUnavailable, nil, err.Error())
-			return
+			return false
 		}
 
-		// parse output
-		rows := make([]interface{}, 0)
-		if !getters.verbose {
-			for _, result := range results {
-				rows = append(rows, result.Values)
+		if ks != nil && r.URL.Query().Get(getterCursor) != "" {
+			count, err = data.GetCount_tx(ctx, tx, dataGetCount, loginId, &query)
+			if err != nil {
+				abort(http.StatusServiceUnavailable, nil, err.Error())
+				return false
 			}
-		} else {
-			// prepare keys for row template object: { "0(person)":{"firstname":"Hans", ...}, "1(department)":{"name":"IT"}...}
-			relIndexMapNames := make(map[int]string)
-			colRefByColumn := make([]string, len(api.Columns))
-			subQueryCtr := 0
-			for i, column := range api.Columns {
-				atr := cache.AttributeIdMap[column.AttributeId]
-				rel := cache.RelationIdMap[atr.RelationId]
-				colRef := ""
-
-				if ref, exists := column.Captions["columnTitle"][languageCodeModule]; exists {
-					colRef = ref
-				} else {
-					if column.SubQuery {
-						colRef = fmt.Sprintf("sub_query%d", subQueryCtr)
-						subQueryCtr++
-					} else {
-						colRef = atr.Name
-					}
-
-					if column.Aggregator.Valid {
-						colRef = fmt.Sprintf("%s (%s)", strings.ToUpper(column.Aggregator.String), colRef)
-					}
-				}
-				colRefByColumn[i] = colRef
+		}
 
-				if _, exists := relIndexMapNames[column.Index]; !exists {
-					relIndexMapNames[column.Index] = rel.Name
-				}
+		// full page with keyset pagination, more records might follow
+		if ks != nil && dataGet.Limit != 0 && len(results) == dataGet.Limit {
+			cursor, err := ks.getCursor(results[len(results)-1].Values)
+			if err != nil {
+				abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+				return false
 			}
+			w.Header().Set(headerNextCursor, cursor)
+		}
 
-			for _, result := range results {
-				row := make(map[string]map[string]interface{})
-				for i, value := range result.Values {
-
-					relIndex := api.Columns[i].Index
-					relRef := fmt.Sprintf("%d(%s)", relIndex, relIndexMapNames[relIndex])
-
-					if _, exists := row[relRef]; !exists {
-						row[relRef] = make(map[string]interface{})
-					}
-
-					row[relRef][colRefByColumn[i]] = value
-				}
-				rows = append(rows, row)
-			}
+		// remove keyset values from output
+		for i := range results {
+			results[i].Values = results[i].Values[:len(columns)]
 		}
 
-		payloadJson, err := json.Marshal(rows)
+		payloadJson, err := json.Marshal(getRows(columns, colRefs, results, getters.verbose))
 		if err != nil {
 			abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
-			return
+			return false
+		}
+		w.Header().Set(headerTotalCount, strconv.Itoa(count))
+		w.WriteHeader(http.StatusOK)
+		w.Write(payloadJson)
+	}
+
+	if isPatch || isPut {
+		payloadJson, httpCode, err := update_tx(ctx, tx, r.Body, api, recordId,
+			loginId, languageCode, languageCodeModule, getters.verbose, isPatch)
+
+		if err != nil {
+			abort(httpCode, nil, err.Error())
+			return false
 		}
 		w.WriteHeader(http.StatusOK)
 		w.Write(payloadJson)
@@ -425,89 +564,335 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		for _, column := range api.Columns {
 			if column.SubQuery {
 				abort(http.StatusBadRequest, nil, "POST does not support sub queries")
-				return
+				return false
 			}
 		}
 
-		values := make([]interface{}, len(api.Columns))
-		if !getters.verbose {
-			// non-verbose mode: values are following columns (equal count and order)
-			// [123,"Fritz","Hans"]
-			if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
-				abort(http.StatusBadRequest, err, "invalid JSON object")
-				return
-			}
-		} else {
-			// verbose mode structure: relation index + relation name (only for readability, optional) -> attribute name -> value
-			// convert verbose to non-verbose input (to process both inputs the same way)
-			/*{
-				"0(employee)":{ "firstname":"Hans", "age":47 },
-				"1(department)":{ "name":"IT" }
-			]*/
-			var jsonObj map[string]map[string]interface{}
-			if err := json.NewDecoder(r.Body).Decode(&jsonObj); err != nil {
-				abort(http.StatusBadRequest, err, "invalid JSON object")
-				return
-			}
+		rows, bulk, err := parseValuesBulk(r.Body, api.Columns, getters.verbose, getters.bulk, languageCodeModule)
+		if err != nil {
+			abort(http.StatusBadRequest, nil, err.Error())
+			return false
+		}
+
+		// all rows are imported in the same transaction, one failing row aborts all
+		indexMapPgIndexAttributeIds := data_import.ResolveQueryLookups(api.Query.Joins, api.Query.Lookups)
+		indexRecordIdsAll := make([]map[int]int64, 0)
+		for i, values := range rows {
+			indexRecordIds, err := data_import.FromInterfaceValues_tx(ctx, tx,
+				loginId, values, api.Columns, api.Query.Joins, api.Query.Lookups,
+				indexMapPgIndexAttributeIds)
 
-			// pre-populate values with nil, in case required attribute values are not given
-			for i, _ := range api.Columns {
-				values[i] = nil
+			if err != nil {
+				if bulk {
+					err = fmt.Errorf("row %d: %s", i, err.Error())
+				}
+				abort(http.StatusConflict, nil, err.Error())
+				return false
 			}
+			indexRecordIdsAll = append(indexRecordIdsAll, indexRecordIds)
+		}
 
-			for relStr, columnNameMapValues := range jsonObj {
+		var payload interface{} = indexRecordIdsAll
+		if !bulk {
+			payload = indexRecordIdsAll[0]
+		}
+		payloadJson, err := json.Marshal(payload)
+		if err != nil {
+			abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+			return false
+		}
 
-				// remove optional relation name and whitespace
-				relStr = strings.TrimSpace(
-					regexp.MustCompile(`\(.+\)`).ReplaceAllString(relStr, ""))
+		w.WriteHeader(http.StatusOK)
+		w.Write(payloadJson)
+	}
+	return true
+}
 
-				// only the mandatory relation index number should be left
-				relIndex, err := strconv.Atoi(relStr)
-				if err != nil {
-					abort(http.StatusBadRequest, nil, fmt.Sprintf("invalid relation index '%s', integer expected", relStr))
-					return
-				}
-				for i, column := range api.Columns {
-					if column.Index != relIndex {
-						continue
-					}
-
-					var colRef string
-					if ref, exists := column.Captions["columnTitle"][languageCodeModule]; exists {
-						colRef = ref
-					} else {
-						colRef = cache.AttributeIdMap[column.AttributeId].Name
-					}
-
-					if value, exists := columnNameMapValues[colRef]; exists {
-						values[i] = value
-					}
-				}
+// returns data GET request for API query and given columns, optionally filtered to a single base record
+func getDataGet(api types.Api, columns []types.Column, loginId int64, languageCode string, recordId int64) types.DataGet {
+	dataGet := types.DataGet{
+		RelationId:  api.Query.RelationId.Bytes,
+		IndexSource: 0,
+	}
+
+	// resolve relation joins
+	for _, join := range api.Query.Joins {
+		if join.Index == 0 {
+			continue
+		}
+		dataGet.Joins = append(dataGet.Joins, types.DataGetJoin{
+			AttributeId: join.AttributeId.Bytes,
+			Index:       join.Index,
+			IndexFrom:   join.IndexFrom,
+			Connector:   join.Connector,
+		})
+	}
+
+	// build expressions from columns
+	for _, column := range columns {
+		dataGet.Expressions = append(dataGet.Expressions,
+			data_query.ConvertColumnToExpression(column, loginId, languageCode))
+	}
+
+	// apply query filters
+	dataGet.Filters = data_query.ConvertQueryToDataFilter(
+		api.Query.Filters, loginId, languageCode)
+
+	// enclose query filters in brackets, so that additional filters (record, URL getters) cannot interfere
+	if len(dataGet.Filters) != 0 {
+		dataGet.Filters[0].Side0.Brackets++
+		dataGet.Filters[len(dataGet.Filters)-1].Side1.Brackets++
+	}
+
+	// add record filter
+	if recordId != 0 {
+		dataGet.Filters = append(dataGet.Filters, types.DataGetFilter{
+			Connector: "AND",
+			Operator:  "=",
+			Side0: types.DataGetFilterSide{
+				AttributeId: pgtype.UUID{
+					Bytes: cache.RelationIdMap[api.Query.RelationId.Bytes].AttributeIdPk,
+					Valid: true,
+				},
+			},
+			Side1: types.DataGetFilterSide{Value: recordId},
+		})
+	}
+
+	// apply query sorting
+	dataGet.Orders = data_query.ConvertQueryToDataOrders(api.Query.Orders)
+	return dataGet
+}
+
+// converts data GET results to API output rows
+// non-verbose: [123,"Fritz","Hans"]
+// verbose:     { "0(person)":{"firstname":"Hans", ...}, "1(department)":{"name":"IT"}...}
+func getRows(columns []types.Column, colRefs []string, results []types.DataGetResult, verbose bool) []interface{} {
+
+	var relRefs []string
+	if verbose {
+		relRefs = getRelationRefs(columns)
+	}
+
+	rows := make([]interface{}, 0)
+	for _, result := range results {
+		rows = append(rows, getRow(colRefs, relRefs, result, verbose))
+	}
+	return rows
+}
+
+// converts single data GET result to API output row
+// does not access the schema cache, relation references must be prepared with getRelationRefs (verbose only)
+func getRow(colRefs []string, relRefs []string, result types.DataGetResult, verbose bool) interface{} {
+	if !verbose {
+		return result.Values
+	}
+
+	row := make(map[string]map[string]interface{})
+	for i, value := range result.Values {
+		if _, exists := row[relRefs[i]]; !exists {
+			row[relRefs[i]] = make(map[string]interface{})
+		}
+		row[relRefs[i]][colRefs[i]] = value
+	}
+	return row
+}
+
+// returns relation reference for each column, used as keys for verbose output rows
+func getRelationRefs(columns []types.Column) []string {
+
+	relIndexMapNames := make(map[int]string)
+	for _, column := range columns {
+		atr := cache.AttributeIdMap[column.AttributeId]
+		rel := cache.RelationIdMap[atr.RelationId]
+
+		if _, exists := relIndexMapNames[column.Index]; !exists {
+			relIndexMapNames[column.Index] = rel.Name
+		}
+	}
+
+	relRefs := make([]string, len(columns))
+	for i, column := range columns {
+		relRefs[i] = fmt.Sprintf("%d(%s)", column.Index, relIndexMapNames[column.Index])
+	}
+	return relRefs
+}
+
+// parses input values from request body, returns values in column order and which columns were given
+// non-verbose mode: values are following columns (equal count and order), [123,"Fritz","Hans"]
+// non-verbose partial mode: column positions map to values, {"0":123,"2":"Hans"}
+// verbose mode: relation index + relation name (only for readability, optional) -> attribute name -> value
+/*{
+	"0(employee)":{ "firstname":"Hans", "age":47 },
+	"1(department)":{ "name":"IT" }
+}*/
+// missing values are nil, in partial mode they are also not given (given = false)
+func parseValues(body io.Reader, columns []types.Column, verbose bool, partial bool,
+	languageCodeModule string) ([]interface{}, []bool, error) {
+
+	values := make([]interface{}, len(columns))
+	given := make([]bool, len(columns))
+
+	if !verbose && !partial {
+		if err := json.NewDecoder(body).Decode(&values); err != nil {
+			return values, given, errors.New("invalid JSON object")
+		}
+		if len(values) != len(columns) {
+			return values, given, fmt.Errorf("invalid value count, %d values expected", len(columns))
+		}
+		for i := range given {
+			given[i] = true
+		}
+		return values, given, nil
+	}
+
+	if !verbose {
+		var jsonObj map[string]interface{}
+		if err := json.NewDecoder(body).Decode(&jsonObj); err != nil {
+			return values, given, errors.New("invalid JSON object")
+		}
+		for posStr, value := range jsonObj {
+			pos, err := strconv.Atoi(posStr)
+			if err != nil || pos < 0 || pos >= len(columns) {
+				return values, given, fmt.Errorf("invalid column position '%s', integer between 0 and %d expected",
+					posStr, len(columns)-1)
 			}
+			values[pos] = value
+			given[pos] = true
 		}
+		return values, given, nil
+	}
+
+	// convert verbose to non-verbose input (to process both inputs the same way)
+	var jsonObj map[string]map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&jsonObj); err != nil {
+		return values, given, errors.New("invalid JSON object")
+	}
 
-		indexRecordIds, err := data_import.FromInterfaceValues_tx(ctx, tx,
-			loginId, values, api.Columns, api.Query.Joins, api.Query.Lookups,
-			data_import.ResolveQueryLookups(api.Query.Joins, api.Query.Lookups))
+	for relStr, columnNameMapValues := range jsonObj {
 
+		// remove optional relation name and whitespace
+		relStr = strings.TrimSpace(
+			regexp.MustCompile(`\(.+\)`).ReplaceAllString(relStr, ""))
+
+		// only the mandatory relation index number should be left
+		relIndex, err := strconv.Atoi(relStr)
 		if err != nil {
-			abort(http.StatusConflict, nil, err.Error())
-			return
+			return values, given, fmt.Errorf("invalid relation index '%s', integer expected", relStr)
 		}
+		for i, column := range columns {
+			if column.Index != relIndex {
+				continue
+			}
 
-		payloadJson, err := json.Marshal(indexRecordIds)
-		if err != nil {
-			abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
-			return
+			var colRef string
+			if ref, exists := column.Captions["columnTitle"][languageCodeModule]; exists {
+				colRef = ref
+			} else {
+				colRef = cache.AttributeIdMap[column.AttributeId].Name
+			}
+
+			if value, exists := columnNameMapValues[colRef]; exists {
+				values[i] = value
+				given[i] = true
+			}
 		}
+	}
 
-		w.WriteHeader(http.StatusOK)
-		w.Write(payloadJson)
+	// without partial mode, all values are replaced - missing values are given as nil
+	if !partial {
+		for i := range given {
+			given[i] = true
+		}
 	}
+	return values, given, nil
+}
 
-	// apply changes
-	if err := tx.Commit(ctx); err != nil {
-		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
-		return
+// parses one or multiple rows of input values from request body (see parseValues for row formats)
+// multiple rows are sent as JSON array of rows: [[123,"Fritz"],[124,"Hans"]] or [{"0(employee)":{...}},{...}]
+// bulk mode: -1 = detect by body, 0 = single row, 1 = multiple rows
+// returns values of all rows and whether multiple rows were sent
+func parseValuesBulk(body io.Reader, columns []types.Column, verbose bool, bulkMode int,
+	languageCodeModule string) ([][]interface{}, bool, error) {
+
+	rows := make([][]interface{}, 0)
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return rows, false, errors.New("invalid JSON object")
+	}
+
+	// single row, verbose: object, non-verbose: array of values
+	// multiple rows, verbose: array of objects, non-verbose: array of arrays with one value per column
+	// non-verbose single rows can look like multiple rows (array values), the bulk getter decides if given
+	var rowsRaw []json.RawMessage
+	bulk := json.Unmarshal(raw, &rowsRaw) == nil
+	switch {
+	case bulkMode == 0:
+		bulk = false
+	case bulkMode == 1:
+		if !bulk {
+			return rows, false, errors.New("invalid JSON array, multiple rows expected")
+		}
+	case bulk && verbose:
+		bulk = len(rowsRaw) != 0
+	case bulk:
+		bulk = len(rowsRaw) != 0
+		for _, rowRaw := range rowsRaw {
+			var values []json.RawMessage
+			if json.Unmarshal(rowRaw, &values) != nil || len(values) != len(columns) {
+				bulk = false
+				break
+			}
+		}
+	}
+	if !bulk {
+		rowsRaw = []json.RawMessage{raw}
+	}
+
+	for i, rowRaw := range rowsRaw {
+		values, _, err := parseValues(bytes.NewReader(rowRaw), columns, verbose, false, languageCodeModule)
+		if err != nil {
+			if bulk {
+				return rows, bulk, fmt.Errorf("row %d: %s", i, err.Error())
+			}
+			return rows, bulk, err
+		}
+		rows = append(rows, values)
 	}
+	return rows, bulk, nil
+}
+
+// returns column references (column title or attribute name) used as keys in verbose mode
+func getColumnRefs(columns []types.Column, languageCodeModule string) []string {
+	colRefs := make([]string, len(columns))
+	subQueryCtr := 0
+	for i, column := range columns {
+		if ref, exists := column.Captions["columnTitle"][languageCodeModule]; exists {
+			colRefs[i] = ref
+			continue
+		}
+
+		if column.SubQuery {
+			colRefs[i] = fmt.Sprintf("sub_query%d", subQueryCtr)
+			subQueryCtr++
+		} else {
+			colRefs[i] = cache.AttributeIdMap[column.AttributeId].Name
+		}
+
+		if column.Aggregator.Valid {
+			colRefs[i] = fmt.Sprintf("%s (%s)", strings.ToUpper(column.Aggregator.String), colRefs[i])
+		}
+	}
+	return colRefs
+}
+
+func getLoginLanguageCode(loginId int64) (string, error) {
+	var languageCode string
+	err := db.Pool.QueryRow(db.Ctx, `
+		SELECT language_code
+		FROM instance.login_setting
+		WHERE login_id = $1
+	`, loginId).Scan(&languageCode)
+	return languageCode, err
 }
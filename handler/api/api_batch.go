@@ -0,0 +1,219 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"r3/bruteforce"
+	"r3/config"
+	"r3/db"
+	"r3/handler"
+	"r3/log"
+	"strings"
+	"time"
+)
+
+const (
+	batchModeAllOrNothing = "allOrNothing" // first failed operation stops batch, no changes are applied
+	batchModeBestEffort   = "bestEffort"   // failed operations are rolled back, others are applied
+)
+
+type batchRequest struct {
+	Mode       string           `json:"mode"`
+	Operations []batchOperation `json:"operations"`
+}
+type batchOperation struct {
+	Method string          `json:"method"` // DELETE, GET, PATCH, POST, PUT
+	Path   string          `json:"path"`   // API path incl. getters, such as: /api/lsw_invoices/contracts/v1?limit=10
+	Body   json.RawMessage `json:"body"`   // request body (POST, PATCH, PUT)
+}
+type batchResponse struct {
+	Committed bool                `json:"committed"` // whether changes were applied
+	Results   []batchResultOutput `json:"results"`   // results in operation order
+}
+type batchResultOutput struct {
+	Status int             `json:"status"`          // HTTP status code of operation
+	Data   json.RawMessage `json:"data,omitempty"`  // response of successful operation
+	Error  string          `json:"error,omitempty"` // error of failed operation
+}
+
+// executes multiple API operations in a single DB transaction
+/*
+	POST /api/batch
+	{
+		"mode":"allOrNothing",
+		"operations":[
+			{ "method":"POST", "path":"/api/lsw_invoices/contracts/v1", "body":[123,"Fritz"] },
+			{ "method":"GET", "path":"/api/lsw_invoices/contracts/v1?limit=10" },
+			{ "method":"DELETE", "path":"/api/lsw_invoices/contracts/v1/45" }
+		]
+	}
+*/
+func HandlerBatch(w http.ResponseWriter, r *http.Request) {
+
+	if blocked := bruteforce.Check(r); blocked {
+		handler.AbortRequestNoLog(w, handler.ErrBruteforceBlock)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	var abort = func(httpCode int, errToLog error, errMsgUser string) {
+		// if not other error is prepared for log, use user error
+		if errToLog == nil {
+			errToLog = errors.New(errMsgUser)
+		}
+		handler.AbortRequestWithCode(w, "api", httpCode, errToLog, errMsgUser)
+	}
+
+	// check token
+	loginId, tokenApi, err := authenticate(r, token)
+	if err != nil {
+		abort(http.StatusUnauthorized, err, handler.ErrUnauthorized)
+		bruteforce.BadAttempt(r)
+		return
+	}
+
+	if r.Method != "POST" {
+		abort(http.StatusBadRequest, nil, "invalid HTTP method")
+		return
+	}
+
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		abort(http.StatusBadRequest, err, "invalid JSON object")
+		return
+	}
+
+	switch req.Mode {
+	case "":
+		req.Mode = batchModeAllOrNothing
+	case batchModeAllOrNothing, batchModeBestEffort:
+	default:
+		abort(http.StatusBadRequest, nil, fmt.Sprintf("invalid batch mode '%s', expected: '%s' or '%s'",
+			req.Mode, batchModeAllOrNothing, batchModeBestEffort))
+		return
+	}
+
+	for i, op := range req.Operations {
+		// first path segment is compared exactly, modules can start with reserved names (e. g. 'authors')
+		segment, _, _ := strings.Cut(strings.TrimPrefix(op.Path, "/api/"), "?")
+		segment, _, _ = strings.Cut(segment, "/")
+
+		if !strings.HasPrefix(op.Path, "/api/") || segment == "batch" || segment == "auth" ||
+			strings.Contains(op.Path, "/"+openApiFileName) {
+
+			abort(http.StatusBadRequest, nil, fmt.Sprintf("invalid path '%s' for operation %d", op.Path, i))
+			return
+		}
+	}
+
+	log.Info("api", fmt.Sprintf("batch is called with %d operations (mode: %s)",
+		len(req.Operations), req.Mode))
+
+	// execute operations
+	ctx, ctxCancel := context.WithTimeout(context.Background(),
+		time.Duration(int64(config.GetUint64("dbTimeoutDataRest")))*time.Second)
+
+	defer ctxCancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	res := batchResponse{
+		Committed: true,
+		Results:   make([]batchResultOutput, 0),
+	}
+	for _, op := range req.Operations {
+
+		// skip remaining operations after failure
+		if !res.Committed {
+			res.Results = append(res.Results, batchResultOutput{
+				Status: http.StatusFailedDependency,
+				Error:  "operation not executed, previous operation failed",
+			})
+			continue
+		}
+
+		// each operation runs in its own savepoint, so that failures can be rolled back individually
+		txOp, err := tx.Begin(ctx)
+		if err != nil {
+			abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+			return
+		}
+
+		rOp, err := http.NewRequestWithContext(ctx, op.Method, op.Path, bytes.NewReader(op.Body))
+		if err != nil {
+			txOp.Rollback(ctx)
+			res.Results = append(res.Results, batchResultOutput{
+				Status: http.StatusBadRequest,
+				Error:  "invalid operation",
+			})
+			if req.Mode == batchModeAllOrNothing {
+				res.Committed = false
+			}
+			continue
+		}
+
+		wOp := bufferedResponseWriter{header: make(http.Header)}
+		ok := call_tx(ctx, txOp, &wOp, rOp, loginId, tokenApi, false)
+
+		if ok {
+			if err := txOp.Commit(ctx); err != nil {
+				abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+				return
+			}
+			result := batchResultOutput{Status: wOp.status}
+			if result.Status == 0 {
+				result.Status = http.StatusOK
+			}
+			if wOp.body.Len() != 0 {
+				result.Data = wOp.body.Bytes()
+			}
+			res.Results = append(res.Results, result)
+			continue
+		}
+
+		if err := txOp.Rollback(ctx); err != nil {
+			abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+			return
+		}
+
+		var errOp struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(wOp.body.Bytes(), &errOp)
+		res.Results = append(res.Results, batchResultOutput{
+			Status: wOp.status,
+			Error:  errOp.Error,
+		})
+		if req.Mode == batchModeAllOrNothing {
+			res.Committed = false
+		}
+	}
+
+	// apply changes
+	if res.Committed {
+		if err := tx.Commit(ctx); err != nil {
+			abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+			return
+		}
+	}
+
+	payloadJson, err := json.Marshal(res)
+	if err != nil {
+		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"r3/cache"
+	"r3/types"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	getterCursor     = "cursor"
+	headerNextCursor = "X-Next-Cursor"
+)
+
+var errCursorInvalid = errors.New("invalid cursor")
+
+// keyset pagination
+// records are ordered by requested/query orders, followed by the primary keys of all joined relations and the base relation
+// the cursor contains the keyset values of the last record of a page, the next page starts after this record
+type keyset struct {
+	orders    []types.DataGetOrder // all orders, including primary key tie breakers
+	positions []int                // positions of keyset values in data GET expressions, by order
+	signature string               // identifies orders, cursors are only valid for the same orders
+}
+
+// cursor content, encoded as base64 JSON (opaque for API clients)
+type keysetCursor struct {
+	Signature string        `json:"s"`
+	Values    []interface{} `json:"v"`
+}
+
+// prepares data GET request for keyset pagination, starting after given cursor (first page if empty)
+// adds primary key orders and expressions for keyset values not available from columns
+func applyKeyset(dataGet *types.DataGet, api types.Api, cursor string) (keyset, error) {
+
+	var ks keyset
+
+	for _, expr := range dataGet.Expressions {
+		if expr.GroupBy || expr.Distincted || expr.Aggregator.Valid {
+			return ks, errors.New("cursor cannot be used with grouped, distinct or aggregated columns")
+		}
+	}
+	for _, order := range dataGet.Orders {
+		if !order.AttributeId.Valid {
+			return ks, errors.New("cursor cannot be used with sorting by sub query columns")
+		}
+	}
+
+	// primary keys of joined relations and base relation (last) make record order unique
+	ks.orders = dataGet.Orders
+	for _, join := range api.Query.Joins {
+		if join.Index == 0 {
+			continue
+		}
+		rel, exists := cache.RelationIdMap[join.RelationId]
+		if !exists {
+			return ks, fmt.Errorf("unknown relation for join index %d", join.Index)
+		}
+		ks.orders = append(ks.orders, types.DataGetOrder{
+			AttributeId: pgtype.UUID{Bytes: rel.AttributeIdPk, Valid: true},
+			Index:       pgtype.Int4{Int32: int32(join.Index), Valid: true},
+			Ascending:   true,
+		})
+	}
+	rel, exists := cache.RelationIdMap[api.Query.RelationId.Bytes]
+	if !exists {
+		return ks, fmt.Errorf("unknown base relation")
+	}
+	ks.orders = append(ks.orders, types.DataGetOrder{
+		AttributeId: pgtype.UUID{Bytes: rel.AttributeIdPk, Valid: true},
+		Index:       pgtype.Int4{Int32: 0, Valid: true},
+		Ascending:   true,
+	})
+
+	// find keyset values in expressions, add missing ones (removed from output)
+	signatureParts := make([]string, len(ks.orders))
+	for i, order := range ks.orders {
+		pos := -1
+		for j, expr := range dataGet.Expressions {
+			if expr.Query.RelationId == uuid.Nil &&
+				expr.AttributeId.Bytes == order.AttributeId.Bytes &&
+				expr.Index == int(order.Index.Int32) {
+
+				pos = j
+				break
+			}
+		}
+		if pos == -1 {
+			dataGet.Expressions = append(dataGet.Expressions, types.DataGetExpression{
+				AttributeId: order.AttributeId,
+				Index:       int(order.Index.Int32),
+			})
+			pos = len(dataGet.Expressions) - 1
+		}
+		ks.positions = append(ks.positions, pos)
+		signatureParts[i] = fmt.Sprintf("%s.%d.%t", uuid.UUID(order.AttributeId.Bytes),
+			order.Index.Int32, order.Ascending)
+	}
+	hash := sha256.Sum256([]byte(strings.Join(signatureParts, ",")))
+	ks.signature = hex.EncodeToString(hash[:8])
+	dataGet.Orders = ks.orders
+
+	if cursor == "" {
+		return ks, nil
+	}
+
+	// continue after cursor record
+	values, err := ks.parseCursor(cursor)
+	if err != nil {
+		return ks, err
+	}
+	filters := ks.getFiltersAfter(values, 0)
+	bracketFilters(filters)
+	filters[0].Connector = "AND"
+	dataGet.Filters = append(dataGet.Filters, filters...)
+	return ks, nil
+}
+
+// returns opaque cursor for the given data GET result values (last record of a page)
+func (ks keyset) getCursor(values []interface{}) (string, error) {
+
+	c := keysetCursor{
+		Signature: ks.signature,
+		Values:    make([]interface{}, len(ks.positions)),
+	}
+	for i, pos := range ks.positions {
+		switch v := values[pos].(type) {
+		case [16]uint8:
+			c.Values[i] = uuid.FromBytesOrNil(v[:]).String()
+		default:
+			c.Values[i] = v
+		}
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// returns keyset values from cursor, converted to attribute types
+func (ks keyset) parseCursor(cursor string) ([]interface{}, error) {
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errCursorInvalid
+	}
+
+	var c keysetCursor
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil {
+		return nil, errCursorInvalid
+	}
+	if c.Signature != ks.signature || len(c.Values) != len(ks.orders) {
+		return nil, errors.New("cursor does not match requested sorting")
+	}
+
+	values := make([]interface{}, len(c.Values))
+	for i, order := range ks.orders {
+		if c.Values[i] == nil {
+			continue
+		}
+		atr, exists := cache.AttributeIdMap[order.AttributeId.Bytes]
+		if !exists {
+			return nil, errCursorInvalid
+		}
+
+		var ok bool
+		switch atr.Content {
+		case "integer", "bigint", "1:1", "n:1":
+			var n json.Number
+			if n, ok = c.Values[i].(json.Number); ok {
+				values[i], err = n.Int64()
+			}
+		case "real", "double precision":
+			var n json.Number
+			if n, ok = c.Values[i].(json.Number); ok {
+				values[i], err = n.Float64()
+			}
+		case "numeric":
+			var n json.Number
+			if n, ok = c.Values[i].(json.Number); ok {
+				var v pgtype.Numeric
+				err = v.Scan(n.String())
+				values[i] = v
+			}
+		case "boolean":
+			values[i], ok = c.Values[i].(bool)
+		case "uuid":
+			var s string
+			if s, ok = c.Values[i].(string); ok {
+				var v pgtype.UUID
+				err = v.Scan(s)
+				values[i] = v
+			}
+		default:
+			values[i], ok = c.Values[i].(string)
+		}
+		if !ok || err != nil {
+			return nil, errCursorInvalid
+		}
+	}
+	return values, nil
+}
+
+// returns filters for all records after keyset values, starting at order position
+// records after: value is beyond (respecting direction, NULLs last) or value is equal and records after next order position
+// (A > a OR A IS NULL) OR (A = a AND ((B > b OR B IS NULL) OR (B = b AND ...)))
+func (ks keyset) getFiltersAfter(values []interface{}, pos int) []types.DataGetFilter {
+
+	order := ks.orders[pos]
+	side0 := types.DataGetFilterSide{
+		AttributeId:    order.AttributeId,
+		AttributeIndex: int(order.Index.Int32),
+	}
+
+	// beyond value, nothing is beyond NULL as NULLs are sorted last
+	beyond := make([]types.DataGetFilter, 0)
+	if values[pos] != nil {
+		operator := ">"
+		if !order.Ascending {
+			operator = "<"
+		}
+		beyond = append(beyond, types.DataGetFilter{
+			Connector: "AND",
+			Operator:  operator,
+			Side0:     side0,
+			Side1:     types.DataGetFilterSide{Value: values[pos]},
+		}, types.DataGetFilter{
+			Connector: "OR",
+			Operator:  "IS NULL",
+			Side0:     side0,
+		})
+		bracketFilters(beyond)
+	}
+
+	// last order is base relation primary key, never NULL
+	if pos == len(ks.orders)-1 {
+		return beyond
+	}
+
+	// equal value and after next order position
+	equal := []types.DataGetFilter{{Connector: "AND", Side0: side0}}
+	if values[pos] == nil {
+		equal[0].Operator = "IS NULL"
+	} else {
+		equal[0].Operator = "="
+		equal[0].Side1 = types.DataGetFilterSide{Value: values[pos]}
+	}
+	after := ks.getFiltersAfter(values, pos+1)
+	bracketFilters(after)
+	after[0].Connector = "AND"
+	equal = append(equal, after...)
+
+	if len(beyond) == 0 {
+		return equal
+	}
+	bracketFilters(equal)
+	equal[0].Connector = "OR"
+	return append(beyond, equal...)
+}
+
+// encloses filters in brackets
+func bracketFilters(filters []types.DataGetFilter) {
+	filters[0].Side0.Brackets++
+	filters[len(filters)-1].Side1.Brackets++
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"r3/cache"
+	"r3/types"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	getterColumns    = "columns"
+	getterFilter     = "filter."
+	getterOrderBy    = "order_by"
+	headerTotalCount = "X-Total-Count"
+)
+
+// filter operators available via URL getters, mapped to data GET filter operators
+// 'in' requires a comma separated list of values, 'null' requires true/false
+var getterFilterOperators = map[string]string{
+	"eq":   "=",
+	"ne":   "<>",
+	"lt":   "<",
+	"le":   "<=",
+	"gt":   ">",
+	"ge":   ">=",
+	"like": "ILIKE",
+	"in":   "= ANY",
+	"null": "IS NULL",
+}
+
+// parses column selection, filters and orders from URL getters of a GET request
+// columns are referenced either by position (0, 1, 2, ...) or by column reference (as used in verbose mode)
+// examples:
+//
+//	?columns=firstname,2
+//	?filter.firstname=like:Hans&filter.age=ge:18&filter.2=in:IT,Sales&filter.department=null:false
+//	?order_by=age:desc,firstname
+//
+// returns selected columns & their references, additional filters and orders (empty if query orders are kept)
+func parseGetGetters(getters url.Values, columns []types.Column, colRefs []string) (
+	[]types.Column, []string, []types.DataGetFilter, []types.DataGetOrder, error) {
+
+	columnsOut := columns
+	colRefsOut := colRefs
+	filters := make([]types.DataGetFilter, 0)
+	orders := make([]types.DataGetOrder, 0)
+
+	// column selection
+	if value := getters.Get(getterColumns); value != "" {
+		columnsOut = make([]types.Column, 0)
+		colRefsOut = make([]string, 0)
+
+		for _, ref := range strings.Split(value, ",") {
+			pos, err := getColumnPosByRef(colRefs, ref)
+			if err != nil {
+				return columnsOut, colRefsOut, filters, orders, err
+			}
+			columnsOut = append(columnsOut, columns[pos])
+			colRefsOut = append(colRefsOut, colRefs[pos])
+		}
+	}
+
+	// filters
+	for getter, values := range getters {
+		if !strings.HasPrefix(getter, getterFilter) {
+			continue
+		}
+		pos, err := getColumnPosByRef(colRefs, strings.TrimPrefix(getter, getterFilter))
+		if err != nil {
+			return columnsOut, colRefsOut, filters, orders, err
+		}
+
+		for _, value := range values {
+			filter, err := getFilterFromGetter(columns[pos], colRefs[pos], value)
+			if err != nil {
+				return columnsOut, colRefsOut, filters, orders, err
+			}
+			filters = append(filters, filter)
+		}
+	}
+
+	// orders
+	if value := getters.Get(getterOrderBy); value != "" {
+		for _, orderBy := range strings.Split(value, ",") {
+			ref, direction, _ := strings.Cut(orderBy, ":")
+
+			var ascending bool
+			switch strings.ToLower(direction) {
+			case "", "asc":
+				ascending = true
+			case "desc":
+				ascending = false
+			default:
+				return columnsOut, colRefsOut, filters, orders, fmt.Errorf("invalid sort direction '%s', expected: 'asc' or 'desc'", direction)
+			}
+
+			pos, err := getColumnPosByRef(colRefs, ref)
+			if err != nil {
+				return columnsOut, colRefsOut, filters, orders, err
+			}
+			column := columns[pos]
+
+			// attribute columns are sorted by attribute value
+			if !column.SubQuery && !column.Aggregator.Valid {
+				orders = append(orders, types.DataGetOrder{
+					AttributeId: pgtype.UUID{Bytes: column.AttributeId, Valid: true},
+					Index:       pgtype.Int4{Int32: int32(column.Index), Valid: true},
+					Ascending:   ascending,
+				})
+				continue
+			}
+
+			// sub query and aggregated columns are sorted by expression, must be part of selected columns
+			exprPos := -1
+			for i, c := range columnsOut {
+				if c.Id == column.Id {
+					exprPos = i
+					break
+				}
+			}
+			if exprPos == -1 {
+				return columnsOut, colRefsOut, filters, orders, fmt.Errorf("cannot sort by column '%s', it is not selected", ref)
+			}
+			orders = append(orders, types.DataGetOrder{
+				ExpressionPos: pgtype.Int4{Int32: int32(exprPos), Valid: true},
+				Ascending:     ascending,
+			})
+		}
+	}
+	return columnsOut, colRefsOut, filters, orders, nil
+}
+
+// returns column position by column reference or position
+func getColumnPosByRef(colRefs []string, ref string) (int, error) {
+
+	if pos, err := strconv.Atoi(ref); err == nil {
+		if pos < 0 || pos >= len(colRefs) {
+			return 0, fmt.Errorf("invalid column position %d, API has %d columns", pos, len(colRefs))
+		}
+		return pos, nil
+	}
+
+	pos := -1
+	for i, colRef := range colRefs {
+		if colRef != ref {
+			continue
+		}
+		if pos != -1 {
+			return 0, fmt.Errorf("column reference '%s' is ambiguous, use column position instead", ref)
+		}
+		pos = i
+	}
+	if pos == -1 {
+		return 0, fmt.Errorf("unknown column '%s'", ref)
+	}
+	return pos, nil
+}
+
+// returns data GET filter for a filter getter value, format: OPERATOR:VALUE
+func getFilterFromGetter(column types.Column, colRef string, getterValue string) (types.DataGetFilter, error) {
+
+	var filter types.DataGetFilter
+
+	opName, value, found := strings.Cut(getterValue, ":")
+	if !found {
+		return filter, fmt.Errorf("invalid filter '%s' for column '%s', expected: OPERATOR:VALUE", getterValue, colRef)
+	}
+	operator, exists := getterFilterOperators[opName]
+	if !exists {
+		return filter, fmt.Errorf("invalid filter operator '%s' for column '%s'", opName, colRef)
+	}
+
+	if column.SubQuery || column.Aggregator.Valid {
+		return filter, fmt.Errorf("cannot filter sub query or aggregated column '%s'", colRef)
+	}
+
+	atr, exists := cache.AttributeIdMap[column.AttributeId]
+	if !exists {
+		return filter, fmt.Errorf("unknown attribute for column '%s'", colRef)
+	}
+	if atr.Encrypted || atr.Content == "files" {
+		return filter, fmt.Errorf("cannot filter encrypted or file column '%s'", colRef)
+	}
+
+	filter.Connector = "AND"
+	filter.Operator = operator
+	filter.Side0 = types.DataGetFilterSide{
+		AttributeId:    pgtype.UUID{Bytes: column.AttributeId, Valid: true},
+		AttributeIndex: column.Index,
+	}
+
+	switch opName {
+	case "null":
+		switch value {
+		case "true":
+		case "false":
+			filter.Operator = "IS NOT NULL"
+		default:
+			return filter, fmt.Errorf("invalid filter value '%s' for column '%s', expected: 'true' or 'false'", value, colRef)
+		}
+		return filter, nil
+
+	case "like":
+		// LIKE operators work on text, wildcard characters are added automatically
+		filter.Side1.Value = value
+		return filter, nil
+
+	case "in":
+		var err error
+		switch getFilterValueType(atr.Content) {
+		case "int":
+			v := make([]int64, 0)
+			for _, s := range strings.Split(value, ",") {
+				var n int64
+				if n, err = strconv.ParseInt(s, 10, 64); err != nil {
+					break
+				}
+				v = append(v, n)
+			}
+			filter.Side1.Value = v
+		case "float":
+			v := make([]float64, 0)
+			for _, s := range strings.Split(value, ",") {
+				var n float64
+				if n, err = strconv.ParseFloat(s, 64); err != nil {
+					break
+				}
+				v = append(v, n)
+			}
+			filter.Side1.Value = v
+		case "bool":
+			err = errors.New("operator 'in' is not supported for boolean values")
+		default:
+			filter.Side1.Value = strings.Split(value, ",")
+		}
+		if err != nil {
+			return filter, fmt.Errorf("invalid filter value '%s' for column '%s', %s", value, colRef, err)
+		}
+		return filter, nil
+	}
+
+	// single value comparison
+	var err error
+	switch getFilterValueType(atr.Content) {
+	case "int":
+		filter.Side1.Value, err = strconv.ParseInt(value, 10, 64)
+	case "float":
+		filter.Side1.Value, err = strconv.ParseFloat(value, 64)
+	case "bool":
+		filter.Side1.Value, err = strconv.ParseBool(value)
+	default:
+		filter.Side1.Value = value
+	}
+	if err != nil {
+		return filter, fmt.Errorf("invalid filter value '%s' for column '%s', %s", value, colRef, err)
+	}
+	return filter, nil
+}
+
+// returns value type of attribute content for filter value conversion
+func getFilterValueType(content string) string {
+	switch content {
+	case "integer", "bigint", "1:1", "n:1":
+		return "int"
+	case "numeric", "real", "double precision":
+		return "float"
+	case "boolean":
+		return "bool"
+	}
+	return "text"
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"r3/config"
+	"r3/tools"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	headerIdempotencyKey      = "Idempotency-Key"
+	headerIdempotencyReplayed = "Idempotent-Replayed"
+	idempotencyKeyLengthMax   = 255
+)
+
+// response writer, collecting response instead of sending it
+// used to process responses before sending them (batch operations, idempotent requests)
+type bufferedResponseWriter struct {
+	body   bytes.Buffer
+	header http.Header
+	status int
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// returns hash identifying a request, to recognize reuse of idempotency keys for different requests
+func getIdempotencyRequestHash(method string, url string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(url))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// claims idempotency key for the login within the current transaction
+// concurrent requests with the same key wait until the first transaction is done
+// returns whether key was claimed (request must be executed), otherwise the stored request hash and response
+func claimIdempotencyKey_tx(ctx context.Context, tx pgx.Tx, loginId int64, key string,
+	requestHash string) (bool, string, int, []byte, error) {
+
+	now := tools.GetTimeUnix()
+
+	// expired keys can be reused
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM instance.api_idempotency
+		WHERE login_id     = $1
+		AND   key          = $2
+		AND   date_created < $3
+	`, loginId, key, now-(3600*int64(config.GetUint64("apiIdempotencyHours")))); err != nil {
+		return false, "", 0, nil, err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO instance.api_idempotency (login_id, key, request_hash, date_created)
+		VALUES ($1,$2,$3,$4)
+		ON CONFLICT DO NOTHING
+	`, loginId, key, requestHash, now)
+	if err != nil {
+		return false, "", 0, nil, err
+	}
+	if tag.RowsAffected() == 1 {
+		return true, "", 0, nil, nil
+	}
+
+	var requestHashStored string
+	var responseCode pgtype.Int2
+	var responseBody pgtype.Text
+	if err := tx.QueryRow(ctx, `
+		SELECT request_hash, response_code, response_body
+		FROM instance.api_idempotency
+		WHERE login_id = $1
+		AND   key      = $2
+	`, loginId, key).Scan(&requestHashStored, &responseCode, &responseBody); err != nil {
+		return false, "", 0, nil, err
+	}
+	return false, requestHashStored, int(responseCode.Int16), []byte(responseBody.String), nil
+}
+
+// stores response for claimed idempotency key
+func setIdempotencyResponse_tx(ctx context.Context, tx pgx.Tx, loginId int64, key string,
+	responseCode int, responseBody []byte) error {
+
+	_, err := tx.Exec(ctx, `
+		UPDATE instance.api_idempotency
+		SET response_code = $1, response_body = $2
+		WHERE login_id = $3
+		AND   key      = $4
+	`, responseCode, string(responseBody), loginId, key)
+	return err
+}
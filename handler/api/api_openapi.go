@@ -0,0 +1,642 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"r3/cache"
+	"r3/config"
+	"r3/handler"
+	"r3/login/login_tokenApi"
+	"r3/schema"
+	"r3/types"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPI 3.1 document, only the parts that are used to describe module APIs
+type openApiDoc struct {
+	OpenApi    string                                 `json:"openapi"`
+	Info       openApiInfo                            `json:"info"`
+	Servers    []openApiServer                        `json:"servers"`
+	Paths      map[string]map[string]openApiOperation `json:"paths"`
+	Components openApiComponents                      `json:"components"`
+	Security   []map[string][]string                  `json:"security"`
+	Tags       []openApiTag                           `json:"tags,omitempty"`
+}
+type openApiInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+type openApiServer struct {
+	Url string `json:"url"`
+}
+type openApiTag struct {
+	Name string `json:"name"`
+}
+type openApiComponents struct {
+	SecuritySchemes map[string]openApiSecurityScheme `json:"securitySchemes"`
+	Schemas         map[string]*openApiSchema        `json:"schemas"`
+}
+type openApiSecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat"`
+}
+type openApiOperation struct {
+	OperationId string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags"`
+	Parameters  []openApiParameter         `json:"parameters,omitempty"`
+	RequestBody *openApiRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openApiResponse `json:"responses"`
+}
+type openApiParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"` // path, query
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Schema      *openApiSchema `json:"schema"`
+}
+type openApiRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openApiMediaType `json:"content"`
+}
+type openApiResponse struct {
+	Description string                      `json:"description"`
+	Headers     map[string]openApiHeader    `json:"headers,omitempty"`
+	Content     map[string]openApiMediaType `json:"content,omitempty"`
+}
+type openApiHeader struct {
+	Description string         `json:"description,omitempty"`
+	Schema      *openApiSchema `json:"schema"`
+}
+type openApiMediaType struct {
+	Schema *openApiSchema `json:"schema"`
+}
+type openApiSchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 interface{}               `json:"type,omitempty"` // string or []string (for nullable types)
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Default              interface{}               `json:"default,omitempty"`
+	Minimum              *int                      `json:"minimum,omitempty"`
+	Maximum              *int                      `json:"maximum,omitempty"`
+	MaxLength            int                       `json:"maxLength,omitempty"`
+	Enum                 []interface{}             `json:"enum,omitempty"`
+	Items                *openApiSchema            `json:"items,omitempty"`
+	PrefixItems          []*openApiSchema          `json:"prefixItems,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+	OneOf                []*openApiSchema          `json:"oneOf,omitempty"`
+	Properties           map[string]*openApiSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}               `json:"additionalProperties,omitempty"` // bool or schema
+	Required             []string                  `json:"required,omitempty"`
+}
+
+const (
+	openApiFileName = "openapi.json"
+	openApiVersion  = "3.1.0"
+)
+
+// serves OpenAPI documents for module APIs, such as:
+// GET /api/openapi.json                            (all APIs accessible to login)
+// GET /api/lsw_invoices/contracts/v1/openapi.json (single API)
+func handlerOpenApi(w http.ResponseWriter, r *http.Request, loginId int64,
+	tokenApi *types.LoginTokenApi, abort func(httpCode int, errToLog error, errMsgUser string)) {
+
+	if r.Method != "GET" {
+		abort(http.StatusBadRequest, nil, "invalid HTTP method, allowed: GET")
+		return
+	}
+
+	// 0 is empty, 1 = "api", 2 = MODULE_NAME, 3 = API_NAME, 4 = API_VERSION, 5 = "openapi.json"
+	elements := strings.Split(r.URL.Path, "/")
+	if len(elements) != 3 && len(elements) != 6 {
+		abort(http.StatusBadRequest, nil, fmt.Sprintf("invalid URL, expected: /api/%s or /api/APP_NAME/API_NAME/VERSION/%s",
+			openApiFileName, openApiFileName))
+
+		return
+	}
+
+	access, err := cache.GetAccessById(loginId)
+	if err != nil {
+		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+		return
+	}
+	languageCode, err := getLoginLanguageCode(loginId)
+	if err != nil {
+		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+		return
+	}
+
+	cache.Schema_mx.RLock()
+	defer cache.Schema_mx.RUnlock()
+
+	appName, _ := config.GetAppName()
+	apis := make([]types.Api, 0)
+	doc := openApiDoc{
+		OpenApi: openApiVersion,
+		Info: openApiInfo{
+			Title:   fmt.Sprintf("%s REST APIs", appName),
+			Version: config.GetAppVersion().Full,
+		},
+	}
+
+	if len(elements) == 3 {
+		// instance-wide index, all APIs the login has access to
+		for apiId := range access.Api {
+			if tokenApi != nil && !login_tokenApi.IsApiAllowed(*tokenApi, apiId) {
+				continue
+			}
+			api, exists := cache.ApiIdMap[apiId]
+			if exists && api.Query.RelationId.Valid {
+				apis = append(apis, api)
+			}
+		}
+		sort.Slice(apis, func(i, j int) bool {
+			return getApiPath(apis[i]) < getApiPath(apis[j])
+		})
+	} else {
+		version, err := strconv.Atoi(strings.TrimPrefix(elements[4], "v"))
+		if err != nil {
+			abort(http.StatusBadRequest, err, fmt.Sprintf("invalid API version format '%s', expected: 'v12'", elements[4]))
+			return
+		}
+
+		apiId, exists := cache.ModuleApiNameMapId[elements[2]][fmt.Sprintf("%s.v%d", elements[3], version)]
+		if !exists {
+			abort(http.StatusNotFound, nil, fmt.Sprintf("API '%s.%s' (v%d) does not exist", elements[2], elements[3], version))
+			return
+		}
+		if _, exists := access.Api[apiId]; !exists || (tokenApi != nil && !login_tokenApi.IsApiAllowed(*tokenApi, apiId)) {
+			abort(http.StatusForbidden, nil, handler.ErrUnauthorized)
+			return
+		}
+		api := cache.ApiIdMap[apiId]
+		if !api.Query.RelationId.Valid {
+			abort(http.StatusServiceUnavailable, nil, "query has no base relation")
+			return
+		}
+		apis = append(apis, api)
+
+		mod := cache.ModuleIdMap[api.ModuleId]
+		doc.Info.Title = fmt.Sprintf("%s.%s", mod.Name, api.Name)
+		doc.Info.Description = api.Comment.String
+		doc.Info.Version = fmt.Sprintf("v%d", api.Version)
+	}
+
+	if err := addOpenApiPaths(&doc, apis, languageCode); err != nil {
+		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+		return
+	}
+
+	payloadJson, err := json.Marshal(doc)
+	if err != nil {
+		abort(http.StatusServiceUnavailable, err, handler.ErrGeneral)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
+// adds paths, tags and components for given APIs to OpenAPI document
+// schema cache must be read locked by caller
+func addOpenApiPaths(doc *openApiDoc, apis []types.Api, languageCode string) error {
+
+	doc.Servers = []openApiServer{{Url: "/api"}}
+	doc.Paths = make(map[string]map[string]openApiOperation)
+	doc.Security = []map[string][]string{{"bearerAuth": {}}}
+	doc.Tags = make([]openApiTag, 0)
+	doc.Components = openApiComponents{
+		SecuritySchemes: map[string]openApiSecurityScheme{
+			"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		},
+		Schemas: map[string]*openApiSchema{
+			"error": {
+				Type:       "object",
+				Properties: map[string]*openApiSchema{"error": {Type: "string"}},
+				Required:   []string{"error"},
+			},
+			"file": {
+				Type: "object",
+				Properties: map[string]*openApiSchema{
+					"id":      {Type: "string", Format: "uuid"},
+					"name":    {Type: "string"},
+					"hash":    {Type: "string"},
+					"size":    {Type: "integer", Format: "int64", Description: "file size in KB"},
+					"version": {Type: "integer", Format: "int64"},
+					"changed": {Type: "integer", Format: "int64", Description: "unix timestamp"},
+				},
+			},
+		},
+	}
+
+	tagsAdded := make([]string, 0)
+	for _, api := range apis {
+		mod, exists := cache.ModuleIdMap[api.ModuleId]
+		if !exists {
+			return handler.ErrSchemaUnknownModule(api.ModuleId)
+		}
+		if !slices.Contains(tagsAdded, mod.Name) {
+			tagsAdded = append(tagsAdded, mod.Name)
+			doc.Tags = append(doc.Tags, openApiTag{Name: mod.Name})
+		}
+
+		// use module language for column captions, fall back to main language
+		languageCodeModule := languageCode
+		if !slices.Contains(mod.Languages, languageCode) {
+			languageCodeModule = mod.LanguageMain
+		}
+
+		rowSchemaName, rowVerboseSchemaName, err := addOpenApiRowSchemas(doc, api, languageCodeModule)
+		if err != nil {
+			return err
+		}
+
+		path := getApiPath(api)
+		pathRecord := fmt.Sprintf("%s/{recordId}", path)
+		opIdPrefix := fmt.Sprintf("%s_%s_v%d", mod.Name, api.Name, api.Version)
+		tags := []string{mod.Name}
+
+		paramRecordId := openApiParameter{
+			Name:        "recordId",
+			In:          "path",
+			Description: "ID of the record on the base relation (index 0)",
+			Required:    true,
+			Schema:      &openApiSchema{Type: "integer", Format: "int64", Minimum: intPtr(1)},
+		}
+		paramVerbose := openApiParameter{
+			Name:        "verbose",
+			In:          "query",
+			Description: "1 = rows are objects keyed by relation index and column name, 0 = rows are value arrays in column order",
+			Schema:      &openApiSchema{Type: "integer", Enum: []interface{}{0, 1}, Default: boolToInt(api.VerboseDef)},
+		}
+
+		paramIdempotencyKey := openApiParameter{
+			Name:        headerIdempotencyKey,
+			In:          "header",
+			Description: "Unique key of a write request, repeated requests with the same key return the stored response instead of being executed again",
+			Required:    false,
+			Schema:      &openApiSchema{Type: "string", MaxLength: idempotencyKeyLengthMax},
+		}
+
+		// row and record responses support both output modes
+		rowsSchema := &openApiSchema{Type: "array", Items: &openApiSchema{OneOf: []*openApiSchema{
+			{Ref: getOpenApiSchemaRef(rowSchemaName)},
+			{Ref: getOpenApiSchemaRef(rowVerboseSchemaName)},
+		}}}
+
+		if api.HasGet {
+			if _, exists := doc.Paths[path]; !exists {
+				doc.Paths[path] = make(map[string]openApiOperation)
+			}
+			if _, exists := doc.Paths[pathRecord]; !exists {
+				doc.Paths[pathRecord] = make(map[string]openApiOperation)
+			}
+			doc.Paths[path]["get"] = openApiOperation{
+				OperationId: fmt.Sprintf("%s_get", opIdPrefix),
+				Summary:     fmt.Sprintf("Get records from %s.%s", mod.Name, api.Name),
+				Description: api.Comment.String,
+				Tags:        tags,
+				Parameters: []openApiParameter{
+					{
+						Name:     "limit",
+						In:       "query",
+						Required: false,
+						Schema: &openApiSchema{Type: "integer", Minimum: intPtr(0),
+							Maximum: intPtr(api.LimitMax), Default: api.LimitDef},
+					},
+					{
+						Name:     "offset",
+						In:       "query",
+						Required: false,
+						Schema:   &openApiSchema{Type: "integer", Minimum: intPtr(0), Default: 0},
+					},
+					paramVerbose,
+					{
+						Name:        getterColumns,
+						In:          "query",
+						Description: "Comma separated list of columns to return (column name or position), in requested order",
+						Required:    false,
+						Schema:      &openApiSchema{Type: "string"},
+					},
+					{
+						Name:        getterCursor,
+						In:          "query",
+						Description: "Keyset pagination, use empty value for the first page and the value of header " + headerNextCursor + " for following pages, cannot be combined with offset",
+						Required:    false,
+						Schema:      &openApiSchema{Type: "string"},
+					},
+					{
+						Name:        "stream",
+						In:          "query",
+						Description: "1 = rows are streamed as chunked JSON array without total count (also enabled with 'Accept: " + contentTypeNdjson + "' for newline delimited JSON), next cursor is sent as HTTP trailer",
+						Required:    false,
+						Schema:      &openApiSchema{Type: "integer", Enum: []interface{}{0, 1}, Default: 0},
+					},
+					{
+						Name:        getterOrderBy,
+						In:          "query",
+						Description: "Comma separated list of columns to sort by (column name or position), with optional direction (':asc' or ':desc'), replaces default sorting",
+						Required:    false,
+						Schema:      &openApiSchema{Type: "string"},
+					},
+				},
+				Responses: getOpenApiResponses("records", rowsSchema),
+			}
+
+			// filter getters for all filterable columns
+			colRefs := getColumnRefs(api.Columns, languageCodeModule)
+			for i, column := range api.Columns {
+				if column.SubQuery || column.Aggregator.Valid {
+					continue
+				}
+				atr, exists := cache.AttributeIdMap[column.AttributeId]
+				if !exists || atr.Encrypted || atr.Content == "files" {
+					continue
+				}
+				op := doc.Paths[path]["get"]
+				op.Parameters = append(op.Parameters, openApiParameter{
+					Name:        fmt.Sprintf("%s%s", getterFilter, colRefs[i]),
+					In:          "query",
+					Description: "Filter by column value, format: OPERATOR:VALUE, operators: eq, ne, lt, le, gt, ge, like (contains, case insensitive), in (comma separated values), null (true/false)",
+					Required:    false,
+					Schema:      &openApiSchema{Type: "string"},
+				})
+				doc.Paths[path]["get"] = op
+			}
+			ok := doc.Paths[path]["get"].Responses["200"]
+			ok.Headers = map[string]openApiHeader{
+				headerTotalCount: {
+					Description: "Total number of records matching the request, regardless of limit and offset (not sent if streamed)",
+					Schema:      &openApiSchema{Type: "integer"},
+				},
+				headerNextCursor: {
+					Description: "Cursor for the next page, only sent with keyset pagination if more records might follow",
+					Schema:      &openApiSchema{Type: "string"},
+				},
+			}
+			ok.Content[contentTypeNdjson] = openApiMediaType{Schema: rowsSchema.Items}
+			doc.Paths[path]["get"].Responses["200"] = ok
+			doc.Paths[pathRecord]["get"] = openApiOperation{
+				OperationId: fmt.Sprintf("%s_get_record", opIdPrefix),
+				Summary:     fmt.Sprintf("Get single record from %s.%s", mod.Name, api.Name),
+				Description: api.Comment.String,
+				Tags:        tags,
+				Parameters:  []openApiParameter{paramRecordId, paramVerbose},
+				Responses:   getOpenApiResponses("records, empty if not found or not accessible", rowsSchema),
+			}
+		}
+
+		if api.HasPost {
+			idsSchema := &openApiSchema{
+				Type:                 "object",
+				AdditionalProperties: &openApiSchema{Type: "integer", Format: "int64"},
+			}
+			if _, exists := doc.Paths[path]; !exists {
+				doc.Paths[path] = make(map[string]openApiOperation)
+			}
+			doc.Paths[path]["post"] = openApiOperation{
+				OperationId: fmt.Sprintf("%s_post", opIdPrefix),
+				Summary:     fmt.Sprintf("Create or update record via %s.%s", mod.Name, api.Name),
+				Description: "Records are looked up by the lookup attributes defined for the API. If found, they are updated, otherwise they are created. Multiple rows can be sent as array of rows, they are imported in a single transaction.",
+				Tags:        tags,
+				Parameters: []openApiParameter{paramVerbose, paramIdempotencyKey, {
+					Name:        "bulk",
+					In:          "query",
+					Description: "1 = body is an array of rows, 0 = body is a single row; if not given, multiple rows are detected as array of objects (verbose) or as array of arrays with one value per column (non-verbose)",
+					Required:    false,
+					Schema:      &openApiSchema{Type: "integer", Enum: []interface{}{0, 1}},
+				}},
+				RequestBody: &openApiRequestBody{
+					Required: true,
+					Content: map[string]openApiMediaType{"application/json": {
+						Schema: &openApiSchema{OneOf: []*openApiSchema{
+							{Ref: getOpenApiSchemaRef(rowSchemaName)},
+							{Ref: getOpenApiSchemaRef(rowVerboseSchemaName)},
+							{Type: "array", Items: &openApiSchema{Ref: getOpenApiSchemaRef(rowSchemaName)}},
+							{Type: "array", Items: &openApiSchema{Ref: getOpenApiSchemaRef(rowVerboseSchemaName)}},
+						}},
+					}},
+				},
+				Responses: getOpenApiResponses("IDs of affected records, keyed by relation index (array for multiple rows)",
+					&openApiSchema{OneOf: []*openApiSchema{idsSchema, {Type: "array", Items: idsSchema}}}),
+			}
+		}
+
+		if api.HasPost {
+			if _, exists := doc.Paths[pathRecord]; !exists {
+				doc.Paths[pathRecord] = make(map[string]openApiOperation)
+			}
+			recordSchema := &openApiSchema{OneOf: []*openApiSchema{
+				{Ref: getOpenApiSchemaRef(rowSchemaName)},
+				{Ref: getOpenApiSchemaRef(rowVerboseSchemaName)},
+			}}
+			doc.Paths[pathRecord]["patch"] = openApiOperation{
+				OperationId: fmt.Sprintf("%s_patch", opIdPrefix),
+				Summary:     fmt.Sprintf("Update given values of record via %s.%s", mod.Name, api.Name),
+				Description: "Only given column values are updated. In non-verbose mode, values are keyed by column position ({\"0\":123}).",
+				Tags:        tags,
+				Parameters:  []openApiParameter{paramRecordId, paramVerbose, paramIdempotencyKey},
+				RequestBody: &openApiRequestBody{
+					Required: true,
+					Content: map[string]openApiMediaType{"application/json": {
+						Schema: &openApiSchema{OneOf: []*openApiSchema{
+							{
+								Type:                 "object",
+								Description:          "column position -> value",
+								AdditionalProperties: true,
+							},
+							{Ref: getOpenApiSchemaRef(rowVerboseSchemaName)},
+						}},
+					}},
+				},
+				Responses: getOpenApiResponses("changed record", recordSchema),
+			}
+			doc.Paths[pathRecord]["put"] = openApiOperation{
+				OperationId: fmt.Sprintf("%s_put", opIdPrefix),
+				Summary:     fmt.Sprintf("Replace record via %s.%s", mod.Name, api.Name),
+				Description: "All column values are replaced. Values of joined relations are ignored, if they must not be updated.",
+				Tags:        tags,
+				Parameters:  []openApiParameter{paramRecordId, paramVerbose, paramIdempotencyKey},
+				RequestBody: &openApiRequestBody{
+					Required: true,
+					Content:  map[string]openApiMediaType{"application/json": {Schema: recordSchema}},
+				},
+				Responses: getOpenApiResponses("changed record", recordSchema),
+			}
+		}
+
+		if api.HasDelete {
+			if _, exists := doc.Paths[pathRecord]; !exists {
+				doc.Paths[pathRecord] = make(map[string]openApiOperation)
+			}
+			doc.Paths[pathRecord]["delete"] = openApiOperation{
+				OperationId: fmt.Sprintf("%s_delete", opIdPrefix),
+				Summary:     fmt.Sprintf("Delete record via %s.%s", mod.Name, api.Name),
+				Description: "Deletes the record and its joined records, if the relation join has DELETE enabled.",
+				Tags:        tags,
+				Parameters:  []openApiParameter{paramRecordId, paramIdempotencyKey},
+				Responses:   getOpenApiResponses("records deleted", nil),
+			}
+		}
+	}
+	return nil
+}
+
+// adds row schemas (non-verbose & verbose) of API to OpenAPI document components
+// returns names of both schemas
+func addOpenApiRowSchemas(doc *openApiDoc, api types.Api, languageCodeModule string) (string, string, error) {
+
+	mod := cache.ModuleIdMap[api.ModuleId]
+	name := fmt.Sprintf("%s.%s.v%d", mod.Name, api.Name, api.Version)
+	nameVerbose := fmt.Sprintf("%s.verbose", name)
+
+	colRefs := getColumnRefs(api.Columns, languageCodeModule)
+	colCount := len(api.Columns)
+
+	rowSchema := &openApiSchema{
+		Type:        "array",
+		Description: "row values in column order",
+		PrefixItems: make([]*openApiSchema, 0),
+		MinItems:    intPtr(colCount),
+		MaxItems:    intPtr(colCount),
+	}
+	rowVerboseSchema := &openApiSchema{
+		Type:        "object",
+		Description: "row values keyed by relation index (with optional relation name) and column name",
+		Properties:  make(map[string]*openApiSchema),
+	}
+
+	for i, column := range api.Columns {
+		atr, exists := cache.AttributeIdMap[column.AttributeId]
+		if !exists {
+			return "", "", handler.ErrSchemaUnknownAttribute(column.AttributeId)
+		}
+		rel, exists := cache.RelationIdMap[atr.RelationId]
+		if !exists {
+			return "", "", handler.ErrSchemaUnknownRelation(atr.RelationId)
+		}
+
+		colSchema := getOpenApiColumnSchema(column, atr, colRefs[i])
+		rowSchema.PrefixItems = append(rowSchema.PrefixItems, colSchema)
+
+		relRef := fmt.Sprintf("%d(%s)", column.Index, rel.Name)
+		if _, exists := rowVerboseSchema.Properties[relRef]; !exists {
+			rowVerboseSchema.Properties[relRef] = &openApiSchema{
+				Type:       "object",
+				Properties: make(map[string]*openApiSchema),
+			}
+		}
+		rowVerboseSchema.Properties[relRef].Properties[colRefs[i]] = colSchema
+	}
+	doc.Components.Schemas[name] = rowSchema
+	doc.Components.Schemas[nameVerbose] = rowVerboseSchema
+	return name, nameVerbose, nil
+}
+
+// returns JSON schema for API column value, based on its attribute
+func getOpenApiColumnSchema(column types.Column, atr types.Attribute, colRef string) *openApiSchema {
+
+	s := &openApiSchema{Description: colRef}
+
+	switch atr.Content {
+	case "integer":
+		s.Type, s.Format = "integer", "int32"
+	case "bigint":
+		s.Type, s.Format = "integer", "int64"
+	case "numeric":
+		s.Type = "number"
+	case "real":
+		s.Type, s.Format = "number", "float"
+	case "double precision":
+		s.Type, s.Format = "number", "double"
+	case "boolean":
+		s.Type = "boolean"
+	case "uuid":
+		s.Type, s.Format = "string", "uuid"
+	case "varchar":
+		s.Type, s.MaxLength = "string", atr.Length
+	case "text", "regconfig":
+		s.Type = "string"
+	case "files":
+		s.Type, s.Items = "array", &openApiSchema{Ref: getOpenApiSchemaRef("file")}
+	default:
+		if schema.IsContentRelationship(atr.Content) {
+			s.Type, s.Format = "integer", "int64"
+			s.Description = fmt.Sprintf("%s, record ID", colRef)
+		}
+	}
+
+	switch atr.ContentUse {
+	case "date", "datetime", "time":
+		s.Description = fmt.Sprintf("%s, %s as unix timestamp", colRef, atr.ContentUse)
+	}
+
+	// aggregated values
+	if column.Aggregator.Valid {
+		switch column.Aggregator.String {
+		case "array":
+			s = &openApiSchema{Type: "array", Description: colRef, Items: s}
+		case "count":
+			s = &openApiSchema{Type: "integer", Format: "int64", Description: colRef}
+		case "avg":
+			s = &openApiSchema{Type: "number", Description: colRef}
+		case "list":
+			s = &openApiSchema{Type: "string", Description: colRef}
+		}
+	}
+
+	// values can be NULL if nullable, from joined relations (outer joins) or from sub queries
+	if s.Type != nil && (atr.Nullable || column.Index != 0 || column.SubQuery || column.Aggregator.Valid) {
+		s.Type = []string{s.Type.(string), "null"}
+	}
+	return s
+}
+
+func getOpenApiResponses(descriptionOk string, schemaOk *openApiSchema) map[string]openApiResponse {
+	responseErr := func(description string) openApiResponse {
+		return openApiResponse{
+			Description: description,
+			Content: map[string]openApiMediaType{"application/json": {
+				Schema: &openApiSchema{Ref: getOpenApiSchemaRef("error")},
+			}},
+		}
+	}
+
+	ok := openApiResponse{Description: descriptionOk}
+	if schemaOk != nil {
+		ok.Content = map[string]openApiMediaType{"application/json": {Schema: schemaOk}}
+	}
+	return map[string]openApiResponse{
+		"200": ok,
+		"400": responseErr("invalid request"),
+		"401": responseErr("authentication failed"),
+		"403": responseErr("no access to API"),
+		"404": responseErr("API does not exist"),
+		"409": responseErr("data could not be changed"),
+		"503": responseErr("service unavailable"),
+	}
+}
+
+func getApiPath(api types.Api) string {
+	return fmt.Sprintf("/%s/%s/v%d", cache.ModuleIdMap[api.ModuleId].Name, api.Name, api.Version)
+}
+func getOpenApiSchemaRef(name string) string {
+	return fmt.Sprintf("#/components/schemas/%s", name)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"r3/data"
+	"r3/types"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	contentTypeNdjson = "application/x-ndjson"
+	streamFlushRows   = 100 // flush response after this many rows
+)
+
+// returns whether data GET request asks for a streamed response (NDJSON or getter 'stream=1')
+// streamed responses use their own database timeout, as large results take longer to be sent
+func isStreamRequest(r *http.Request) bool {
+	if r.Method != "GET" {
+		return false
+	}
+	if strings.Contains(r.Header.Get("Accept"), contentTypeNdjson) {
+		return true
+	}
+	value := r.URL.Query()["stream"]
+	if len(value) != 1 {
+		return false
+	}
+	n, err := strconv.Atoi(value[0])
+	return err == nil && n == 1
+}
+
+// writes data GET results directly to response while reading them from the database
+// rows are written either as newline delimited JSON (NDJSON) or as a JSON array, sent in chunks
+// with keyset pagination, the cursor for the next page is sent as HTTP trailer
+// the schema cache is not accessed while rows are written, relation references must be prepared (verbose only)
+// returns whether the response was already started (errors cannot be sent as regular response anymore)
+func streamRows_tx(ctx context.Context, tx pgx.Tx, w http.ResponseWriter, dataGet types.DataGet,
+	loginId int64, colRefs []string, relRefs []string, columnCount int, verbose bool, ndjson bool,
+	ks *keyset) (bool, error) {
+
+	flusher, canFlush := w.(http.Flusher)
+
+	var started bool
+	var start = func() {
+		if ndjson {
+			w.Header().Set("Content-Type", contentTypeNdjson)
+		}
+		if ks != nil {
+			w.Header().Set("Trailer", headerNextCursor)
+		}
+		w.WriteHeader(http.StatusOK)
+		if !ndjson {
+			w.Write([]byte("["))
+		}
+		started = true
+	}
+
+	var rowCount int
+	var valuesLast []interface{}
+	var query string
+	err := data.GetEach_tx(ctx, tx, dataGet, loginId, &query, func(result types.DataGetResult) error {
+
+		// keyset values are not part of output
+		valuesLast = result.Values
+		result.Values = result.Values[:columnCount]
+
+		rowJson, err := json.Marshal(getRow(colRefs, relRefs, result, verbose))
+		if err != nil {
+			return err
+		}
+
+		if !started {
+			start()
+		}
+		if ndjson {
+			rowJson = append(rowJson, '\n')
+		} else if rowCount != 0 {
+			w.Write([]byte(","))
+		}
+		if _, err := w.Write(rowJson); err != nil {
+			return err
+		}
+
+		rowCount++
+		if canFlush && rowCount%streamFlushRows == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return started, err
+	}
+
+	if !started {
+		start()
+	}
+	if !ndjson {
+		w.Write([]byte("]"))
+	}
+
+	// full page, more records might follow
+	if ks != nil && dataGet.Limit != 0 && rowCount == dataGet.Limit {
+		cursor, err := ks.getCursor(valuesLast)
+		if err != nil {
+			return started, err
+		}
+		w.Header().Set(headerNextCursor, cursor)
+	}
+	return started, nil
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"r3/cache"
+	"r3/data"
+	"r3/handler"
+	"r3/types"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// updates an existing record (and its joined records) via API
+// PATCH: only given column values are updated, columns must be updatable (join with ApplyUpdate & existing record)
+// PUT:   all column values are replaced, values for non-updatable joins are ignored (same as POST)
+// returns the changed record (in the same format as GET) and HTTP status code in case of error
+func update_tx(ctx context.Context, tx pgx.Tx, body io.Reader, api types.Api, recordId int64,
+	loginId int64, languageCode string, languageCodeModule string, verbose bool,
+	partial bool) ([]byte, int, error) {
+
+	if recordId < 1 {
+		return nil, http.StatusBadRequest, errors.New("record ID must be > 0")
+	}
+
+	for _, column := range api.Columns {
+		if column.SubQuery || column.Aggregator.Valid {
+			return nil, http.StatusBadRequest, errors.New("PATCH/PUT do not support sub queries or aggregated columns")
+		}
+	}
+
+	values, given, err := parseValues(body, api.Columns, verbose, partial, languageCodeModule)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	// get current record, with record IDs of joined relations and update permissions from relation policies
+	dataGet := getDataGet(api, api.Columns, loginId, languageCode, recordId)
+	dataGet.GetPerm = true
+
+	var query string
+	results, _, err := data.Get_tx(ctx, tx, dataGet, loginId, &query)
+	if err != nil {
+		if err.Error() == handler.ErrUnauthorized {
+			return nil, http.StatusUnauthorized, err
+		}
+		return nil, http.StatusServiceUnavailable, err
+	}
+	if len(results) != 1 {
+		return nil, http.StatusNotFound, fmt.Errorf("record with ID %d does not exist", recordId)
+	}
+	result := results[0]
+
+	// prepare data SET structure for all joined relations with existing records
+	dataSetsByIndex := make(map[int]types.DataSet)
+	for _, join := range api.Query.Joins {
+
+		recordIdJoin, exists := result.IndexRecordIds[join.Index]
+		if !exists || recordIdJoin == nil {
+			continue // joined relation has no record
+		}
+		recordIdJoinInt, err := getRecordIdFromResult(recordIdJoin)
+		if err != nil {
+			return nil, http.StatusServiceUnavailable, err
+		}
+		dataSetsByIndex[join.Index] = types.DataSet{
+			RelationId:  join.RelationId,
+			AttributeId: join.AttributeId.Bytes,
+			IndexFrom:   join.IndexFrom,
+			RecordId:    recordIdJoinInt,
+			Attributes:  make([]types.DataSetAttribute, 0),
+		}
+	}
+
+	// assign column values to relation records
+	for i, column := range api.Columns {
+		if !given[i] {
+			continue
+		}
+
+		atr, exists := cache.AttributeIdMap[column.AttributeId]
+		if !exists {
+			return nil, http.StatusServiceUnavailable, handler.ErrSchemaUnknownAttribute(column.AttributeId)
+		}
+		if atr.Encrypted {
+			return nil, http.StatusBadRequest, errors.New("cannot handle value for encrypted attribute")
+		}
+
+		// base relation is always updated, joined relations only if enabled
+		updatable := column.Index == 0
+		for _, join := range api.Query.Joins {
+			if join.Index == column.Index && join.Index != 0 {
+				updatable = join.ApplyUpdate
+				break
+			}
+		}
+		dataSet, exists := dataSetsByIndex[column.Index]
+
+		if !updatable || !exists {
+			if !partial {
+				continue
+			}
+			if !updatable {
+				return nil, http.StatusBadRequest, fmt.Errorf("attribute '%s' of relation index %d must not be updated",
+					atr.Name, column.Index)
+			}
+			return nil, http.StatusBadRequest, fmt.Errorf("attribute '%s' cannot be updated, relation index %d has no record",
+				atr.Name, column.Index)
+		}
+
+		// check relation policies
+		if slices.Contains(result.IndexesPermNoSet, column.Index) {
+			return nil, http.StatusForbidden, errors.New(handler.ErrUnauthorized)
+		}
+
+		dataSet.Attributes = append(dataSet.Attributes, types.DataSetAttribute{
+			AttributeId:   column.AttributeId,
+			AttributeIdNm: pgtype.UUID{},
+			OutsideIn:     false,
+			Value:         values[i],
+		})
+		dataSetsByIndex[column.Index] = dataSet
+	}
+
+	// remove data SETs without changes
+	for index, dataSet := range dataSetsByIndex {
+		if len(dataSet.Attributes) == 0 {
+			delete(dataSetsByIndex, index)
+		}
+	}
+
+	if len(dataSetsByIndex) != 0 {
+		if _, err := data.Set_tx(ctx, tx, dataSetsByIndex, loginId); err != nil {
+			if err.Error() == handler.ErrUnauthorized {
+				return nil, http.StatusForbidden, err
+			}
+			return nil, http.StatusConflict, err
+		}
+	}
+
+	// return changed record
+	dataGet.GetPerm = false
+	results, _, err = data.Get_tx(ctx, tx, dataGet, loginId, &query)
+	if err != nil {
+		return nil, http.StatusServiceUnavailable, err
+	}
+	rows := getRows(api.Columns, getColumnRefs(api.Columns, languageCodeModule), results, verbose)
+	if len(rows) != 1 {
+		// record is not visible anymore after update (query filters/policies)
+		return []byte("null"), 0, nil
+	}
+
+	payloadJson, err := json.Marshal(rows[0])
+	if err != nil {
+		return nil, http.StatusServiceUnavailable, err
+	}
+	return payloadJson, 0, nil
+}
+
+// record IDs in data GET results can be returned as different integer types
+func getRecordIdFromResult(v interface{}) (int64, error) {
+	switch id := v.(type) {
+	case int32:
+		return int64(id), nil
+	case int64:
+		return id, nil
+	}
+	return 0, fmt.Errorf("invalid record ID type %T", v)
+}
@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"r3/bruteforce"
 	"r3/handler"
 	"r3/login/login_auth"
+	"r3/types"
 
+	"github.com/gofrs/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -46,9 +49,18 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	var loginId int64
 	var isAdmin bool
 	var noAuth bool
+	var sessionId uuid.UUID
 
-	token, _, mfaTokens, err := login_auth.User(req.Username, req.Password,
-		pgtype.Int4{}, pgtype.Text{}, &loginId, &isAdmin, &noAuth)
+	address, _, _ := net.SplitHostPort(r.RemoteAddr)
+	client := types.LoginSessionClient{
+		Address:   address,
+		Device:    "api",
+		UserAgent: r.UserAgent(),
+	}
+
+	token, _, mfaTokens, mfaWebauthn, err := login_auth.User(req.Username, req.Password, "",
+		pgtype.Int4{}, pgtype.Text{}, types.LoginWebauthnAssertion{}, client,
+		&loginId, &isAdmin, &noAuth, &sessionId)
 
 	if err != nil {
 		handler.AbortRequestWithCode(w, context, http.StatusUnauthorized,
@@ -58,7 +70,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(mfaTokens) != 0 {
+	if len(mfaTokens) != 0 || mfaWebauthn != nil {
 		handler.AbortRequestWithCode(w, context, http.StatusBadRequest,
 			nil, "failed to authenticate, MFA is currently not supported")
 
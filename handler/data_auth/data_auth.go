@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"r3/bruteforce"
 	"r3/handler"
 	"r3/login/login_auth"
+	"r3/types"
 
+	"github.com/gofrs/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -44,11 +47,20 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	var loginId int64
 	var isAdmin bool
 	var noAuth bool
+	var sessionId uuid.UUID
 	var mfaTokenId = pgtype.Int4{}
 	var mfaTokenPin = pgtype.Text{}
 
-	token, _, _, err := login_auth.User(req.Username, req.Password,
-		mfaTokenId, mfaTokenPin, &loginId, &isAdmin, &noAuth)
+	address, _, _ := net.SplitHostPort(r.RemoteAddr)
+	client := types.LoginSessionClient{
+		Address:   address,
+		Device:    "api",
+		UserAgent: r.UserAgent(),
+	}
+
+	token, _, _, _, err := login_auth.User(req.Username, req.Password, "",
+		mfaTokenId, mfaTokenPin, types.LoginWebauthnAssertion{}, client,
+		&loginId, &isAdmin, &noAuth, &sessionId)
 
 	if err != nil {
 		handler.AbortRequest(w, context, err, handler.ErrAuthFailed)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"mime/multipart"
 	"net/http"
+	"r3/config"
 	"r3/log"
 	"strconv"
 
@@ -49,6 +50,12 @@ func ReadGetterFromUrl(r *http.Request, name string) (string, error) {
 	}
 	return keys[0], nil
 }
+
+// returns base URL of this instance from configured public host name
+// request headers (Host, X-Forwarded-Proto) are client controlled and must not be used for URLs checked by other parties
+func GetPublicBaseUrl() string {
+	return fmt.Sprintf("https://%s", config.GetString("publicHostName"))
+}
 func SetNoImage(v []byte) {
 	NoImage = v
 }
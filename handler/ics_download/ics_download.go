@@ -61,8 +61,8 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 	// authenticate via fixed token
 	var languageCode string
-	var tokenNotUsed string
-	if err := login_auth.TokenFixed(loginId, "ics", tokenFixed, &languageCode, &tokenNotUsed); err != nil {
+	if err := login_auth.TokenFixed(loginId, "ics", tokenFixed,
+		types.LoginSessionClient{}, &languageCode, nil, nil); err != nil {
 		handler.AbortRequest(w, handlerContext, err, handler.ErrAuthFailed)
 		bruteforce.BadAttempt(r)
 		return
@@ -0,0 +1,79 @@
+package oidc
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"r3/handler"
+	"r3/log"
+	"r3/oidc/oidc_auth"
+	"strconv"
+	"strings"
+)
+
+var handlerContext = "oidc"
+
+// starts OpenID Connect authentication by redirecting to identity provider
+func HandlerLogin(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "GET" {
+		handler.AbortRequestNoLog(w, handler.ErrGeneral)
+		return
+	}
+
+	/*
+		Parse URL, such as:
+		GET /oidc/login/1
+	*/
+	elements := strings.Split(r.URL.Path, "/")
+	if len(elements) != 4 {
+		handler.AbortRequestNoLog(w, handler.ErrGeneral)
+		return
+	}
+
+	oidcId, err := strconv.ParseInt(elements[3], 10, 32)
+	if err != nil {
+		handler.AbortRequest(w, handlerContext, err, handler.ErrGeneral)
+		return
+	}
+
+	authUrl, err := oidc_auth.GetAuthUrl(int32(oidcId),
+		fmt.Sprintf("%s/oidc/callback", handler.GetPublicBaseUrl()))
+
+	if err != nil {
+		handler.AbortRequest(w, handlerContext, err, handler.ErrGeneral)
+		return
+	}
+	http.Redirect(w, r, authUrl, http.StatusFound)
+}
+
+// completes OpenID Connect authentication, redirects to client with one-time code
+func HandlerCallback(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "GET" {
+		handler.AbortRequestNoLog(w, handler.ErrGeneral)
+		return
+	}
+
+	/*
+		Parse URL, such as:
+		GET /oidc/callback?state=abc&code=def
+		GET /oidc/callback?state=abc&error=access_denied
+	*/
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		log.Info("server", fmt.Sprintf("OpenID Connect authentication failed, identity provider returned '%s'",
+			query.Get("error")))
+
+		http.Redirect(w, r, "/#/?oidcError=1", http.StatusFound)
+		return
+	}
+
+	code, err := oidc_auth.Callback(query.Get("state"), query.Get("code"))
+	if err != nil {
+		log.Error("server", "OpenID Connect authentication failed", err)
+		http.Redirect(w, r, "/#/?oidcError=1", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/#/?oidc=%s", url.QueryEscape(code)), http.StatusFound)
+}
@@ -0,0 +1,104 @@
+package saml
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"r3/handler"
+	"r3/log"
+	"r3/saml/saml_auth"
+	"strconv"
+	"strings"
+)
+
+var handlerContext = "saml"
+
+// delivers service provider metadata, to be registered with the identity provider
+func HandlerMetadata(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "GET" {
+		handler.AbortRequestNoLog(w, handler.ErrGeneral)
+		return
+	}
+
+	/*
+		Parse URL, such as:
+		GET /saml/metadata/1
+	*/
+	samlId, err := getSamlIdFromPath(r.URL.Path)
+	if err != nil {
+		handler.AbortRequest(w, handlerContext, err, handler.ErrGeneral)
+		return
+	}
+
+	metadata, err := saml_auth.GetMetadata(samlId, handler.GetPublicBaseUrl())
+	if err != nil {
+		handler.AbortRequest(w, handlerContext, err, handler.ErrGeneral)
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(metadata)
+}
+
+// starts SAML authentication by redirecting to identity provider with signed authentication request
+func HandlerLogin(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "GET" {
+		handler.AbortRequestNoLog(w, handler.ErrGeneral)
+		return
+	}
+
+	/*
+		Parse URL, such as:
+		GET /saml/login/1
+	*/
+	samlId, err := getSamlIdFromPath(r.URL.Path)
+	if err != nil {
+		handler.AbortRequest(w, handlerContext, err, handler.ErrGeneral)
+		return
+	}
+
+	authUrl, err := saml_auth.GetAuthUrl(samlId, handler.GetPublicBaseUrl())
+	if err != nil {
+		handler.AbortRequest(w, handlerContext, err, handler.ErrGeneral)
+		return
+	}
+	http.Redirect(w, r, authUrl, http.StatusFound)
+}
+
+// assertion consumer service, completes SAML authentication, redirects to client with one-time code
+func HandlerAcs(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		handler.AbortRequestNoLog(w, handler.ErrGeneral)
+		return
+	}
+
+	/*
+		Parse URL, such as:
+		POST /saml/acs/1 (form value 'SAMLResponse')
+	*/
+	samlId, err := getSamlIdFromPath(r.URL.Path)
+	if err != nil {
+		handler.AbortRequest(w, handlerContext, err, handler.ErrGeneral)
+		return
+	}
+
+	code, err := saml_auth.Callback(samlId, handler.GetPublicBaseUrl(), r.PostFormValue("SAMLResponse"))
+	if err != nil {
+		log.Error("server", "SAML authentication failed", err)
+		http.Redirect(w, r, "/#/?samlError=1", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/#/?saml=%s", url.QueryEscape(code)), http.StatusSeeOther)
+}
+
+func getSamlIdFromPath(path string) (int32, error) {
+	elements := strings.Split(path, "/")
+	if len(elements) != 4 {
+		return 0, fmt.Errorf("invalid path '%s'", path)
+	}
+	id, err := strconv.ParseInt(elements[3], 10, 32)
+	return int32(id), err
+}
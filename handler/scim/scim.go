@@ -0,0 +1,198 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"r3/bruteforce"
+	"r3/handler"
+	"r3/log"
+	"r3/scim"
+	"r3/scim/scim_provision"
+	"strconv"
+	"strings"
+)
+
+var (
+	bodySizeMax    int64 = 1024 * 1024
+	handlerContext       = "scim"
+)
+
+// SCIM 2.0 service provider endpoint (RFC 7644), identity providers push users and groups
+func Handler(w http.ResponseWriter, r *http.Request) {
+
+	if blocked := bruteforce.Check(r); blocked {
+		handler.AbortRequestNoLog(w, handler.ErrBruteforceBlock)
+		return
+	}
+
+	s, err := scim.GetByToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		bruteforce.BadAttempt(r)
+		writeError(w, scim_provision.ErrorScim{Status: http.StatusUnauthorized, Detail: err.Error()})
+		return
+	}
+
+	/*
+		Parse URL, such as:
+		GET    /scim/v2/Users?filter=userName%20eq%20"john"
+		GET    /scim/v2/Users/12
+		PATCH  /scim/v2/Groups/0b5c8a6e-0e0b-4bd5-a3a8-5e8d25c5d7a1
+		GET    /scim/v2/ServiceProviderConfig
+	*/
+	elements := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(elements) < 4 || len(elements) > 5 {
+		writeError(w, scim_provision.ErrorScim{Status: http.StatusNotFound, Detail: "unknown endpoint"})
+		return
+	}
+	var (
+		baseUrl  = handler.GetPublicBaseUrl()
+		resource = elements[3]
+		id       = ""
+	)
+	if len(elements) == 5 {
+		id = elements[4]
+	}
+
+	// resources are created without ID, all other write requests address one
+	if (r.Method == "POST") != (id == "") && r.Method != "GET" {
+		writeError(w, scim_provision.ErrorScim{Status: http.StatusNotFound, Detail: "unknown endpoint"})
+		return
+	}
+
+	var res interface{}
+	var status = http.StatusOK
+
+	switch fmt.Sprintf("%s %s", r.Method, resource) {
+	case "GET ServiceProviderConfig":
+		res = scim_provision.GetServiceProviderConfig(baseUrl)
+
+	case "GET Users":
+		if id != "" {
+			res, err = scim_provision.UserGet(s, id, baseUrl)
+		} else {
+			startIndex, count := getListPage(r)
+			res, err = scim_provision.UserList(s, r.URL.Query().Get("filter"), startIndex, count, baseUrl)
+		}
+	case "POST Users":
+		var u scim_provision.User
+		if err = readBody(r, &u); err == nil {
+			res, err = scim_provision.UserCreate(s, u, baseUrl)
+			status = http.StatusCreated
+		}
+	case "PUT Users":
+		var u scim_provision.User
+		if err = readBody(r, &u); err == nil {
+			res, err = scim_provision.UserReplace(s, id, u, baseUrl)
+		}
+	case "PATCH Users":
+		var req scim_provision.PatchRequest
+		if err = readBody(r, &req); err == nil {
+			res, err = scim_provision.UserPatch(s, id, req, baseUrl)
+		}
+	case "DELETE Users":
+		err = scim_provision.UserDel(s, id)
+		status = http.StatusNoContent
+
+	case "GET Groups":
+		if id != "" {
+			res, err = scim_provision.GroupGet(s, id, baseUrl)
+		} else {
+			startIndex, count := getListPage(r)
+			res, err = scim_provision.GroupList(s, r.URL.Query().Get("filter"), startIndex, count, baseUrl)
+		}
+	case "POST Groups":
+		var g scim_provision.Group
+		if err = readBody(r, &g); err == nil {
+			res, err = scim_provision.GroupCreate(s, g, baseUrl)
+			status = http.StatusCreated
+		}
+	case "PUT Groups":
+		var g scim_provision.Group
+		if err = readBody(r, &g); err == nil {
+			res, err = scim_provision.GroupReplace(s, id, g, baseUrl)
+		}
+	case "PATCH Groups":
+		var req scim_provision.PatchRequest
+		if err = readBody(r, &req); err == nil {
+			res, err = scim_provision.GroupPatch(s, id, req, baseUrl)
+		}
+	case "DELETE Groups":
+		err = scim_provision.GroupDel(s, id)
+		status = http.StatusNoContent
+
+	default:
+		err = scim_provision.ErrorScim{Status: http.StatusNotFound, Detail: "unknown endpoint"}
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+
+	resJson, err := json.Marshal(res)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if status == http.StatusCreated {
+		switch v := res.(type) {
+		case scim_provision.User:
+			w.Header().Set("Location", v.Meta.Location)
+		case scim_provision.Group:
+			w.Header().Set("Location", v.Meta.Location)
+		}
+	}
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	w.Write(resJson)
+}
+
+// helpers
+func getListPage(r *http.Request) (int, int) {
+	startIndex, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil {
+		count = -1
+	}
+	return startIndex, count
+}
+
+func readBody(r *http.Request, target interface{}) error {
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, bodySizeMax)).Decode(target); err != nil {
+		return scim_provision.ErrorScim{
+			Status:   http.StatusBadRequest,
+			ScimType: "invalidSyntax",
+			Detail:   fmt.Sprintf("failed to parse request body, %s", err),
+		}
+	}
+	return nil
+}
+
+// writes SCIM error response, unexpected errors are logged and not shown to the client
+func writeError(w http.ResponseWriter, err error) {
+	var errScim scim_provision.ErrorScim
+	if !errors.As(err, &errScim) {
+		log.Error("server", fmt.Sprintf("aborted %s request", handlerContext), err)
+		errScim = scim_provision.ErrorScim{Status: http.StatusInternalServerError, Detail: handler.ErrGeneral}
+	}
+
+	resJson, _ := json.Marshal(scim_provision.ErrorResponse{
+		Schemas:  []string{scim_provision.SchemaError},
+		Status:   strconv.Itoa(errScim.Status),
+		ScimType: errScim.ScimType,
+		Detail:   errScim.Detail,
+	})
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(errScim.Status)
+	w.Write(resJson)
+}
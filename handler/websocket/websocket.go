@@ -31,6 +31,8 @@ type clientType struct {
 	local     bool                        // client is local (::1, 127.0.0.1)
 	loginId   int64                       // client login ID, 0 = not logged in yet
 	noAuth    bool                        // logged in without authentication (public auth, username only)
+	sessionId uuid.UUID                   // login session, used to revoke access
+	userAgent string                      // user agent of client, to identify login session
 	write_mx  sync.Mutex                  // to force sequential writes
 	ws        *websocket.Conn             // websocket connection
 }
@@ -104,6 +106,8 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		local:     host == "::1" || host == "127.0.0.1",
 		loginId:   0,
 		noAuth:    false,
+		sessionId: uuid.Nil,
+		userAgent: r.Header.Get("User-Agent"),
 		write_mx:  sync.Mutex{},
 		ws:        ws,
 	}
@@ -201,6 +205,15 @@ func (hub *hubType) start() {
 					continue
 				}
 
+				// disconnect client of revoked session, other clients are not affected
+				if event.Content == "kickSession" {
+					if sessionId, ok := event.Payload.(uuid.UUID); ok && sessionId == client.sessionId {
+						log.Info(handlerContext, fmt.Sprintf("kicking client of revoked session (login ID %d)", client.loginId))
+						removeClient(client)
+					}
+					continue
+				}
+
 				// disconnect and do not send message if kicked
 				if event.Content == "kick" || (event.Content == "kickNonAdmin" && !client.admin) {
 					log.Info(handlerContext, fmt.Sprintf("kicking client (login ID %d)", client.loginId))
@@ -289,18 +302,43 @@ func (client *clientType) handleTransaction(reqTransJson json.RawMessage) json.R
 		var err error
 		var resPayload interface{}
 
+		sessionClient := types.LoginSessionClient{
+			Address:   client.address,
+			Device:    "browser",
+			UserAgent: client.userAgent,
+		}
+		if client.device == types.WebsocketClientDeviceFatClient {
+			sessionClient.Device = "fatClient"
+		}
+
 		switch req.Action {
+		case "oidc": // authentication via one-time code from OpenID Connect
+			resPayload, err = request.LoginAuthOidc(req.Payload, sessionClient,
+				&client.loginId, &client.admin, &client.noAuth, &client.sessionId)
+
+		case "saml": // authentication via one-time code from SAML
+			resPayload, err = request.LoginAuthSaml(req.Payload, sessionClient,
+				&client.loginId, &client.admin, &client.noAuth, &client.sessionId)
+
 		case "token": // authentication via JSON web token
-			resPayload, err = request.LoginAuthToken(req.Payload,
-				&client.loginId, &client.admin, &client.noAuth)
+			resPayload, err = request.LoginAuthToken(req.Payload, sessionClient,
+				&client.loginId, &client.admin, &client.noAuth, &client.sessionId)
 
 		case "tokenFixed": // authentication via fixed token (fat-client only)
-			resPayload, err = request.LoginAuthTokenFixed(req.Payload, &client.loginId)
+			sessionClient.Device = "fatClient"
+			resPayload, err = request.LoginAuthTokenFixed(req.Payload, sessionClient, &client.loginId, &client.sessionId)
 			client.device = types.WebsocketClientDeviceFatClient
 
 		case "user": // authentication via credentials
-			resPayload, err = request.LoginAuthUser(req.Payload,
-				&client.loginId, &client.admin, &client.noAuth)
+			resPayload, err = request.LoginAuthUser(req.Payload, sessionClient,
+				&client.loginId, &client.admin, &client.noAuth, &client.sessionId)
+
+		case "webauthn": // passwordless authentication via WebAuthn assertion
+			resPayload, err = request.LoginAuthWebauthn(req.Payload, sessionClient,
+				&client.loginId, &client.admin, &client.noAuth, &client.sessionId)
+
+		case "webauthnOptions": // challenge for passwordless authentication, does not authenticate
+			resPayload, err = request.LoginAuthWebauthnOptions()
 		}
 
 		if err != nil {
@@ -324,7 +362,7 @@ func (client *clientType) handleTransaction(reqTransJson json.RawMessage) json.R
 			}
 		}
 
-		if resTrans.Error == "" {
+		if resTrans.Error == "" && client.loginId != 0 {
 			log.Info(handlerContext, fmt.Sprintf("authenticated client (login ID %d, admin: %v)",
 				client.loginId, client.admin))
 		}
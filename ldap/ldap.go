@@ -22,7 +22,8 @@ func Get() ([]types.Ldap, error) {
 		SELECT id, login_template_id, name, host, port, bind_user_dn,
 			bind_user_pw, search_class, search_dn, key_attribute,
 			login_attribute, member_attribute, assign_roles, ms_ad_ext,
-			starttls, tls, tls_verify
+			starttls, tls, tls_verify, login_form_id, sync_incremental,
+			sync_full_days, sync_mark, date_sync_full
 		FROM instance.ldap
 		ORDER BY name ASC
 	`)
@@ -35,7 +36,9 @@ func Get() ([]types.Ldap, error) {
 		if err := rows.Scan(&l.Id, &l.LoginTemplateId, &l.Name, &l.Host,
 			&l.Port, &l.BindUserDn, &l.BindUserPw, &l.SearchClass, &l.SearchDn,
 			&l.KeyAttribute, &l.LoginAttribute, &l.MemberAttribute,
-			&l.AssignRoles, &l.MsAdExt, &l.Starttls, &l.Tls, &l.TlsVerify); err != nil {
+			&l.AssignRoles, &l.MsAdExt, &l.Starttls, &l.Tls, &l.TlsVerify,
+			&l.LoginFormId, &l.SyncIncremental, &l.SyncFullDays, &l.SyncMark,
+			&l.DateSyncFull); err != nil {
 
 			rows.Close()
 			return ldaps, err
@@ -45,10 +48,18 @@ func Get() ([]types.Ldap, error) {
 	rows.Close()
 
 	for i, _ := range ldaps {
+		ldaps[i].Attributes, err = getAttributes(ldaps[i].Id)
+		if err != nil {
+			return ldaps, err
+		}
 		ldaps[i].Roles, err = getRoles(ldaps[i].Id)
 		if err != nil {
 			return ldaps, err
 		}
+		ldaps[i].Templates, err = getTemplates(ldaps[i].Id)
+		if err != nil {
+			return ldaps, err
+		}
 	}
 	return ldaps, nil
 }
@@ -60,14 +71,15 @@ func Set_tx(tx pgx.Tx, l types.Ldap) error {
 			INSERT INTO instance.ldap (
 				login_template_id, name, host, port, bind_user_dn, bind_user_pw,
 				search_class, search_dn, key_attribute, login_attribute,
-				member_attribute, assign_roles, ms_ad_ext, starttls, tls, tls_verify
+				member_attribute, assign_roles, ms_ad_ext, starttls, tls, tls_verify,
+				login_form_id, sync_incremental, sync_full_days
 			)
-			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19)
 			RETURNING id
 		`, l.LoginTemplateId, l.Name, l.Host, l.Port, l.BindUserDn, l.BindUserPw,
 			l.SearchClass, l.SearchDn, l.KeyAttribute, l.LoginAttribute,
 			l.MemberAttribute, l.AssignRoles, l.MsAdExt, l.Starttls, l.Tls,
-			l.TlsVerify).Scan(&l.Id); err != nil {
+			l.TlsVerify, l.LoginFormId, l.SyncIncremental, l.SyncFullDays).Scan(&l.Id); err != nil {
 
 			return err
 		}
@@ -78,12 +90,14 @@ func Set_tx(tx pgx.Tx, l types.Ldap) error {
 				bind_user_dn = $5, bind_user_pw = $6, search_class = $7,
 				search_dn = $8, key_attribute = $9, login_attribute = $10,
 				member_attribute = $11, assign_roles = $12, ms_ad_ext = $13,
-				starttls = $14, tls = $15, tls_verify = $16
-			WHERE id = $17
+				starttls = $14, tls = $15, tls_verify = $16, login_form_id = $17,
+				sync_incremental = $18, sync_full_days = $19,
+				sync_mark = NULL -- connection settings might have changed, next import is full
+			WHERE id = $20
 		`, l.LoginTemplateId, l.Name, l.Host, l.Port, l.BindUserDn, l.BindUserPw,
 			l.SearchClass, l.SearchDn, l.KeyAttribute, l.LoginAttribute,
 			l.MemberAttribute, l.AssignRoles, l.MsAdExt, l.Starttls, l.Tls,
-			l.TlsVerify, l.Id); err != nil {
+			l.TlsVerify, l.LoginFormId, l.SyncIncremental, l.SyncFullDays, l.Id); err != nil {
 
 			return err
 		}
@@ -105,9 +119,70 @@ func Set_tx(tx pgx.Tx, l types.Ldap) error {
 			return err
 		}
 	}
+
+	// update LDAP attribute mapping
+	if _, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.ldap_attribute
+		WHERE ldap_id = $1
+	`, l.Id); err != nil {
+		return err
+	}
+
+	if l.LoginFormId.Valid {
+		for _, atr := range l.Attributes {
+			if _, err := tx.Exec(db.Ctx, `
+				INSERT INTO instance.ldap_attribute (ldap_id, attribute_id, ldap_attribute)
+				VALUES ($1,$2,$3)
+			`, l.Id, atr.AttributeId, atr.LdapAttribute); err != nil {
+				return err
+			}
+		}
+	}
+
+	// update LDAP template assignment
+	if _, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.ldap_template
+		WHERE ldap_id = $1
+	`, l.Id); err != nil {
+		return err
+	}
+
+	for i, template := range l.Templates {
+		if _, err := tx.Exec(db.Ctx, `
+			INSERT INTO instance.ldap_template (ldap_id, login_template_id, group_dn, position)
+			VALUES ($1,$2,$3,$4)
+		`, l.Id, template.LoginTemplateId, template.GroupDn, i); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func getAttributes(ldapId int32) ([]types.LdapAttribute, error) {
+	attributes := make([]types.LdapAttribute, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT attribute_id, ldap_attribute
+		FROM instance.ldap_attribute
+		WHERE ldap_id = $1
+		ORDER BY ldap_attribute
+	`, ldapId)
+	if err != nil {
+		return attributes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a types.LdapAttribute
+		if err := rows.Scan(&a.AttributeId, &a.LdapAttribute); err != nil {
+			return attributes, err
+		}
+		a.LdapId = ldapId
+		attributes = append(attributes, a)
+	}
+	return attributes, nil
+}
+
 func getRoles(ldapId int32) ([]types.LdapRole, error) {
 	roles := make([]types.LdapRole, 0)
 
@@ -132,3 +207,28 @@ func getRoles(ldapId int32) ([]types.LdapRole, error) {
 	}
 	return roles, nil
 }
+
+func getTemplates(ldapId int32) ([]types.LdapTemplate, error) {
+	templates := make([]types.LdapTemplate, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT login_template_id, group_dn
+		FROM instance.ldap_template
+		WHERE ldap_id = $1
+		ORDER BY position
+	`, ldapId)
+	if err != nil {
+		return templates, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t types.LdapTemplate
+		if err := rows.Scan(&t.LoginTemplateId, &t.GroupDn); err != nil {
+			return templates, err
+		}
+		t.LdapId = ldapId
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
@@ -6,23 +6,28 @@ import (
 	"fmt"
 	"r3/cache"
 	"r3/cluster"
-	//"r3/config"   // FWDO
 	"r3/db"
 	"r3/ldap/ldap_conn"
 	"r3/log"
 	"r3/login"
+	"r3/tools"
 	"r3/types"
 	"slices"
+	"strings"
 	"unicode/utf8"
 
 	goldap "github.com/go-ldap/ldap/v3"
 	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type loginType struct {
-	active  bool
-	name    string
-	roleIds []uuid.UUID
+	active    bool
+	dn        string
+	name      string
+	groupDns  []string             // DNs of queried groups that login is member of
+	values    map[uuid.UUID]string // values of mapped LDAP attributes, key: module attribute ID
+	syncValue string               // value of change tracking attribute, for high-water mark
 }
 
 var (
@@ -33,22 +38,18 @@ var (
 
 func RunAll() error {
 
-	ldapIdMap := cache.GetLdapIdMap()
-
-	if len(ldapIdMap) != 0  { //&& config.GetLicenseActive() {  //FWDO
-		//log.Warning("ldap", "skipping run", errors.New("no valid license"))
-		return nil
-	}
-
-	for _, ldap := range ldapIdMap {
-		if err := Run(ldap.Id); err != nil {
+	for _, ldap := range cache.GetLdapIdMap() {
+		if err := Run(ldap.Id, false); err != nil {
 			return err
 		}
-	}  
+	}
 	return nil
 }
 
-func Run(ldapId int32) error {
+// imports logins from LDAP connection
+// with incremental sync, only entries changed since the last run are imported
+// full import (reconciliation) is done if forced, on first run and after configured interval
+func Run(ldapId int32, forceFull bool) error {
 
 	ldapConn, ldap, err := ldap_conn.ConnectAndBind(ldapId)
 	if err != nil {
@@ -56,9 +57,44 @@ func Run(ldapId int32) error {
 	}
 	defer ldapConn.Close()
 
+	// decide between full and incremental import
+	mark, dateSyncFull, err := getSyncState(ldap.Id)
+	if err != nil {
+		return err
+	}
+	full := forceFull || !ldap.SyncIncremental || !mark.Valid || !dateSyncFull.Valid ||
+		dateSyncFull.Int64+(int64(ldap.SyncFullDays)*86400) <= tools.GetTimeUnix()
+
+	filterSync := ""
+	if !full {
+		filterSync, err = getSyncFilter(ldap, mark.String)
+		if err != nil {
+			return err
+		}
+	}
+
 	// define attributes to lookup and filters to apply
 	attributes := []string{"dn", ldap.KeyAttribute, ldap.LoginAttribute}
 
+	// add change tracking attribute, to update high-water mark
+	syncAttribute := getSyncAttribute(ldap)
+	if ldap.SyncIncremental {
+		attributes = append(attributes, syncAttribute)
+	}
+
+	// add mapped attributes, synced into module records of logins
+	target, syncRecords, err := getRecordTarget(ldap)
+	if err != nil {
+		return err
+	}
+	if syncRecords {
+		for _, atr := range ldap.Attributes {
+			if !slices.Contains(attributes, atr.LdapAttribute) {
+				attributes = append(attributes, atr.LdapAttribute)
+			}
+		}
+	}
+
 	// MS AD, add user account control (currently for account (de)activation)
 	if ldap.MsAdExt {
 		attributes = append(attributes, "userAccountControl")
@@ -75,6 +111,7 @@ func Run(ldapId int32) error {
 
 	// keeping 1 million logins in memory with 3 role IDs each, uses ~300MB RAM
 	// simulation ran: 2020-05-19, go 1.14.2
+	// incremental imports only keep changed logins in memory
 	logins := make(map[string]loginType) // key: key LDAP attribute
 
 	// LDAP auto role assignment removes existing roles from user, defining no roles here would remove all access
@@ -82,30 +119,62 @@ func Run(ldapId int32) error {
 		return errors.New("no roles are defined for assignment by LDAP group")
 	}
 
-	// if LDAP auto role assignment is disabled, remove defined role assignments (do not need to be queried)
-	if !ldap.AssignRoles && len(ldap.Roles) != 0 {
-		ldap.Roles = make([]types.LdapRole, 0)
+	// group DNs to query memberships for
+	// role assignments are only relevant if LDAP auto role assignment is enabled
+	groupDns := make([]string, 0)
+	if ldap.AssignRoles {
+		for _, role := range ldap.Roles {
+			if !slices.Contains(groupDns, role.GroupDn) {
+				groupDns = append(groupDns, role.GroupDn)
+			}
+		}
+	}
+	for _, template := range ldap.Templates {
+		if !slices.Contains(groupDns, template.GroupDn) {
+			groupDns = append(groupDns, template.GroupDn)
+		}
+	}
+
+	// group membership changes do not change the member entries, only the group entries
+	// if a queried group changed, all logins are imported to apply added and removed memberships
+	syncValuesGroup := make([]string, 0)
+	if ldap.SyncIncremental {
+		for _, groupDn := range groupDns {
+			value, err := getEntrySyncValue(ldapConn, groupDn, syncAttribute)
+			if err != nil {
+				return err
+			}
+			if value == "" {
+				continue
+			}
+			if !full && isSyncMarkNewer(ldap, value, mark) {
+				log.Info("ldap", fmt.Sprintf("group '%s' changed, switching to full login import", groupDn))
+				full = true
+				filterSync = ""
+			}
+			syncValuesGroup = append(syncValuesGroup, value)
+		}
 	}
 
-	// to get users with and without roles, we need multiple queries
-	// * query of users in membership of each defined group DN (for role assignment)
+	// to get users with and without group memberships, we need multiple queries
+	// * query of users in membership of each defined group DN (for role/template assignment)
 	// * query of just users (without we´d loose users that have no defined group DN assigned)
-	ldap.Roles = append(ldap.Roles, types.LdapRole{}) // empty group DN
+	groupDns = append(groupDns, "") // empty group DN
 
-	for _, role := range ldap.Roles {
+	for _, groupDn := range groupDns {
 
-		filters := fmt.Sprintf("(&(objectClass=%s))", ldap.SearchClass)
+		filters := fmt.Sprintf("(&(objectClass=%s)%s)", ldap.SearchClass, filterSync)
 
-		// set filters to search for group DN if role assignment is active
+		// set filters to search for group DN
 		// group DN is empty if just users are queried
-		if ldap.AssignRoles && role.GroupDn != "" {
+		if groupDn != "" {
 
 			if ldap.MsAdExt {
-				filters = fmt.Sprintf("(&(objectClass=%s)(%s:1.2.840.113556.1.4.1941:=%s))",
-					ldap.SearchClass, ldap.MemberAttribute, role.GroupDn)
+				filters = fmt.Sprintf("(&(objectClass=%s)(%s:1.2.840.113556.1.4.1941:=%s)%s)",
+					ldap.SearchClass, ldap.MemberAttribute, groupDn, filterSync)
 			} else {
-				filters = fmt.Sprintf("(&(objectClass=%s)(%s=%s))",
-					ldap.SearchClass, ldap.MemberAttribute, role.GroupDn)
+				filters = fmt.Sprintf("(&(objectClass=%s)(%s=%s)%s)",
+					ldap.SearchClass, ldap.MemberAttribute, groupDn, filterSync)
 			}
 		}
 
@@ -130,24 +199,28 @@ func Run(ldapId int32) error {
 
 			for _, entry := range response.Entries {
 
-				// key attribute is used to uniquely identifiy an user
-				// MS AD uses binary for some (like objectGUID), encode base64 if invalid UTF8
-				var key string
-				keyRaw := entry.GetRawAttributeValue(ldap.KeyAttribute)
-				if utf8.Valid(keyRaw) {
-					key = string(keyRaw)
-				} else {
-					key = fmt.Sprintf(base64.StdEncoding.EncodeToString(keyRaw))
-				}
+				key := getEntryKey(entry, ldap)
 
 				l, exists := logins[key]
 				if !exists {
 					l = loginType{}
 					l.active = true
-					l.roleIds = make([]uuid.UUID, 0)
+					l.groupDns = make([]string, 0)
+					l.values = make(map[uuid.UUID]string)
 				}
+				l.dn = entry.DN
 				l.name = entry.GetAttributeValue(ldap.LoginAttribute)
 
+				if ldap.SyncIncremental {
+					l.syncValue = entry.GetAttributeValue(syncAttribute)
+				}
+
+				if syncRecords {
+					for _, atr := range ldap.Attributes {
+						l.values[atr.AttributeId] = entry.GetAttributeValue(atr.LdapAttribute)
+					}
+				}
+
 				if ldap.MsAdExt {
 					for _, value := range entry.GetAttributeValues("userAccountControl") {
 						if slices.Contains(msAdExtDisabledAtrFlags, value) {
@@ -156,9 +229,9 @@ func Run(ldapId int32) error {
 					}
 				}
 
-				// role ID is empty if just users are queried
-				if ldap.AssignRoles && role.RoleId != uuid.Nil && !slices.Contains(l.roleIds, role.RoleId) {
-					l.roleIds = append(l.roleIds, role.RoleId)
+				// group DN is empty if just users are queried
+				if groupDn != "" && !slices.Contains(l.groupDns, groupDn) {
+					l.groupDns = append(l.groupDns, groupDn)
 				}
 				logins[key] = l
 			}
@@ -178,38 +251,135 @@ func Run(ldapId int32) error {
 	}
 
 	// import logins
+	dnMapLoginId := make(map[string]int64) // key: lower case DN, for references between login records
+	syncValuesDone := syncValuesGroup
+	syncValuesFailed := make([]string, 0)
 	for key, l := range logins {
-		if err := importLogin(l, key, ldap); err != nil {
+		loginId, err := importLogin(l, key, ldap, target, syncRecords)
+		if err != nil {
 			log.Warning("ldap", fmt.Sprintf("failed to import login '%s'", l.name), err)
+			if l.syncValue != "" {
+				syncValuesFailed = append(syncValuesFailed, l.syncValue)
+			}
 			continue
 		}
+		dnMapLoginId[strings.ToLower(l.dn)] = loginId
+		if l.syncValue != "" {
+			syncValuesDone = append(syncValuesDone, l.syncValue)
+		}
+	}
+
+	// update references between login records, once all records exist
+	if syncRecords {
+		for _, l := range logins {
+			loginId, exists := dnMapLoginId[strings.ToLower(l.dn)]
+			if !exists {
+				continue
+			}
+			if err := resolveReferences(ldapConn, ldap, target, l, dnMapLoginId); err != nil {
+				log.Warning("ldap", fmt.Sprintf("failed to resolve record references of login '%s'", l.name), err)
+				continue
+			}
+			if err := importRecordReferences(l, loginId, target, dnMapLoginId); err != nil {
+				log.Warning("ldap", fmt.Sprintf("failed to update record references of login '%s'", l.name), err)
+			}
+		}
+	}
+
+	// full reconciliation, deactivate logins removed from directory
+	// an empty result is more likely a misconfiguration than an empty directory
+	if full {
+		if len(logins) == 0 {
+			log.Warning("ldap", fmt.Sprintf("skipping deactivation of missing logins for '%s'",
+				ldap.Name), errors.New("no entries found"))
+		} else {
+			keys := make([]string, 0, len(logins))
+			for key, _ := range logins {
+				keys = append(keys, key)
+			}
+			if err := deactivateMissing(ldap, keys); err != nil {
+				return err
+			}
+		}
 	}
 
-	log.Info("ldap", fmt.Sprintf("finished login import for '%s'", ldap.Name))
+	// store high-water mark for next incremental import
+	// mark is not advanced past failed entries, so that they are imported again
+	if ldap.SyncIncremental {
+		mark = getSyncMarkNext(ldap, mark, syncValuesDone, syncValuesFailed)
+	} else {
+		mark = pgtype.Text{}
+	}
+	if err := setSyncState(ldap.Id, mark, full); err != nil {
+		return err
+	}
+
+	if full {
+		log.Info("ldap", fmt.Sprintf("finished full login import for '%s' (%d entries)",
+			ldap.Name, len(logins)))
+	} else {
+		log.Info("ldap", fmt.Sprintf("finished incremental login import for '%s' (%d changed entries)",
+			ldap.Name, len(logins)))
+	}
 	return nil
 }
 
-func importLogin(l loginType, key string, ldap types.Ldap) error {
+// key attribute is used to uniquely identifiy an user
+// MS AD uses binary for some (like objectGUID), encode base64 if invalid UTF8
+func getEntryKey(entry *goldap.Entry, ldap types.Ldap) string {
+	keyRaw := entry.GetRawAttributeValue(ldap.KeyAttribute)
+	if utf8.Valid(keyRaw) {
+		return string(keyRaw)
+	}
+	return base64.StdEncoding.EncodeToString(keyRaw)
+}
+
+func importLogin(l loginType, key string, ldap types.Ldap, target recordTarget, syncRecords bool) (int64, error) {
+
+	// roles from group memberships
+	roleIds := make([]uuid.UUID, 0)
+	if ldap.AssignRoles {
+		for _, role := range ldap.Roles {
+			if slices.Contains(l.groupDns, role.GroupDn) && !slices.Contains(roleIds, role.RoleId) {
+				roleIds = append(roleIds, role.RoleId)
+			}
+		}
+	}
+
+	// login template from first matching group membership, LDAP default otherwise
+	loginTemplateId := ldap.LoginTemplateId
+	for _, template := range ldap.Templates {
+		if slices.Contains(l.groupDns, template.GroupDn) {
+			loginTemplateId = pgtype.Int8{Int64: template.LoginTemplateId, Valid: true}
+			break
+		}
+	}
 
 	log.Info("ldap", fmt.Sprintf("importing login '%s' (key: %s, roles: %d)",
-		l.name, key, len(l.roleIds)))
+		l.name, key, len(roleIds)))
 
 	tx, err := db.Pool.Begin(db.Ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback(db.Ctx)
 
 	loginId, changed, err := login.SetLdapLogin_tx(tx, ldap.Id, key, l.name,
-		l.active, l.roleIds, ldap.LoginTemplateId, ldap.AssignRoles)
+		l.active, roleIds, loginTemplateId, ldap.AssignRoles)
 
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if syncRecords {
+		if err := setRecord_tx(tx, target, loginId, l.values); err != nil {
+			return 0, err
+		}
 	}
 
 	// commit before renewing access cache (to apply new permissions)
 	if err := tx.Commit(db.Ctx); err != nil {
-		return err
+		return 0, err
 	}
 
 	if changed {
@@ -225,5 +395,18 @@ func importLogin(l loginType, key string, ldap types.Ldap) error {
 			cluster.LoginDisabled(true, loginId)
 		}
 	}
-	return nil
+	return loginId, nil
+}
+
+func importRecordReferences(l loginType, loginId int64, target recordTarget, dnMapLoginId map[string]int64) error {
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	if err := setRecordReferences_tx(tx, target, loginId, l.values, dnMapLoginId); err != nil {
+		return err
+	}
+	return tx.Commit(db.Ctx)
 }
@@ -0,0 +1,316 @@
+package ldap_import
+
+import (
+	"errors"
+	"fmt"
+	"r3/cache"
+	"r3/data"
+	"r3/db"
+	"r3/schema"
+	"r3/types"
+	"strings"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// module relation to store LDAP attribute values in, defined by login form of LDAP connection
+// each login has one record, identified by login attribute of login form
+type recordTarget struct {
+	modName      string
+	relId        uuid.UUID
+	relName      string
+	atrIdLogin   uuid.UUID
+	atrLoginName string
+	atrs         []recordAttribute
+}
+type recordAttribute struct {
+	id        uuid.UUID
+	name      string
+	length    int  // max. length of varchar attribute, 0 if not limited
+	reference bool // n:1 relationship to target relation, LDAP value is DN of other login (e.g. manager)
+}
+
+// returns record target of LDAP connection, false if no attributes are to be synced
+func getRecordTarget(ldap types.Ldap) (recordTarget, bool, error) {
+	var t recordTarget
+
+	if !ldap.LoginFormId.Valid || len(ldap.Attributes) == 0 {
+		return t, false, nil
+	}
+
+	cache.Schema_mx.RLock()
+	defer cache.Schema_mx.RUnlock()
+
+	var loginForm types.LoginForm
+	var found bool
+	for _, mod := range cache.ModuleIdMap {
+		for _, lf := range mod.LoginForms {
+			if lf.Id == ldap.LoginFormId.Bytes {
+				loginForm = lf
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return t, false, fmt.Errorf("unknown login form '%s'", uuid.UUID(ldap.LoginFormId.Bytes))
+	}
+
+	atrLogin, exists := cache.AttributeIdMap[loginForm.AttributeIdLogin]
+	if !exists {
+		return t, false, errors.New("unknown login attribute of login form")
+	}
+	rel := cache.RelationIdMap[atrLogin.RelationId]
+	mod := cache.ModuleIdMap[rel.ModuleId]
+
+	t.modName = mod.Name
+	t.relId = rel.Id
+	t.relName = rel.Name
+	t.atrIdLogin = atrLogin.Id
+	t.atrLoginName = atrLogin.Name
+	t.atrs = make([]recordAttribute, 0)
+
+	for _, la := range ldap.Attributes {
+		atr, exists := cache.AttributeIdMap[la.AttributeId]
+		if !exists {
+			return t, false, fmt.Errorf("unknown attribute '%s'", la.AttributeId)
+		}
+		if atr.RelationId != rel.Id {
+			return t, false, fmt.Errorf("attribute '%s' is not part of login form relation", atr.Name)
+		}
+		if atr.Encrypted {
+			return t, false, fmt.Errorf("attribute '%s' is encrypted", atr.Name)
+		}
+
+		ra := recordAttribute{id: atr.Id, name: atr.Name}
+		switch {
+		case schema.IsContentText(atr.Content):
+			if atr.Content == "varchar" {
+				ra.length = atr.Length
+			}
+		case atr.Content == "n:1" && atr.RelationshipId.Valid && atr.RelationshipId.Bytes == rel.Id:
+			ra.reference = true
+		default:
+			return t, false, fmt.Errorf("attribute '%s' has unsupported content '%s'", atr.Name, atr.Content)
+		}
+		t.atrs = append(t.atrs, ra)
+	}
+	return t, true, nil
+}
+
+// creates or updates record of login with LDAP attribute values
+// reference attributes are skipped, other logins might not have records yet
+// records are written as system, to apply data logs & webhooks like any other change
+func setRecord_tx(tx pgx.Tx, t recordTarget, loginId int64, values map[uuid.UUID]string) error {
+
+	names := make([]string, 0)
+	valuesNew := make([]pgtype.Text, 0)
+	for _, atr := range t.atrs {
+		if atr.reference {
+			continue
+		}
+		value := values[atr.id]
+		if atr.length != 0 && len([]rune(value)) > atr.length {
+			value = string([]rune(value)[:atr.length])
+		}
+		names = append(names, fmt.Sprintf(`"%s"`, atr.name))
+		valuesNew = append(valuesNew, pgtype.Text{String: value, Valid: value != ""})
+	}
+
+	recordId, valuesOld, err := getRecord_tx(tx, t, loginId, names)
+	if err != nil {
+		return err
+	}
+
+	dataSet := types.DataSet{
+		RelationId: t.relId,
+		RecordId:   recordId,
+		Attributes: make([]types.DataSetAttribute, 0),
+	}
+
+	// create record for login
+	if recordId == 0 {
+		dataSet.Attributes = append(dataSet.Attributes, types.DataSetAttribute{
+			AttributeId: t.atrIdLogin,
+			Value:       loginId,
+		})
+	}
+
+	// set values, only if changed
+	i := 0
+	for _, atr := range t.atrs {
+		if atr.reference {
+			continue
+		}
+		if recordId == 0 || valuesNew[i] != valuesOld[i] {
+			dataSet.Attributes = append(dataSet.Attributes, types.DataSetAttribute{
+				AttributeId: atr.id,
+				Value:       getTextValue(valuesNew[i]),
+			})
+		}
+		i++
+	}
+	return setRecordData_tx(tx, dataSet)
+}
+
+// resolves referenced DNs (e.g. manager) of login, which were not part of this import, to logins
+// incremental imports only contain changed logins, unchanged ones are looked up in directory by their key
+func resolveReferences(ldapConn *goldap.Conn, ldap types.Ldap, t recordTarget,
+	l loginType, dnMapLoginId map[string]int64) error {
+
+	for _, atr := range t.atrs {
+		dn := strings.ToLower(l.values[atr.id])
+		if !atr.reference || dn == "" {
+			continue
+		}
+		if _, exists := dnMapLoginId[dn]; exists {
+			continue
+		}
+
+		response, err := ldapConn.Search(goldap.NewSearchRequest(
+			dn,
+			goldap.ScopeBaseObject,
+			goldap.NeverDerefAliases, 0, 0, false,
+			fmt.Sprintf("(objectClass=%s)", ldap.SearchClass),
+			[]string{ldap.KeyAttribute},
+			nil))
+
+		if err != nil && !goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchObject) {
+			return err
+		}
+		if err != nil || len(response.Entries) == 0 {
+			continue // DN does not exist or is no login
+		}
+
+		var loginId int64
+		err = db.Pool.QueryRow(db.Ctx, `
+			SELECT id
+			FROM instance.login
+			WHERE ldap_id  = $1
+			AND   ldap_key = $2
+		`, ldap.Id, getEntryKey(response.Entries[0], ldap)).Scan(&loginId)
+
+		if err == pgx.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		dnMapLoginId[dn] = loginId
+	}
+	return nil
+}
+
+// updates reference attributes of login record (e.g. manager), after records of all logins exist
+// references to DNs of unknown logins are removed
+func setRecordReferences_tx(tx pgx.Tx, t recordTarget, loginId int64,
+	values map[uuid.UUID]string, dnMapLoginId map[string]int64) error {
+
+	names := make([]string, 0)
+	for _, atr := range t.atrs {
+		if atr.reference {
+			names = append(names, fmt.Sprintf(`"%s"::TEXT`, atr.name))
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	recordId, valuesOld, err := getRecord_tx(tx, t, loginId, names)
+	if err != nil {
+		return err
+	}
+	if recordId == 0 {
+		return nil
+	}
+
+	dataSet := types.DataSet{
+		RelationId: t.relId,
+		RecordId:   recordId,
+		Attributes: make([]types.DataSetAttribute, 0),
+	}
+
+	i := 0
+	for _, atr := range t.atrs {
+		if !atr.reference {
+			continue
+		}
+
+		// record of referenced login
+		var recordIdRef pgtype.Int8
+		if loginIdRef, exists := dnMapLoginId[strings.ToLower(values[atr.id])]; exists {
+			err := tx.QueryRow(db.Ctx, fmt.Sprintf(`
+				SELECT "%s"
+				FROM "%s"."%s"
+				WHERE "%s" = $1
+			`, schema.PkName, t.modName, t.relName, t.atrLoginName), loginIdRef).Scan(&recordIdRef)
+
+			if err != nil && err != pgx.ErrNoRows {
+				return err
+			}
+		}
+
+		valueOld := valuesOld[i]
+		i++
+
+		// skip unchanged reference
+		if !recordIdRef.Valid && !valueOld.Valid {
+			continue
+		}
+		if recordIdRef.Valid && valueOld.Valid && fmt.Sprintf("%d", recordIdRef.Int64) == valueOld.String {
+			continue
+		}
+
+		var value interface{}
+		if recordIdRef.Valid {
+			value = recordIdRef.Int64
+		}
+		dataSet.Attributes = append(dataSet.Attributes, types.DataSetAttribute{
+			AttributeId: atr.id,
+			Value:       value,
+		})
+	}
+	return setRecordData_tx(tx, dataSet)
+}
+
+// returns ID and current values (as text) of record of login, record ID 0 if login has no record
+func getRecord_tx(tx pgx.Tx, t recordTarget, loginId int64, names []string) (int64, []pgtype.Text, error) {
+
+	var recordId int64
+	valuesOld := make([]pgtype.Text, len(names))
+	targets := []interface{}{&recordId}
+	for i := range valuesOld {
+		targets = append(targets, &valuesOld[i])
+	}
+
+	cols := append([]string{fmt.Sprintf(`"%s"`, schema.PkName)}, names...)
+	err := tx.QueryRow(db.Ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM "%s"."%s"
+		WHERE "%s" = $1
+	`, strings.Join(cols, ", "), t.modName, t.relName, t.atrLoginName), loginId).Scan(targets...)
+
+	if err == pgx.ErrNoRows {
+		return 0, valuesOld, nil
+	}
+	return recordId, valuesOld, err
+}
+
+// writes data set as system, nothing is written if no attribute values are set
+func setRecordData_tx(tx pgx.Tx, dataSet types.DataSet) error {
+	if len(dataSet.Attributes) == 0 {
+		return nil
+	}
+	_, err := data.SetSystem_tx(db.Ctx, tx, map[int]types.DataSet{0: dataSet})
+	return err
+}
+
+func getTextValue(value pgtype.Text) interface{} {
+	if !value.Valid {
+		return nil
+	}
+	return value.String
+}
@@ -0,0 +1,138 @@
+package ldap_import
+
+import (
+	"fmt"
+	"r3/cluster"
+	"r3/db"
+	"r3/log"
+	"r3/login"
+	"r3/tools"
+	"r3/types"
+	"strconv"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// change tracking attribute, MS AD uses update sequence numbers, other directories operational timestamps
+func getSyncAttribute(ldap types.Ldap) string {
+	if ldap.MsAdExt {
+		return "uSNChanged"
+	}
+	return "modifyTimestamp"
+}
+
+// returns filter to only query entries changed after high-water mark
+func getSyncFilter(ldap types.Ldap, mark string) (string, error) {
+	if ldap.MsAdExt {
+		usn, err := strconv.ParseInt(mark, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(uSNChanged>=%d)", usn+1), nil
+	}
+	return fmt.Sprintf("(modifyTimestamp>=%s)", goldap.EscapeFilter(mark)), nil
+}
+
+// returns whether change tracking value is newer than high-water mark
+func isSyncMarkNewer(ldap types.Ldap, value string, mark pgtype.Text) bool {
+	if !mark.Valid {
+		return true
+	}
+	if ldap.MsAdExt {
+		usnValue, err0 := strconv.ParseInt(value, 10, 64)
+		usnMark, err1 := strconv.ParseInt(mark.String, 10, 64)
+		return err0 == nil && (err1 != nil || usnValue > usnMark)
+	}
+	// generalized time (YYYYMMDDHHMMSSZ) can be compared as text
+	return value > mark.String
+}
+
+// returns next high-water mark, the newest change of successfully imported entries
+// mark stays before the oldest change of failed entries, to import them again on the next run
+func getSyncMarkNext(ldap types.Ldap, mark pgtype.Text, valuesDone []string, valuesFailed []string) pgtype.Text {
+	var markFailed pgtype.Text
+	for _, value := range valuesFailed {
+		if !markFailed.Valid || isSyncMarkNewer(ldap, markFailed.String, pgtype.Text{String: value, Valid: true}) {
+			markFailed = pgtype.Text{String: value, Valid: true}
+		}
+	}
+	for _, value := range valuesDone {
+		if !isSyncMarkNewer(ldap, value, mark) {
+			continue
+		}
+		if markFailed.Valid && !isSyncMarkNewer(ldap, markFailed.String, pgtype.Text{String: value, Valid: true}) {
+			continue
+		}
+		mark = pgtype.Text{String: value, Valid: true}
+	}
+	return mark
+}
+
+// returns value of change tracking attribute of single entry, empty if entry or value does not exist
+func getEntrySyncValue(ldapConn *goldap.Conn, dn string, syncAttribute string) (string, error) {
+	response, err := ldapConn.Search(goldap.NewSearchRequest(
+		dn,
+		goldap.ScopeBaseObject,
+		goldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{syncAttribute},
+		nil))
+
+	if err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchObject) {
+			return "", nil
+		}
+		return "", err
+	}
+	if len(response.Entries) == 0 {
+		return "", nil
+	}
+	return response.Entries[0].GetAttributeValue(syncAttribute), nil
+}
+
+// import state is not kept in cache as it changes with every run
+func getSyncState(ldapId int32) (pgtype.Text, pgtype.Int8, error) {
+	var mark pgtype.Text
+	var dateSyncFull pgtype.Int8
+	err := db.Pool.QueryRow(db.Ctx, `
+		SELECT sync_mark, date_sync_full
+		FROM instance.ldap
+		WHERE id = $1
+	`, ldapId).Scan(&mark, &dateSyncFull)
+	return mark, dateSyncFull, err
+}
+
+func setSyncState(ldapId int32, mark pgtype.Text, full bool) error {
+	_, err := db.Pool.Exec(db.Ctx, `
+		UPDATE instance.ldap
+		SET sync_mark = $1, date_sync_full = CASE WHEN $2 THEN $3 ELSE date_sync_full END
+		WHERE id = $4
+	`, mark, full, tools.GetTimeUnix(), ldapId)
+	return err
+}
+
+// full reconciliation, deactivates logins that do not exist in the directory anymore
+func deactivateMissing(ldap types.Ldap, keysFound []string) error {
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	loginIds, err := login.SetLdapLoginsInactive_tx(tx, ldap.Id, keysFound)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(db.Ctx); err != nil {
+		return err
+	}
+
+	for _, loginId := range loginIds {
+		log.Info("ldap", fmt.Sprintf("login %d was removed from directory, kicking active sessions",
+			loginId))
+
+		cluster.LoginDisabled(true, loginId)
+	}
+	return nil
+}
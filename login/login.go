@@ -5,12 +5,17 @@ import (
 	"fmt"
 	"math/rand"
 	"r3/cache"
+	"r3/config"
 	"r3/db"
 	"r3/handler"
+	"r3/login/login_check"
+	"r3/login/login_hash"
+	"r3/login/login_lock"
 	"r3/login/login_setting"
 	"r3/schema"
 	"r3/tools"
 	"r3/types"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -37,7 +42,8 @@ func Get(byId int64, byString string, limit int, offset int,
 	var qb tools.QueryBuilder
 	qb.UseDollarSigns()
 	qb.AddList("SELECT", []string{"l.id", "l.ldap_id", "l.ldap_key",
-		"l.name", "l.admin", "l.no_auth", "l.active", "l.token_expiry_hours"})
+		"l.name", "l.mail", "l.admin", "l.no_auth", "l.active", "l.token_expiry_hours",
+		"l.date_locked_until", "l.date_pw_change", "l.pw_change_required"})
 
 	qb.Set("FROM", "instance.login AS l")
 
@@ -94,12 +100,20 @@ func Get(byId int64, byString string, limit int, offset int,
 		var l types.LoginAdmin
 		var records []string
 
-		if err := rows.Scan(&l.Id, &l.LdapId, &l.LdapKey, &l.Name, &l.Admin,
-			&l.NoAuth, &l.Active, &l.TokenExpiryHours, &records); err != nil {
+		var dateLockedUntil pgtype.Int8
+		if err := rows.Scan(&l.Id, &l.LdapId, &l.LdapKey, &l.Name, &l.Mail, &l.Admin,
+			&l.NoAuth, &l.Active, &l.TokenExpiryHours, &dateLockedUntil, &l.DatePwChange,
+			&l.PwChangeRequired, &records); err != nil {
 
 			return logins, 0, err
 		}
 
+		// lock & password states
+		if login_lock.IsLocked(dateLockedUntil) {
+			l.DateLockedUntil = dateLockedUntil
+		}
+		l.PwExpired = login_check.PasswordExpired(l.DatePwChange)
+
 		// process looked up login records
 		l.Records = make([]types.LoginAdminRecord, 0)
 		for _, r := range records {
@@ -188,7 +202,10 @@ func Set_tx(tx pgx.Tx, id int64, loginTemplateId pgtype.Int8, ldapId pgtype.Int4
 	}
 
 	// generate password hash, if password was provided
-	salt, hash := GenerateSaltHash(pass)
+	salt, hash, err := GenerateSaltHash(pass)
+	if err != nil {
+		return 0, err
+	}
 	saltKdf := tools.RandStringRunes(16)
 
 	if isNew {
@@ -205,6 +222,12 @@ func Set_tx(tx pgx.Tx, id int64, loginTemplateId pgtype.Int8, ldapId pgtype.Int4
 			return 0, err
 		}
 
+		if pass != "" {
+			if err := SetSaltHash_tx(tx, salt, hash, id); err != nil {
+				return 0, err
+			}
+		}
+
 		// apply default login settings from login template
 		if !loginTemplateId.Valid {
 			// get GLOBAL template
@@ -274,13 +297,66 @@ func Set_tx(tx pgx.Tx, id int64, loginTemplateId pgtype.Int8, ldapId pgtype.Int4
 	return id, setRoleIds_tx(tx, id, roleIds)
 }
 
+// mail address of login, used to send password reset links
+func SetMail_tx(tx pgx.Tx, id int64, mail pgtype.Text) error {
+	_, err := tx.Exec(db.Ctx, `
+		UPDATE instance.login
+		SET mail = $1
+		WHERE id = $2
+	`, mail, id)
+	return err
+}
+
+// requires login to change its password on next authentication
+func SetPwChangeRequired_tx(tx pgx.Tx, id int64, required bool) error {
+	_, err := tx.Exec(db.Ctx, `
+		UPDATE instance.login
+		SET pw_change_required = $1
+		WHERE id = $2
+	`, required, id)
+	return err
+}
+
+// sets new password hash, resets password age & stores replaced hash in password history
 func SetSaltHash_tx(tx pgx.Tx, salt pgtype.Text, hash pgtype.Text, id int64) error {
+	if err := setPasswordHistory_tx(tx, id); err != nil {
+		return err
+	}
 	_, err := tx.Exec(db.Ctx, `
 		UPDATE instance.login
-		SET salt = $1, hash = $2
-		WHERE id = $3
-	`, &salt, &hash, id)
+		SET salt = $1, hash = $2, date_pw_change = $3, pw_change_required = FALSE
+		WHERE id = $4
+	`, &salt, &hash, tools.GetTimeUnix(), id)
+	return err
+}
+
+// adds current salt & hash of login to its password history, only keeps as many entries as are checked
+// salt is kept for legacy hashes, which cannot be checked without it
+func setPasswordHistory_tx(tx pgx.Tx, id int64) error {
+	count := config.GetUint64("pwHistoryCount")
 
+	if count != 0 {
+		if _, err := tx.Exec(db.Ctx, `
+			INSERT INTO instance.login_password_history (login_id, salt, hash, date_create)
+			SELECT id, salt, hash, $2
+			FROM instance.login
+			WHERE id = $1
+			AND hash IS NOT NULL
+		`, id, tools.GetTimeUnix()); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.login_password_history
+		WHERE login_id = $1
+		AND id NOT IN (
+			SELECT id
+			FROM instance.login_password_history
+			WHERE login_id = $1
+			ORDER BY id DESC
+			LIMIT $2
+		)
+	`, id, count)
 	return err
 }
 
@@ -450,6 +526,15 @@ func ResetTotp_tx(tx pgx.Tx, loginId int64) error {
 	return err
 }
 
+// reset all WebAuthn credentials
+func ResetWebauthn_tx(tx pgx.Tx, loginId int64) error {
+	_, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.login_webauthn
+		WHERE login_id = $1
+	`, loginId)
+	return err
+}
+
 // updates internal login backend with logins from LDAP
 // uses unique key value to update login record
 // can optionally update login roles
@@ -517,12 +602,303 @@ func SetLdapLogin_tx(tx pgx.Tx, ldapId int32, ldapKey string, ldapName string,
 	return id, false, nil
 }
 
-func GenerateSaltHash(pw string) (salt pgtype.Text, hash pgtype.Text) {
-	if pw != "" {
-		salt.String = tools.RandStringRunes(32)
-		salt.Valid = true
-		hash.String = tools.Hash(salt.String + pw)
-		hash.Valid = true
+// deactivates logins of LDAP connection that were not found in directory
+// returns IDs of deactivated logins
+func SetLdapLoginsInactive_tx(tx pgx.Tx, ldapId int32, ldapKeysFound []string) ([]int64, error) {
+	ids := make([]int64, 0)
+	rows, err := tx.Query(db.Ctx, `
+		UPDATE instance.login
+		SET active = FALSE
+		WHERE ldap_id = $1
+		AND   active
+		AND   ldap_key <> ALL($2::text[])
+		RETURNING id
+	`, ldapId, ldapKeysFound)
+	if err != nil {
+		return ids, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// updates internal login backend with login from OpenID Connect provider
+// uses unique subject value of provider to identify login, creates login if new
+// can optionally update login roles
+// returns login ID and whether login needed to be changed
+func SetOidcLogin_tx(tx pgx.Tx, oidcId int32, oidcKey string, oidcName string,
+	oidcRoleIds []uuid.UUID, loginTemplateId pgtype.Int8, updateRoles bool) (int64, bool, error) {
+
+	// existing login details
+	var id int64
+	var nameEx string
+	var roleIds []uuid.UUID
+	var admin, active bool
+	var tokenExpiryHours pgtype.Int4
+
+	// get login details and check whether roles could be updated
+	var rolesEqual pgtype.Bool
+
+	err := tx.QueryRow(db.Ctx, `
+		SELECT r1.id, r1.name, r1.admin, r1.active, r1.token_expiry_hours, r1.roles,
+			(r1.roles <@ r2.roles AND r1.roles @> r2.roles) AS equal
+		FROM (
+			SELECT *, (
+				SELECT ARRAY_AGG(lr.role_id)
+				FROM instance.login_role AS lr
+				WHERE lr.login_id = l.id
+			) AS roles
+			FROM instance.login AS l
+			WHERE l.oidc_id = $1::integer
+			AND l.oidc_key = $2::text
+		) AS r1
+		
+		INNER JOIN (
+			SELECT $3::uuid[] AS roles
+		) AS r2 ON true
+	`, oidcId, oidcKey, oidcRoleIds).Scan(&id, &nameEx, &admin, &active,
+		&tokenExpiryHours, &roleIds, &rolesEqual)
+
+	if err != nil && err != pgx.ErrNoRows {
+		return 0, false, err
+	}
+
+	// create if new
+	// update if name or roles changed, active state is kept as logins can be disabled locally
+	oidcName = strings.ToLower(oidcName)
+	newLogin := err == pgx.ErrNoRows
+	rolesNeedUpdate := updateRoles && !rolesEqual.Bool
+
+	if newLogin || nameEx != oidcName || rolesNeedUpdate {
+
+		if rolesNeedUpdate {
+			roleIds = oidcRoleIds
+		}
+		if newLogin {
+			active = true
+		}
+
+		idSet, err := Set_tx(tx, id, loginTemplateId, pgtype.Int4{}, pgtype.Text{},
+			oidcName, "", admin, false, active, tokenExpiryHours, roleIds,
+			[]types.LoginAdminRecordSet{})
+
+		if err != nil {
+			return 0, false, err
+		}
+
+		if newLogin {
+			id = idSet
+			if _, err := tx.Exec(db.Ctx, `
+				UPDATE instance.login
+				SET oidc_id = $1, oidc_key = $2
+				WHERE id = $3
+			`, oidcId, oidcKey, id); err != nil {
+				return 0, false, err
+			}
+		}
+		return id, true, nil
+	}
+	return id, false, nil
+}
+
+// updates internal login backend with login from SAML identity provider
+// uses unique name ID of assertion to identify login, creates login if new
+// can optionally update login roles
+// returns login ID and whether login needed to be changed
+func SetSamlLogin_tx(tx pgx.Tx, samlId int32, samlKey string, samlName string,
+	samlRoleIds []uuid.UUID, loginTemplateId pgtype.Int8, updateRoles bool) (int64, bool, error) {
+
+	// existing login details
+	var id int64
+	var nameEx string
+	var roleIds []uuid.UUID
+	var admin, active bool
+	var tokenExpiryHours pgtype.Int4
+
+	// get login details and check whether roles could be updated
+	var rolesEqual pgtype.Bool
+
+	err := tx.QueryRow(db.Ctx, `
+		SELECT r1.id, r1.name, r1.admin, r1.active, r1.token_expiry_hours, r1.roles,
+			(r1.roles <@ r2.roles AND r1.roles @> r2.roles) AS equal
+		FROM (
+			SELECT *, (
+				SELECT ARRAY_AGG(lr.role_id)
+				FROM instance.login_role AS lr
+				WHERE lr.login_id = l.id
+			) AS roles
+			FROM instance.login AS l
+			WHERE l.saml_id = $1::integer
+			AND l.saml_key = $2::text
+		) AS r1
+		
+		INNER JOIN (
+			SELECT $3::uuid[] AS roles
+		) AS r2 ON true
+	`, samlId, samlKey, samlRoleIds).Scan(&id, &nameEx, &admin, &active,
+		&tokenExpiryHours, &roleIds, &rolesEqual)
+
+	if err != nil && err != pgx.ErrNoRows {
+		return 0, false, err
+	}
+
+	// create if new
+	// update if name or roles changed, active state is kept as logins can be disabled locally
+	samlName = strings.ToLower(samlName)
+	newLogin := err == pgx.ErrNoRows
+	rolesNeedUpdate := updateRoles && !rolesEqual.Bool
+
+	if newLogin || nameEx != samlName || rolesNeedUpdate {
+
+		if rolesNeedUpdate {
+			roleIds = samlRoleIds
+		}
+		if newLogin {
+			active = true
+		}
+
+		idSet, err := Set_tx(tx, id, loginTemplateId, pgtype.Int4{}, pgtype.Text{},
+			samlName, "", admin, false, active, tokenExpiryHours, roleIds,
+			[]types.LoginAdminRecordSet{})
+
+		if err != nil {
+			return 0, false, err
+		}
+
+		if newLogin {
+			id = idSet
+			if _, err := tx.Exec(db.Ctx, `
+				UPDATE instance.login
+				SET saml_id = $1, saml_key = $2
+				WHERE id = $3
+			`, samlId, samlKey, id); err != nil {
+				return 0, false, err
+			}
+		}
+		return id, true, nil
+	}
+	return id, false, nil
+}
+
+// updates internal login backend with login provisioned via SCIM
+// SCIM resource ID is the login ID (0 creates new login), external ID of identity provider is stored as key
+// password is only set if given, roles are only updated if requested
+// returns login ID and whether login needed to be changed
+func SetScimLogin_tx(tx pgx.Tx, scimId int32, id int64, scimKey pgtype.Text,
+	scimName string, pass string, scimActive bool, scimRoleIds []uuid.UUID,
+	loginTemplateId pgtype.Int8, updateRoles bool) (int64, bool, error) {
+
+	// existing login details
+	var nameEx string
+	var keyEx pgtype.Text
+	var roleIds []uuid.UUID
+	var admin, active bool
+	var tokenExpiryHours pgtype.Int4
+
+	// get login details and check whether roles could be updated
+	var rolesEqual pgtype.Bool
+
+	newLogin := id == 0
+	if !newLogin {
+		err := tx.QueryRow(db.Ctx, `
+			SELECT r1.name, r1.scim_key, r1.admin, r1.active, r1.token_expiry_hours, r1.roles,
+				(r1.roles <@ r2.roles AND r1.roles @> r2.roles) AS equal
+			FROM (
+				SELECT *, (
+					SELECT ARRAY_AGG(lr.role_id)
+					FROM instance.login_role AS lr
+					WHERE lr.login_id = l.id
+				) AS roles
+				FROM instance.login AS l
+				WHERE l.id      = $1::integer
+				AND   l.scim_id = $2::integer
+			) AS r1
+			
+			INNER JOIN (
+				SELECT $3::uuid[] AS roles
+			) AS r2 ON true
+		`, id, scimId, scimRoleIds).Scan(&nameEx, &keyEx, &admin, &active,
+			&tokenExpiryHours, &roleIds, &rolesEqual)
+
+		if err == pgx.ErrNoRows {
+			return 0, false, fmt.Errorf("no login with ID %d for SCIM connection", id)
+		}
+		if err != nil {
+			return 0, false, err
+		}
 	}
-	return salt, hash
+
+	// create if new
+	// update if name, active state, password or roles changed
+	scimName = strings.ToLower(scimName)
+	rolesNeedUpdate := updateRoles && !rolesEqual.Bool
+
+	if newLogin || nameEx != scimName || active != scimActive || pass != "" || rolesNeedUpdate {
+
+		if rolesNeedUpdate || newLogin {
+			roleIds = scimRoleIds
+		}
+
+		idSet, err := Set_tx(tx, id, loginTemplateId, pgtype.Int4{}, pgtype.Text{},
+			scimName, pass, admin, false, scimActive, tokenExpiryHours, roleIds,
+			[]types.LoginAdminRecordSet{})
+
+		if err != nil {
+			return 0, false, err
+		}
+		if newLogin {
+			id = idSet
+		}
+	}
+
+	if newLogin || keyEx != scimKey {
+		if _, err := tx.Exec(db.Ctx, `
+			UPDATE instance.login
+			SET scim_id = $1, scim_key = $2
+			WHERE id = $3
+		`, scimId, scimKey, id); err != nil {
+			return 0, false, err
+		}
+	}
+	return id, newLogin || nameEx != scimName || active != scimActive || rolesNeedUpdate, nil
+}
+
+// updates roles of login provisioned via SCIM, after its group memberships changed
+// returns whether roles needed to be changed
+func SetScimLoginRoles_tx(tx pgx.Tx, scimId int32, id int64, roleIds []uuid.UUID) (bool, error) {
+	var roleIdsEx []uuid.UUID
+	if err := tx.QueryRow(db.Ctx, `
+		SELECT COALESCE((
+			SELECT ARRAY_AGG(lr.role_id)
+			FROM instance.login_role AS lr
+			WHERE lr.login_id = l.id
+		), '{}')
+		FROM instance.login AS l
+		WHERE l.id      = $1
+		AND   l.scim_id = $2
+	`, id, scimId).Scan(&roleIdsEx); err != nil {
+		return false, err
+	}
+
+	rolesEqual := len(roleIdsEx) == len(roleIds)
+	for _, roleId := range roleIds {
+		if !slices.Contains(roleIdsEx, roleId) {
+			rolesEqual = false
+		}
+	}
+	if rolesEqual {
+		return false, nil
+	}
+	return true, setRoleIds_tx(tx, id, roleIds)
+}
+
+func GenerateSaltHash(pw string) (salt pgtype.Text, hash pgtype.Text, err error) {
+	return login_hash.Generate(pw)
 }
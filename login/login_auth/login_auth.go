@@ -1,7 +1,6 @@
 package login_auth
 
 import (
-	"database/sql"
 	"encoding/base32"
 	"errors"
 	"fmt"
@@ -9,7 +8,14 @@ import (
 	"r3/db"
 	"r3/handler"
 	"r3/ldap/ldap_auth"
+	"r3/login"
+	"r3/login/login_check"
+	"r3/login/login_hash"
 	"r3/login/login_license"
+	"r3/login/login_lock"
+	"r3/login/login_session"
+	"r3/login/login_tokenApi"
+	"r3/login/login_webauthn"
 	"r3/tools"
 	"r3/types"
 	"slices"
@@ -17,6 +23,7 @@ import (
 	"time"
 
 	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/gofrs/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/xlzd/gotp"
@@ -37,7 +44,10 @@ func authCheckSystemMode(admin bool) error {
 	return nil
 }
 
-func createToken(loginId int64, username string, admin bool, noAuth bool, tokenExpiryHours pgtype.Int4) (string, error) {
+// creates session token, token ID is registered as login session for the given client
+// returns token and session ID
+func createToken(loginId int64, username string, admin bool, noAuth bool,
+	tokenExpiryHours pgtype.Int4, client types.LoginSessionClient) (string, uuid.UUID, error) {
 
 	// token is valid for multiple days, if user decides to stay logged in
 	now := time.Now()
@@ -47,19 +57,26 @@ func createToken(loginId int64, username string, admin bool, noAuth bool, tokenE
 	} else {
 		expiryHoursTime = time.Duration(int64(config.GetUint64("tokenExpiryHours")))
 	}
+	expiry := now.Add(expiryHoursTime * time.Hour)
+
+	sessionId, err := login_session.Create(loginId, client, expiry.Unix())
+	if err != nil {
+		return "", sessionId, err
+	}
 
 	token, err := jwt.Sign(tokenPayload{
 		Payload: jwt.Payload{
 			Issuer:         "r3 application",
 			Subject:        username,
-			ExpirationTime: jwt.NumericDate(now.Add(expiryHoursTime * time.Hour)),
+			ExpirationTime: jwt.NumericDate(expiry),
 			IssuedAt:       jwt.NumericDate(now),
+			JWTID:          sessionId.String(),
 		},
 		LoginId: loginId,
 		Admin:   admin,
 		NoAuth:  noAuth,
 	}, config.GetTokenSecret())
-	return string(token), err
+	return string(token), sessionId, err
 }
 func storeLastAuthDate(loginId int64) error {
 	_, err := db.Pool.Exec(db.Ctx, `
@@ -71,15 +88,83 @@ func storeLastAuthDate(loginId int64) error {
 	return err
 }
 
+// checks whether new password can replace the current one (complexity, history)
+func checkPasswordNew(loginId int64, password string, passwordNew string) error {
+	if passwordNew == password {
+		return errors.New("PW_USED_BEFORE")
+	}
+	if err := login_check.PasswordComplexity(passwordNew); err != nil {
+		return err
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	return login_check.PasswordHistory(tx, loginId, passwordNew)
+}
+
+// sets new password of login, replaces expired or admin-defined password
+func setPasswordNew(loginId int64, passwordNew string) error {
+	salt, hash, err := login.GenerateSaltHash(passwordNew)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	if err := login.SetSaltHash_tx(tx, salt, hash, loginId); err != nil {
+		return err
+	}
+	return tx.Commit(db.Ctx)
+}
+
+// replaces password hash of login with one generated with current algorithm & cost parameters
+func setHash(loginId int64, password string) error {
+	salt, hash, err := login_hash.Generate(password)
+	if err != nil {
+		return err
+	}
+	_, err = db.Pool.Exec(db.Ctx, `
+		UPDATE instance.login
+		SET salt = $1, hash = $2
+		WHERE id = $3
+	`, salt, hash, loginId)
+
+	return err
+}
+
+// returned if password of login must be changed before authentication can complete
+// reason is set if the given new password was not accepted (PW_TOO_SHORT, PW_USED_BEFORE, ...)
+type PwChangeRequiredError struct {
+	Reason string
+}
+
+func (e PwChangeRequiredError) Error() string {
+	if e.Reason == "" {
+		return "password change required"
+	}
+	return fmt.Sprintf("password change required, new password not accepted: %s", e.Reason)
+}
+
 // performs authentication attempt for user by using username and password
-// returns JWT, KDF salt, MFA token list (if MFA is required)
-func User(username string, password string, mfaTokenId pgtype.Int4,
-	mfaTokenPin pgtype.Text, grantLoginId *int64, grantAdmin *bool,
-	grantNoAuth *bool) (string, string, []types.LoginMfaToken, error) {
+// new password must be given if password change is required (expired or requested by admin)
+// returns JWT, KDF salt, MFA token list & WebAuthn options (if MFA is required)
+func User(username string, password string, passwordNew string, mfaTokenId pgtype.Int4,
+	mfaTokenPin pgtype.Text, mfaWebauthn types.LoginWebauthnAssertion,
+	client types.LoginSessionClient, grantLoginId *int64, grantAdmin *bool,
+	grantNoAuth *bool, grantSessionId *uuid.UUID) (string, string,
+	[]types.LoginMfaToken, *types.LoginWebauthnRequestOptions, error) {
 
 	mfaTokens := make([]types.LoginMfaToken, 0)
 	if username == "" {
-		return "", "", mfaTokens, errors.New("username not given")
+		return "", "", mfaTokens, nil, errors.New("username not given")
 	}
 
 	// usernames are case insensitive
@@ -87,54 +172,92 @@ func User(username string, password string, mfaTokenId pgtype.Int4,
 
 	var loginId int64
 	var ldapId pgtype.Int4
-	var salt sql.NullString
-	var hash sql.NullString
+	var salt pgtype.Text
+	var hash pgtype.Text
 	var saltKdf string
 	var admin bool
 	var noAuth bool
 	var tokenExpiryHours pgtype.Int4
+	var dateLockedUntil pgtype.Int8
+	var datePwChange pgtype.Int8
+	var pwChangeRequired bool
 
 	err := db.Pool.QueryRow(db.Ctx, `
-		SELECT id, ldap_id, salt, hash, salt_kdf, admin, no_auth, token_expiry_hours
+		SELECT id, ldap_id, salt, hash, salt_kdf, admin, no_auth, token_expiry_hours,
+			date_locked_until, date_pw_change, pw_change_required
 		FROM instance.login
 		WHERE active
 		AND name = $1
-	`, username).Scan(&loginId, &ldapId, &salt, &hash, &saltKdf, &admin, &noAuth, &tokenExpiryHours)
+	`, username).Scan(&loginId, &ldapId, &salt, &hash, &saltKdf, &admin, &noAuth,
+		&tokenExpiryHours, &dateLockedUntil, &datePwChange, &pwChangeRequired)
 
 	if err != nil && err != pgx.ErrNoRows {
-		return "", "", mfaTokens, err
+		return "", "", mfaTokens, nil, err
 	}
 
 	// username not found / user inactive must result in same response as authentication failed
 	// otherwise we can probe the system for valid user names
 	if err == pgx.ErrNoRows {
-		return "", "", mfaTokens, errors.New(handler.ErrAuthFailed)
+		return "", "", mfaTokens, nil, errors.New(handler.ErrAuthFailed)
 	}
 
 	if !noAuth && password == "" {
-		return "", "", mfaTokens, errors.New("password not given")
+		return "", "", mfaTokens, nil, errors.New("password not given")
 	}
 
+	// locked logins must result in same response as authentication failed
+	// otherwise we can probe the system for valid user names
+	if !noAuth && login_lock.IsLocked(dateLockedUntil) {
+		return "", "", mfaTokens, nil, errors.New(handler.ErrAuthFailed)
+	}
+
+	// local password must be changed if requested by admin or if maximum age is exceeded
+	pwChange := !noAuth && !ldapId.Valid && (pwChangeRequired || login_check.PasswordExpired(datePwChange))
+
 	if !noAuth {
 		if ldapId.Valid {
 			// authentication against LDAP
 			if err := ldap_auth.Check(ldapId.Int32, username, password); err != nil {
-				return "", "", mfaTokens, errors.New(handler.ErrAuthFailed)
+				if err := login_lock.BadAttempt(loginId); err != nil {
+					return "", "", mfaTokens, nil, err
+				}
+				return "", "", mfaTokens, nil, errors.New(handler.ErrAuthFailed)
 			}
 		} else {
 			// authentication against stored hash
-			if !hash.Valid || !salt.Valid || hash.String != tools.Hash(salt.String+password) {
-				return "", "", mfaTokens, errors.New(handler.ErrAuthFailed)
+			ok, rehash := login_hash.Check(salt, hash, password)
+			if !ok {
+				if err := login_lock.BadAttempt(loginId); err != nil {
+					return "", "", mfaTokens, nil, err
+				}
+				return "", "", mfaTokens, nil, errors.New(handler.ErrAuthFailed)
+			}
+
+			// replace legacy or outdated hash, now that password is known
+			if rehash {
+				if err := setHash(loginId, password); err != nil {
+					return "", "", mfaTokens, nil, err
+				}
 			}
 		}
 	}
 
 	if err := authCheckSystemMode(admin); err != nil {
-		return "", "", mfaTokens, err
+		return "", "", mfaTokens, nil, err
 	}
 
 	// login ok
 
+	// new password is checked before MFA, so that MFA does not need to be repeated if it is not accepted
+	if pwChange {
+		if passwordNew == "" {
+			return "", "", mfaTokens, nil, PwChangeRequiredError{}
+		}
+		if err := checkPasswordNew(loginId, password, passwordNew); err != nil {
+			return "", "", mfaTokens, nil, PwChangeRequiredError{Reason: err.Error()}
+		}
+	}
+
 	if mfaTokenId.Valid && mfaTokenPin.Valid {
 
 		// validate provided MFA token
@@ -146,13 +269,26 @@ func User(username string, password string, mfaTokenId pgtype.Int4,
 			AND   id       = $2
 			AND   context  = 'totp'
 		`, loginId, mfaTokenId.Int32).Scan(&mfaToken); err != nil {
-			return "", "", mfaTokens, err
+			return "", "", mfaTokens, nil, err
 		}
 
 		if mfaTokenPin.String != gotp.NewDefaultTOTP(base32.StdEncoding.WithPadding(
 			base32.NoPadding).EncodeToString(mfaToken)).Now() {
 
-			return "", "", mfaTokens, errors.New(handler.ErrAuthFailed)
+			if err := login_lock.BadAttempt(loginId); err != nil {
+				return "", "", mfaTokens, nil, err
+			}
+			return "", "", mfaTokens, nil, errors.New(handler.ErrAuthFailed)
+		}
+
+	} else if mfaWebauthn.CredentialId != "" {
+
+		// validate provided WebAuthn assertion
+		if _, err := login_webauthn.Assert(loginId, mfaWebauthn); err != nil {
+			if err := login_lock.BadAttempt(loginId); err != nil {
+				return "", "", mfaTokens, nil, err
+			}
+			return "", "", mfaTokens, nil, errors.New(handler.ErrAuthFailed)
 		}
 
 	} else {
@@ -164,48 +300,81 @@ func User(username string, password string, mfaTokenId pgtype.Int4,
 			AND   context  = 'totp'
 		`, loginId)
 		if err != nil {
-			return "", "", mfaTokens, err
+			return "", "", mfaTokens, nil, err
 		}
 
 		for rows.Next() {
 			var m types.LoginMfaToken
 			if err := rows.Scan(&m.Id, &m.Name); err != nil {
-				return "", "", mfaTokens, err
+				return "", "", mfaTokens, nil, err
 			}
 			mfaTokens = append(mfaTokens, m)
 		}
 		rows.Close()
 
-		// MFA tokens available, return with list
-		if len(mfaTokens) != 0 {
-			return "", "", mfaTokens, nil
+		// get available WebAuthn credentials
+		credentials, err := login_webauthn.Get(loginId)
+		if err != nil {
+			return "", "", mfaTokens, nil, err
+		}
+		var mfaWebauthnOptions *types.LoginWebauthnRequestOptions
+		if len(credentials) != 0 {
+			o, err := login_webauthn.GetRequestOptions(loginId)
+			if err != nil {
+				return "", "", mfaTokens, nil, err
+			}
+			mfaWebauthnOptions = &o
+		}
+
+		// MFA options available, return with list
+		if len(mfaTokens) != 0 || mfaWebauthnOptions != nil {
+			return "", "", mfaTokens, mfaWebauthnOptions, nil
+		}
+	}
+
+	// set new password
+	if pwChange {
+		if err := setPasswordNew(loginId, passwordNew); err != nil {
+			return "", "", mfaTokens, nil, err
 		}
 	}
 
 	// create session token
-	token, err := createToken(loginId, username, admin, noAuth, tokenExpiryHours)
+	token, sessionId, err := createToken(loginId, username, admin, noAuth, tokenExpiryHours, client)
 	if err != nil {
-		return "", "", mfaTokens, err
+		return "", "", mfaTokens, nil, err
 	}
 
 	// everything in order, auth successful
 	
 	if err := login_license.RequestConcurrent(loginId, admin); err != nil {
-		return "", "", mfaTokens, err
+		return "", "", mfaTokens, nil, err
 	}
 	if err := storeLastAuthDate(loginId); err != nil {
-		return "", "", mfaTokens, err
+		return "", "", mfaTokens, nil, err
+	}
+	if err := login_lock.Reset(loginId); err != nil {
+		return "", "", mfaTokens, nil, err
 	}
 
 	*grantLoginId = loginId
 	*grantAdmin = admin
 	*grantNoAuth = noAuth
-	return token, saltKdf, mfaTokens, nil
+	*grantSessionId = sessionId
+	return token, saltKdf, mfaTokens, nil, nil
 }
 
 // performs authentication attempt for user by using existing JWT token, signed by server
 // returns username
 func Token(token string, grantLoginId *int64, grantAdmin *bool, grantNoAuth *bool) (string, error) {
+	var sessionId uuid.UUID
+	return TokenSession(token, "", grantLoginId, grantAdmin, grantNoAuth, &sessionId)
+}
+
+// same as Token() but also grants ID of the session that the token belongs to
+// address of session is updated, if given
+func TokenSession(token string, address string, grantLoginId *int64,
+	grantAdmin *bool, grantNoAuth *bool, grantSessionId *uuid.UUID) (string, error) {
 
 	if token == "" {
 		return "", errors.New("empty token")
@@ -247,6 +416,15 @@ func Token(token string, grantLoginId *int64, grantAdmin *bool, grantNoAuth *boo
 		return "", errors.New("login inactive")
 	}
 
+	// check if session was revoked, tokens without session ID are not accepted
+	sessionId, err := uuid.FromString(tp.JWTID)
+	if err != nil {
+		return "", errors.New("token has no valid session ID")
+	}
+	if err := login_session.Check(sessionId, tp.LoginId, address); err != nil {
+		return "", err
+	}
+
 	// everything in order, auth successful
 	if err := login_license.RequestConcurrent(tp.LoginId, tp.Admin); err != nil {
 		return "", err
@@ -257,13 +435,197 @@ func Token(token string, grantLoginId *int64, grantAdmin *bool, grantNoAuth *boo
 	*grantLoginId = tp.LoginId
 	*grantAdmin = tp.Admin
 	*grantNoAuth = tp.NoAuth
+	*grantSessionId = sessionId
 	return name, nil
 }
 
+// performs authentication attempt for REST API by using personal access token
+// scope of token (APIs, methods) must be checked by caller
+func TokenApi(token string, address string, grantLoginId *int64,
+	grantToken *types.LoginTokenApi) error {
+
+	if token == "" {
+		return errors.New("empty token")
+	}
+
+	t, err := login_tokenApi.GetValid(token, address)
+	if err != nil {
+		return err
+	}
+
+	var admin bool
+	if err := db.Pool.QueryRow(db.Ctx, `
+		SELECT admin
+		FROM instance.login
+		WHERE id = $1
+	`, t.LoginId).Scan(&admin); err != nil {
+		return err
+	}
+	if err := authCheckSystemMode(admin); err != nil {
+		return err
+	}
+
+	// everything in order, auth successful
+	if err := login_license.RequestConcurrent(t.LoginId, admin); err != nil {
+		return err
+	}
+	*grantLoginId = t.LoginId
+	*grantToken = t
+	return nil
+}
+
+// performs authentication attempt for user by using one-time code from completed OpenID Connect authentication
+// returns JWT and username
+func Oidc(code string, client types.LoginSessionClient, grantLoginId *int64,
+	grantAdmin *bool, grantNoAuth *bool, grantSessionId *uuid.UUID) (string, string, error) {
+
+	return oneTimeCode("oidc", code, client, grantLoginId, grantAdmin, grantNoAuth, grantSessionId)
+}
+
+// performs authentication attempt for user by using one-time code from completed SAML authentication
+// returns JWT and username
+func Saml(code string, client types.LoginSessionClient, grantLoginId *int64,
+	grantAdmin *bool, grantNoAuth *bool, grantSessionId *uuid.UUID) (string, string, error) {
+
+	return oneTimeCode("saml", code, client, grantLoginId, grantAdmin, grantNoAuth, grantSessionId)
+}
+
+// one-time codes are issued after successful authentication with an external identity provider
+// provider can be 'oidc' or 'saml', codes are only valid for logins of the same provider type
+func oneTimeCode(provider string, code string, client types.LoginSessionClient, grantLoginId *int64,
+	grantAdmin *bool, grantNoAuth *bool, grantSessionId *uuid.UUID) (string, string, error) {
+
+	if code == "" {
+		return "", "", errors.New("empty code")
+	}
+	if !slices.Contains([]string{"oidc", "saml"}, provider) {
+		return "", "", fmt.Errorf("invalid identity provider type '%s'", provider)
+	}
+
+	// codes are single use
+	var loginId int64
+	var name string
+	var admin bool
+	var noAuth bool
+	var tokenExpiryHours pgtype.Int4
+
+	err := db.Pool.QueryRow(db.Ctx, fmt.Sprintf(`
+		WITH c AS (
+			DELETE FROM instance.%s_code
+			WHERE code = $1
+			RETURNING login_id, date_expiry
+		)
+		SELECT l.id, l.name, l.admin, l.no_auth, l.token_expiry_hours
+		FROM c
+		INNER JOIN instance.login AS l ON l.id = c.login_id
+		WHERE c.date_expiry >= $2
+		AND   l.active
+		AND   l.%s_id IS NOT NULL
+	`, provider, provider), code, tools.GetTimeUnix()).Scan(&loginId, &name, &admin, &noAuth, &tokenExpiryHours)
+
+	if err == pgx.ErrNoRows {
+		return "", "", errors.New(handler.ErrAuthFailed)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := authCheckSystemMode(admin); err != nil {
+		return "", "", err
+	}
+
+	// create session token
+	token, sessionId, err := createToken(loginId, name, admin, noAuth, tokenExpiryHours, client)
+	if err != nil {
+		return "", "", err
+	}
+
+	// everything in order, auth successful
+	if err := login_license.RequestConcurrent(loginId, admin); err != nil {
+		return "", "", err
+	}
+	if err := storeLastAuthDate(loginId); err != nil {
+		return "", "", err
+	}
+	*grantLoginId = loginId
+	*grantAdmin = admin
+	*grantNoAuth = noAuth
+	*grantSessionId = sessionId
+	return token, name, nil
+}
+
+// performs passwordless authentication attempt for user by using WebAuthn assertion (passkey)
+// returns JWT and username
+func Webauthn(assertion types.LoginWebauthnAssertion, client types.LoginSessionClient, grantLoginId *int64,
+	grantAdmin *bool, grantNoAuth *bool, grantSessionId *uuid.UUID) (string, string, error) {
+
+	// login is known once credential is found, failed attempts are then counted for it
+	loginId, err := login_webauthn.Assert(0, assertion)
+	if err != nil {
+		if loginId != 0 {
+			if err := login_lock.BadAttempt(loginId); err != nil {
+				return "", "", err
+			}
+		}
+		return "", "", errors.New(handler.ErrAuthFailed)
+	}
+
+	var name string
+	var admin bool
+	var noAuth bool
+	var tokenExpiryHours pgtype.Int4
+	var dateLockedUntil pgtype.Int8
+
+	err = db.Pool.QueryRow(db.Ctx, `
+		SELECT name, admin, no_auth, token_expiry_hours, date_locked_until
+		FROM instance.login
+		WHERE active
+		AND id = $1
+	`, loginId).Scan(&name, &admin, &noAuth, &tokenExpiryHours, &dateLockedUntil)
+
+	if err == pgx.ErrNoRows {
+		return "", "", errors.New(handler.ErrAuthFailed)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if login_lock.IsLocked(dateLockedUntil) {
+		return "", "", errors.New(handler.ErrAuthFailed)
+	}
+
+	if err := authCheckSystemMode(admin); err != nil {
+		return "", "", err
+	}
+
+	// create session token
+	token, sessionId, err := createToken(loginId, name, admin, noAuth, tokenExpiryHours, client)
+	if err != nil {
+		return "", "", err
+	}
+
+	// everything in order, auth successful
+	if err := login_license.RequestConcurrent(loginId, admin); err != nil {
+		return "", "", err
+	}
+	if err := storeLastAuthDate(loginId); err != nil {
+		return "", "", err
+	}
+	if err := login_lock.Reset(loginId); err != nil {
+		return "", "", err
+	}
+	*grantLoginId = loginId
+	*grantAdmin = admin
+	*grantNoAuth = noAuth
+	*grantSessionId = sessionId
+	return token, name, nil
+}
+
 // performs authentication for user by using fixed (permanent) token
 // used for application access (like ICS download or fat-client access)
+// session token is only created if requested (grantToken & grantSessionId != nil)
 // cannot grant admin access
-func TokenFixed(loginId int64, context string, tokenFixed string, grantLanguageCode *string, grantToken *string) error {
+func TokenFixed(loginId int64, context string, tokenFixed string, client types.LoginSessionClient,
+	grantLanguageCode *string, grantToken *string, grantSessionId *uuid.UUID) error {
 
 	if tokenFixed == "" {
 		return errors.New("empty token")
@@ -297,6 +659,9 @@ func TokenFixed(loginId int64, context string, tokenFixed string, grantLanguageC
 
 	// everything in order, auth successful
 	*grantLanguageCode = languageCode
-	*grantToken, err = createToken(loginId, username, false, false, pgtype.Int4{})
+	if grantToken == nil || grantSessionId == nil {
+		return nil
+	}
+	*grantToken, *grantSessionId, err = createToken(loginId, username, false, false, pgtype.Int4{}, client)
 	return err
 }
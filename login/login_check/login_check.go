@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"r3/config"
 	"r3/db"
+	"r3/login/login_hash"
 	"r3/tools"
 	"regexp"
 
@@ -12,7 +13,7 @@ import (
 )
 
 func Password(tx pgx.Tx, loginId int64, pwOld string) error {
-	var salt, hash string
+	var salt, hash pgtype.Text
 	var ldapId pgtype.Int4
 
 	if err := tx.QueryRow(db.Ctx, `
@@ -27,12 +28,66 @@ func Password(tx pgx.Tx, loginId int64, pwOld string) error {
 	if ldapId.Valid {
 		return fmt.Errorf("cannot set password for LDAP login")
 	}
-	if hash != tools.Hash(salt+pwOld) {
+	if ok, _ := login_hash.Check(salt, hash, pwOld); !ok {
 		return fmt.Errorf("PW_CURRENT_WRONG")
 	}
 	return nil
 }
 
+// checks new password against current and previous passwords of login
+// number of previous passwords is defined by config, 0 disables the check
+func PasswordHistory(tx pgx.Tx, loginId int64, pw string) error {
+	count := config.GetUint64("pwHistoryCount")
+	if count == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(db.Ctx, `
+		SELECT salt, hash
+		FROM instance.login
+		WHERE id = $1
+		AND hash IS NOT NULL
+		UNION ALL (
+			SELECT salt, hash
+			FROM instance.login_password_history
+			WHERE login_id = $1
+			ORDER BY id DESC
+			LIMIT $2
+		)
+	`, loginId, count)
+	if err != nil {
+		return err
+	}
+
+	hashes := make([][2]pgtype.Text, 0)
+	for rows.Next() {
+		var salt, hash pgtype.Text
+		if err := rows.Scan(&salt, &hash); err != nil {
+			rows.Close()
+			return err
+		}
+		hashes = append(hashes, [2]pgtype.Text{salt, hash})
+	}
+	rows.Close()
+
+	for _, h := range hashes {
+		if ok, _ := login_hash.Check(h[0], h[1], pw); ok {
+			return fmt.Errorf("PW_USED_BEFORE")
+		}
+	}
+	return nil
+}
+
+// returns whether password of login has exceeded its maximum age
+// logins without password change date (no local password) do not expire
+func PasswordExpired(datePwChange pgtype.Int8) bool {
+	days := config.GetUint64("pwMaxAgeDays")
+	if days == 0 || !datePwChange.Valid {
+		return false
+	}
+	return datePwChange.Int64+(int64(days)*86400) < tools.GetTimeUnix()
+}
+
 func PasswordComplexity(pw string) error {
 
 	if len(pw) < int(config.GetUint64("pwLengthMin")) {
@@ -0,0 +1,130 @@
+// password hashes of local logins
+// current hashes use Argon2id, stored in PHC string format: $argon2id$v=19$m=65536,t=3,p=2$SALT$KEY
+// legacy hashes (SHA256 of salt + password, without prefix) can still be checked and are replaced on next login
+
+package login_hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"r3/config"
+	"r3/tools"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	keyLength  uint32 = 32
+	saltLength        = 16
+)
+
+type argon2Params struct {
+	memory     uint32 // in KiB
+	iterations uint32
+	threads    uint8
+}
+
+// returns salt & hash for password, salt is only used by legacy hashes and is therefore empty
+func Generate(pw string) (pgtype.Text, pgtype.Text, error) {
+	if pw == "" {
+		return pgtype.Text{}, pgtype.Text{}, nil
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return pgtype.Text{}, pgtype.Text{}, err
+	}
+
+	p := getParamsFromConfig()
+	key := argon2.IDKey([]byte(pw), salt, p.iterations, p.memory, p.threads, keyLength)
+
+	return pgtype.Text{}, pgtype.Text{
+		String: fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, p.memory, p.iterations, p.threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)),
+		Valid: true,
+	}, nil
+}
+
+// checks password against stored salt & hash
+// returns whether password matches and whether hash should be replaced (legacy hash or outdated cost parameters)
+func Check(salt pgtype.Text, hash pgtype.Text, pw string) (bool, bool) {
+	if !hash.Valid || pw == "" {
+		return false, false
+	}
+
+	if !strings.HasPrefix(hash.String, "$") {
+		// legacy hash: SHA256 of salt + password
+		if !salt.Valid {
+			return false, false
+		}
+		return subtle.ConstantTimeCompare([]byte(hash.String),
+			[]byte(tools.Hash(salt.String+pw))) == 1, true
+	}
+
+	p, saltHash, key, err := parseArgon2id(hash.String)
+	if err != nil {
+		return false, false
+	}
+	keyCheck := argon2.IDKey([]byte(pw), saltHash, p.iterations, p.memory, p.threads, uint32(len(key)))
+
+	if subtle.ConstantTimeCompare(key, keyCheck) != 1 {
+		return false, false
+	}
+	return true, p != getParamsFromConfig()
+}
+
+func getParamsFromConfig() argon2Params {
+	p := argon2Params{
+		memory:     uint32(config.GetUint64("pwArgon2Memory")),
+		iterations: uint32(config.GetUint64("pwArgon2Iterations")),
+		threads:    uint8(min(config.GetUint64("pwArgon2Threads"), 255)),
+	}
+
+	// apply minimums
+	if p.iterations < 1 {
+		p.iterations = 1
+	}
+	if p.threads < 1 {
+		p.threads = 1
+	}
+	if p.memory < 8*uint32(p.threads) {
+		p.memory = 8 * uint32(p.threads)
+	}
+	return p
+}
+
+func parseArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	var version int
+
+	// expected: ["", "argon2id", "v=19", "m=65536,t=3,p=2", SALT, KEY]
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, fmt.Errorf("unsupported hash format")
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, err
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported Argon2 version %d", version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.threads); err != nil {
+		return p, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, err
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,63 @@
+// temporary lockout of logins after repeated failed authentication attempts
+// complements bruteforce protection, which blocks hosts instead of logins
+
+package login_lock
+
+import (
+	"r3/config"
+	"r3/db"
+	"r3/tools"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// returns whether login is currently locked
+func IsLocked(dateLockedUntil pgtype.Int8) bool {
+	return dateLockedUntil.Valid && dateLockedUntil.Int64 > tools.GetTimeUnix()
+}
+
+// counts failed authentication attempt for login
+// login is locked once the configured number of attempts is reached, counter starts anew afterwards
+func BadAttempt(loginId int64) error {
+	attempts := int64(config.GetUint64("loginLockAttempts"))
+	if attempts == 0 {
+		return nil
+	}
+	dateLockedUntil := tools.GetTimeUnix() + (int64(config.GetUint64("loginLockMinutes")) * 60)
+
+	_, err := db.Pool.Exec(db.Ctx, `
+		UPDATE instance.login
+		SET auth_fail_count = CASE
+				WHEN auth_fail_count + 1 >= $2 THEN 0
+				ELSE auth_fail_count + 1
+			END,
+			date_locked_until = CASE
+				WHEN auth_fail_count + 1 >= $2 THEN $3
+				ELSE date_locked_until
+			END
+		WHERE id = $1
+	`, loginId, attempts, dateLockedUntil)
+	return err
+}
+
+// resets failed attempts after successful authentication
+func Reset(loginId int64) error {
+	_, err := db.Pool.Exec(db.Ctx, `
+		UPDATE instance.login
+		SET auth_fail_count = 0, date_locked_until = NULL
+		WHERE id = $1
+		AND (auth_fail_count <> 0 OR date_locked_until IS NOT NULL)
+	`, loginId)
+	return err
+}
+
+// unlocks login, used by admins
+func Unlock_tx(tx pgx.Tx, loginId int64) error {
+	_, err := tx.Exec(db.Ctx, `
+		UPDATE instance.login
+		SET auth_fail_count = 0, date_locked_until = NULL
+		WHERE id = $1
+	`, loginId)
+	return err
+}
@@ -0,0 +1,184 @@
+package login_passwordReset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"r3/config"
+	"r3/login"
+	"r3/login/login_check"
+	"r3/login/login_session"
+	"strings"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	audience = "password reset" // separates reset tokens from session tokens, both are signed with the same secret
+
+	mailSubject = "Password reset"
+	mailBody    = `<p>A password reset was requested for your login '{NAME}'.</p>
+		<p>To choose a new password, please open the following link within {MINUTES} minutes: <a href="{URL}">{URL}</a></p>
+		<p>If you did not request a password reset, you can ignore this message. Your password stays unchanged.</p>`
+)
+
+type resetPayload struct {
+	jwt.Payload
+	LoginId int64 `json:"loginId"`
+}
+
+// only logins authenticated by the internal backend with a mail address can reset their password
+// logins from LDAP or external identity providers authenticate elsewhere
+var loginCanResetSql = `
+	active
+	AND NOT no_auth
+	AND ldap_id IS NULL
+	AND oidc_id IS NULL
+	AND saml_id IS NULL
+	AND mail IS NOT NULL
+	AND mail <> ''
+`
+
+// sends mail with signed, single-use password reset link to login
+// returns without error if login cannot reset its password, to avoid probing for valid usernames
+func Request_tx(ctx context.Context, tx pgx.Tx, username string) error {
+
+	if config.GetUint64("pwResetEnable") == 0 {
+		return errors.New("password reset is disabled")
+	}
+	if username == "" {
+		return errors.New("username not given")
+	}
+
+	// usernames are case insensitive
+	username = strings.ToLower(username)
+
+	var loginId int64
+	var mail string
+	err := tx.QueryRow(ctx, fmt.Sprintf(`
+		SELECT id, mail
+		FROM instance.login
+		WHERE name = $1
+		AND %s
+	`, loginCanResetSql), username).Scan(&loginId, &mail)
+
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	expiryMinutes := config.GetUint64("pwResetExpiryMinutes")
+	expiry := now.Add(time.Duration(int64(expiryMinutes)) * time.Minute)
+
+	// only the latest requested link is valid
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM instance.login_password_reset
+		WHERE login_id    = $1
+		OR    date_expiry < $2
+	`, loginId, now.Unix()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO instance.login_password_reset (id, login_id, date_expiry)
+		VALUES ($1,$2,$3)
+	`, id, loginId, expiry.Unix()); err != nil {
+		return err
+	}
+
+	token, err := jwt.Sign(resetPayload{
+		Payload: jwt.Payload{
+			Issuer:         "r3 application",
+			Subject:        username,
+			Audience:       jwt.Audience{audience},
+			ExpirationTime: jwt.NumericDate(expiry),
+			IssuedAt:       jwt.NumericDate(now),
+			JWTID:          id.String(),
+		},
+		LoginId: loginId,
+	}, config.GetTokenSecret())
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://%s/#/?pwReset=%s", config.GetString("publicHostName"), token)
+	body := mailBody
+	body = strings.Replace(body, "{NAME}", html.EscapeString(username), -1)
+	body = strings.Replace(body, "{MINUTES}", fmt.Sprintf("%d", expiryMinutes), -1)
+	body = strings.Replace(body, "{URL}", url, -1)
+
+	_, err = tx.Exec(ctx, `SELECT instance.mail_send($1,$2,$3)`, mailSubject, body, mail)
+	return err
+}
+
+// sets new password for login of password reset token, token is consumed
+// existing sessions of login might be compromised, they are revoked
+// returns login ID
+func Set_tx(ctx context.Context, tx pgx.Tx, token string, password string) (int64, error) {
+
+	if config.GetUint64("pwResetEnable") == 0 {
+		return 0, errors.New("password reset is disabled")
+	}
+
+	var p resetPayload
+	if _, err := jwt.Verify([]byte(token), config.GetTokenSecret(), &p,
+		jwt.ValidatePayload(&p.Payload, jwt.ExpirationTimeValidator(time.Now()),
+			jwt.AudienceValidator(jwt.Audience{audience}))); err != nil {
+
+		return 0, err
+	}
+	id, err := uuid.FromString(p.JWTID)
+	if err != nil {
+		return 0, err
+	}
+
+	// check new password before consuming token, user can retry with another password
+	if err := login_check.PasswordComplexity(password); err != nil {
+		return 0, err
+	}
+
+	// tokens are single use
+	var exists bool
+	if err := tx.QueryRow(ctx, fmt.Sprintf(`
+		WITH r AS (
+			DELETE FROM instance.login_password_reset
+			WHERE id       = $1
+			AND   login_id = $2
+			RETURNING login_id
+		)
+		SELECT EXISTS(
+			SELECT id
+			FROM instance.login
+			WHERE id = (SELECT login_id FROM r)
+			AND %s
+		)
+	`, loginCanResetSql), id, p.LoginId).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, errors.New("password reset token is invalid or was already used")
+	}
+
+	if err := login_check.PasswordHistory(tx, p.LoginId, password); err != nil {
+		return 0, err
+	}
+
+	salt, hash, err := login.GenerateSaltHash(password)
+	if err != nil {
+		return 0, err
+	}
+	if err := login.SetSaltHash_tx(tx, salt, hash, p.LoginId); err != nil {
+		return 0, err
+	}
+	return p.LoginId, login_session.DelByLogin_tx(ctx, tx, p.LoginId)
+}
@@ -0,0 +1,135 @@
+package login_session
+
+import (
+	"context"
+	"errors"
+	"r3/db"
+	"r3/tools"
+	"r3/types"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// last seen date is only updated once per interval, to avoid writes on every request
+var lastSeenIntervalSeconds int64 = 60
+
+// registers new session for login, session ID is used as JWT ID
+func Create(loginId int64, client types.LoginSessionClient, dateExpiry int64) (uuid.UUID, error) {
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return id, err
+	}
+	now := tools.GetTimeUnix()
+
+	// clean up expired sessions
+	if _, err := db.Pool.Exec(db.Ctx, `
+		DELETE FROM instance.login_session
+		WHERE date_expiry < $1
+	`, now); err != nil {
+		return id, err
+	}
+
+	_, err = db.Pool.Exec(db.Ctx, `
+		INSERT INTO instance.login_session (id, login_id, address, device,
+			user_agent, date_create, date_expiry, date_last_seen)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$6)
+	`, id, loginId, client.Address, client.Device, client.UserAgent, now, dateExpiry)
+
+	return id, err
+}
+
+// checks that session of login exists and was not revoked
+// updates last seen date and, if given, client address
+func Check(id uuid.UUID, loginId int64, address string) error {
+
+	var addressLast string
+	var dateLastSeen int64
+	err := db.Pool.QueryRow(db.Ctx, `
+		SELECT address, date_last_seen
+		FROM instance.login_session
+		WHERE id       = $1
+		AND   login_id = $2
+	`, id, loginId).Scan(&addressLast, &dateLastSeen)
+
+	if err == pgx.ErrNoRows {
+		return errors.New("session does not exist or was revoked")
+	}
+	if err != nil {
+		return err
+	}
+
+	now := tools.GetTimeUnix()
+	if address == "" {
+		address = addressLast
+	}
+	if address == addressLast && now-dateLastSeen < lastSeenIntervalSeconds {
+		return nil
+	}
+
+	_, err = db.Pool.Exec(db.Ctx, `
+		UPDATE instance.login_session
+		SET address = $1, date_last_seen = $2
+		WHERE id = $3
+	`, address, now, id)
+
+	return err
+}
+
+// deletes session, loginId > 0 restricts deletion to sessions of this login
+// returns login ID of deleted session, 0 if nothing was deleted
+func Del(id uuid.UUID, loginId int64) (int64, error) {
+
+	var loginIdSession int64
+	err := db.Pool.QueryRow(db.Ctx, `
+		DELETE FROM instance.login_session
+		WHERE id = $1
+		AND ($2 = 0 OR login_id = $2)
+		RETURNING login_id
+	`, id, loginId).Scan(&loginIdSession)
+
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+	return loginIdSession, err
+}
+
+// deletes all sessions of login
+func DelByLogin_tx(ctx context.Context, tx pgx.Tx, loginId int64) error {
+	_, err := tx.Exec(ctx, `
+		DELETE FROM instance.login_session
+		WHERE login_id = $1
+	`, loginId)
+	return err
+}
+
+// returns active sessions of login, most recently used first
+func Get(loginId int64) ([]types.LoginSession, error) {
+
+	sessions := make([]types.LoginSession, 0)
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT s.id, s.login_id, l.name, s.address, s.device, s.user_agent,
+			s.date_create, s.date_expiry, s.date_last_seen
+		FROM instance.login_session AS s
+		INNER JOIN instance.login   AS l ON l.id = s.login_id
+		WHERE s.login_id    =  $1
+		AND   s.date_expiry >= $2
+		ORDER BY s.date_last_seen DESC
+	`, loginId, tools.GetTimeUnix())
+	if err != nil {
+		return sessions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s types.LoginSession
+		if err := rows.Scan(&s.Id, &s.LoginId, &s.LoginName, &s.Address, &s.Device,
+			&s.UserAgent, &s.DateCreate, &s.DateExpiry, &s.DateLastSeen); err != nil {
+
+			return sessions, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
@@ -0,0 +1,217 @@
+package login_tokenApi
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"r3/db"
+	"r3/tools"
+	"r3/types"
+	"slices"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// API tokens are identified by their prefix, to be distinguishable from session tokens (JWTs)
+var Prefix = "r3pat_"
+
+var (
+	methodsValid = []string{"DELETE", "GET", "POST"}
+
+	// last use is only updated once per interval, to avoid writes on every request
+	usedIntervalSeconds int64 = 60
+)
+
+// returns hash of token, only hashes are stored
+// tokens are random with high entropy, a fast hash is sufficient
+func GetHash(token string) []byte {
+	h := sha256.Sum256([]byte(token))
+	return h[:]
+}
+
+// returns whether API is allowed by token
+func IsApiAllowed(t types.LoginTokenApi, apiId uuid.UUID) bool {
+	return len(t.ApiIds) == 0 || slices.Contains(t.ApiIds, apiId)
+}
+
+// returns whether HTTP method is allowed by token
+// PATCH & PUT are allowed together with POST, same as for APIs
+func IsMethodAllowed(t types.LoginTokenApi, method string) bool {
+	if len(t.Methods) == 0 {
+		return true
+	}
+	if method == "PATCH" || method == "PUT" {
+		method = "POST"
+	}
+	return slices.Contains(t.Methods, method)
+}
+
+// returns whether client address is allowed by token
+func IsAddressAllowed(t types.LoginTokenApi, address string) bool {
+	if len(t.Addresses) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, a := range t.Addresses {
+		prefix, err := parsePrefix(a)
+		if err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// deletes token, loginId > 0 restricts deletion to tokens of this login
+func Del(id int64, loginId int64) error {
+	_, err := db.Pool.Exec(db.Ctx, `
+		DELETE FROM instance.login_token_api
+		WHERE id = $1
+		AND ($2 = 0 OR login_id = $2)
+	`, id, loginId)
+	return err
+}
+
+// returns tokens, of one login (loginId > 0) or of all logins
+func Get(loginId int64) ([]types.LoginTokenApi, error) {
+	tokens := make([]types.LoginTokenApi, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT t.id, t.login_id, l.name, t.name, t.api_ids, t.methods, t.addresses,
+			t.date_create, t.date_expiry, t.date_used, t.address_used
+		FROM instance.login_token_api AS t
+		INNER JOIN instance.login     AS l ON l.id = t.login_id
+		WHERE $1 = 0 OR t.login_id = $1
+		ORDER BY l.name ASC, t.date_create ASC
+	`, loginId)
+	if err != nil {
+		return tokens, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t types.LoginTokenApi
+		if err := rows.Scan(&t.Id, &t.LoginId, &t.LoginName, &t.Name, &t.ApiIds,
+			&t.Methods, &t.Addresses, &t.DateCreate, &t.DateExpiry, &t.DateUsed,
+			&t.AddressUsed); err != nil {
+
+			return tokens, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// returns valid (not expired) token by its hash, login must be active and client address allowed
+// updates date & address of last use, only for valid uses
+func GetValid(token string, address string) (types.LoginTokenApi, error) {
+	var t types.LoginTokenApi
+	now := tools.GetTimeUnix()
+
+	err := db.Pool.QueryRow(db.Ctx, `
+		SELECT t.id, t.login_id, l.name, t.name, t.api_ids, t.methods, t.addresses,
+			t.date_create, t.date_expiry, t.date_used, t.address_used
+		FROM instance.login_token_api AS t
+		INNER JOIN instance.login     AS l ON l.id = t.login_id
+		WHERE t.token_hash  =  $1
+		AND   t.date_expiry >= $2
+		AND   l.active
+	`, GetHash(token), now).Scan(&t.Id, &t.LoginId, &t.LoginName, &t.Name,
+		&t.ApiIds, &t.Methods, &t.Addresses, &t.DateCreate, &t.DateExpiry,
+		&t.DateUsed, &t.AddressUsed)
+
+	if err == pgx.ErrNoRows {
+		return t, errors.New("API token does not exist or has expired")
+	}
+	if err != nil {
+		return t, err
+	}
+	if !IsAddressAllowed(t, address) {
+		return t, fmt.Errorf("client address '%s' is not allowed for API token", address)
+	}
+
+	if t.DateUsed.Valid && now-t.DateUsed.Int64 < usedIntervalSeconds &&
+		t.AddressUsed.Valid && t.AddressUsed.String == address {
+
+		return t, nil
+	}
+	_, err = db.Pool.Exec(db.Ctx, `
+		UPDATE instance.login_token_api
+		SET date_used = $1, address_used = $2
+		WHERE id = $3
+	`, now, address, t.Id)
+
+	return t, err
+}
+
+// creates new token for login
+// returns token, which is not stored and can only be shown once
+func Set_tx(tx pgx.Tx, loginId int64, name string, dateExpiry int64,
+	apiIds []uuid.UUID, methods []string, addresses []string) (string, error) {
+
+	if name == "" {
+		return "", errors.New("token name must not be empty")
+	}
+	if dateExpiry <= tools.GetTimeUnix() {
+		return "", errors.New("token expiry date must be in the future")
+	}
+	for _, m := range methods {
+		if !slices.Contains(methodsValid, m) {
+			return "", fmt.Errorf("invalid HTTP method '%s', allowed: %s", m, strings.Join(methodsValid, ", "))
+		}
+	}
+	for _, a := range addresses {
+		if _, err := parsePrefix(a); err != nil {
+			return "", fmt.Errorf("invalid address or network '%s'", a)
+		}
+	}
+
+	// empty lists are stored, NULL is not allowed
+	if apiIds == nil {
+		apiIds = make([]uuid.UUID, 0)
+	}
+	if methods == nil {
+		methods = make([]string, 0)
+	}
+	if addresses == nil {
+		addresses = make([]string, 0)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := Prefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	_, err := tx.Exec(db.Ctx, `
+		INSERT INTO instance.login_token_api (login_id, name, token_hash,
+			api_ids, methods, addresses, date_create, date_expiry)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+	`, loginId, name, GetHash(token), apiIds, methods, addresses,
+		tools.GetTimeUnix(), dateExpiry)
+
+	return token, err
+}
+
+// parses single address (like 10.0.0.12) or network (like 10.0.0.0/24)
+func parsePrefix(v string) (netip.Prefix, error) {
+	if strings.Contains(v, "/") {
+		prefix, err := netip.ParsePrefix(v)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,452 @@
+package login_webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"r3/config"
+	"r3/db"
+	"r3/tools"
+	"r3/types"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	challengeExpirySeconds int64 = 300    // time for user to complete ceremony with authenticator
+	timeoutMs              int   = 120000 // ceremony timeout for client
+	algorithms                   = []int64{algEs256, algEdDsa, algRs256}
+)
+
+type clientData struct {
+	Type      string `json:"type"` // webauthn.create, webauthn.get
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// returns registered WebAuthn credentials of login
+func Get(loginId int64) ([]types.LoginWebauthn, error) {
+	credentials := make([]types.LoginWebauthn, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT id, name, date_create, date_used
+		FROM instance.login_webauthn
+		WHERE login_id = $1
+		ORDER BY date_create ASC
+	`, loginId)
+	if err != nil {
+		return credentials, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c types.LoginWebauthn
+		if err := rows.Scan(&c.Id, &c.Name, &c.DateCreate, &c.DateUsed); err != nil {
+			return credentials, err
+		}
+		credentials = append(credentials, c)
+	}
+	return credentials, nil
+}
+
+func Del(loginId int64, id int64) error {
+	_, err := db.Pool.Exec(db.Ctx, `
+		DELETE FROM instance.login_webauthn
+		WHERE login_id = $1
+		AND   id       = $2
+	`, loginId, id)
+	return err
+}
+
+// registration ceremony, step 1
+// returns options for client to create new credential with authenticator
+func GetCreationOptions(loginId int64) (types.LoginWebauthnCreationOptions, error) {
+	var o types.LoginWebauthnCreationOptions
+
+	rpId, err := getRpId()
+	if err != nil {
+		return o, err
+	}
+
+	if err := db.Pool.QueryRow(db.Ctx, `
+		SELECT name
+		FROM instance.login
+		WHERE id = $1
+	`, loginId).Scan(&o.UserName); err != nil {
+		return o, err
+	}
+
+	// existing credentials are excluded, to avoid registering the same authenticator twice
+	o.ExcludeCredentials, err = getCredentials(loginId)
+	if err != nil {
+		return o, err
+	}
+	o.Challenge, err = createChallenge(pgtype.Int8{Int64: loginId, Valid: true}, "register")
+	if err != nil {
+		return o, err
+	}
+	o.RpId = rpId
+	o.RpName = config.GetString("appName")
+	o.UserId, err = getUserHandle(loginId)
+	if err != nil {
+		return o, err
+	}
+	o.Algorithms = algorithms
+	o.ResidentKey = "preferred"
+	o.UserVerification = "preferred"
+	o.Timeout = timeoutMs
+	return o, nil
+}
+
+// registration ceremony, step 2
+// validates new credential created by authenticator and stores it for login
+// attestation is not requested, authenticator is therefore not verified
+func Register_tx(tx pgx.Tx, loginId int64, name string, clientDataJsonB64 string,
+	attestationObjectB64 string) error {
+
+	if name == "" {
+		return errors.New("name not given")
+	}
+
+	rpId, err := getRpId()
+	if err != nil {
+		return err
+	}
+
+	clientDataJson, err := base64.RawURLEncoding.DecodeString(clientDataJsonB64)
+	if err != nil {
+		return err
+	}
+	attestationRaw, err := base64.RawURLEncoding.DecodeString(attestationObjectB64)
+	if err != nil {
+		return err
+	}
+	if err := checkClientData(clientDataJson, "webauthn.create", "register", loginId, rpId); err != nil {
+		return err
+	}
+
+	var attestation attestationObject
+	if err := cbor.Unmarshal(attestationRaw, &attestation); err != nil {
+		return fmt.Errorf("failed to parse attestation object, %s", err)
+	}
+	authData, err := parseAuthenticatorData(attestation.AuthData)
+	if err != nil {
+		return err
+	}
+	if err := checkAuthenticatorData(authData, rpId, false); err != nil {
+		return err
+	}
+	if authData.CredentialId == nil {
+		return errors.New("attested credential data missing")
+	}
+
+	publicKey, alg, err := parseCoseKey(authData.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(db.Ctx, `
+		INSERT INTO instance.login_webauthn (login_id, name, credential_id,
+			public_key, algorithm, sign_count, date_create)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
+	`, loginId, name, authData.CredentialId, publicKey, alg,
+		int64(authData.SignCount), tools.GetTimeUnix())
+
+	return err
+}
+
+// authentication ceremony, step 1
+// returns options for client to get assertion from authenticator
+// login ID 0 requests passwordless login, any discoverable credential of the relying party can be used
+func GetRequestOptions(loginId int64) (types.LoginWebauthnRequestOptions, error) {
+	var o types.LoginWebauthnRequestOptions
+
+	rpId, err := getRpId()
+	if err != nil {
+		return o, err
+	}
+
+	if loginId == 0 {
+		if config.GetUint64("webauthnPasswordless") == 0 {
+			return o, errors.New("passwordless login is disabled")
+		}
+		o.AllowCredentials = make([]types.LoginWebauthnCredential, 0)
+		o.UserVerification = "required"
+		o.Challenge, err = createChallenge(pgtype.Int8{}, "login")
+	} else {
+		// user is already verified by password, authenticator only needs to be present
+		o.AllowCredentials, err = getCredentials(loginId)
+		if err != nil {
+			return o, err
+		}
+		o.UserVerification = "discouraged"
+		o.Challenge, err = createChallenge(pgtype.Int8{Int64: loginId, Valid: true}, "mfa")
+	}
+	o.RpId = rpId
+	o.Timeout = timeoutMs
+	return o, err
+}
+
+// authentication ceremony, step 2
+// validates assertion of authenticator for login (second factor) or for any login (passwordless, login ID 0)
+// returns login ID of credential, also on failure once the credential is known (to count failed attempts)
+func Assert(loginId int64, a types.LoginWebauthnAssertion) (int64, error) {
+
+	passwordless := loginId == 0
+	if passwordless && config.GetUint64("webauthnPasswordless") == 0 {
+		return 0, errors.New("passwordless login is disabled")
+	}
+
+	rpId, err := getRpId()
+	if err != nil {
+		return 0, err
+	}
+
+	var credentialId, authDataRaw, clientDataJson, signature []byte
+	for _, v := range []struct {
+		encoded string
+		target  *[]byte
+	}{
+		{a.CredentialId, &credentialId},
+		{a.AuthenticatorData, &authDataRaw},
+		{a.ClientDataJson, &clientDataJson},
+		{a.Signature, &signature},
+	} {
+		*v.target, err = base64.RawURLEncoding.DecodeString(v.encoded)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	context := "mfa"
+	if passwordless {
+		context = "login"
+	}
+	if err := checkClientData(clientDataJson, "webauthn.get", context, loginId, rpId); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	var credentialLoginId int64
+	var publicKey []byte
+	var alg int64
+	var signCount int64
+	if err := db.Pool.QueryRow(db.Ctx, `
+		SELECT id, login_id, public_key, algorithm, sign_count
+		FROM instance.login_webauthn
+		WHERE credential_id = $1
+	`, credentialId).Scan(&id, &credentialLoginId, &publicKey, &alg, &signCount); err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, errors.New("unknown credential")
+		}
+		return 0, err
+	}
+
+	if passwordless {
+		// user handle is returned by authenticator for discoverable credentials
+		if a.UserHandle != "" {
+			userHandle, err := getUserHandle(credentialLoginId)
+			if err != nil {
+				return credentialLoginId, err
+			}
+			if a.UserHandle != userHandle {
+				return credentialLoginId, errors.New("user handle does not match credential")
+			}
+		}
+	} else if credentialLoginId != loginId {
+		return credentialLoginId, errors.New("credential does not belong to login")
+	}
+
+	authData, err := parseAuthenticatorData(authDataRaw)
+	if err != nil {
+		return credentialLoginId, err
+	}
+	if err := checkAuthenticatorData(authData, rpId, passwordless); err != nil {
+		return credentialLoginId, err
+	}
+	if err := verifySignature(publicKey, alg, authDataRaw, clientDataJson, signature); err != nil {
+		return credentialLoginId, err
+	}
+
+	// authenticators that support sign counters must always increase them
+	// a counter that did not increase indicates a cloned authenticator
+	if (authData.SignCount != 0 || signCount != 0) && int64(authData.SignCount) <= signCount {
+		return credentialLoginId, errors.New("sign counter did not increase, authenticator might be cloned")
+	}
+
+	// counter is checked again on update, concurrent assertions with the same counter must not both succeed
+	tag, err := db.Pool.Exec(db.Ctx, `
+		UPDATE instance.login_webauthn
+		SET sign_count = $1, date_used = $2
+		WHERE id = $3
+		AND (sign_count < $1 OR ($1 = 0 AND sign_count = 0))
+	`, int64(authData.SignCount), tools.GetTimeUnix(), id)
+
+	if err != nil {
+		return credentialLoginId, err
+	}
+	if tag.RowsAffected() == 0 {
+		return credentialLoginId, errors.New("sign counter did not increase, authenticator might be cloned")
+	}
+	return credentialLoginId, nil
+}
+
+// validates client data & consumes challenge
+// login ID 0 expects a challenge without login (passwordless)
+func checkClientData(clientDataJson []byte, ceremonyType string, context string,
+	loginId int64, rpId string) error {
+
+	var c clientData
+	if err := json.Unmarshal(clientDataJson, &c); err != nil {
+		return err
+	}
+	if c.Type != ceremonyType {
+		return fmt.Errorf("invalid client data type '%s'", c.Type)
+	}
+	if err := checkOrigin(c.Origin, rpId); err != nil {
+		return err
+	}
+
+	// challenges are single use
+	var challengeLoginId pgtype.Int8
+	var dateExpiry int64
+	if err := db.Pool.QueryRow(db.Ctx, `
+		DELETE FROM instance.login_webauthn_challenge
+		WHERE challenge = $1
+		AND   context   = $2
+		RETURNING login_id, date_expiry
+	`, c.Challenge, context).Scan(&challengeLoginId, &dateExpiry); err != nil {
+		if err == pgx.ErrNoRows {
+			return errors.New("unknown challenge")
+		}
+		return err
+	}
+	if dateExpiry < tools.GetTimeUnix() {
+		return errors.New("challenge expired")
+	}
+	if challengeLoginId.Valid != (loginId != 0) || challengeLoginId.Int64 != loginId {
+		return errors.New("challenge does not belong to login")
+	}
+	return nil
+}
+
+func checkAuthenticatorData(d authenticatorData, rpId string, requireVerification bool) error {
+	rpIdHash := sha256.Sum256([]byte(rpId))
+	if !bytes.Equal(d.RpIdHash, rpIdHash[:]) {
+		return errors.New("relying party ID hash does not match")
+	}
+	if d.Flags&flagUserPresent == 0 {
+		return errors.New("user not present")
+	}
+	if requireVerification && d.Flags&flagUserVerified == 0 {
+		return errors.New("user not verified")
+	}
+	return nil
+}
+
+// origin must be the relying party ID or one of its sub domains
+// only secure contexts are supported by clients, except for localhost
+func checkOrigin(origin string, rpId string) error {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	host := strings.ToLower(u.Hostname())
+
+	if host != rpId && !strings.HasSuffix(host, fmt.Sprintf(".%s", rpId)) {
+		return fmt.Errorf("origin '%s' does not match relying party ID '%s'", origin, rpId)
+	}
+	if u.Scheme != "https" && host != "localhost" {
+		return fmt.Errorf("origin '%s' is not secure", origin)
+	}
+	return nil
+}
+
+func createChallenge(loginId pgtype.Int8, context string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(b)
+	now := tools.GetTimeUnix()
+
+	if _, err := db.Pool.Exec(db.Ctx, `
+		DELETE FROM instance.login_webauthn_challenge
+		WHERE date_expiry < $1
+	`, now); err != nil {
+		return "", err
+	}
+	if _, err := db.Pool.Exec(db.Ctx, `
+		INSERT INTO instance.login_webauthn_challenge (challenge, login_id, context, date_expiry)
+		VALUES ($1,$2,$3,$4)
+	`, challenge, loginId, context, now+challengeExpirySeconds); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+func getCredentials(loginId int64) ([]types.LoginWebauthnCredential, error) {
+	credentials := make([]types.LoginWebauthnCredential, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT credential_id
+		FROM instance.login_webauthn
+		WHERE login_id = $1
+	`, loginId)
+	if err != nil {
+		return credentials, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id []byte
+		if err := rows.Scan(&id); err != nil {
+			return credentials, err
+		}
+		credentials = append(credentials, types.LoginWebauthnCredential{
+			Type: "public-key",
+			Id:   base64.RawURLEncoding.EncodeToString(id),
+		})
+	}
+	return credentials, nil
+}
+
+// relying party ID is the public host name, without port
+func getRpId() (string, error) {
+	host := strings.ToLower(config.GetString("publicHostName"))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		return "", errors.New("public host name is not set")
+	}
+	return host, nil
+}
+
+// user handle identifies login on passwordless authentication, must not contain personal information
+// random handle is created for login on first use, the login ID is not used as it is sequential and guessable
+func getUserHandle(loginId int64) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	var handle string
+	err := db.Pool.QueryRow(db.Ctx, `
+		UPDATE instance.login
+		SET webauthn_handle = COALESCE(webauthn_handle, $1)
+		WHERE id = $2
+		RETURNING webauthn_handle
+	`, base64.RawURLEncoding.EncodeToString(b), loginId).Scan(&handle)
+
+	return handle, err
+}
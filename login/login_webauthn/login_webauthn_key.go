@@ -0,0 +1,203 @@
+package login_webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// COSE algorithms, supported for WebAuthn credentials
+const (
+	algEdDsa int64 = -8
+	algEs256 int64 = -7
+	algRs256 int64 = -257
+)
+
+// flags of authenticator data
+const (
+	flagUserPresent  byte = 0x01
+	flagUserVerified byte = 0x04
+	flagAttestedData byte = 0x40
+)
+
+type attestationObject struct {
+	Fmt      string          `cbor:"fmt"`
+	AttStmt  cbor.RawMessage `cbor:"attStmt"`
+	AuthData []byte          `cbor:"authData"`
+}
+type authenticatorData struct {
+	RpIdHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialId []byte // only set during registration (attested credential data)
+	PublicKey    []byte // COSE key, only set during registration (attested credential data)
+}
+
+// COSE key, parameters -1 to -3 depend on key type
+type coseKey struct {
+	Kty    int64           `cbor:"1,keyasint"`
+	Alg    int64           `cbor:"3,keyasint"`
+	Param1 cbor.RawMessage `cbor:"-1,keyasint"` // EC2/OKP: curve, RSA: modulus
+	Param2 cbor.RawMessage `cbor:"-2,keyasint"` // EC2/OKP: x coordinate, RSA: exponent
+	Param3 cbor.RawMessage `cbor:"-3,keyasint"` // EC2: y coordinate
+}
+
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	var d authenticatorData
+	if len(data) < 37 {
+		return d, errors.New("authenticator data too short")
+	}
+	d.RpIdHash = data[0:32]
+	d.Flags = data[32]
+	d.SignCount = binary.BigEndian.Uint32(data[33:37])
+
+	if d.Flags&flagAttestedData == 0 {
+		return d, nil
+	}
+
+	// attested credential data: AAGUID (16), credential ID length (2), credential ID, COSE key
+	if len(data) < 55 {
+		return d, errors.New("attested credential data too short")
+	}
+	idLength := int(binary.BigEndian.Uint16(data[53:55]))
+	if idLength > 1023 || len(data) < 55+idLength {
+		return d, errors.New("invalid credential ID length")
+	}
+	d.CredentialId = data[55 : 55+idLength]
+
+	// COSE key can be followed by extension data
+	var key cbor.RawMessage
+	if _, err := cbor.UnmarshalFirst(data[55+idLength:], &key); err != nil {
+		return d, fmt.Errorf("failed to parse credential public key, %s", err)
+	}
+	d.PublicKey = key
+	return d, nil
+}
+
+// converts COSE key to PKIX (DER) public key
+// returns key and COSE algorithm
+func parseCoseKey(data []byte) ([]byte, int64, error) {
+	var k coseKey
+	if err := cbor.Unmarshal(data, &k); err != nil {
+		return nil, 0, err
+	}
+
+	var pub crypto.PublicKey
+	switch k.Alg {
+	case algEs256:
+		var crv int64
+		var x, y []byte
+		if err := unmarshalParams(k, &crv, &x, &y); err != nil {
+			return nil, 0, err
+		}
+		if k.Kty != 2 || crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid ES256 key")
+		}
+
+		// check that point is on curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, 0, err
+		}
+		pub = &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+
+	case algEdDsa:
+		var crv int64
+		var x []byte
+		if err := unmarshalParams(k, &crv, &x, nil); err != nil {
+			return nil, 0, err
+		}
+		if k.Kty != 1 || crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid EdDSA key")
+		}
+		pub = ed25519.PublicKey(x)
+
+	case algRs256:
+		var n, e []byte
+		if err := unmarshalParams(k, &n, &e, nil); err != nil {
+			return nil, 0, err
+		}
+		if k.Kty != 3 || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RS256 key")
+		}
+		pub = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	default:
+		return nil, 0, fmt.Errorf("unsupported COSE algorithm %d", k.Alg)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	return der, k.Alg, err
+}
+
+// verifies assertion signature over authenticator data and hash of client data
+func verifySignature(publicKey []byte, alg int64, authData []byte,
+	clientDataJson []byte, signature []byte) error {
+
+	pub, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJson)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	signedHash := sha256.Sum256(signed)
+
+	switch alg {
+	case algEs256:
+		k, ok := pub.(*ecdsa.PublicKey)
+		if !ok || !ecdsa.VerifyASN1(k, signedHash[:], signature) {
+			return errors.New("invalid signature")
+		}
+	case algEdDsa:
+		k, ok := pub.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(k, signed, signature) {
+			return errors.New("invalid signature")
+		}
+	case algRs256:
+		k, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("invalid signature")
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, signedHash[:], signature); err != nil {
+			return errors.New("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported COSE algorithm %d", alg)
+	}
+	return nil
+}
+
+func unmarshalParams(k coseKey, p1 interface{}, p2 interface{}, p3 interface{}) error {
+	for i, p := range []struct {
+		raw    cbor.RawMessage
+		target interface{}
+	}{{k.Param1, p1}, {k.Param2, p2}, {k.Param3, p3}} {
+		if p.target == nil {
+			continue
+		}
+		if len(p.raw) == 0 {
+			return fmt.Errorf("COSE key parameter %d missing", -(i + 1))
+		}
+		if err := cbor.Unmarshal(p.raw, p.target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package oidc
+
+import (
+	"r3/db"
+	"r3/types"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func Del_tx(tx pgx.Tx, id int32) error {
+	_, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.oidc
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+func Get() ([]types.Oidc, error) {
+	oidcs := make([]types.Oidc, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT id, login_template_id, name, issuer_url, client_id,
+			client_secret, scopes, claim_username, claim_roles,
+			assign_roles, active
+		FROM instance.oidc
+		ORDER BY name ASC
+	`)
+	if err != nil {
+		return oidcs, err
+	}
+
+	for rows.Next() {
+		var o types.Oidc
+		if err := rows.Scan(&o.Id, &o.LoginTemplateId, &o.Name, &o.IssuerUrl,
+			&o.ClientId, &o.ClientSecret, &o.Scopes, &o.ClaimUsername,
+			&o.ClaimRoles, &o.AssignRoles, &o.Active); err != nil {
+
+			rows.Close()
+			return oidcs, err
+		}
+		oidcs = append(oidcs, o)
+	}
+	rows.Close()
+
+	for i, _ := range oidcs {
+		oidcs[i].Roles, err = getRoles(oidcs[i].Id)
+		if err != nil {
+			return oidcs, err
+		}
+	}
+	return oidcs, nil
+}
+
+func Set_tx(tx pgx.Tx, o types.Oidc) error {
+
+	if o.Scopes == nil {
+		o.Scopes = make([]string, 0)
+	}
+
+	if o.Id == 0 {
+		if err := tx.QueryRow(db.Ctx, `
+			INSERT INTO instance.oidc (
+				login_template_id, name, issuer_url, client_id, client_secret,
+				scopes, claim_username, claim_roles, assign_roles, active
+			)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+			RETURNING id
+		`, o.LoginTemplateId, o.Name, o.IssuerUrl, o.ClientId, o.ClientSecret,
+			o.Scopes, o.ClaimUsername, o.ClaimRoles, o.AssignRoles,
+			o.Active).Scan(&o.Id); err != nil {
+
+			return err
+		}
+	} else {
+		if _, err := tx.Exec(db.Ctx, `
+			UPDATE instance.oidc
+			SET login_template_id = $1, name = $2, issuer_url = $3,
+				client_id = $4, client_secret = $5, scopes = $6,
+				claim_username = $7, claim_roles = $8, assign_roles = $9,
+				active = $10
+			WHERE id = $11
+		`, o.LoginTemplateId, o.Name, o.IssuerUrl, o.ClientId, o.ClientSecret,
+			o.Scopes, o.ClaimUsername, o.ClaimRoles, o.AssignRoles,
+			o.Active, o.Id); err != nil {
+
+			return err
+		}
+	}
+
+	// update OIDC role assignment
+	if _, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.oidc_role
+		WHERE oidc_id = $1
+	`, o.Id); err != nil {
+		return err
+	}
+
+	for _, role := range o.Roles {
+		if _, err := tx.Exec(db.Ctx, `
+			INSERT INTO instance.oidc_role (oidc_id, role_id, claim_value)
+			VALUES ($1,$2,$3)
+		`, o.Id, role.RoleId, role.ClaimValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getRoles(oidcId int32) ([]types.OidcRole, error) {
+	roles := make([]types.OidcRole, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT role_id, claim_value
+		FROM instance.oidc_role
+		WHERE oidc_id = $1
+		ORDER BY claim_value
+	`, oidcId)
+	if err != nil {
+		return roles, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r types.OidcRole
+		if err := rows.Scan(&r.RoleId, &r.ClaimValue); err != nil {
+			return roles, err
+		}
+		r.OidcId = oidcId
+		roles = append(roles, r)
+	}
+	return roles, nil
+}
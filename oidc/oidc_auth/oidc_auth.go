@@ -0,0 +1,370 @@
+package oidc_auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"r3/cache"
+	"r3/cluster"
+	"r3/config"
+	"r3/db"
+	"r3/log"
+	"r3/login"
+	"r3/tools"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gofrs/uuid"
+)
+
+var (
+	cacheExpirySeconds int64 = 3600 // discovery documents & key sets are refreshed after expiry
+	codeExpirySeconds  int64 = 60   // one-time code, handing over authentication to client
+	keysRefetchSeconds int64 = 60   // min. time between key set fetches for unknown key IDs
+	stateExpirySeconds int64 = 600  // time for user to authenticate at identity provider
+	timeoutHttp        int64 = 30
+
+	cache_mx           sync.Mutex
+	issuerMapDiscCache = make(map[string]discCache) // key: issuer URL
+	jwksUriMapKeyCache = make(map[string]keyCache)  // key: JWKS URI
+)
+
+type discCache struct {
+	disc       discovery
+	dateExpiry int64
+}
+type keyCache struct {
+	keys       []jwk
+	dateExpiry int64
+	dateFetch  int64
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+type tokenResponse struct {
+	IdToken string `json:"id_token"`
+}
+
+// returns URL of identity provider to redirect user to, to start authentication
+func GetAuthUrl(oidcId int32, redirectUri string) (string, error) {
+
+	o, err := cache.GetOidc(oidcId)
+	if err != nil {
+		return "", err
+	}
+	if !o.Active {
+		return "", errors.New("OpenID Connect provider is inactive")
+	}
+
+	disc, err := getDiscovery(o.IssuerUrl)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := getRandomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := getRandomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := getRandomString()
+	if err != nil {
+		return "", err
+	}
+
+	ctx := db.Ctx
+	now := tools.GetTimeUnix()
+	if _, err := db.Pool.Exec(ctx, `
+		DELETE FROM instance.oidc_state
+		WHERE date_expiry < $1
+	`, now); err != nil {
+		return "", err
+	}
+	if _, err := db.Pool.Exec(ctx, `
+		INSERT INTO instance.oidc_state (state, oidc_id, code_verifier, nonce, redirect_uri, date_expiry)
+		VALUES ($1,$2,$3,$4,$5,$6)
+	`, state, oidcId, verifier, nonce, redirectUri, now+stateExpirySeconds); err != nil {
+		return "", err
+	}
+
+	// PKCE challenge (S256)
+	challenge := sha256.Sum256([]byte(verifier))
+
+	scopes := o.Scopes
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", o.ClientId)
+	params.Set("redirect_uri", redirectUri)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(disc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s%s", disc.AuthorizationEndpoint, sep, params.Encode()), nil
+}
+
+// completes authentication with authorization code from identity provider
+// creates/updates login and returns one-time code to hand over authentication to client
+func Callback(state string, code string) (string, error) {
+
+	ctx := db.Ctx
+	// states are single use
+	var oidcId int32
+	var verifier, nonce, redirectUri string
+	var dateExpiry int64
+	if err := db.Pool.QueryRow(ctx, `
+		DELETE FROM instance.oidc_state
+		WHERE state = $1
+		RETURNING oidc_id, code_verifier, nonce, redirect_uri, date_expiry
+	`, state).Scan(&oidcId, &verifier, &nonce, &redirectUri, &dateExpiry); err != nil {
+		return "", errors.New("unknown authorization state")
+	}
+	if dateExpiry < tools.GetTimeUnix() {
+		return "", errors.New("authorization state expired")
+	}
+
+	o, err := cache.GetOidc(oidcId)
+	if err != nil {
+		return "", err
+	}
+	if !o.Active {
+		return "", errors.New("OpenID Connect provider is inactive")
+	}
+
+	disc, err := getDiscovery(o.IssuerUrl)
+	if err != nil {
+		return "", err
+	}
+
+	// exchange authorization code for ID token
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectUri)
+	form.Set("client_id", o.ClientId)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequest("POST", disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.ClientId), url.QueryEscape(o.ClientSecret))
+	}
+
+	var tokenRes tokenResponse
+	if err := doJsonRequest(req, &tokenRes); err != nil {
+		return "", fmt.Errorf("failed to retrieve ID token, %s", err)
+	}
+	if tokenRes.IdToken == "" {
+		return "", errors.New("token response did not include an ID token")
+	}
+
+	// verify ID token with key set of identity provider
+	keys, err := getKeys(disc.JwksUri, getTokenKeyId(tokenRes.IdToken))
+	if err != nil {
+		return "", err
+	}
+	claims, err := verifyIdToken(tokenRes.IdToken, keys, disc.Issuer, o.ClientId, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	// login name & key
+	subject := getClaimValues(claims, "sub")
+	if len(subject) != 1 {
+		return "", errors.New("ID token does not include a valid subject")
+	}
+	claimUsername := o.ClaimUsername
+	if claimUsername == "" {
+		claimUsername = "sub"
+	}
+	usernames := getClaimValues(claims, claimUsername)
+	if len(usernames) != 1 || usernames[0] == "" {
+		return "", fmt.Errorf("ID token does not include a valid username claim '%s'", claimUsername)
+	}
+
+	// roles from claim values
+	roleIds := make([]uuid.UUID, 0)
+	if o.AssignRoles && o.ClaimRoles != "" {
+		for _, value := range getClaimValues(claims, o.ClaimRoles) {
+			for _, r := range o.Roles {
+				if r.ClaimValue == value && !slices.Contains(roleIds, r.RoleId) {
+					roleIds = append(roleIds, r.RoleId)
+				}
+			}
+		}
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	loginId, changed, err := login.SetOidcLogin_tx(tx, o.Id, subject[0], usernames[0],
+		roleIds, o.LoginTemplateId, o.AssignRoles)
+
+	if err != nil {
+		return "", err
+	}
+
+	oneTimeCode, err := getRandomString()
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM instance.oidc_code
+		WHERE date_expiry < $1
+	`, tools.GetTimeUnix()); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO instance.oidc_code (code, login_id, date_expiry)
+		VALUES ($1,$2,$3)
+	`, oneTimeCode, loginId, tools.GetTimeUnix()+codeExpirySeconds); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+
+	if changed {
+		if err := cluster.LoginReauthorized(true, loginId); err != nil {
+			log.Warning("server", fmt.Sprintf("could not renew access permissions for login ID %d", loginId), err)
+		}
+	}
+	log.Info("server", fmt.Sprintf("OpenID Connect authentication for login '%s' successful", usernames[0]))
+	return oneTimeCode, nil
+}
+
+// returns discovery document of issuer, cached until expiry
+func getDiscovery(issuerUrl string) (discovery, error) {
+	cache_mx.Lock()
+	c, exists := issuerMapDiscCache[issuerUrl]
+	cache_mx.Unlock()
+
+	if exists && c.dateExpiry > tools.GetTimeUnix() {
+		return c.disc, nil
+	}
+
+	var disc discovery
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/.well-known/openid-configuration",
+		strings.TrimSuffix(issuerUrl, "/")), nil)
+
+	if err != nil {
+		return disc, err
+	}
+	if err := doJsonRequest(req, &disc); err != nil {
+		return disc, fmt.Errorf("failed to retrieve OpenID Connect discovery document, %s", err)
+	}
+	if disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.JwksUri == "" {
+		return disc, errors.New("OpenID Connect discovery document is incomplete")
+	}
+
+	// issuer of document must be the configured issuer (OpenID Connect Discovery 1.0, 4.3)
+	// trailing slash is ignored as it is often left out in configuration
+	if strings.TrimSuffix(disc.Issuer, "/") != strings.TrimSuffix(issuerUrl, "/") {
+		return disc, fmt.Errorf("OpenID Connect discovery document issuer '%s' does not match configured issuer '%s'",
+			disc.Issuer, issuerUrl)
+	}
+
+	cache_mx.Lock()
+	issuerMapDiscCache[issuerUrl] = discCache{
+		disc:       disc,
+		dateExpiry: tools.GetTimeUnix() + cacheExpirySeconds,
+	}
+	cache_mx.Unlock()
+	return disc, nil
+}
+
+// returns key set of identity provider, cached until expiry
+// key set is fetched again if key ID is not included (key rotation), but not more often than refetch interval
+func getKeys(jwksUri string, keyId string) ([]jwk, error) {
+	now := tools.GetTimeUnix()
+
+	cache_mx.Lock()
+	c, exists := jwksUriMapKeyCache[jwksUri]
+	cache_mx.Unlock()
+
+	if exists && c.dateExpiry > now {
+		if keyId == "" || c.dateFetch+keysRefetchSeconds > now || slices.ContainsFunc(c.keys, func(k jwk) bool {
+			return k.Kid == keyId
+		}) {
+			return c.keys, nil
+		}
+	}
+
+	var keySet struct {
+		Keys []jwk `json:"keys"`
+	}
+	req, err := http.NewRequest("GET", jwksUri, nil)
+	if err != nil {
+		return keySet.Keys, err
+	}
+	if err := doJsonRequest(req, &keySet); err != nil {
+		return keySet.Keys, fmt.Errorf("failed to retrieve key set of identity provider, %s", err)
+	}
+
+	cache_mx.Lock()
+	jwksUriMapKeyCache[jwksUri] = keyCache{
+		keys:       keySet.Keys,
+		dateExpiry: now + cacheExpirySeconds,
+		dateFetch:  now,
+	}
+	cache_mx.Unlock()
+	return keySet.Keys, nil
+}
+
+func doJsonRequest(req *http.Request, target interface{}) error {
+	httpClient, err := config.GetHttpClient(false, timeoutHttp)
+	if err != nil {
+		return err
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("non-success response code %d", res.StatusCode)
+	}
+	return json.Unmarshal(body, target)
+}
+
+func getRandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
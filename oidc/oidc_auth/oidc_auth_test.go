@@ -0,0 +1,247 @@
+package oidc_auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+// identity provider stand-in, serving discovery document & key set
+type testIdp struct {
+	server   *httptest.Server
+	issuer   string // issuer in discovery document, defaults to server URL
+	mx       sync.Mutex
+	keys     map[string]*rsa.PrivateKey // key: key ID
+	requests map[string]int             // key: request path
+}
+
+func newTestIdp(t *testing.T) *testIdp {
+	idp := &testIdp{
+		keys:     make(map[string]*rsa.PrivateKey),
+		requests: make(map[string]int),
+	}
+	idp.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idp.mx.Lock()
+		defer idp.mx.Unlock()
+		idp.requests[r.URL.Path]++
+
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			issuer := idp.issuer
+			if issuer == "" {
+				issuer = idp.server.URL
+			}
+			json.NewEncoder(w).Encode(discovery{
+				Issuer:                issuer,
+				AuthorizationEndpoint: idp.server.URL + "/authorize",
+				TokenEndpoint:         idp.server.URL + "/token",
+				JwksUri:               idp.server.URL + "/jwks",
+			})
+		case "/jwks":
+			keys := make([]jwk, 0)
+			for kid, key := range idp.keys {
+				keys = append(keys, jwk{
+					Kty: "RSA",
+					Kid: kid,
+					Use: "sig",
+					Alg: "RS256",
+					N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				})
+			}
+			json.NewEncoder(w).Encode(map[string][]jwk{"keys": keys})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(idp.server.Close)
+
+	// start every test with empty caches
+	t.Cleanup(resetTestCache)
+	resetTestCache()
+
+	idp.addKey(t, "key1")
+	return idp
+}
+
+func (idp *testIdp) addKey(t *testing.T, kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mx.Lock()
+	idp.keys[kid] = key
+	idp.mx.Unlock()
+}
+
+func (idp *testIdp) getRequestCount(path string) int {
+	idp.mx.Lock()
+	defer idp.mx.Unlock()
+	return idp.requests[path]
+}
+
+func (idp *testIdp) getIdToken(t *testing.T, kid string, issuer string, audience string, nonce string) string {
+	idp.mx.Lock()
+	key := idp.keys[kid]
+	idp.mx.Unlock()
+
+	now := time.Now()
+	token, err := jwt.Sign(idTokenPayload{
+		Payload: jwt.Payload{
+			Issuer:         issuer,
+			Subject:        "user1",
+			Audience:       jwt.Audience{audience},
+			ExpirationTime: jwt.NumericDate(now.Add(time.Minute)),
+			IssuedAt:       jwt.NumericDate(now),
+		},
+		Nonce: nonce,
+	}, jwt.NewRS256(jwt.RSAPrivateKey(key)), jwt.KeyID(kid))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(token)
+}
+
+func resetTestCache() {
+	cache_mx.Lock()
+	issuerMapDiscCache = make(map[string]discCache)
+	jwksUriMapKeyCache = make(map[string]keyCache)
+	cache_mx.Unlock()
+}
+
+func TestDiscovery(t *testing.T) {
+	idp := newTestIdp(t)
+
+	// trailing slash of configured issuer is ignored
+	for _, issuerUrl := range []string{idp.server.URL, idp.server.URL + "/"} {
+		disc, err := getDiscovery(issuerUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if disc.TokenEndpoint != idp.server.URL+"/token" {
+			t.Fatalf("unexpected token endpoint '%s'", disc.TokenEndpoint)
+		}
+	}
+
+	// discovery document is cached per issuer URL
+	if _, err := getDiscovery(idp.server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if n := idp.getRequestCount("/.well-known/openid-configuration"); n != 2 {
+		t.Fatalf("expected 2 discovery requests, got %d", n)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newTestIdp(t)
+	idp.issuer = "https://attacker.example.org"
+
+	if _, err := getDiscovery(idp.server.URL); err == nil {
+		t.Fatal("accepted discovery document of other issuer")
+	}
+
+	// rejected documents are not cached
+	idp.mx.Lock()
+	idp.issuer = ""
+	idp.mx.Unlock()
+	if _, err := getDiscovery(idp.server.URL); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyIdToken(t *testing.T) {
+	idp := newTestIdp(t)
+	issuer := idp.server.URL
+
+	keys, err := getKeys(idp.server.URL+"/jwks", "key1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := idp.getIdToken(t, "key1", issuer, "client1", "nonce1")
+	claims, err := verifyIdToken(token, keys, issuer, "client1", "nonce1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject := getClaimValues(claims, "sub"); len(subject) != 1 || subject[0] != "user1" {
+		t.Fatalf("unexpected subject %v", subject)
+	}
+
+	// wrong issuer, audience or nonce
+	invalid := map[string]string{
+		"issuer":   idp.getIdToken(t, "key1", "https://attacker.example.org", "client1", "nonce1"),
+		"audience": idp.getIdToken(t, "key1", issuer, "client2", "nonce1"),
+		"nonce":    idp.getIdToken(t, "key1", issuer, "client1", "nonce2"),
+	}
+	for name, token := range invalid {
+		if _, err := verifyIdToken(token, keys, issuer, "client1", "nonce1"); err == nil {
+			t.Fatalf("accepted ID token with wrong %s", name)
+		}
+	}
+
+	// changed payload
+	parts := strings.Split(token, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), "user1", "user2", 1)))
+	if _, err := verifyIdToken(strings.Join(parts, "."), keys, issuer, "client1", "nonce1"); err == nil {
+		t.Fatal("accepted ID token with changed payload")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	idp := newTestIdp(t)
+	jwksUri := idp.server.URL + "/jwks"
+
+	if _, err := getKeys(jwksUri, "key1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// known key ID is served from cache
+	if _, err := getKeys(jwksUri, "key1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := idp.getRequestCount("/jwks"); n != 1 {
+		t.Fatalf("expected 1 key set request, got %d", n)
+	}
+
+	// identity provider rotates key, unknown key ID is not refetched within refetch interval
+	idp.addKey(t, "key2")
+	token := idp.getIdToken(t, "key2", idp.server.URL, "client1", "nonce1")
+
+	keys, err := getKeys(jwksUri, getTokenKeyId(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyIdToken(token, keys, idp.server.URL, "client1", "nonce1"); err == nil {
+		t.Fatal("verified ID token without key")
+	}
+
+	// after refetch interval, unknown key ID causes key set to be fetched again
+	cache_mx.Lock()
+	c := jwksUriMapKeyCache[jwksUri]
+	c.dateFetch -= keysRefetchSeconds
+	jwksUriMapKeyCache[jwksUri] = c
+	cache_mx.Unlock()
+
+	keys, err = getKeys(jwksUri, getTokenKeyId(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := idp.getRequestCount("/jwks"); n != 2 {
+		t.Fatalf("expected 2 key set requests, got %d", n)
+	}
+	if _, err := verifyIdToken(token, keys, idp.server.URL, "client1", "nonce1"); err != nil {
+		t.Fatalf("failed to verify ID token with rotated key, %s", err)
+	}
+}
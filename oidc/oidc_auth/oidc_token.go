@@ -0,0 +1,231 @@
+package oidc_auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+type jwk struct {
+	Kty string `json:"kty"` // key type: RSA, EC
+	Kid string `json:"kid"` // key ID, referenced by JWT header
+	Use string `json:"use"` // sig, enc
+	Alg string `json:"alg"`
+	N   string `json:"n"` // RSA modulus
+	E   string `json:"e"` // RSA exponent
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type idTokenPayload struct {
+	jwt.Payload
+	Nonce string `json:"nonce"`
+}
+
+// JWT algorithm, resolving the signing key from the identity provider key set by the JWT header
+type keySetAlgorithm struct {
+	alg  jwt.Algorithm
+	keys []jwk
+}
+
+func (a *keySetAlgorithm) Name() string {
+	if a.alg == nil {
+		return ""
+	}
+	return a.alg.Name()
+}
+func (a *keySetAlgorithm) Sign(headerPayload []byte) ([]byte, error) {
+	return nil, errors.New("signing is not supported")
+}
+func (a *keySetAlgorithm) Size() int {
+	if a.alg == nil {
+		return 0
+	}
+	return a.alg.Size()
+}
+func (a *keySetAlgorithm) Verify(headerPayload []byte, sig []byte) error {
+	if a.alg == nil {
+		return errors.New("no signing key resolved")
+	}
+	return a.alg.Verify(headerPayload, sig)
+}
+func (a *keySetAlgorithm) Resolve(hd jwt.Header) error {
+	for _, k := range a.keys {
+		if (hd.KeyID != "" && k.Kid != hd.KeyID) || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		if k.Alg != "" && k.Alg != hd.Algorithm {
+			continue
+		}
+
+		switch hd.Algorithm {
+		case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+			if k.Kty != "RSA" {
+				continue
+			}
+			pub, err := getRsaPublicKey(k)
+			if err != nil {
+				return err
+			}
+			switch hd.Algorithm {
+			case "RS256":
+				a.alg = jwt.NewRS256(jwt.RSAPublicKey(pub))
+			case "RS384":
+				a.alg = jwt.NewRS384(jwt.RSAPublicKey(pub))
+			case "RS512":
+				a.alg = jwt.NewRS512(jwt.RSAPublicKey(pub))
+			case "PS256":
+				a.alg = jwt.NewPS256(jwt.RSAPublicKey(pub))
+			case "PS384":
+				a.alg = jwt.NewPS384(jwt.RSAPublicKey(pub))
+			case "PS512":
+				a.alg = jwt.NewPS512(jwt.RSAPublicKey(pub))
+			}
+			return nil
+
+		case "ES256", "ES384", "ES512":
+			if k.Kty != "EC" {
+				continue
+			}
+			pub, err := getEcdsaPublicKey(k)
+			if err != nil {
+				return err
+			}
+			switch hd.Algorithm {
+			case "ES256":
+				a.alg = jwt.NewES256(jwt.ECDSAPublicKey(pub))
+			case "ES384":
+				a.alg = jwt.NewES384(jwt.ECDSAPublicKey(pub))
+			case "ES512":
+				a.alg = jwt.NewES512(jwt.ECDSAPublicKey(pub))
+			}
+			return nil
+
+		default:
+			return fmt.Errorf("unsupported ID token algorithm '%s'", hd.Algorithm)
+		}
+	}
+	return fmt.Errorf("no signing key found for key ID '%s'", hd.KeyID)
+}
+
+// verifies signature and claims of ID token, returns all claims
+func verifyIdToken(token string, keys []jwk, issuer string, clientId string, nonce string) (map[string]interface{}, error) {
+
+	var pl idTokenPayload
+	now := time.Now()
+	alg := keySetAlgorithm{keys: keys}
+
+	if _, err := jwt.Verify([]byte(token), &alg, &pl, jwt.ValidateHeader,
+		jwt.ValidatePayload(&pl.Payload,
+			jwt.IssuerValidator(issuer),
+			jwt.AudienceValidator(jwt.Audience{clientId}),
+			jwt.ExpirationTimeValidator(now),
+			jwt.IssuedAtValidator(now.Add(time.Minute)), // allow for some clock skew
+		)); err != nil {
+
+		return nil, fmt.Errorf("invalid ID token, %s", err)
+	}
+
+	if pl.Nonce != nonce {
+		return nil, errors.New("invalid ID token, nonce does not match")
+	}
+	if pl.Subject == "" {
+		return nil, errors.New("invalid ID token, subject is empty")
+	}
+
+	// signature is valid, read all claims
+	parts := strings.Split(token, ".")
+	claimsJson, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(claimsJson, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// returns key ID from header of token, empty if not set or token is malformed
+func getTokenKeyId(token string) string {
+	var hd jwt.Header
+	headerB64, _, _ := strings.Cut(token, ".")
+	headerJson, err := base64.RawURLEncoding.DecodeString(headerB64)
+	if err != nil {
+		return ""
+	}
+	if err := json.Unmarshal(headerJson, &hd); err != nil {
+		return ""
+	}
+	return hd.KeyID
+}
+
+func getRsaPublicKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func getEcdsaPublicKey(k jwk) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve '%s'", k.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+// returns string values of claim, claim can be a single string or a list of strings
+func getClaimValues(claims map[string]interface{}, name string) []string {
+	values := make([]string, 0)
+
+	switch v := claims[name].(type) {
+	case string:
+		values = append(values, v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
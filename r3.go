@@ -37,6 +37,9 @@ import (
 	"r3/handler/ics_download"
 	"r3/handler/license_upload"
 	"r3/handler/manifest_download"
+	"r3/handler/oidc"
+	"r3/handler/saml"
+	"r3/handler/scim"
 	"r3/handler/transfer_export"
 	"r3/handler/transfer_import"
 	"r3/handler/websocket"
@@ -58,8 +61,8 @@ var (
 	// overwritten by build parameters
 	appName          string = "REI3"
 	appNameShort     string = "R3"
-	appVersion       string = "3.8.2.5272"
-	appVersionClient string = "3.8.2.5272"
+	appVersion       string = "3.9.0.5272"
+	appVersionClient string = "3.9.0.5272"
 
 	// start parameters
 	cli struct {
@@ -401,10 +404,22 @@ func (prg *program) execute(svc service.Service) {
 		prg.executeAborted(svc, fmt.Errorf("failed to initialize oauth client cache, %v", err))
 		return
 	}
+	if err := cache.LoadOidcMap(); err != nil {
+		prg.executeAborted(svc, fmt.Errorf("failed to initialize OpenID Connect cache, %v", err))
+		return
+	}
 	if err := cache.LoadPwaDomainMap(); err != nil {
 		prg.executeAborted(svc, fmt.Errorf("failed to initialize PWA domain cache, %v", err))
 		return
 	}
+	if err := cache.LoadSamlMap(); err != nil {
+		prg.executeAborted(svc, fmt.Errorf("failed to initialize SAML cache, %v", err))
+		return
+	}
+	if err := cache.LoadWebhookMap(); err != nil {
+		prg.executeAborted(svc, fmt.Errorf("failed to initialize webhook cache, %v", err))
+		return
+	}
 	if err := cache.LoadSearchDictionaries(); err != nil {
 		// failure is not mission critical (in case of no access to DB system tables)
 		log.Error("server", "failed to read/update text search dictionaries", err)
@@ -449,6 +464,7 @@ func (prg *program) execute(svc service.Service) {
 
 	mux.HandleFunc("/api/", api.Handler)
 	mux.HandleFunc("/api/auth", api_auth.Handler)
+	mux.HandleFunc("/api/batch", api.HandlerBatch)
 	mux.HandleFunc("/cache/download/", cache_download.Handler)
 	mux.HandleFunc("/csv/download/", csv_download.Handler)
 	mux.HandleFunc("/csv/upload", csv_upload.Handler)
@@ -461,6 +477,12 @@ func (prg *program) execute(svc service.Service) {
 	mux.HandleFunc("/ics/download/", ics_download.Handler)
 	mux.HandleFunc("/license/upload", license_upload.Handler)
 	mux.HandleFunc("/manifests/", manifest_download.Handler)
+	mux.HandleFunc("/oidc/callback", oidc.HandlerCallback)
+	mux.HandleFunc("/oidc/login/", oidc.HandlerLogin)
+	mux.HandleFunc("/saml/acs/", saml.HandlerAcs)
+	mux.HandleFunc("/saml/login/", saml.HandlerLogin)
+	mux.HandleFunc("/saml/metadata/", saml.HandlerMetadata)
+	mux.HandleFunc("/scim/v2/", scim.Handler)
 	mux.HandleFunc("/websocket", websocket.Handler)
 	mux.HandleFunc("/export/", transfer_export.Handler)
 	mux.HandleFunc("/import", transfer_import.Handler)
@@ -120,6 +120,13 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 
 	// public requests: accessible to all
 	switch ressource {
+	case "loginPassword":
+		switch action {
+		case "reset":
+			return loginPasswordReset_tx(ctx, tx, reqJson, address)
+		case "resetRequest":
+			return loginPasswordResetRequest_tx(ctx, tx, reqJson, address)
+		}
 	case "public":
 		switch action {
 		case "get":
@@ -192,12 +199,30 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 		switch action {
 		case "getNames":
 			return LoginGetNames(reqJson)
+		case "delSession":
+			return LoginDelSession(reqJson, loginId)
+		case "delTokenApi":
+			return LoginDelTokenApi(reqJson, loginId)
 		case "delTokenFixed":
 			return LoginDelTokenFixed(reqJson, loginId)
+		case "getSessions":
+			return LoginGetSessions(loginId)
+		case "getTokensApi":
+			return LoginGetTokensApi(loginId)
 		case "getTokensFixed":
 			return LoginGetTokensFixed(loginId)
+		case "delWebauthn":
+			return LoginDelWebauthn(reqJson, loginId)
+		case "getWebauthn":
+			return LoginGetWebauthn(loginId)
+		case "getWebauthnOptions":
+			return LoginGetWebauthnOptions(loginId)
+		case "setTokenApi":
+			return LoginSetTokenApi_tx(tx, reqJson, loginId)
 		case "setTokenFixed":
 			return LoginSetTokenFixed_tx(tx, reqJson, loginId)
+		case "setWebauthn":
+			return LoginSetWebauthn_tx(tx, reqJson, loginId)
 		}
 	case "loginClientEvent":
 		switch action {
@@ -414,6 +439,10 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 		switch action {
 		case "del":
 			return LoginDel_tx(tx, reqJson)
+		case "delSessionAdmin":
+			return LoginDelSessionAdmin(reqJson)
+		case "delTokenApiAdmin":
+			return LoginDelTokenApiAdmin(reqJson)
 		case "get":
 			return LoginGet(reqJson)
 		case "getConcurrent":
@@ -422,6 +451,10 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 			return LoginGetMembers(reqJson)
 		case "getRecords":
 			return LoginGetRecords(reqJson)
+		case "getSessionsAdmin":
+			return LoginGetSessionsAdmin(reqJson)
+		case "getTokensApiAdmin":
+			return LoginGetTokensApiAdmin()
 		case "kick":
 			return LoginKick(reqJson)
 		case "reauth":
@@ -430,10 +463,14 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 			return LoginReauthAll()
 		case "resetTotp":
 			return LoginResetTotp_tx(tx, reqJson)
+		case "resetWebauthn":
+			return LoginResetWebauthn_tx(tx, reqJson)
 		case "set":
 			return LoginSet_tx(tx, reqJson)
 		case "setMembers":
 			return LoginSetMembers_tx(tx, reqJson)
+		case "unlock":
+			return LoginUnlock_tx(tx, reqJson)
 		}
 	case "loginForm":
 		switch action {
@@ -478,6 +515,13 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 		case "get":
 			return MailTrafficGet(reqJson)
 		}
+	case "mailTemplate":
+		switch action {
+		case "del":
+			return MailTemplateDel_tx(tx, reqJson)
+		case "set":
+			return MailTemplateSet_tx(tx, reqJson)
+		}
 	case "menu":
 		switch action {
 		case "copy":
@@ -514,6 +558,17 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 		case "set":
 			return OauthClientSet_tx(tx, reqJson)
 		}
+	case "oidc":
+		switch action {
+		case "del":
+			return OidcDel_tx(tx, reqJson)
+		case "get":
+			return OidcGet()
+		case "reload":
+			return nil, cache.LoadOidcMap()
+		case "set":
+			return OidcSet_tx(tx, reqJson)
+		}
 	case "package":
 		switch action {
 		case "install":
@@ -580,6 +635,17 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 		case "update":
 			return RepoModuleUpdate()
 		}
+	case "restSpooler":
+		switch action {
+		case "del":
+			return RestSpoolerDel_tx(tx, reqJson)
+		case "get":
+			return RestSpoolerGet(reqJson)
+		case "purge":
+			return RestSpoolerPurge_tx(tx)
+		case "retry":
+			return RestSpoolerRetry_tx(tx, reqJson)
+		}
 	case "role":
 		switch action {
 		case "del":
@@ -587,6 +653,17 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 		case "set":
 			return RoleSet_tx(tx, reqJson)
 		}
+	case "saml":
+		switch action {
+		case "del":
+			return SamlDel_tx(tx, reqJson)
+		case "get":
+			return SamlGet()
+		case "reload":
+			return nil, cache.LoadSamlMap()
+		case "set":
+			return SamlSet_tx(tx, reqJson)
+		}
 	case "scheduler":
 		switch action {
 		case "get":
@@ -599,6 +676,17 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 		case "reload":
 			return SchemaReload(reqJson)
 		}
+	case "scim":
+		switch action {
+		case "del":
+			return ScimDel_tx(tx, reqJson)
+		case "get":
+			return ScimGet()
+		case "set":
+			return ScimSet_tx(tx, reqJson)
+		case "setToken":
+			return ScimSetToken_tx(tx, reqJson)
+		}
 	case "system":
 		switch action {
 		case "get":
@@ -620,6 +708,24 @@ func Exec_tx(ctx context.Context, tx pgx.Tx, address string, loginId int64, isAd
 		case "storeExportKey":
 			return TransferStoreExportKey(reqJson)
 		}
+	case "webhook":
+		switch action {
+		case "del":
+			return WebhookDel_tx(tx, reqJson)
+		case "get":
+			return WebhookGet()
+		case "reload":
+			return WebhookReload()
+		case "set":
+			return WebhookSet_tx(tx, reqJson)
+		}
+	case "webhookDelivery":
+		switch action {
+		case "del":
+			return WebhookDeliveryDel_tx(tx, reqJson)
+		case "get":
+			return WebhookDeliveryGet(reqJson)
+		}
 	case "widget":
 		switch action {
 		case "del":
@@ -43,7 +43,7 @@ func LdapImport(reqJson json.RawMessage) (interface{}, error) {
 	if err := json.Unmarshal(reqJson, &req); err != nil {
 		return nil, err
 	}
-	return nil, ldap_import.Run(req.Id)
+	return nil, ldap_import.Run(req.Id, true)
 }
 
 func LdapCheck(reqJson json.RawMessage) (interface{}, error) {
@@ -6,6 +6,10 @@ import (
 	"r3/cluster"
 	"r3/login"
 	"r3/login/login_license"
+	"r3/login/login_lock"
+	"r3/login/login_session"
+	"r3/login/login_tokenApi"
+	"r3/login/login_webauthn"
 	"r3/types"
 
 	"github.com/gofrs/uuid"
@@ -28,6 +32,24 @@ func LoginGetNames(reqJson json.RawMessage) (interface{}, error) {
 	}
 	return login.GetNames(req.Id, req.IdsExclude, req.ByString, req.NoLdapAssign)
 }
+func LoginDelSession(reqJson json.RawMessage, loginId int64) (interface{}, error) {
+	var req struct {
+		Id uuid.UUID `json:"id"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, loginSessionRevoke(req.Id, loginId)
+}
+func LoginDelTokenApi(reqJson json.RawMessage, loginId int64) (interface{}, error) {
+	var req struct {
+		Id int64 `json:"id"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, login_tokenApi.Del(req.Id, loginId)
+}
 func LoginDelTokenFixed(reqJson json.RawMessage, loginId int64) (interface{}, error) {
 	var req struct {
 		Id int64 `json:"id"`
@@ -37,9 +59,29 @@ func LoginDelTokenFixed(reqJson json.RawMessage, loginId int64) (interface{}, er
 	}
 	return nil, login.DelTokenFixed(loginId, req.Id)
 }
+func LoginGetSessions(loginId int64) (interface{}, error) {
+	return login_session.Get(loginId)
+}
+func LoginGetTokensApi(loginId int64) (interface{}, error) {
+	return login_tokenApi.Get(loginId)
+}
 func LoginGetTokensFixed(loginId int64) (interface{}, error) {
 	return login.GetTokensFixed(loginId)
 }
+func LoginSetTokenApi_tx(tx pgx.Tx, reqJson json.RawMessage, loginId int64) (interface{}, error) {
+	var req struct {
+		Name       string      `json:"name"`
+		DateExpiry int64       `json:"dateExpiry"`
+		ApiIds     []uuid.UUID `json:"apiIds"`
+		Methods    []string    `json:"methods"`
+		Addresses  []string    `json:"addresses"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return login_tokenApi.Set_tx(tx, loginId, req.Name, req.DateExpiry,
+		req.ApiIds, req.Methods, req.Addresses)
+}
 func LoginSetTokenFixed_tx(tx pgx.Tx, reqJson json.RawMessage, loginId int64) (interface{}, error) {
 
 	var (
@@ -62,6 +104,33 @@ func LoginSetTokenFixed_tx(tx pgx.Tx, reqJson json.RawMessage, loginId int64) (i
 
 	return res, err
 }
+func LoginDelWebauthn(reqJson json.RawMessage, loginId int64) (interface{}, error) {
+	var req struct {
+		Id int64 `json:"id"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, login_webauthn.Del(loginId, req.Id)
+}
+func LoginGetWebauthn(loginId int64) (interface{}, error) {
+	return login_webauthn.Get(loginId)
+}
+func LoginGetWebauthnOptions(loginId int64) (interface{}, error) {
+	return login_webauthn.GetCreationOptions(loginId)
+}
+func LoginSetWebauthn_tx(tx pgx.Tx, reqJson json.RawMessage, loginId int64) (interface{}, error) {
+	var req struct {
+		Name              string `json:"name"`
+		ClientDataJson    string `json:"clientDataJson"`
+		AttestationObject string `json:"attestationObject"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, login_webauthn.Register_tx(tx, loginId, req.Name,
+		req.ClientDataJson, req.AttestationObject)
+}
 
 // admin requests
 func LoginDel_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
@@ -74,6 +143,24 @@ func LoginDel_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
 	}
 	return nil, login.Del_tx(tx, req.Id)
 }
+func LoginDelSessionAdmin(reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Id uuid.UUID `json:"id"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, loginSessionRevoke(req.Id, 0)
+}
+func LoginDelTokenApiAdmin(reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Id int64 `json:"id"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, login_tokenApi.Del(req.Id, 0)
+}
 func LoginGet(reqJson json.RawMessage) (interface{}, error) {
 
 	var (
@@ -146,11 +233,13 @@ func LoginSet_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
 		LdapId           pgtype.Int4                 `json:"ldapId"`
 		LdapKey          pgtype.Text                 `json:"ldapKey"`
 		Name             string                      `json:"name"`
+		Mail             pgtype.Text                 `json:"mail"`
 		Pass             string                      `json:"pass"`
 		Active           bool                        `json:"active"`
 		Admin            bool                        `json:"admin"`
 		NoAuth           bool                        `json:"noAuth"`
 		TokenExpiryHours pgtype.Int4                 `json:"tokenExpiryHours"`
+		PwChangeRequired bool                        `json:"pwChangeRequired"`
 		RoleIds          []uuid.UUID                 `json:"roleIds"`
 		Records          []types.LoginAdminRecordSet `json:"records"`
 		TemplateId       pgtype.Int8                 `json:"templateId"`
@@ -159,9 +248,16 @@ func LoginSet_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
 	if err := json.Unmarshal(reqJson, &req); err != nil {
 		return nil, err
 	}
-	return login.Set_tx(tx, req.Id, req.TemplateId, req.LdapId, req.LdapKey,
+	id, err := login.Set_tx(tx, req.Id, req.TemplateId, req.LdapId, req.LdapKey,
 		req.Name, req.Pass, req.Admin, req.NoAuth, req.Active, req.TokenExpiryHours,
 		req.RoleIds, req.Records)
+	if err != nil {
+		return nil, err
+	}
+	if err := login.SetMail_tx(tx, id, req.Mail); err != nil {
+		return nil, err
+	}
+	return id, login.SetPwChangeRequired_tx(tx, id, req.PwChangeRequired)
 }
 func LoginSetMembers_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
 
@@ -175,6 +271,18 @@ func LoginSetMembers_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error)
 	}
 	return nil, login.SetRoleLoginIds_tx(tx, req.RoleId, req.LoginIds)
 }
+func LoginGetSessionsAdmin(reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Id int64 `json:"id"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return login_session.Get(req.Id)
+}
+func LoginGetTokensApiAdmin() (interface{}, error) {
+	return login_tokenApi.Get(0)
+}
 func LoginKick(reqJson json.RawMessage) (interface{}, error) {
 
 	var req struct {
@@ -209,3 +317,32 @@ func LoginResetTotp_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error)
 	}
 	return nil, login.ResetTotp_tx(tx, req.Id)
 }
+func LoginResetWebauthn_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Id int64 `json:"id"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, login.ResetWebauthn_tx(tx, req.Id)
+}
+func LoginUnlock_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Id int64 `json:"id"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, login_lock.Unlock_tx(tx, req.Id)
+}
+
+// helpers
+// revokes session, loginId > 0 restricts to sessions of this login
+// connected clients of the session are kicked on all nodes
+func loginSessionRevoke(id uuid.UUID, loginId int64) error {
+	loginIdSession, err := login_session.Del(id, loginId)
+	if err != nil || loginIdSession == 0 {
+		return err
+	}
+	return cluster.SessionRevoked(true, loginIdSession, id)
+}
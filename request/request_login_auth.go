@@ -2,16 +2,20 @@ package request
 
 import (
 	"encoding/json"
+	"errors"
 	"r3/login/login_auth"
+	"r3/login/login_webauthn"
 	"r3/types"
 
+	"github.com/gofrs/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 // attempt login via user credentials
-// applies login ID, admin and no auth state to provided parameters if successful
+// applies login ID, admin, no auth state and session ID to provided parameters if successful
 // returns token and success state
-func LoginAuthUser(reqJson json.RawMessage, loginId *int64, admin *bool, noAuth *bool) (interface{}, error) {
+func LoginAuthUser(reqJson json.RawMessage, client types.LoginSessionClient, loginId *int64,
+	admin *bool, noAuth *bool, sessionId *uuid.UUID) (interface{}, error) {
 
 	var (
 		err error
@@ -19,9 +23,13 @@ func LoginAuthUser(reqJson json.RawMessage, loginId *int64, admin *bool, noAuth
 			Username string `json:"username"`
 			Password string `json:"password"`
 
+			// new password, sent if password change is required (on second auth attempt)
+			PasswordNew string `json:"passwordNew"`
+
 			// MFA details, sent together with credentials (usually on second auth attempt)
-			MfaTokenId  pgtype.Int4 `json:"mfaTokenId"`
-			MfaTokenPin pgtype.Text `json:"mfaTokenPin"`
+			MfaTokenId  pgtype.Int4                  `json:"mfaTokenId"`
+			MfaTokenPin pgtype.Text                  `json:"mfaTokenPin"`
+			MfaWebauthn types.LoginWebauthnAssertion `json:"mfaWebauthn"`
 		}
 		res struct {
 			LoginId   int64  `json:"loginId"`
@@ -29,8 +37,13 @@ func LoginAuthUser(reqJson json.RawMessage, loginId *int64, admin *bool, noAuth
 			SaltKdf   string `json:"saltKdf"`
 			Token     string `json:"token"`
 
-			// MFA token details, filled if login was successful but MFA not satisfied yet
-			MfaTokens []types.LoginMfaToken `json:"mfaTokens"`
+			// MFA details, filled if login was successful but MFA not satisfied yet
+			MfaTokens   []types.LoginMfaToken              `json:"mfaTokens"`
+			MfaWebauthn *types.LoginWebauthnRequestOptions `json:"mfaWebauthn"` // options for WebAuthn assertion, empty if no credential is registered
+
+			// password change details, filled if login was successful but password must be changed first
+			PwChangeRequired bool   `json:"pwChangeRequired"`
+			PwChangeError    string `json:"pwChangeError"` // reason why new password was not accepted
 		}
 	)
 
@@ -38,9 +51,17 @@ func LoginAuthUser(reqJson json.RawMessage, loginId *int64, admin *bool, noAuth
 		return nil, err
 	}
 
-	res.Token, res.SaltKdf, res.MfaTokens, err = login_auth.User(req.Username,
-		req.Password, req.MfaTokenId, req.MfaTokenPin, loginId, admin, noAuth)
+	res.Token, res.SaltKdf, res.MfaTokens, res.MfaWebauthn, err = login_auth.User(req.Username,
+		req.Password, req.PasswordNew, req.MfaTokenId, req.MfaTokenPin, req.MfaWebauthn, client,
+		loginId, admin, noAuth, sessionId)
 
+	var errPwChange login_auth.PwChangeRequiredError
+	if errors.As(err, &errPwChange) {
+		res.LoginName = req.Username
+		res.PwChangeRequired = true
+		res.PwChangeError = errPwChange.Reason
+		return res, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -50,8 +71,9 @@ func LoginAuthUser(reqJson json.RawMessage, loginId *int64, admin *bool, noAuth
 }
 
 // attempt login via JWT
-// applies login ID, admin and no auth state to provided parameters if successful
-func LoginAuthToken(reqJson json.RawMessage, loginId *int64, admin *bool, noAuth *bool) (interface{}, error) {
+// applies login ID, admin, no auth state and session ID to provided parameters if successful
+func LoginAuthToken(reqJson json.RawMessage, client types.LoginSessionClient, loginId *int64,
+	admin *bool, noAuth *bool, sessionId *uuid.UUID) (interface{}, error) {
 
 	var (
 		err error
@@ -68,7 +90,7 @@ func LoginAuthToken(reqJson json.RawMessage, loginId *int64, admin *bool, noAuth
 		return nil, err
 	}
 
-	res.LoginName, err = login_auth.Token(req.Token, loginId, admin, noAuth)
+	res.LoginName, err = login_auth.TokenSession(req.Token, client.Address, loginId, admin, noAuth, sessionId)
 	if err != nil {
 		return nil, err
 	}
@@ -77,8 +99,105 @@ func LoginAuthToken(reqJson json.RawMessage, loginId *int64, admin *bool, noAuth
 	return res, nil
 }
 
+// attempt login via one-time code from completed OpenID Connect authentication
+// applies login ID, admin, no auth state and session ID to provided parameters if successful
+func LoginAuthOidc(reqJson json.RawMessage, client types.LoginSessionClient, loginId *int64,
+	admin *bool, noAuth *bool, sessionId *uuid.UUID) (interface{}, error) {
+
+	var (
+		err error
+		req struct {
+			Code string `json:"code"`
+		}
+		res struct {
+			LoginId   int64  `json:"loginId"`
+			LoginName string `json:"loginName"`
+			Token     string `json:"token"`
+		}
+	)
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	res.Token, res.LoginName, err = login_auth.Oidc(req.Code, client, loginId, admin, noAuth, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	res.LoginId = *loginId
+	return res, nil
+}
+
+// attempt login via one-time code from completed SAML authentication
+// applies login ID, admin, no auth state and session ID to provided parameters if successful
+func LoginAuthSaml(reqJson json.RawMessage, client types.LoginSessionClient, loginId *int64,
+	admin *bool, noAuth *bool, sessionId *uuid.UUID) (interface{}, error) {
+
+	var (
+		err error
+		req struct {
+			Code string `json:"code"`
+		}
+		res struct {
+			LoginId   int64  `json:"loginId"`
+			LoginName string `json:"loginName"`
+			Token     string `json:"token"`
+		}
+	)
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	res.Token, res.LoginName, err = login_auth.Saml(req.Code, client, loginId, admin, noAuth, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	res.LoginId = *loginId
+	return res, nil
+}
+
+// attempt passwordless login via WebAuthn assertion (passkey)
+// applies login ID, admin, no auth state and session ID to provided parameters if successful
+func LoginAuthWebauthn(reqJson json.RawMessage, client types.LoginSessionClient, loginId *int64,
+	admin *bool, noAuth *bool, sessionId *uuid.UUID) (interface{}, error) {
+
+	var (
+		err error
+		req struct {
+			Assertion types.LoginWebauthnAssertion `json:"assertion"`
+		}
+		res struct {
+			LoginId   int64  `json:"loginId"`
+			LoginName string `json:"loginName"`
+			Token     string `json:"token"`
+		}
+	)
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	res.Token, res.LoginName, err = login_auth.Webauthn(req.Assertion, client, loginId, admin, noAuth, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	res.LoginId = *loginId
+	return res, nil
+}
+
+// get options for passwordless login via WebAuthn, does not authenticate
+func LoginAuthWebauthnOptions() (interface{}, error) {
+	return login_webauthn.GetRequestOptions(0)
+}
+
 // attempt login via fixed token
-func LoginAuthTokenFixed(reqJson json.RawMessage, loginId *int64) (interface{}, error) {
+// applies login ID and session ID to provided parameters if successful
+func LoginAuthTokenFixed(reqJson json.RawMessage, client types.LoginSessionClient,
+	loginId *int64, sessionId *uuid.UUID) (interface{}, error) {
 
 	var (
 		req struct {
@@ -94,7 +213,7 @@ func LoginAuthTokenFixed(reqJson json.RawMessage, loginId *int64) (interface{},
 	if err := json.Unmarshal(reqJson, &req); err != nil {
 		return nil, err
 	}
-	if err := login_auth.TokenFixed(req.LoginId, "client", req.TokenFixed, &res.LanguageCode, &res.Token); err != nil {
+	if err := login_auth.TokenFixed(req.LoginId, "client", req.TokenFixed, client, &res.LanguageCode, &res.Token, sessionId); err != nil {
 		return nil, err
 	}
 	*loginId = req.LoginId
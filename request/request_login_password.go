@@ -1,10 +1,16 @@
 package request
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"r3/bruteforce"
+	"r3/cluster"
+	"r3/handler"
 	"r3/login"
 	"r3/login/login_check"
+	"r3/login/login_passwordReset"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -30,7 +36,58 @@ func loginPasswortSet_tx(tx pgx.Tx, reqJson json.RawMessage, loginId int64) (int
 	if err := login_check.PasswordComplexity(req.PwNew0); err != nil {
 		return nil, err
 	}
+	if err := login_check.PasswordHistory(tx, loginId, req.PwNew0); err != nil {
+		return nil, err
+	}
 
-	salt, hash := login.GenerateSaltHash(req.PwNew0)
+	salt, hash, err := login.GenerateSaltHash(req.PwNew0)
+	if err != nil {
+		return nil, err
+	}
 	return nil, login.SetSaltHash_tx(tx, salt, hash, loginId)
 }
+
+// public requests, every attempt counts towards bruteforce protection to limit requested mails & guessed tokens
+func loginPasswordReset_tx(ctx context.Context, tx pgx.Tx, reqJson json.RawMessage, address string) (interface{}, error) {
+
+	var req struct {
+		PwNew0 string `json:"pwNew0"`
+		PwNew1 string `json:"pwNew1"`
+		Token  string `json:"token"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	if bruteforce.CheckByHost(address) {
+		return nil, errors.New(handler.ErrBruteforceBlock)
+	}
+	bruteforce.BadAttemptByHost(address)
+
+	if req.Token == "" || req.PwNew0 == "" || req.PwNew0 != req.PwNew1 {
+		return nil, fmt.Errorf("invalid input")
+	}
+	loginId, err := login_passwordReset.Set_tx(ctx, tx, req.Token, req.PwNew0)
+	if err != nil {
+		return nil, err
+	}
+
+	// sessions of login were revoked, kick connected clients
+	return nil, cluster.LoginDisabled(true, loginId)
+}
+func loginPasswordResetRequest_tx(ctx context.Context, tx pgx.Tx, reqJson json.RawMessage, address string) (interface{}, error) {
+
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	if bruteforce.CheckByHost(address) {
+		return nil, errors.New(handler.ErrBruteforceBlock)
+	}
+	bruteforce.BadAttemptByHost(address)
+
+	return nil, login_passwordReset.Request_tx(ctx, tx, req.Username)
+}
@@ -0,0 +1,30 @@
+package request
+
+import (
+	"encoding/json"
+	"r3/schema/mailTemplate"
+	"r3/types"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func MailTemplateDel_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+
+	var req struct {
+		Id uuid.UUID `json:"id"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, mailTemplate.Del_tx(tx, req.Id)
+}
+
+func MailTemplateSet_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+
+	var req types.MailTemplate
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, mailTemplate.Set_tx(tx, req)
+}
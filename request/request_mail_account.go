@@ -1,13 +1,24 @@
 package request
 
 import (
+	"crypto/ed25519"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"r3/cache"
+	"r3/config"
 	"r3/db"
+	"r3/handler"
+	"r3/schema"
+	"r3/tools/mailsign"
 	"r3/types"
+	"regexp"
+	"slices"
 
+	"github.com/gofrs/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func MailAccountDel_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
@@ -36,7 +47,6 @@ func MailAccountSet_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error)
 		return nil, err
 	}
 
-	var err error
 	newRecord := req.Id == 0
 
 	if req.AuthMethod == "xoauth2" {
@@ -47,26 +57,277 @@ func MailAccountSet_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error)
 		req.OauthClientId.Valid = false
 	}
 
+	if req.Mode == "imap" {
+		if err := mailAccountCheckRules(req.Rules); err != nil {
+			return nil, err
+		}
+		if err := mailAccountCheckBounceFunction(req.BouncePgFunctionId); err != nil {
+			return nil, err
+		}
+		if len(req.ImapFolders) == 0 {
+			return nil, fmt.Errorf("Cannot set IMAP email account without folders to retrieve from")
+		}
+		if req.ImapAction == "move" && req.ImapFolderMove.String == "" {
+			return nil, fmt.Errorf("Cannot set IMAP email account to move messages but no target folder")
+		}
+		if req.ImapAction == "move" && slices.Contains(req.ImapFolders, req.ImapFolderMove.String) {
+			return nil, fmt.Errorf("Cannot set IMAP email account to move messages into a folder it retrieves from")
+		}
+		req.DkimDomain = pgtype.Text{}
+		req.SmimeSign = false
+		req.SmimeEncrypt = false
+	} else {
+		req.ImapFolders = []string{"INBOX"}
+		req.ImapAction = "delete"
+		req.Rules = make([]types.MailRule, 0)
+		req.BouncePgFunctionId.Valid = false
+
+		if err := mailAccountCheckSigning(req); err != nil {
+			return nil, err
+		}
+	}
+	if req.DkimDomain.String == "" {
+		req.DkimDomain.Valid = false
+		req.DkimSelector.Valid = false
+		req.DkimKey.Valid = false
+	}
+	if !req.SmimeSign && !req.SmimeEncrypt {
+		req.SmimeCert.Valid = false
+		req.SmimeKey.Valid = false
+	}
+	if req.ImapAction != "move" {
+		req.ImapFolderMove.Valid = false
+	}
+
 	if newRecord {
-		_, err = tx.Exec(db.Ctx, `
+		if err := tx.QueryRow(db.Ctx, `
 			INSERT INTO instance.mail_account (oauth_client_id, name, mode,
 				auth_method, send_as, username, password, start_tls, host_name,
-				host_port)
-			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
-		`, req.OauthClientId, req.Name, req.Mode, req.AuthMethod, req.SendAs,
-			req.Username, req.Password, req.StartTls, req.HostName, req.HostPort)
-	} else {
-		_, err = tx.Exec(db.Ctx, `
-			UPDATE instance.mail_account
-			SET oauth_client_id = $1, name = $2, mode = $3, auth_method = $4,
-				send_as = $5, username = $6, password = $7, start_tls = $8,
-				host_name = $9, host_port = $10
-			WHERE id = $11
+				host_port, imap_folders, imap_action, imap_folder_move, dkim_domain,
+				dkim_selector, dkim_key, smime_cert, smime_key, smime_sign,
+				smime_encrypt, bounce_pg_function_id)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21)
+			RETURNING id
 		`, req.OauthClientId, req.Name, req.Mode, req.AuthMethod, req.SendAs,
 			req.Username, req.Password, req.StartTls, req.HostName, req.HostPort,
-			req.Id)
+			req.ImapFolders, req.ImapAction, req.ImapFolderMove, req.DkimDomain,
+			req.DkimSelector, req.DkimKey, req.SmimeCert, req.SmimeKey,
+			req.SmimeSign, req.SmimeEncrypt, req.BouncePgFunctionId).Scan(&req.Id); err != nil {
+
+			return nil, err
+		}
+		return nil, mailAccountSetRules_tx(tx, req.Id, req.Rules)
 	}
-	return nil, err
+
+	if _, err := tx.Exec(db.Ctx, `
+		UPDATE instance.mail_account
+		SET oauth_client_id = $1, name = $2, mode = $3, auth_method = $4,
+			send_as = $5, username = $6, password = $7, start_tls = $8,
+			host_name = $9, host_port = $10, imap_folders = $11,
+			imap_action = $12, imap_folder_move = $13, dkim_domain = $14,
+			dkim_selector = $15, dkim_key = $16, smime_cert = $17,
+			smime_key = $18, smime_sign = $19, smime_encrypt = $20,
+			bounce_pg_function_id = $21
+		WHERE id = $22
+	`, req.OauthClientId, req.Name, req.Mode, req.AuthMethod, req.SendAs,
+		req.Username, req.Password, req.StartTls, req.HostName, req.HostPort,
+		req.ImapFolders, req.ImapAction, req.ImapFolderMove, req.DkimDomain,
+		req.DkimSelector, req.DkimKey, req.SmimeCert, req.SmimeKey,
+		req.SmimeSign, req.SmimeEncrypt, req.BouncePgFunctionId, req.Id); err != nil {
+
+		return nil, err
+	}
+
+	// remove UID watermarks of folders no longer retrieved from
+	if _, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.mail_account_imap_folder
+		WHERE mail_account_id = $1
+		AND   folder <> ALL($2)
+	`, req.Id, req.ImapFolders); err != nil {
+		return nil, err
+	}
+	return nil, mailAccountSetRules_tx(tx, req.Id, req.Rules)
+}
+
+// validates DKIM key and S/MIME certificate files (stored in certificates path) of SMTP account
+func mailAccountCheckSigning(req types.MailAccount) error {
+	if req.DkimDomain.String != "" {
+		if req.DkimSelector.String == "" {
+			return fmt.Errorf("Cannot set DKIM signing without selector")
+		}
+		key, err := mailsign.ParsePrivateKey([]byte(req.DkimKey.String))
+		if err != nil {
+			return fmt.Errorf("Cannot set DKIM signing with invalid private key, %s", err)
+		}
+		switch key.(type) {
+		case *rsa.PrivateKey, ed25519.PrivateKey:
+		default:
+			return fmt.Errorf("Cannot set DKIM signing with private key of type %T, only RSA and Ed25519 are supported", key)
+		}
+	}
+
+	if req.SmimeSign {
+		for _, fileName := range []string{req.SmimeCert.String, req.SmimeKey.String} {
+			if fileName == "" || filepath.Base(fileName) != fileName {
+				return fmt.Errorf("Cannot set S/MIME signing without certificate and key file names")
+			}
+		}
+		if _, err := mailsign.LoadCertificate(filepath.Join(config.File.Paths.Certificates, req.SmimeCert.String)); err != nil {
+			return fmt.Errorf("Cannot set S/MIME signing with invalid certificate, %s", err)
+		}
+		if _, err := mailsign.LoadPrivateKey(filepath.Join(config.File.Paths.Certificates, req.SmimeKey.String)); err != nil {
+			return fmt.Errorf("Cannot set S/MIME signing with invalid private key, %s", err)
+		}
+	}
+
+	// own certificate is optional for encryption, messages are then also encrypted for sender
+	if req.SmimeEncrypt && req.SmimeCert.String != "" {
+		if filepath.Base(req.SmimeCert.String) != req.SmimeCert.String {
+			return fmt.Errorf("Cannot set S/MIME encryption with invalid certificate file name")
+		}
+		if _, err := mailsign.LoadCertificate(filepath.Join(config.File.Paths.Certificates, req.SmimeCert.String)); err != nil {
+			return fmt.Errorf("Cannot set S/MIME encryption with invalid certificate, %s", err)
+		}
+	}
+	return nil
+}
+
+// validates optional function to call for bounced mails
+func mailAccountCheckBounceFunction(id pgtype.UUID) error {
+	if !id.Valid {
+		return nil
+	}
+	cache.Schema_mx.RLock()
+	defer cache.Schema_mx.RUnlock()
+
+	fnc, exists := cache.PgFunctionIdMap[id.Bytes]
+	if !exists {
+		return fmt.Errorf("Cannot set bounce function, function does not exist")
+	}
+	if fnc.IsTrigger {
+		return fmt.Errorf("Cannot set trigger function as bounce function")
+	}
+	return nil
+}
+
+// validates routing rules against module schema
+func mailAccountCheckRules(rules []types.MailRule) error {
+	cache.Schema_mx.RLock()
+	defer cache.Schema_mx.RUnlock()
+
+	for _, r := range rules {
+		if r.Name == "" {
+			return fmt.Errorf("Cannot set mail rule without name")
+		}
+
+		regexes := []string{r.MatchFrom.String, r.MatchTo.String, r.MatchSubject.String}
+		for _, h := range r.MatchHeaders {
+			if h.Name == "" {
+				return fmt.Errorf("Cannot set mail rule '%s' with empty header name", r.Name)
+			}
+			regexes = append(regexes, h.Match)
+		}
+		for _, regex := range regexes {
+			if _, err := regexp.Compile(regex); err != nil {
+				return fmt.Errorf("Cannot set mail rule '%s' with invalid expression, %s", r.Name, err)
+			}
+		}
+
+		switch r.Action {
+		case "function":
+			fnc, exists := cache.PgFunctionIdMap[r.PgFunctionId.Bytes]
+			if !r.PgFunctionId.Valid || !exists {
+				return fmt.Errorf("Cannot set mail rule '%s' without valid function", r.Name)
+			}
+			if fnc.IsTrigger {
+				return fmt.Errorf("Cannot set mail rule '%s' with trigger function", r.Name)
+			}
+		case "record":
+			if !r.RelationId.Valid {
+				return fmt.Errorf("Cannot set mail rule '%s' without relation", r.Name)
+			}
+			if _, exists := cache.RelationIdMap[r.RelationId.Bytes]; !exists {
+				return handler.ErrSchemaUnknownRelation(r.RelationId.Bytes)
+			}
+
+			for _, a := range []struct {
+				id    pgtype.UUID
+				check func(string) bool
+			}{
+				{r.AttributeIdFrom, schema.IsContentText},
+				{r.AttributeIdTo, schema.IsContentText},
+				{r.AttributeIdSubject, schema.IsContentText},
+				{r.AttributeIdBody, schema.IsContentText},
+				{r.AttributeIdDate, func(c string) bool { return c == "integer" || c == "bigint" }},
+				{r.AttributeIdFiles, schema.IsContentFiles},
+			} {
+				if !a.id.Valid {
+					continue
+				}
+				atr, exists := cache.AttributeIdMap[a.id.Bytes]
+				if !exists {
+					return handler.ErrSchemaUnknownAttribute(a.id.Bytes)
+				}
+				if atr.RelationId != r.RelationId.Bytes || !a.check(atr.Content) || atr.Encrypted {
+					return fmt.Errorf("Cannot set mail rule '%s' with incompatible attribute '%s'", r.Name, atr.Name)
+				}
+			}
+		default:
+			return fmt.Errorf("Cannot set mail rule '%s' with unknown action '%s'", r.Name, r.Action)
+		}
+	}
+	return nil
+}
+
+func mailAccountSetRules_tx(tx pgx.Tx, mailAccountId int32, rules []types.MailRule) error {
+	if _, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.mail_rule
+		WHERE mail_account_id = $1
+	`, mailAccountId); err != nil {
+		return err
+	}
+
+	for i, r := range rules {
+		// only keep references relevant for action
+		if r.Action == "function" {
+			r.RelationId.Valid = false
+			r.AttributeIdFrom.Valid = false
+			r.AttributeIdTo.Valid = false
+			r.AttributeIdSubject.Valid = false
+			r.AttributeIdBody.Valid = false
+			r.AttributeIdDate.Valid = false
+			r.AttributeIdFiles.Valid = false
+		} else {
+			r.PgFunctionId.Valid = false
+		}
+
+		var ruleId uuid.UUID
+		if err := tx.QueryRow(db.Ctx, `
+			INSERT INTO instance.mail_rule (mail_account_id, position, name,
+				match_from, match_to, match_subject, action, pg_function_id,
+				relation_id, attribute_id_from, attribute_id_to,
+				attribute_id_subject, attribute_id_body, attribute_id_date,
+				attribute_id_files)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15)
+			RETURNING id
+		`, mailAccountId, i, r.Name, r.MatchFrom, r.MatchTo, r.MatchSubject,
+			r.Action, r.PgFunctionId, r.RelationId, r.AttributeIdFrom,
+			r.AttributeIdTo, r.AttributeIdSubject, r.AttributeIdBody,
+			r.AttributeIdDate, r.AttributeIdFiles).Scan(&ruleId); err != nil {
+
+			return err
+		}
+
+		for j, h := range r.MatchHeaders {
+			if _, err := tx.Exec(db.Ctx, `
+				INSERT INTO instance.mail_rule_header (mail_rule_id, position, name, match)
+				VALUES ($1,$2,$3,$4)
+			`, ruleId, j, h.Name, h.Match); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func MailAccountReload() (interface{}, error) {
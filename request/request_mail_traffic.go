@@ -25,7 +25,8 @@ func MailTrafficGet(reqJson json.RawMessage) (interface{}, error) {
 		return nil, err
 	}
 
-	var searchFields = []string{"from_list", "to_list", "cc_list", "bcc_list", "subject"}
+	var searchFields = []string{"from_list", "to_list", "cc_list", "bcc_list", "subject",
+		"ARRAY_TO_STRING(bounce_recipients, ' ')", "ARRAY_TO_STRING(bounce_diagnostics, ' ')"}
 
 	// prepare SQL request and arguments
 	sqlArgs := make([]interface{}, 0)
@@ -45,7 +46,9 @@ func MailTrafficGet(reqJson json.RawMessage) (interface{}, error) {
 
 	rows, err := db.Pool.Query(db.Ctx, fmt.Sprintf(`
 		SELECT from_list, to_list, cc_list, bcc_list,
-			subject, outgoing, date, files, mail_account_id
+			subject, outgoing, date, files, mail_account_id, message_id,
+			bounce_date, COALESCE(bounce_recipients, '{}'),
+			COALESCE(bounce_statuses, '{}'), COALESCE(bounce_diagnostics, '{}')
 		FROM instance.mail_traffic
 		%s
 		ORDER BY date DESC
@@ -61,7 +64,9 @@ func MailTrafficGet(reqJson json.RawMessage) (interface{}, error) {
 	for rows.Next() {
 		var m types.MailTraffic
 		if err := rows.Scan(&m.FromList, &m.ToList, &m.CcList, &m.BccList,
-			&m.Subject, &m.Outgoing, &m.Date, &m.Files, &m.AccountId); err != nil {
+			&m.Subject, &m.Outgoing, &m.Date, &m.Files, &m.AccountId,
+			&m.MessageId, &m.BounceDate, &m.BounceRecipients, &m.BounceStatuses,
+			&m.BounceDiagnostics); err != nil {
 
 			return nil, err
 		}
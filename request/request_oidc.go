@@ -0,0 +1,33 @@
+package request
+
+import (
+	"encoding/json"
+	"r3/oidc"
+	"r3/types"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func OidcDel_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Id int32 `json:"id"`
+	}
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, oidc.Del_tx(tx, req.Id)
+}
+
+func OidcGet() (interface{}, error) {
+	return oidc.Get()
+}
+
+func OidcSet_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req types.Oidc
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, oidc.Set_tx(tx, req)
+}
@@ -5,34 +5,45 @@ import (
 	"r3/cache"
 	"r3/config"
 	"r3/types"
+	"slices"
+	"strings"
 
 	"github.com/gofrs/uuid"
 )
 
+type publicIdp struct {
+	Id   int32  `json:"id"`
+	Name string `json:"name"`
+}
+
 func PublicGet() (interface{}, error) {
 	var res struct {
-		Activated           bool                           `json:"activated"`
-		AppName             string                         `json:"appName"`
-		AppNameShort        string                         `json:"appNameShort"`
-		AppVersion          string                         `json:"appVersion"`
-		CaptionMapCustom    types.CaptionMapsAll           `json:"captionMapCustom"`
-		ClusterNodeName     string                         `json:"clusterNodeName"`
-		CompanyColorHeader  string                         `json:"companyColorHeader"`
-		CompanyColorLogin   string                         `json:"companyColorLogin"`
-		CompanyLoginImage   string                         `json:"companyLoginImage"`
-		CompanyLogo         string                         `json:"companyLogo"`
-		CompanyLogoUrl      string                         `json:"companyLogoUrl"`
-		CompanyName         string                         `json:"companyName"`
-		CompanyWelcome      string                         `json:"companyWelcome"`
-		Css                 string                         `json:"css"`
-		LanguageCodes       []string                       `json:"languageCodes"`
-		LoginBackground     uint64                         `json:"loginBackground"`
-		ModuleIdMapMeta     map[uuid.UUID]types.ModuleMeta `json:"moduleIdMapMeta"`
-		PresetIdMapRecordId map[uuid.UUID]int64            `json:"presetIdMapRecordId"`
-		ProductionMode      uint64                         `json:"productionMode"`
-		PwaDomainMap        map[string]uuid.UUID           `json:"pwaDomainMap"`
-		SearchDictionaries  []string                       `json:"searchDictionaries"`
-		TokenKeepEnable     bool                           `json:"tokenKeepEnable"`
+		Activated            bool                           `json:"activated"`
+		AppName              string                         `json:"appName"`
+		AppNameShort         string                         `json:"appNameShort"`
+		AppVersion           string                         `json:"appVersion"`
+		CaptionMapCustom     types.CaptionMapsAll           `json:"captionMapCustom"`
+		ClusterNodeName      string                         `json:"clusterNodeName"`
+		CompanyColorHeader   string                         `json:"companyColorHeader"`
+		CompanyColorLogin    string                         `json:"companyColorLogin"`
+		CompanyLoginImage    string                         `json:"companyLoginImage"`
+		CompanyLogo          string                         `json:"companyLogo"`
+		CompanyLogoUrl       string                         `json:"companyLogoUrl"`
+		CompanyName          string                         `json:"companyName"`
+		CompanyWelcome       string                         `json:"companyWelcome"`
+		Css                  string                         `json:"css"`
+		LanguageCodes        []string                       `json:"languageCodes"`
+		LoginBackground      uint64                         `json:"loginBackground"`
+		ModuleIdMapMeta      map[uuid.UUID]types.ModuleMeta `json:"moduleIdMapMeta"`
+		OidcProviders        []publicIdp                    `json:"oidcProviders"`
+		PresetIdMapRecordId  map[uuid.UUID]int64            `json:"presetIdMapRecordId"`
+		ProductionMode       uint64                         `json:"productionMode"`
+		PwResetEnable        bool                           `json:"pwResetEnable"`
+		PwaDomainMap         map[string]uuid.UUID           `json:"pwaDomainMap"`
+		SamlProviders        []publicIdp                    `json:"samlProviders"`
+		SearchDictionaries   []string                       `json:"searchDictionaries"`
+		TokenKeepEnable      bool                           `json:"tokenKeepEnable"`
+		WebauthnPasswordless bool                           `json:"webauthnPasswordless"`
 	}
 	res.Activated = config.GetLicenseActive()
 	res.AppName = config.GetString("appName")
@@ -50,11 +61,32 @@ func PublicGet() (interface{}, error) {
 	res.Css = config.GetString("css")
 	res.LanguageCodes = cache.GetCaptionLanguageCodes()
 	res.ModuleIdMapMeta = cache.GetModuleIdMapMeta()
+	res.OidcProviders = make([]publicIdp, 0)
 	res.PresetIdMapRecordId = cache.GetPresetRecordIds()
 	res.ProductionMode = config.GetUint64("productionMode")
+	res.PwResetEnable = config.GetUint64("pwResetEnable") == 1
 	res.PwaDomainMap = cache.GetPwaDomainMap()
+	res.SamlProviders = make([]publicIdp, 0)
 	res.SearchDictionaries = cache.GetSearchDictionaries()
 	res.TokenKeepEnable = config.GetUint64("tokenKeepEnable") == 1
+	res.WebauthnPasswordless = config.GetUint64("webauthnPasswordless") == 1
+
+	// active OpenID Connect & SAML providers, offered on login page
+	for _, o := range cache.GetOidcIdMap() {
+		if o.Active {
+			res.OidcProviders = append(res.OidcProviders, publicIdp{Id: o.Id, Name: o.Name})
+		}
+	}
+	for _, s := range cache.GetSamlIdMap() {
+		if s.Active {
+			res.SamlProviders = append(res.SamlProviders, publicIdp{Id: s.Id, Name: s.Name})
+		}
+	}
+	sortByName := func(a, b publicIdp) int {
+		return strings.Compare(a.Name, b.Name)
+	}
+	slices.SortFunc(res.OidcProviders, sortByName)
+	slices.SortFunc(res.SamlProviders, sortByName)
 
 	// random background from available list
 	var loginBackgrounds = config.GetUint64Slice("loginBackgrounds")
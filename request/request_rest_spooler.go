@@ -0,0 +1,126 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"r3/db"
+	"r3/types"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func RestSpoolerDel_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Ids []uuid.UUID `json:"ids"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	_, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.rest_spool
+		WHERE id = ANY($1)
+	`, req.Ids)
+
+	return nil, err
+}
+
+func RestSpoolerGet(reqJson json.RawMessage) (interface{}, error) {
+
+	var (
+		req struct {
+			Limit      int    `json:"limit"`
+			Offset     int    `json:"offset"`
+			Search     string `json:"search"`     // optional, searches URL, body, error, response and group key
+			DeadLetter bool   `json:"deadLetter"` // only calls that reached the attempt limit
+		}
+		res struct {
+			Calls []types.RestSpool `json:"calls"`
+			Total int64             `json:"total"`
+		}
+	)
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	sqlArgs := make([]interface{}, 0)
+	sqlWhere := "WHERE TRUE"
+	if req.DeadLetter {
+		sqlWhere = fmt.Sprintf("%s\nAND dead_letter = true", sqlWhere)
+	}
+	if req.Search != "" {
+		sqlArgs = append(sqlArgs, fmt.Sprintf("%%%s%%", req.Search))
+		sqlWhere = fmt.Sprintf("%s\nAND (url ILIKE $%d OR body ILIKE $%d OR error ILIKE $%d OR response_body ILIKE $%d OR group_key ILIKE $%d)",
+			sqlWhere, len(sqlArgs), len(sqlArgs), len(sqlArgs), len(sqlArgs), len(sqlArgs))
+	}
+
+	if err := db.Pool.QueryRow(db.Ctx, fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM instance.rest_spool
+		%s
+	`, sqlWhere), sqlArgs...).Scan(&res.Total); err != nil {
+		return nil, err
+	}
+
+	sqlArgs = append(sqlArgs, req.Limit, req.Offset)
+	res.Calls = make([]types.RestSpool, 0)
+	rows, err := db.Pool.Query(db.Ctx, fmt.Sprintf(`
+		SELECT id, pg_function_id_callback, method, url, headers, body,
+			skip_verify, date_added, date_attempt, next_attempt, attempt_count,
+			dead_letter, timeout_seconds, success_codes, response_code,
+			response_body, error, group_key, claim_node_id, claim_until
+		FROM instance.rest_spool
+		%s
+		ORDER BY date_added DESC
+		LIMIT $%d
+		OFFSET $%d
+	`, sqlWhere, len(sqlArgs)-1, len(sqlArgs)), sqlArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c types.RestSpool
+		if err := rows.Scan(&c.Id, &c.PgFunctionId, &c.Method, &c.Url, &c.Headers,
+			&c.Body, &c.SkipVerify, &c.DateAdded, &c.DateAttempt, &c.NextAttempt,
+			&c.AttemptCount, &c.DeadLetter, &c.TimeoutSeconds, &c.SuccessCodes,
+			&c.ResponseCode, &c.ResponseBody, &c.Error, &c.GroupKey,
+			&c.ClaimNodeId, &c.ClaimUntil); err != nil {
+
+			return nil, err
+		}
+		res.Calls = append(res.Calls, c)
+	}
+	return res, nil
+}
+
+// deletes all dead letters
+func RestSpoolerPurge_tx(tx pgx.Tx) (interface{}, error) {
+	_, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.rest_spool
+		WHERE dead_letter = true
+	`)
+	return nil, err
+}
+
+// resets attempts of REST calls, to execute them with the next spooler run
+func RestSpoolerRetry_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Ids []uuid.UUID `json:"ids"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	_, err := tx.Exec(db.Ctx, `
+		UPDATE instance.rest_spool
+		SET attempt_count = 0, next_attempt = 0, dead_letter = false,
+			claim_node_id = NULL, claim_until = 0
+		WHERE id = ANY($1)
+	`, req.Ids)
+
+	return nil, err
+}
@@ -0,0 +1,33 @@
+package request
+
+import (
+	"encoding/json"
+	"r3/saml"
+	"r3/types"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func SamlDel_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Id int32 `json:"id"`
+	}
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, saml.Del_tx(tx, req.Id)
+}
+
+func SamlGet() (interface{}, error) {
+	return saml.Get()
+}
+
+func SamlSet_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req types.Saml
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, saml.Set_tx(tx, req)
+}
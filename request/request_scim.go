@@ -0,0 +1,45 @@
+package request
+
+import (
+	"encoding/json"
+	"r3/scim"
+	"r3/types"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func ScimDel_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Id int32 `json:"id"`
+	}
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, scim.Del_tx(tx, req.Id)
+}
+
+func ScimGet() (interface{}, error) {
+	return scim.Get()
+}
+
+func ScimSet_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req types.Scim
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return nil, scim.Set_tx(tx, req)
+}
+
+// returns new bearer token, it cannot be retrieved again
+func ScimSetToken_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Id int32 `json:"id"`
+	}
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+	return scim.SetToken_tx(tx, req.Id)
+}
@@ -0,0 +1,144 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"r3/cache"
+	"r3/db"
+	"r3/types"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func WebhookDel_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Id int32 `json:"id"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	_, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.webhook
+		WHERE id = $1
+	`, req.Id)
+	return nil, err
+}
+
+func WebhookGet() (interface{}, error) {
+	return cache.GetWebhookMap(), nil
+}
+
+func WebhookReload() (interface{}, error) {
+	return nil, cache.LoadWebhookMap()
+}
+
+func WebhookSet_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req types.Webhook
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	var err error
+	newRecord := req.Id == 0
+
+	if newRecord {
+		_, err = tx.Exec(db.Ctx, `
+			INSERT INTO instance.webhook (relation_id, name, url, secret,
+				on_insert, on_update, on_delete, skip_verify, active)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		`, req.RelationId, req.Name, req.Url, req.Secret, req.OnInsert,
+			req.OnUpdate, req.OnDelete, req.SkipVerify, req.Active)
+	} else {
+		_, err = tx.Exec(db.Ctx, `
+			UPDATE instance.webhook
+			SET relation_id = $1, name = $2, url = $3, secret = $4, on_insert = $5,
+				on_update = $6, on_delete = $7, skip_verify = $8, active = $9
+			WHERE id = $10
+		`, req.RelationId, req.Name, req.Url, req.Secret, req.OnInsert,
+			req.OnUpdate, req.OnDelete, req.SkipVerify, req.Active, req.Id)
+	}
+	return nil, err
+}
+
+// delivery history
+func WebhookDeliveryDel_tx(tx pgx.Tx, reqJson json.RawMessage) (interface{}, error) {
+	var req struct {
+		Ids []int64 `json:"ids"`
+	}
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	_, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.webhook_delivery
+		WHERE id = ANY($1)
+	`, req.Ids)
+	return nil, err
+}
+
+func WebhookDeliveryGet(reqJson json.RawMessage) (interface{}, error) {
+
+	var (
+		req struct {
+			Limit     int         `json:"limit"`
+			Offset    int         `json:"offset"`
+			WebhookId pgtype.Int4 `json:"webhookId"` // optional filter
+			Failed    bool        `json:"failed"`    // only deliveries which were not done (yet)
+		}
+		res struct {
+			Deliveries []types.WebhookDelivery `json:"deliveries"`
+			Total      int64                   `json:"total"`
+		}
+	)
+
+	if err := json.Unmarshal(reqJson, &req); err != nil {
+		return nil, err
+	}
+
+	sqlArgs := make([]interface{}, 0)
+	sqlWhere := "WHERE TRUE"
+	if req.WebhookId.Valid {
+		sqlArgs = append(sqlArgs, req.WebhookId.Int32)
+		sqlWhere = fmt.Sprintf("%s\nAND webhook_id = $%d", sqlWhere, len(sqlArgs))
+	}
+	if req.Failed {
+		sqlWhere = fmt.Sprintf("%s\nAND date_done IS NULL", sqlWhere)
+	}
+
+	if err := db.Pool.QueryRow(db.Ctx, fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM instance.webhook_delivery
+		%s
+	`, sqlWhere), sqlArgs...).Scan(&res.Total); err != nil {
+		return nil, err
+	}
+
+	sqlArgs = append(sqlArgs, req.Limit, req.Offset)
+	res.Deliveries = make([]types.WebhookDelivery, 0)
+	rows, err := db.Pool.Query(db.Ctx, fmt.Sprintf(`
+		SELECT id, webhook_id, event, record_id, date_added,
+			date_done, attempt_count, response_code, error
+		FROM instance.webhook_delivery
+		%s
+		ORDER BY date_added DESC, id DESC
+		LIMIT $%d
+		OFFSET $%d
+	`, sqlWhere, len(sqlArgs)-1, len(sqlArgs)), sqlArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d types.WebhookDelivery
+		if err := rows.Scan(&d.Id, &d.WebhookId, &d.Event, &d.RecordId, &d.DateAdded,
+			&d.DateDone, &d.AttemptCount, &d.ResponseCode, &d.Error); err != nil {
+
+			return nil, err
+		}
+		res.Deliveries = append(res.Deliveries, d)
+	}
+	return res, nil
+}
@@ -0,0 +1,180 @@
+package saml
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"r3/db"
+	"r3/types"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var spCertificateValidDays = 3650
+
+func Del_tx(tx pgx.Tx, id int32) error {
+	_, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.saml
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+func Get() ([]types.Saml, error) {
+	samls := make([]types.Saml, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT id, login_template_id, name, idp_entity_id, idp_sso_url,
+			idp_certificate, sp_certificate, sp_private_key, attribute_username,
+			attribute_groups, assign_roles, active
+		FROM instance.saml
+		ORDER BY name ASC
+	`)
+	if err != nil {
+		return samls, err
+	}
+
+	for rows.Next() {
+		var s types.Saml
+		if err := rows.Scan(&s.Id, &s.LoginTemplateId, &s.Name, &s.IdpEntityId,
+			&s.IdpSsoUrl, &s.IdpCertificate, &s.SpCertificate, &s.SpPrivateKey,
+			&s.AttributeUsername, &s.AttributeGroups, &s.AssignRoles,
+			&s.Active); err != nil {
+
+			rows.Close()
+			return samls, err
+		}
+		samls = append(samls, s)
+	}
+	rows.Close()
+
+	for i, _ := range samls {
+		samls[i].Roles, err = getRoles(samls[i].Id)
+		if err != nil {
+			return samls, err
+		}
+	}
+	return samls, nil
+}
+
+func Set_tx(tx pgx.Tx, s types.Saml) error {
+
+	if s.Id == 0 {
+		// service provider key pair is generated once, used to sign authentication requests
+		cert, key, err := createSpKeyPair(s.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.QueryRow(db.Ctx, `
+			INSERT INTO instance.saml (
+				login_template_id, name, idp_entity_id, idp_sso_url,
+				idp_certificate, sp_certificate, sp_private_key,
+				attribute_username, attribute_groups, assign_roles, active
+			)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+			RETURNING id
+		`, s.LoginTemplateId, s.Name, s.IdpEntityId, s.IdpSsoUrl,
+			s.IdpCertificate, cert, key, s.AttributeUsername,
+			s.AttributeGroups, s.AssignRoles, s.Active).Scan(&s.Id); err != nil {
+
+			return err
+		}
+	} else {
+		if _, err := tx.Exec(db.Ctx, `
+			UPDATE instance.saml
+			SET login_template_id = $1, name = $2, idp_entity_id = $3,
+				idp_sso_url = $4, idp_certificate = $5, attribute_username = $6,
+				attribute_groups = $7, assign_roles = $8, active = $9
+			WHERE id = $10
+		`, s.LoginTemplateId, s.Name, s.IdpEntityId, s.IdpSsoUrl,
+			s.IdpCertificate, s.AttributeUsername, s.AttributeGroups,
+			s.AssignRoles, s.Active, s.Id); err != nil {
+
+			return err
+		}
+	}
+
+	// update SAML role assignment
+	if _, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.saml_role
+		WHERE saml_id = $1
+	`, s.Id); err != nil {
+		return err
+	}
+
+	for _, role := range s.Roles {
+		if _, err := tx.Exec(db.Ctx, `
+			INSERT INTO instance.saml_role (saml_id, role_id, group_name)
+			VALUES ($1,$2,$3)
+		`, s.Id, role.RoleId, role.GroupName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getRoles(samlId int32) ([]types.SamlRole, error) {
+	roles := make([]types.SamlRole, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT role_id, group_name
+		FROM instance.saml_role
+		WHERE saml_id = $1
+		ORDER BY group_name
+	`, samlId)
+	if err != nil {
+		return roles, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r types.SamlRole
+		if err := rows.Scan(&r.RoleId, &r.GroupName); err != nil {
+			return roles, err
+		}
+		r.SamlId = samlId
+		roles = append(roles, r)
+	}
+	return roles, nil
+}
+
+// creates self-signed certificate & private key (both PEM encoded) for service provider
+func createSpKeyPair(name string) (string, string, error) {
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+
+	validFrom := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   name,
+			Organization: []string{"REI3"},
+		},
+		NotBefore:             validFrom,
+		NotAfter:              validFrom.AddDate(0, 0, spCertificateValidDays),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		return "", "", err
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	return string(cert), string(key), nil
+}
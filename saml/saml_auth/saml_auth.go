@@ -0,0 +1,493 @@
+package saml_auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"r3/cache"
+	"r3/cluster"
+	"r3/db"
+	"r3/log"
+	"r3/login"
+	"r3/tools"
+	"r3/types"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/gofrs/uuid"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+var (
+	clockSkew                  = time.Minute * 3 // tolerated time difference to identity provider
+	codeExpirySeconds    int64 = 60              // one-time code, handing over authentication to client
+	requestExpirySeconds int64 = 600             // time for user to authenticate at identity provider
+)
+
+// service provider URLs, derived from public base URL of this instance
+func GetEntityId(samlId int32, baseUrl string) string {
+	return fmt.Sprintf("%s/saml/metadata/%d", baseUrl, samlId)
+}
+func GetAcsUrl(samlId int32, baseUrl string) string {
+	return fmt.Sprintf("%s/saml/acs/%d", baseUrl, samlId)
+}
+
+// returns service provider metadata, to register this instance with the identity provider
+func GetMetadata(samlId int32, baseUrl string) ([]byte, error) {
+
+	s, err := cache.GetSaml(samlId)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(s.SpCertificate))
+	if block == nil {
+		return nil, errors.New("invalid service provider certificate")
+	}
+
+	b, err := xml.MarshalIndent(xmlEntityDescriptor{
+		XmlnsMd:  nsMetadata,
+		XmlnsDs:  nsSignature,
+		EntityId: GetEntityId(s.Id, baseUrl),
+		SpSsoDescriptor: xmlSpSsoDescriptor{
+			AuthnRequestsSigned:        true,
+			WantAssertionsSigned:       true,
+			ProtocolSupportEnumeration: nsProtocol,
+			KeyDescriptor: xmlKeyDescriptor{
+				Use:         "signing",
+				Certificate: base64.StdEncoding.EncodeToString(block.Bytes),
+			},
+			NameIdFormat: nameIdFormat,
+			AssertionConsumerService: xmlEndpointIndexed{
+				Binding:   bindingPost,
+				Location:  GetAcsUrl(s.Id, baseUrl),
+				Index:     0,
+				IsDefault: true,
+			},
+		},
+	}, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// returns URL of identity provider to redirect user to, with signed authentication request (HTTP-Redirect binding)
+func GetAuthUrl(samlId int32, baseUrl string) (string, error) {
+
+	s, err := cache.GetSaml(samlId)
+	if err != nil {
+		return "", err
+	}
+	if !s.Active {
+		return "", errors.New("SAML identity provider is inactive")
+	}
+
+	key, err := getSpPrivateKey(s)
+	if err != nil {
+		return "", err
+	}
+
+	// request ID must be a valid XML ID (cannot start with a digit)
+	idBytes := make([]byte, 20)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	requestId := fmt.Sprintf("_%s", hex.EncodeToString(idBytes))
+
+	now := tools.GetTimeUnix()
+	if _, err := db.Pool.Exec(db.Ctx, `
+		DELETE FROM instance.saml_request
+		WHERE date_expiry < $1
+	`, now); err != nil {
+		return "", err
+	}
+	if _, err := db.Pool.Exec(db.Ctx, `
+		INSERT INTO instance.saml_request (id, saml_id, date_expiry)
+		VALUES ($1,$2,$3)
+	`, requestId, s.Id, now+requestExpirySeconds); err != nil {
+		return "", err
+	}
+
+	reqXml, err := xml.Marshal(xmlAuthnRequest{
+		XmlnsSamlp:                  nsProtocol,
+		XmlnsSaml:                   nsAssertion,
+		Id:                          requestId,
+		Version:                     "2.0",
+		IssueInstant:                time.Now().UTC().Format(time.RFC3339),
+		Destination:                 s.IdpSsoUrl,
+		AssertionConsumerServiceUrl: GetAcsUrl(s.Id, baseUrl),
+		ProtocolBinding:             bindingPost,
+		Issuer:                      GetEntityId(s.Id, baseUrl),
+		NameIdPolicy: xmlNameIdPolicy{
+			Format:      nameIdFormat,
+			AllowCreate: true,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// HTTP-Redirect binding: deflated, base64 encoded request, signature over query string
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(reqXml); err != nil {
+		return "", err
+	}
+	if err := fw.Close(); err != nil {
+		return "", err
+	}
+
+	query := fmt.Sprintf("SAMLRequest=%s&SigAlg=%s",
+		url.QueryEscape(base64.StdEncoding.EncodeToString(buf.Bytes())),
+		url.QueryEscape(sigAlgRsaSha256))
+
+	hash := sha256.Sum256([]byte(query))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	sep := "?"
+	if strings.Contains(s.IdpSsoUrl, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s%s&Signature=%s", s.IdpSsoUrl, sep, query,
+		url.QueryEscape(base64.StdEncoding.EncodeToString(sig))), nil
+}
+
+// validates SAML response from identity provider (HTTP-POST binding)
+// creates/updates login and returns one-time code to hand over authentication to client
+func Callback(samlId int32, baseUrl string, samlResponse string) (string, error) {
+
+	s, err := cache.GetSaml(samlId)
+	if err != nil {
+		return "", err
+	}
+	if !s.Active {
+		return "", errors.New("SAML identity provider is inactive")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode SAML response, %s", err)
+	}
+
+	res, a, err := getVerifiedAssertion(s, raw)
+	if err != nil {
+		return "", err
+	}
+
+	// validate response & assertion
+	acsUrl := GetAcsUrl(s.Id, baseUrl)
+	entityId := GetEntityId(s.Id, baseUrl)
+	now := time.Now()
+
+	if res.Status.StatusCode.Value != statusSuccess {
+		return "", fmt.Errorf("identity provider returned status '%s' (%s)",
+			res.Status.StatusCode.Value, res.Status.StatusMessage)
+	}
+	if res.Destination != "" && res.Destination != acsUrl {
+		return "", fmt.Errorf("response destination '%s' does not match '%s'", res.Destination, acsUrl)
+	}
+	if strings.TrimSpace(a.Issuer) != s.IdpEntityId {
+		return "", fmt.Errorf("assertion issuer '%s' does not match '%s'", a.Issuer, s.IdpEntityId)
+	}
+	if err := checkConditions(a.Conditions, entityId, now); err != nil {
+		return "", err
+	}
+
+	requestId, err := checkSubjectConfirmation(a.Subject, acsUrl, now)
+	if err != nil {
+		return "", err
+	}
+
+	nameId := strings.TrimSpace(a.Subject.NameId)
+	if nameId == "" {
+		return "", errors.New("assertion does not include a name ID")
+	}
+
+	// login name, roles by group membership
+	username := nameId
+	if s.AttributeUsername != "" {
+		values := getAttributeValues(a, s.AttributeUsername)
+		if len(values) != 1 || values[0] == "" {
+			return "", fmt.Errorf("assertion does not include a valid username attribute '%s'", s.AttributeUsername)
+		}
+		username = values[0]
+	}
+
+	roleIds := make([]uuid.UUID, 0)
+	if s.AssignRoles && s.AttributeGroups != "" {
+		for _, group := range getAttributeValues(a, s.AttributeGroups) {
+			for _, r := range s.Roles {
+				if r.GroupName == group && !slices.Contains(roleIds, r.RoleId) {
+					roleIds = append(roleIds, r.RoleId)
+				}
+			}
+		}
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	// responses are only accepted once, for requests issued by this instance
+	var dateExpiry int64
+	if err := tx.QueryRow(db.Ctx, `
+		DELETE FROM instance.saml_request
+		WHERE id      = $1
+		AND   saml_id = $2
+		RETURNING date_expiry
+	`, requestId, s.Id).Scan(&dateExpiry); err != nil {
+		return "", errors.New("assertion does not refer to a known authentication request")
+	}
+	if dateExpiry < tools.GetTimeUnix() {
+		return "", errors.New("authentication request expired")
+	}
+
+	loginId, changed, err := login.SetSamlLogin_tx(tx, s.Id, nameId, username,
+		roleIds, s.LoginTemplateId, s.AssignRoles)
+
+	if err != nil {
+		return "", err
+	}
+
+	codeBytes := make([]byte, 32)
+	if _, err := rand.Read(codeBytes); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(codeBytes)
+
+	if _, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.saml_code
+		WHERE date_expiry < $1
+	`, tools.GetTimeUnix()); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(db.Ctx, `
+		INSERT INTO instance.saml_code (code, login_id, date_expiry)
+		VALUES ($1,$2,$3)
+	`, code, loginId, tools.GetTimeUnix()+codeExpirySeconds); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(db.Ctx); err != nil {
+		return "", err
+	}
+
+	if changed {
+		if err := cluster.LoginReauthorized(true, loginId); err != nil {
+			log.Warning("server", fmt.Sprintf("could not renew access permissions for login ID %d", loginId), err)
+		}
+	}
+	log.Info("server", fmt.Sprintf("SAML authentication for login '%s' successful", username))
+	return code, nil
+}
+
+// verifies signature of response or assertion with identity provider certificate
+// only content covered by a valid signature is returned, to prevent signature wrapping attacks
+func getVerifiedAssertion(s types.Saml, raw []byte) (xmlResponse, xmlAssertion, error) {
+	var res xmlResponse
+	var a xmlAssertion
+
+	cert, err := getIdpCertificate(s)
+	if err != nil {
+		return res, a, err
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return res, a, fmt.Errorf("failed to parse SAML response, %s", err)
+	}
+	root := doc.Root()
+	if root == nil || root.Tag != "Response" || root.NamespaceURI() != nsProtocol {
+		return res, a, errors.New("invalid SAML response")
+	}
+
+	if el, _ := etreeutils.NSFindOneChild(root, nsAssertion, "EncryptedAssertion"); el != nil {
+		return res, a, errors.New("encrypted assertions are not supported")
+	}
+
+	var assertionEls []*etree.Element
+	if err := etreeutils.NSFindChildrenIterateCtx(etreeutils.NewDefaultNSContext(), root, nsAssertion, "Assertion",
+		func(ctx etreeutils.NSContext, el *etree.Element) error {
+			assertionEls = append(assertionEls, el)
+			return nil
+		}); err != nil {
+		return res, a, err
+	}
+	if len(assertionEls) != 1 {
+		return res, a, fmt.Errorf("SAML response must include exactly one assertion, found %d", len(assertionEls))
+	}
+
+	vctx := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: []*x509.Certificate{cert},
+	})
+
+	// signed response, covers assertion
+	if el, _ := etreeutils.NSFindOneChild(root, nsSignature, "Signature"); el != nil {
+		verified, err := vctx.Validate(root)
+		if err != nil {
+			return res, a, fmt.Errorf("invalid SAML response signature, %s", err)
+		}
+		if err := unmarshalElement(verified, &res); err != nil {
+			return res, a, err
+		}
+		if len(res.Assertions) != 1 {
+			return res, a, errors.New("signed SAML response must include exactly one assertion")
+		}
+		return res, res.Assertions[0], nil
+	}
+
+	// signed assertion, response itself is unsigned
+	if err := xml.Unmarshal(raw, &res); err != nil {
+		return res, a, err
+	}
+	ctx, err := etreeutils.NSBuildParentContext(assertionEls[0])
+	if err != nil {
+		return res, a, err
+	}
+	detached, err := etreeutils.NSDetatch(ctx, assertionEls[0])
+	if err != nil {
+		return res, a, err
+	}
+	verified, err := vctx.Validate(detached)
+	if err != nil {
+		return res, a, fmt.Errorf("invalid SAML assertion signature, %s", err)
+	}
+	if err := unmarshalElement(verified, &a); err != nil {
+		return res, a, err
+	}
+	return res, a, nil
+}
+
+func checkConditions(c *xmlConditions, entityId string, now time.Time) error {
+	if c == nil {
+		return errors.New("assertion does not include conditions")
+	}
+	if c.NotBefore != "" {
+		t, err := time.Parse(time.RFC3339, c.NotBefore)
+		if err != nil {
+			return err
+		}
+		if now.Add(clockSkew).Before(t) {
+			return errors.New("assertion is not yet valid")
+		}
+	}
+	if c.NotOnOrAfter != "" {
+		t, err := time.Parse(time.RFC3339, c.NotOnOrAfter)
+		if err != nil {
+			return err
+		}
+		if !now.Add(-clockSkew).Before(t) {
+			return errors.New("assertion has expired")
+		}
+	}
+
+	// each audience restriction must include this service provider
+	if len(c.AudienceRestrictions) == 0 {
+		return errors.New("assertion does not include an audience restriction")
+	}
+	for _, r := range c.AudienceRestrictions {
+		if !slices.Contains(r.Audiences, entityId) {
+			return fmt.Errorf("assertion audience does not include '%s'", entityId)
+		}
+	}
+	return nil
+}
+
+// returns ID of authentication request that bearer confirmation refers to
+func checkSubjectConfirmation(sub xmlSubject, acsUrl string, now time.Time) (string, error) {
+	for _, sc := range sub.SubjectConfirmations {
+		if sc.Method != methodBearer {
+			continue
+		}
+		if sc.Data.Recipient != acsUrl {
+			continue
+		}
+		if sc.Data.InResponseTo == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, sc.Data.NotOnOrAfter)
+		if err != nil || !now.Add(-clockSkew).Before(t) {
+			continue
+		}
+		return sc.Data.InResponseTo, nil
+	}
+	return "", errors.New("assertion does not include a valid bearer subject confirmation")
+}
+
+func getAttributeValues(a xmlAssertion, name string) []string {
+	values := make([]string, 0)
+	for _, st := range a.AttributeStatements {
+		for _, atr := range st.Attributes {
+			if atr.Name == name || atr.FriendlyName == name {
+				for _, v := range atr.Values {
+					values = append(values, strings.TrimSpace(v))
+				}
+			}
+		}
+	}
+	return values
+}
+
+// accepts PEM or plain base64 encoded certificate
+func getIdpCertificate(s types.Saml) (*x509.Certificate, error) {
+	var der []byte
+	if block, _ := pem.Decode([]byte(s.IdpCertificate)); block != nil {
+		der = block.Bytes
+	} else {
+		var err error
+		der, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s.IdpCertificate), ""))
+		if err != nil {
+			return nil, errors.New("invalid identity provider certificate")
+		}
+	}
+	return x509.ParseCertificate(der)
+}
+
+func getSpPrivateKey(s types.Saml) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s.SpPrivateKey))
+	if block == nil {
+		return nil, errors.New("invalid service provider private key")
+	}
+
+	// PKCS#8 is the default of most tools, PKCS#1 is still used for RSA keys
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported service provider private key type %T, RSA is required", key)
+		}
+		return rsaKey, nil
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func unmarshalElement(el *etree.Element, target interface{}) error {
+	doc := etree.NewDocument()
+	doc.SetRoot(el)
+	b, err := doc.WriteToBytes()
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(b, target)
+}
@@ -0,0 +1,110 @@
+package saml_auth
+
+import "encoding/xml"
+
+const (
+	bindingPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	methodBearer    = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	nameIdFormat    = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	nsAssertion     = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsMetadata      = "urn:oasis:names:tc:SAML:2.0:metadata"
+	nsProtocol      = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsSignature     = "http://www.w3.org/2000/09/xmldsig#"
+	sigAlgRsaSha256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	statusSuccess   = "urn:oasis:names:tc:SAML:2.0:status:Success"
+)
+
+// service provider metadata
+type xmlEntityDescriptor struct {
+	XMLName         xml.Name           `xml:"md:EntityDescriptor"`
+	XmlnsMd         string             `xml:"xmlns:md,attr"`
+	XmlnsDs         string             `xml:"xmlns:ds,attr"`
+	EntityId        string             `xml:"entityID,attr"`
+	SpSsoDescriptor xmlSpSsoDescriptor `xml:"md:SPSSODescriptor"`
+}
+type xmlSpSsoDescriptor struct {
+	AuthnRequestsSigned        bool               `xml:"AuthnRequestsSigned,attr"`
+	WantAssertionsSigned       bool               `xml:"WantAssertionsSigned,attr"`
+	ProtocolSupportEnumeration string             `xml:"protocolSupportEnumeration,attr"`
+	KeyDescriptor              xmlKeyDescriptor   `xml:"md:KeyDescriptor"`
+	NameIdFormat               string             `xml:"md:NameIDFormat"`
+	AssertionConsumerService   xmlEndpointIndexed `xml:"md:AssertionConsumerService"`
+}
+type xmlKeyDescriptor struct {
+	Use         string `xml:"use,attr"`
+	Certificate string `xml:"ds:KeyInfo>ds:X509Data>ds:X509Certificate"`
+}
+type xmlEndpointIndexed struct {
+	Binding   string `xml:"Binding,attr"`
+	Location  string `xml:"Location,attr"`
+	Index     int    `xml:"index,attr"`
+	IsDefault bool   `xml:"isDefault,attr"`
+}
+
+// authentication request, sent to identity provider
+type xmlAuthnRequest struct {
+	XMLName                     xml.Name        `xml:"samlp:AuthnRequest"`
+	XmlnsSamlp                  string          `xml:"xmlns:samlp,attr"`
+	XmlnsSaml                   string          `xml:"xmlns:saml,attr"`
+	Id                          string          `xml:"ID,attr"`
+	Version                     string          `xml:"Version,attr"`
+	IssueInstant                string          `xml:"IssueInstant,attr"`
+	Destination                 string          `xml:"Destination,attr"`
+	AssertionConsumerServiceUrl string          `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string          `xml:"ProtocolBinding,attr"`
+	Issuer                      string          `xml:"saml:Issuer"`
+	NameIdPolicy                xmlNameIdPolicy `xml:"samlp:NameIDPolicy"`
+}
+type xmlNameIdPolicy struct {
+	Format      string `xml:"Format,attr"`
+	AllowCreate bool   `xml:"AllowCreate,attr"`
+}
+
+// response with assertion, received from identity provider
+type xmlResponse struct {
+	XMLName             xml.Name       `xml:"urn:oasis:names:tc:SAML:2.0:protocol Response"`
+	Destination         string         `xml:"Destination,attr"`
+	InResponseTo        string         `xml:"InResponseTo,attr"`
+	Status              xmlStatus      `xml:"urn:oasis:names:tc:SAML:2.0:protocol Status"`
+	Assertions          []xmlAssertion `xml:"urn:oasis:names:tc:SAML:2.0:assertion Assertion"`
+	EncryptedAssertions []struct{}     `xml:"urn:oasis:names:tc:SAML:2.0:assertion EncryptedAssertion"`
+}
+type xmlStatus struct {
+	StatusCode struct {
+		Value string `xml:"Value,attr"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:protocol StatusCode"`
+	StatusMessage string `xml:"urn:oasis:names:tc:SAML:2.0:protocol StatusMessage"`
+}
+type xmlAssertion struct {
+	XMLName             xml.Name                `xml:"urn:oasis:names:tc:SAML:2.0:assertion Assertion"`
+	Issuer              string                  `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	Subject             xmlSubject              `xml:"urn:oasis:names:tc:SAML:2.0:assertion Subject"`
+	Conditions          *xmlConditions          `xml:"urn:oasis:names:tc:SAML:2.0:assertion Conditions"`
+	AttributeStatements []xmlAttributeStatement `xml:"urn:oasis:names:tc:SAML:2.0:assertion AttributeStatement"`
+}
+type xmlSubject struct {
+	NameId               string                   `xml:"urn:oasis:names:tc:SAML:2.0:assertion NameID"`
+	SubjectConfirmations []xmlSubjectConfirmation `xml:"urn:oasis:names:tc:SAML:2.0:assertion SubjectConfirmation"`
+}
+type xmlSubjectConfirmation struct {
+	Method string `xml:"Method,attr"`
+	Data   struct {
+		InResponseTo string `xml:"InResponseTo,attr"`
+		NotOnOrAfter string `xml:"NotOnOrAfter,attr"`
+		Recipient    string `xml:"Recipient,attr"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:assertion SubjectConfirmationData"`
+}
+type xmlConditions struct {
+	NotBefore            string `xml:"NotBefore,attr"`
+	NotOnOrAfter         string `xml:"NotOnOrAfter,attr"`
+	AudienceRestrictions []struct {
+		Audiences []string `xml:"urn:oasis:names:tc:SAML:2.0:assertion Audience"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:assertion AudienceRestriction"`
+}
+type xmlAttributeStatement struct {
+	Attributes []struct {
+		Name         string   `xml:"Name,attr"`
+		FriendlyName string   `xml:"FriendlyName,attr"`
+		Values       []string `xml:"urn:oasis:names:tc:SAML:2.0:assertion AttributeValue"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:assertion Attribute"`
+}
@@ -338,9 +338,15 @@ func load() error {
 		case "backupRun":
 			t.nameLog = "Integrated full backups"
 			t.fn = backup.Run
+		case "cleanupApiIdempotency":
+			t.nameLog = "Cleanup of expired API idempotency keys"
+			t.fn = cleanupApiIdempotency
 		case "cleanupBruteforce":
 			t.nameLog = "Cleanup of bruteforce cache"
 			t.fn = bruteforce.ClearHostMap
+		case "cleanupWebhookDeliveries":
+			t.nameLog = "Cleanup of webhook delivery history"
+			t.fn = cleanupWebhookDeliveries
 		case "cleanupTempDir":
 			t.nameLog = "Cleanup of temp. directory"
 			t.fn = cleanupTemp
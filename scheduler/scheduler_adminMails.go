@@ -1,8 +1,10 @@
 package scheduler
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"r3/config"
 	"r3/db"
 	"r3/log"
@@ -24,10 +26,10 @@ func adminMails() error {
 		oauthClientExpirationSubject string
 	}{
 		intro: `<p>You are receiving this message, because your email address has been added to the REI3 admin notification list.</p>
-		<p>To change this setting, please visit your REI3 instance: {URL}</p>`,
-		licenseExpirationBody:        `<p>Your license expires on: {DATE}</p>`,
+		<p>To change this setting, please visit your REI3 instance: {{.Url}}</p>`,
+		licenseExpirationBody:        `<p>Your license expires on: {{.Date}}</p>`,
 		licenseExpirationSubject:     `Your REI3 Professional license is about to expire`,
-		oauthClientExpirationBody:    `<p>Your OAuth client expires on: {DATE}</p>`,
+		oauthClientExpirationBody:    `<p>Your OAuth client expires on: {{.Date}}</p>`,
 		oauthClientExpirationSubject: `Your REI3 OAuth client is about to expire`,
 	}
 
@@ -48,16 +50,26 @@ func adminMails() error {
 			return nil
 		}
 
-		// apply intro
-		body = fmt.Sprintf("%s%s", templates.intro, body)
+		// apply intro and render placeholders
+		tmpl, err := template.New("body").Parse(fmt.Sprintf("%s%s", templates.intro, body))
+		if err != nil {
+			return err
+		}
 
-		// replace known placeholders
-		body = strings.Replace(body, "{URL}", config.GetString("publicHostName"), -1)
-		body = strings.Replace(body, "{DATE}", time.Unix(dateExpiration, 0).String(), -1)
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, struct {
+			Date string
+			Url  string
+		}{
+			Date: time.Unix(dateExpiration, 0).String(),
+			Url:  config.GetString("publicHostName"),
+		}); err != nil {
+			return err
+		}
 
 		if _, err := db.Pool.Exec(db.Ctx, `
 			SELECT instance.mail_send($1,$2,$3)
-		`, subject, body, strings.Join(toList, ",")); err != nil {
+		`, subject, buf.String(), strings.Join(toList, ",")); err != nil {
 			return err
 		}
 
@@ -46,6 +46,15 @@ func cleanupTemp() error {
 	return nil
 }
 
+// deletes expired idempotency keys of API requests
+func cleanupApiIdempotency() error {
+	_, err := db.Pool.Exec(db.Ctx, `
+		DELETE FROM instance.api_idempotency
+		WHERE date_created < $1
+	`, tools.GetTimeUnix()-(3600*int64(config.GetUint64("apiIdempotencyHours"))))
+	return err
+}
+
 // deletes expired logs
 func cleanupLogs() error {
 	keepForDays := config.GetUint64("logsKeepDays")
@@ -74,6 +83,25 @@ func cleanupMailTraffic() error {
 	return err
 }
 
+// deletes expired webhook deliveries, pending deliveries are kept
+func cleanupWebhookDeliveries() error {
+	keepForDays := config.GetUint64("webhookDeliveriesKeepDays")
+	if keepForDays == 0 {
+		return nil
+	}
+
+	_, err := db.Pool.Exec(db.Ctx, `
+		DELETE FROM instance.webhook_delivery AS d
+		WHERE d.date_added < $1
+		AND NOT EXISTS (
+			SELECT id
+			FROM instance.rest_spool
+			WHERE webhook_delivery_id = d.id
+		)
+	`, tools.GetTimeUnix()-(oneDayInSeconds*int64(keepForDays)))
+	return err
+}
+
 // removes files that were deleted from their attribute or that are not assigned to a record
 func cleanUpFiles() error {
 
@@ -123,6 +123,12 @@ func clusterProcessEvents() error {
 				return err
 			}
 			err = cluster.SchemaChanged(false, moduleIds)
+		case "sessionRevoked":
+			var sessionId uuid.UUID
+			if err := json.Unmarshal(jsonPayload, &sessionId); err != nil {
+				return err
+			}
+			err = cluster.SessionRevoked(false, e.Target.LoginId, sessionId)
 		case "tasksChanged":
 			err = cluster.TasksChanged(false)
 		case "taskTriggered":
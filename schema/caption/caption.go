@@ -121,6 +121,11 @@ func GetDefaultContent(entity string) types.CaptionMap {
 		return types.CaptionMap{
 			"loginFormTitle": make(map[string]string),
 		}
+	case "mailTemplate":
+		return types.CaptionMap{
+			"mailTemplateBody":    make(map[string]string),
+			"mailTemplateSubject": make(map[string]string),
+		}
 	case "menu":
 		return types.CaptionMap{
 			"menuTitle": make(map[string]string),
@@ -185,6 +190,9 @@ func GetEntityName(content string) (string, error) {
 	case "loginFormTitle":
 		return "login_form_id", nil
 
+	case "mailTemplateBody", "mailTemplateSubject":
+		return "mail_template_id", nil
+
 	case "menuTitle":
 		return "menu_id", nil
 
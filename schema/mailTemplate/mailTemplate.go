@@ -0,0 +1,99 @@
+package mailTemplate
+
+import (
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"r3/db"
+	"r3/schema"
+	"r3/schema/caption"
+	"r3/types"
+	textTemplate "text/template"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func Del_tx(tx pgx.Tx, id uuid.UUID) error {
+	_, err := tx.Exec(db.Ctx, `DELETE FROM app.mail_template WHERE id = $1`, id)
+	return err
+}
+
+func Get(moduleId uuid.UUID) ([]types.MailTemplate, error) {
+
+	templates := make([]types.MailTemplate, 0)
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT id, relation_id, name
+		FROM app.mail_template
+		WHERE module_id = $1
+		ORDER BY name ASC
+	`, moduleId)
+	if err != nil {
+		return templates, err
+	}
+
+	for rows.Next() {
+		var t types.MailTemplate
+		if err := rows.Scan(&t.Id, &t.RelationId, &t.Name); err != nil {
+			rows.Close()
+			return templates, err
+		}
+		t.ModuleId = moduleId
+		templates = append(templates, t)
+	}
+	rows.Close()
+
+	// get subject/body captions
+	for i, t := range templates {
+		t.Captions, err = caption.Get("mail_template", t.Id, []string{"mailTemplateBody", "mailTemplateSubject"})
+		if err != nil {
+			return templates, err
+		}
+		templates[i] = t
+	}
+	return templates, nil
+}
+
+func Set_tx(tx pgx.Tx, t types.MailTemplate) error {
+
+	if t.Name == "" {
+		return errors.New("missing name")
+	}
+
+	// subjects are plain text, bodies are HTML with escaped record values
+	for code, value := range t.Captions["mailTemplateSubject"] {
+		if _, err := textTemplate.New("").Parse(value); err != nil {
+			return fmt.Errorf("invalid subject template for language '%s', %s", code, err)
+		}
+	}
+	for code, value := range t.Captions["mailTemplateBody"] {
+		if _, err := htmlTemplate.New("").Parse(value); err != nil {
+			return fmt.Errorf("invalid body template for language '%s', %s", code, err)
+		}
+	}
+
+	known, err := schema.CheckCreateId_tx(tx, &t.Id, "mail_template", "id")
+	if err != nil {
+		return err
+	}
+
+	if known {
+		if _, err := tx.Exec(db.Ctx, `
+			UPDATE app.mail_template
+			SET relation_id = $1, name = $2
+			WHERE id = $3
+		`, t.RelationId, t.Name, t.Id); err != nil {
+			return err
+		}
+	} else {
+		if _, err := tx.Exec(db.Ctx, `
+			INSERT INTO app.mail_template (id, module_id, relation_id, name)
+			VALUES ($1,$2,$3,$4)
+		`, t.Id, t.ModuleId, t.RelationId, t.Name); err != nil {
+			return err
+		}
+	}
+
+	// set captions
+	return caption.Set_tx(tx, t.Id, t.Captions)
+}
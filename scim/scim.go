@@ -0,0 +1,172 @@
+package scim
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"r3/db"
+	"r3/types"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// bearer tokens are prefixed to be recognizable (e.g. by secret scanners)
+var tokenPrefix = "r3scim_"
+
+func Del_tx(tx pgx.Tx, id int32) error {
+	_, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.scim
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+func Get() ([]types.Scim, error) {
+	scims := make([]types.Scim, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT id, login_template_id, name, token_hash IS NOT NULL,
+			assign_roles, active
+		FROM instance.scim
+		ORDER BY name ASC
+	`)
+	if err != nil {
+		return scims, err
+	}
+
+	for rows.Next() {
+		var s types.Scim
+		if err := rows.Scan(&s.Id, &s.LoginTemplateId, &s.Name, &s.HasToken,
+			&s.AssignRoles, &s.Active); err != nil {
+
+			rows.Close()
+			return scims, err
+		}
+		scims = append(scims, s)
+	}
+	rows.Close()
+
+	for i, _ := range scims {
+		scims[i].Roles, err = GetRoles(scims[i].Id)
+		if err != nil {
+			return scims, err
+		}
+	}
+	return scims, nil
+}
+
+// returns active SCIM connection for bearer token
+func GetByToken(token string) (types.Scim, error) {
+	var s types.Scim
+
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return s, errors.New("invalid token")
+	}
+
+	err := db.Pool.QueryRow(db.Ctx, `
+		SELECT id, login_template_id, name, assign_roles, active
+		FROM instance.scim
+		WHERE token_hash = $1
+		AND   active
+	`, getHash(token)).Scan(&s.Id, &s.LoginTemplateId, &s.Name,
+		&s.AssignRoles, &s.Active)
+
+	if err == pgx.ErrNoRows {
+		return s, errors.New("invalid token")
+	}
+	if err != nil {
+		return s, err
+	}
+	s.HasToken = true
+	s.Roles, err = GetRoles(s.Id)
+	return s, err
+}
+
+func GetRoles(scimId int32) ([]types.ScimRole, error) {
+	roles := make([]types.ScimRole, 0)
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		SELECT role_id, group_name
+		FROM instance.scim_role
+		WHERE scim_id = $1
+		ORDER BY group_name
+	`, scimId)
+	if err != nil {
+		return roles, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r types.ScimRole
+		if err := rows.Scan(&r.RoleId, &r.GroupName); err != nil {
+			return roles, err
+		}
+		r.ScimId = scimId
+		roles = append(roles, r)
+	}
+	return roles, nil
+}
+
+func Set_tx(tx pgx.Tx, s types.Scim) error {
+
+	if s.Id == 0 {
+		if err := tx.QueryRow(db.Ctx, `
+			INSERT INTO instance.scim (login_template_id, name, assign_roles, active)
+			VALUES ($1,$2,$3,$4)
+			RETURNING id
+		`, s.LoginTemplateId, s.Name, s.AssignRoles, s.Active).Scan(&s.Id); err != nil {
+			return err
+		}
+	} else {
+		if _, err := tx.Exec(db.Ctx, `
+			UPDATE instance.scim
+			SET login_template_id = $1, name = $2, assign_roles = $3, active = $4
+			WHERE id = $5
+		`, s.LoginTemplateId, s.Name, s.AssignRoles, s.Active, s.Id); err != nil {
+			return err
+		}
+	}
+
+	// update SCIM role assignment
+	if _, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.scim_role
+		WHERE scim_id = $1
+	`, s.Id); err != nil {
+		return err
+	}
+
+	for _, role := range s.Roles {
+		if _, err := tx.Exec(db.Ctx, `
+			INSERT INTO instance.scim_role (scim_id, role_id, group_name)
+			VALUES ($1,$2,$3)
+		`, s.Id, role.RoleId, role.GroupName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generates new bearer token for SCIM connection, replaces existing one
+// token is returned once, only its hash is stored
+func SetToken_tx(tx pgx.Tx, id int32) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	_, err := tx.Exec(db.Ctx, `
+		UPDATE instance.scim
+		SET token_hash = $1
+		WHERE id = $2
+	`, getHash(token), id)
+	return token, err
+}
+
+// tokens are random with high entropy, a fast hash is sufficient
+func getHash(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
@@ -0,0 +1,293 @@
+package scim_provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// filter attribute, resolved to SQL expression
+type filterColumn struct {
+	expr   string // SQL expression to compare with
+	isBool bool   // boolean attribute, only supports eq/ne/pr
+	wrap   string // optional, wraps condition for multi-valued attributes (e.g. 'EXISTS(... AND %s)')
+}
+
+// filter parser, translates SCIM filter (RFC 7644, 3.4.2.2) into SQL condition
+// values are never part of the condition, they are added as arguments
+type filterParser struct {
+	args    []interface{}
+	columns map[string]filterColumn // key: lower case attribute path
+	pos     int
+	prefix  string // attribute prefix inside value path filter (e.g. 'emails.')
+	tokens  []filterToken
+}
+type filterToken struct {
+	value    string
+	isString bool // quoted string literal
+}
+
+var filterOperators = []string{"eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le"}
+
+// returns SQL condition and arguments for SCIM filter
+// argument placeholders start after given number of existing arguments
+func parseFilter(filter string, columns map[string]filterColumn, argsExisting int) (string, []interface{}, error) {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return "", nil, err
+	}
+	p := filterParser{
+		args:    make([]interface{}, argsExisting),
+		columns: columns,
+		tokens:  tokens,
+	}
+
+	cond, err := p.parseOr()
+	if err != nil {
+		return "", nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return "", nil, fmt.Errorf("unexpected token '%s'", p.tokens[p.pos].value)
+	}
+	return cond, p.args[argsExisting:], nil
+}
+
+func tokenizeFilter(filter string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	runes := []rune(filter)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			continue
+		case r == '(' || r == ')' || r == '[' || r == ']':
+			tokens = append(tokens, filterToken{value: string(r)})
+		case r == '"':
+			// JSON string literal, find closing quote (skip escaped ones)
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == '\\' {
+					j++
+					continue
+				}
+				if runes[j] == '"' {
+					break
+				}
+			}
+			if j >= len(runes) {
+				return tokens, fmt.Errorf("unterminated string in filter")
+			}
+			var value string
+			if err := json.Unmarshal([]byte(string(runes[i:j+1])), &value); err != nil {
+				return tokens, err
+			}
+			tokens = append(tokens, filterToken{value: value, isString: true})
+			i = j
+		default:
+			j := i
+			for ; j < len(runes); j++ {
+				if unicode.IsSpace(runes[j]) || strings.ContainsRune("()[]\"", runes[j]) {
+					break
+				}
+			}
+			tokens = append(tokens, filterToken{value: string(runes[i:j])})
+			i = j - 1
+		}
+	}
+	return tokens, nil
+}
+
+func (p *filterParser) next() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, true
+}
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].isString &&
+		strings.EqualFold(p.tokens[p.pos].value, keyword)
+}
+
+func (p *filterParser) parseOr() (string, error) {
+	cond, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		condNext, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		cond = fmt.Sprintf("(%s OR %s)", cond, condNext)
+	}
+	return cond, nil
+}
+
+func (p *filterParser) parseAnd() (string, error) {
+	cond, err := p.parseNot()
+	if err != nil {
+		return "", err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		condNext, err := p.parseNot()
+		if err != nil {
+			return "", err
+		}
+		cond = fmt.Sprintf("(%s AND %s)", cond, condNext)
+	}
+	return cond, nil
+}
+
+func (p *filterParser) parseNot() (string, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		cond, err := p.parseGroup()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("NOT COALESCE(%s,FALSE)", cond), nil
+	}
+	if p.peekKeyword("(") {
+		return p.parseGroup()
+	}
+	return p.parseAttribute()
+}
+
+func (p *filterParser) parseGroup() (string, error) {
+	if t, ok := p.next(); !ok || t.isString || t.value != "(" {
+		return "", fmt.Errorf("expected '('")
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return "", err
+	}
+	if t, ok := p.next(); !ok || t.isString || t.value != ")" {
+		return "", fmt.Errorf("expected ')'")
+	}
+	return fmt.Sprintf("(%s)", cond), nil
+}
+
+func (p *filterParser) parseAttribute() (string, error) {
+	t, ok := p.next()
+	if !ok || t.isString {
+		return "", fmt.Errorf("expected attribute")
+	}
+	path := p.prefix + getAttributePath(t.value)
+
+	// value path, filter on sub attributes of multi-valued attribute (e.g. 'emails[value eq "a@b.c"]')
+	if p.peekKeyword("[") {
+		p.pos++
+		prefixEx := p.prefix
+		p.prefix = path + "."
+		cond, err := p.parseOr()
+		p.prefix = prefixEx
+		if err != nil {
+			return "", err
+		}
+		if t, ok := p.next(); !ok || t.isString || t.value != "]" {
+			return "", fmt.Errorf("expected ']'")
+		}
+		return cond, nil
+	}
+
+	column, exists := p.columns[path]
+	if !exists {
+		return "", fmt.Errorf("unsupported filter attribute '%s'", path)
+	}
+
+	opToken, ok := p.next()
+	if !ok || opToken.isString {
+		return "", fmt.Errorf("expected operator")
+	}
+	op := strings.ToLower(opToken.value)
+
+	// presence check
+	if op == "pr" {
+		if column.isBool {
+			return p.wrap(column, fmt.Sprintf("%s IS NOT NULL", column.expr)), nil
+		}
+		return p.wrap(column, fmt.Sprintf("COALESCE(%s,'') <> ''", column.expr)), nil
+	}
+
+	valueToken, ok := p.next()
+	if !ok || !slices.Contains(filterOperators, op) {
+		return "", fmt.Errorf("invalid operator '%s'", op)
+	}
+
+	if column.isBool {
+		if valueToken.isString || (op != "eq" && op != "ne") {
+			return "", fmt.Errorf("invalid comparison for boolean attribute '%s'", path)
+		}
+		var value bool
+		switch strings.ToLower(valueToken.value) {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		default:
+			return "", fmt.Errorf("invalid boolean value '%s'", valueToken.value)
+		}
+		ref := p.addArg(value)
+		if op == "eq" {
+			return p.wrap(column, fmt.Sprintf("%s = %s", column.expr, ref)), nil
+		}
+		return p.wrap(column, fmt.Sprintf("%s <> %s", column.expr, ref)), nil
+	}
+
+	// string comparisons are case insensitive, as with user names
+	var cond string
+	switch op {
+	case "eq":
+		cond = fmt.Sprintf("LOWER(%s) = LOWER(%s)", column.expr, p.addArg(valueToken.value))
+	case "ne":
+		cond = fmt.Sprintf("LOWER(%s) <> LOWER(%s)", column.expr, p.addArg(valueToken.value))
+	case "co":
+		cond = fmt.Sprintf("%s ILIKE %s", column.expr, p.addArg("%"+escapeLike(valueToken.value)+"%"))
+	case "sw":
+		cond = fmt.Sprintf("%s ILIKE %s", column.expr, p.addArg(escapeLike(valueToken.value)+"%"))
+	case "ew":
+		cond = fmt.Sprintf("%s ILIKE %s", column.expr, p.addArg("%"+escapeLike(valueToken.value)))
+	case "gt":
+		cond = fmt.Sprintf("%s > %s", column.expr, p.addArg(valueToken.value))
+	case "ge":
+		cond = fmt.Sprintf("%s >= %s", column.expr, p.addArg(valueToken.value))
+	case "lt":
+		cond = fmt.Sprintf("%s < %s", column.expr, p.addArg(valueToken.value))
+	case "le":
+		cond = fmt.Sprintf("%s <= %s", column.expr, p.addArg(valueToken.value))
+	}
+	return p.wrap(column, cond), nil
+}
+
+func (p *filterParser) addArg(value interface{}) string {
+	p.args = append(p.args, value)
+	return fmt.Sprintf("$%d", len(p.args))
+}
+func (p *filterParser) wrap(column filterColumn, cond string) string {
+	if column.wrap == "" {
+		return cond
+	}
+	return fmt.Sprintf(column.wrap, cond)
+}
+
+// helpers
+// returns lower case attribute path without schema URN (e.g. 'urn:ietf:params:scim:schemas:core:2.0:User:userName')
+func getAttributePath(path string) string {
+	path = strings.ToLower(path)
+	if strings.HasPrefix(path, "urn:") {
+		if pos := strings.LastIndex(path, ":"); pos != -1 {
+			path = path[pos+1:]
+		}
+	}
+	return path
+}
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
\ No newline at end of file
@@ -0,0 +1,434 @@
+package scim_provision
+
+import (
+	"fmt"
+	"r3/db"
+	"r3/tools"
+	"r3/types"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// SCIM group attributes available for filtering
+var groupFilterColumns = map[string]filterColumn{
+	"id":          {expr: "g.id::TEXT"},
+	"displayname": {expr: "g.display_name"},
+	"externalid":  {expr: "g.external_id"},
+	"members": {expr: "m.login_id::TEXT", wrap: `EXISTS(
+		SELECT 1
+		FROM instance.scim_group_member AS m
+		WHERE m.scim_group_id = g.id
+		AND %s
+	)`},
+	"members.value": {expr: "m.login_id::TEXT", wrap: `EXISTS(
+		SELECT 1
+		FROM instance.scim_group_member AS m
+		WHERE m.scim_group_id = g.id
+		AND %s
+	)`},
+}
+
+// group state to apply changes to
+type groupSet struct {
+	displayName string
+	externalId  string
+	loginIds    []int64
+}
+
+func GroupDel(s types.Scim, id string) error {
+	groupId, err := getGroupId(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	g, err := groupGetSet_tx(tx, s, groupId)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.scim_group
+		WHERE id = $1
+	`, groupId); err != nil {
+		return err
+	}
+
+	loginIdsChanged, err := setLoginRoles_tx(tx, s, g.loginIds)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(db.Ctx); err != nil {
+		return err
+	}
+	notifyLoginsChanged(loginIdsChanged)
+	return nil
+}
+
+func GroupGet(s types.Scim, id string, baseUrl string) (Group, error) {
+	groupId, err := getGroupId(id)
+	if err != nil {
+		return Group{}, err
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return Group{}, err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	return groupGet_tx(tx, s, groupId, baseUrl)
+}
+
+func GroupList(s types.Scim, filter string, startIndex int, count int, baseUrl string) (ListResponse, error) {
+	startIndex, count = getListPage(startIndex, count)
+	res := ListResponse{
+		Schemas:    []string{SchemaListResponse},
+		StartIndex: startIndex,
+		Resources:  make([]interface{}, 0),
+	}
+
+	cond := "TRUE"
+	args := []interface{}{s.Id}
+	if filter != "" {
+		condFilter, argsFilter, err := parseFilter(filter, groupFilterColumns, len(args))
+		if err != nil {
+			return res, errBadRequest("invalidFilter", "%s", err.Error())
+		}
+		cond = condFilter
+		args = append(args, argsFilter...)
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	groupIds := make([]uuid.UUID, 0)
+	if err := tx.QueryRow(db.Ctx, fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM instance.scim_group AS g
+		WHERE g.scim_id = $1
+		AND (%s)
+	`, cond), args...).Scan(&res.TotalResults); err != nil {
+		return res, err
+	}
+
+	if count != 0 {
+		if err := tx.QueryRow(db.Ctx, fmt.Sprintf(`
+			SELECT COALESCE(ARRAY_AGG(id), '{}')
+			FROM (
+				SELECT g.id
+				FROM instance.scim_group AS g
+				WHERE g.scim_id = $1
+				AND (%s)
+				ORDER BY g.display_name ASC, g.id ASC
+				LIMIT %d
+				OFFSET %d
+			) AS sub
+		`, cond, count, startIndex-1), args...).Scan(&groupIds); err != nil {
+			return res, err
+		}
+	}
+
+	for _, groupId := range groupIds {
+		g, err := groupGet_tx(tx, s, groupId, baseUrl)
+		if err != nil {
+			return res, err
+		}
+		res.Resources = append(res.Resources, g)
+	}
+	res.ItemsPerPage = len(res.Resources)
+	return res, nil
+}
+
+func GroupCreate(s types.Scim, g Group, baseUrl string) (Group, error) {
+	set, err := getGroupSet(g)
+	if err != nil {
+		return g, err
+	}
+	return groupUpdate(s, uuid.Nil, baseUrl, func(groupSet) (groupSet, error) {
+		return set, nil
+	})
+}
+
+func GroupReplace(s types.Scim, id string, g Group, baseUrl string) (Group, error) {
+	groupId, err := getGroupId(id)
+	if err != nil {
+		return g, err
+	}
+	set, err := getGroupSet(g)
+	if err != nil {
+		return g, err
+	}
+	return groupUpdate(s, groupId, baseUrl, func(groupSet) (groupSet, error) {
+		return set, nil
+	})
+}
+
+func GroupPatch(s types.Scim, id string, req PatchRequest, baseUrl string) (Group, error) {
+	groupId, err := getGroupId(id)
+	if err != nil {
+		return Group{}, err
+	}
+	return groupUpdate(s, groupId, baseUrl, func(g groupSet) (groupSet, error) {
+		return g, applyGroupPatch(&g, req.Operations)
+	})
+}
+
+// creates (nil ID) or updates group, updates roles of affected members
+func groupUpdate(s types.Scim, groupId uuid.UUID, baseUrl string,
+	apply func(groupSet) (groupSet, error)) (Group, error) {
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return Group{}, err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	gEx := groupSet{loginIds: make([]int64, 0)}
+	if groupId != uuid.Nil {
+		gEx, err = groupGetSet_tx(tx, s, groupId)
+		if err != nil {
+			return Group{}, err
+		}
+	}
+
+	g, err := apply(groupSet{
+		displayName: gEx.displayName,
+		externalId:  gEx.externalId,
+		loginIds:    slices.Clone(gEx.loginIds),
+	})
+	if err != nil {
+		return Group{}, err
+	}
+
+	groupId, err = groupSet_tx(tx, s, groupId, g)
+	if err != nil {
+		return Group{}, err
+	}
+
+	// members need updated roles if they joined or left the group
+	// renamed groups can map to different roles, affecting all members
+	loginIds := make([]int64, 0)
+	renamed := !strings.EqualFold(gEx.displayName, g.displayName)
+	for _, loginId := range gEx.loginIds {
+		if renamed || !slices.Contains(g.loginIds, loginId) {
+			loginIds = append(loginIds, loginId)
+		}
+	}
+	for _, loginId := range g.loginIds {
+		if (renamed || !slices.Contains(gEx.loginIds, loginId)) && !slices.Contains(loginIds, loginId) {
+			loginIds = append(loginIds, loginId)
+		}
+	}
+	loginIdsChanged, err := setLoginRoles_tx(tx, s, loginIds)
+	if err != nil {
+		return Group{}, err
+	}
+
+	res, err := groupGet_tx(tx, s, groupId, baseUrl)
+	if err != nil {
+		return res, err
+	}
+	if err := tx.Commit(db.Ctx); err != nil {
+		return res, err
+	}
+	notifyLoginsChanged(loginIdsChanged)
+	return res, nil
+}
+
+func groupGet_tx(tx pgx.Tx, s types.Scim, groupId uuid.UUID, baseUrl string) (Group, error) {
+	var g Group
+	var externalId pgtype.Text
+	var dateCreate, dateChange int64
+
+	err := tx.QueryRow(db.Ctx, `
+		SELECT display_name, external_id, date_create, date_change
+		FROM instance.scim_group
+		WHERE id      = $1
+		AND   scim_id = $2
+	`, groupId, s.Id).Scan(&g.DisplayName, &externalId, &dateCreate, &dateChange)
+
+	if err == pgx.ErrNoRows {
+		return g, errNotFound("group", groupId.String())
+	}
+	if err != nil {
+		return g, err
+	}
+
+	g.Schemas = []string{SchemaGroup}
+	g.Id = groupId.String()
+	g.ExternalId = externalId.String
+	g.Members = make([]Ref, 0)
+	g.Meta = &Meta{
+		ResourceType: "Group",
+		Created:      time.Unix(dateCreate, 0).UTC().Format(time.RFC3339),
+		LastModified: time.Unix(dateChange, 0).UTC().Format(time.RFC3339),
+		Location:     fmt.Sprintf("%s/scim/v2/Groups/%s", baseUrl, groupId),
+	}
+
+	rows, err := tx.Query(db.Ctx, `
+		SELECT l.id, l.name
+		FROM instance.scim_group_member AS m
+		INNER JOIN instance.login AS l ON l.id = m.login_id
+		WHERE m.scim_group_id = $1
+		ORDER BY l.name ASC
+	`, groupId)
+	if err != nil {
+		return g, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var loginId int64
+		var r Ref
+		if err := rows.Scan(&loginId, &r.Display); err != nil {
+			return g, err
+		}
+		r.Value = fmt.Sprintf("%d", loginId)
+		r.Ref = fmt.Sprintf("%s/scim/v2/Users/%d", baseUrl, loginId)
+		g.Members = append(g.Members, r)
+	}
+	return g, nil
+}
+
+func groupGetSet_tx(tx pgx.Tx, s types.Scim, groupId uuid.UUID) (groupSet, error) {
+	var g groupSet
+	var externalId pgtype.Text
+
+	err := tx.QueryRow(db.Ctx, `
+		SELECT g.display_name, g.external_id, COALESCE((
+			SELECT ARRAY_AGG(m.login_id)
+			FROM instance.scim_group_member AS m
+			WHERE m.scim_group_id = g.id
+		), '{}')
+		FROM instance.scim_group AS g
+		WHERE g.id      = $1
+		AND   g.scim_id = $2
+	`, groupId, s.Id).Scan(&g.displayName, &externalId, &g.loginIds)
+
+	if err == pgx.ErrNoRows {
+		return g, errNotFound("group", groupId.String())
+	}
+	g.externalId = externalId.String
+	return g, err
+}
+
+// stores group and its members, nil ID creates new group
+func groupSet_tx(tx pgx.Tx, s types.Scim, groupId uuid.UUID, g groupSet) (uuid.UUID, error) {
+	g.displayName = strings.TrimSpace(g.displayName)
+	if g.displayName == "" {
+		return groupId, errBadRequest("invalidValue", "displayName must not be empty")
+	}
+
+	// display names identify groups for role assignment, must be unique per connection
+	var exists bool
+	if err := tx.QueryRow(db.Ctx, `
+		SELECT EXISTS(
+			SELECT id
+			FROM instance.scim_group
+			WHERE scim_id = $1
+			AND   id     <> $2
+			AND   LOWER(display_name) = LOWER($3)
+		)
+	`, s.Id, groupId, g.displayName).Scan(&exists); err != nil {
+		return groupId, err
+	}
+	if exists {
+		return groupId, errConflict("displayName '%s' is already in use", g.displayName)
+	}
+
+	// members can only be logins provisioned by this connection
+	var cnt int
+	if err := tx.QueryRow(db.Ctx, `
+		SELECT COUNT(*)
+		FROM instance.login
+		WHERE id = ANY($1)
+		AND   scim_id = $2
+	`, g.loginIds, s.Id).Scan(&cnt); err != nil {
+		return groupId, err
+	}
+	if cnt != len(g.loginIds) {
+		return groupId, errBadRequest("invalidValue", "group members must be users of this SCIM connection")
+	}
+
+	externalId := pgtype.Text{String: g.externalId, Valid: g.externalId != ""}
+	now := tools.GetTimeUnix()
+
+	if groupId == uuid.Nil {
+		if err := tx.QueryRow(db.Ctx, `
+			INSERT INTO instance.scim_group (scim_id, display_name,
+				external_id, date_create, date_change)
+			VALUES ($1,$2,$3,$4,$4)
+			RETURNING id
+		`, s.Id, g.displayName, externalId, now).Scan(&groupId); err != nil {
+			return groupId, err
+		}
+	} else {
+		if _, err := tx.Exec(db.Ctx, `
+			UPDATE instance.scim_group
+			SET display_name = $1, external_id = $2, date_change = $3
+			WHERE id = $4
+		`, g.displayName, externalId, now, groupId); err != nil {
+			return groupId, err
+		}
+	}
+
+	if _, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.scim_group_member
+		WHERE scim_group_id = $1
+		AND   login_id <> ALL($2)
+	`, groupId, g.loginIds); err != nil {
+		return groupId, err
+	}
+	if _, err := tx.Exec(db.Ctx, `
+		INSERT INTO instance.scim_group_member (scim_group_id, login_id)
+		SELECT $1, UNNEST($2::INTEGER[])
+		ON CONFLICT DO NOTHING
+	`, groupId, g.loginIds); err != nil {
+		return groupId, err
+	}
+	return groupId, nil
+}
+
+// helpers
+func getGroupId(id string) (uuid.UUID, error) {
+	groupId, err := uuid.FromString(id)
+	if err != nil {
+		return groupId, errNotFound("group", id)
+	}
+	return groupId, nil
+}
+func getGroupSet(g Group) (groupSet, error) {
+	loginIds, err := getMemberLoginIds(g.Members)
+	return groupSet{
+		displayName: g.DisplayName,
+		externalId:  g.ExternalId,
+		loginIds:    loginIds,
+	}, err
+}
+func getMemberLoginIds(members []Ref) ([]int64, error) {
+	loginIds := make([]int64, 0)
+	for _, m := range members {
+		loginId, err := strconv.ParseInt(m.Value, 10, 64)
+		if err != nil {
+			return loginIds, errBadRequest("invalidValue", "invalid member '%s'", m.Value)
+		}
+		if !slices.Contains(loginIds, loginId) {
+			loginIds = append(loginIds, loginId)
+		}
+	}
+	return loginIds, nil
+}
@@ -0,0 +1,243 @@
+package scim_provision
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// value path filter to address single group member, such as 'members[value eq "12"]'
+var regexMemberPath = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+
+// applies PATCH operations (RFC 7644, 3.5.2) to user
+// unsupported attributes (e.g. name or enterprise extension) are ignored, as identity providers send them regardless
+func applyUserPatch(u *User, ops []PatchOperation) error {
+	for _, op := range ops {
+		opName, err := getPatchOp(op)
+		if err != nil {
+			return err
+		}
+
+		if op.Path == "" {
+			values, err := getPatchValues(opName, op.Value)
+			if err != nil {
+				return err
+			}
+			for path, value := range values {
+				if err := applyUserPatchAttribute(u, opName, getPatchPath(path), value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := applyUserPatchAttribute(u, opName, getPatchPath(op.Path), op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyUserPatchAttribute(u *User, op string, path string, value json.RawMessage) error {
+	remove := op == "remove"
+
+	switch path {
+	case "username":
+		if remove {
+			return errBadRequest("mutability", "userName is required")
+		}
+		return parsePatchString(value, &u.UserName)
+	case "externalid":
+		if remove {
+			u.ExternalId = ""
+			return nil
+		}
+		return parsePatchString(value, &u.ExternalId)
+	case "active":
+		// removing active state deactivates user, unset state would default to active
+		if remove {
+			active := false
+			u.Active = &active
+			return nil
+		}
+		active, err := parsePatchBool(value)
+		if err != nil {
+			return err
+		}
+		u.Active = &active
+	case "password":
+		if remove {
+			return nil
+		}
+		return parsePatchString(value, &u.Password)
+	case "emails":
+		if remove {
+			u.Emails = nil
+			return nil
+		}
+		var emails []Email
+		if err := json.Unmarshal(value, &emails); err != nil {
+			return errBadRequest("invalidValue", "emails must be a list")
+		}
+		if op == "add" {
+			u.Emails = append(emails, u.Emails...)
+		} else {
+			u.Emails = emails
+		}
+	case "emails.value":
+		if remove {
+			u.Emails = nil
+			return nil
+		}
+		var mail string
+		if err := parsePatchString(value, &mail); err != nil {
+			return err
+		}
+		u.Emails = []Email{{Value: mail, Type: "work", Primary: true}}
+	}
+	return nil
+}
+
+// applies PATCH operations (RFC 7644, 3.5.2) to group
+func applyGroupPatch(g *groupSet, ops []PatchOperation) error {
+	for _, op := range ops {
+		opName, err := getPatchOp(op)
+		if err != nil {
+			return err
+		}
+
+		// single member, addressed by value path filter
+		if m := regexMemberPath.FindStringSubmatch(strings.TrimSpace(op.Path)); m != nil {
+			if opName != "remove" {
+				return errBadRequest("invalidPath", "member filter is only supported for remove operations")
+			}
+			loginId, err := strconv.ParseInt(m[1], 10, 64)
+			if err != nil {
+				return errBadRequest("invalidValue", "invalid member '%s'", m[1])
+			}
+			g.loginIds = slices.DeleteFunc(g.loginIds, func(id int64) bool { return id == loginId })
+			continue
+		}
+
+		if op.Path == "" {
+			values, err := getPatchValues(opName, op.Value)
+			if err != nil {
+				return err
+			}
+			for path, value := range values {
+				if err := applyGroupPatchAttribute(g, opName, getPatchPath(path), value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := applyGroupPatchAttribute(g, opName, getPatchPath(op.Path), op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyGroupPatchAttribute(g *groupSet, op string, path string, value json.RawMessage) error {
+	remove := op == "remove"
+
+	switch path {
+	case "displayname":
+		if remove {
+			return errBadRequest("mutability", "displayName is required")
+		}
+		return parsePatchString(value, &g.displayName)
+	case "externalid":
+		if remove {
+			g.externalId = ""
+			return nil
+		}
+		return parsePatchString(value, &g.externalId)
+	case "members":
+		// remove without value removes all members
+		if remove && (len(value) == 0 || string(value) == "null") {
+			g.loginIds = make([]int64, 0)
+			return nil
+		}
+
+		var members []Ref
+		if err := json.Unmarshal(value, &members); err != nil {
+			return errBadRequest("invalidValue", "members must be a list")
+		}
+		loginIds, err := getMemberLoginIds(members)
+		if err != nil {
+			return err
+		}
+
+		switch op {
+		case "add":
+			for _, loginId := range loginIds {
+				if !slices.Contains(g.loginIds, loginId) {
+					g.loginIds = append(g.loginIds, loginId)
+				}
+			}
+		case "remove":
+			g.loginIds = slices.DeleteFunc(g.loginIds, func(id int64) bool {
+				return slices.Contains(loginIds, id)
+			})
+		case "replace":
+			g.loginIds = loginIds
+		}
+	}
+	return nil
+}
+
+// helpers
+func getPatchOp(op PatchOperation) (string, error) {
+	name := strings.ToLower(op.Op)
+	if name != "add" && name != "remove" && name != "replace" {
+		return name, errBadRequest("invalidSyntax", "invalid operation '%s'", op.Op)
+	}
+	if name == "remove" && op.Path == "" {
+		return name, errBadRequest("noTarget", "remove operation requires path")
+	}
+	return name, nil
+}
+
+// returns lower case attribute path without schema URN and value filter
+// e.g. 'emails[type eq "work"].value' -> 'emails.value'
+func getPatchPath(path string) string {
+	if start := strings.Index(path, "["); start != -1 {
+		if end := strings.LastIndex(path, "]"); end > start {
+			path = path[:start] + path[end+1:]
+		}
+	}
+	return getAttributePath(strings.TrimSpace(path))
+}
+
+// operations without path apply an object of attributes
+func getPatchValues(op string, value json.RawMessage) (map[string]json.RawMessage, error) {
+	values := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(value, &values); err != nil {
+		return values, errBadRequest("invalidValue", "%s operation without path requires object value", op)
+	}
+	return values, nil
+}
+
+func parsePatchString(value json.RawMessage, target *string) error {
+	if err := json.Unmarshal(value, target); err != nil {
+		return errBadRequest("invalidValue", "expected string value")
+	}
+	return nil
+}
+
+// some identity providers send boolean values as strings (e.g. "False")
+func parsePatchBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(strings.ToLower(s)); err == nil {
+			return b, nil
+		}
+	}
+	return false, errBadRequest("invalidValue", "expected boolean value")
+}
@@ -0,0 +1,234 @@
+package scim_provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"r3/cluster"
+	"r3/db"
+	"r3/log"
+	"r3/login"
+	"r3/types"
+	"strconv"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaSpConfig     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+
+	listCountDefault = 100
+	listCountMax     = 1000
+)
+
+// SCIM resources (RFC 7643)
+type User struct {
+	Schemas    []string `json:"schemas"`
+	Id         string   `json:"id,omitempty"`
+	ExternalId string   `json:"externalId,omitempty"`
+	UserName   string   `json:"userName"`
+	Active     *bool    `json:"active,omitempty"`   // defaults to true if not given
+	Password   string   `json:"password,omitempty"` // write only, never returned
+	Emails     []Email  `json:"emails,omitempty"`
+	Groups     []Ref    `json:"groups,omitempty"` // read only, managed via groups
+	Meta       *Meta    `json:"meta,omitempty"`
+}
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	Id          string   `json:"id,omitempty"`
+	ExternalId  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Ref    `json:"members"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+type Ref struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location"`
+}
+
+// SCIM messages (RFC 7644)
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+type ErrorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// error to be returned to SCIM client with HTTP status and SCIM error type
+type ErrorScim struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e ErrorScim) Error() string {
+	return e.Detail
+}
+
+func errBadRequest(scimType string, detail string, args ...interface{}) error {
+	return ErrorScim{Status: http.StatusBadRequest, ScimType: scimType, Detail: fmt.Sprintf(detail, args...)}
+}
+func errConflict(detail string, args ...interface{}) error {
+	return ErrorScim{Status: http.StatusConflict, ScimType: "uniqueness", Detail: fmt.Sprintf(detail, args...)}
+}
+func errNotFound(resource string, id string) error {
+	return ErrorScim{Status: http.StatusNotFound, Detail: fmt.Sprintf("%s '%s' not found", resource, id)}
+}
+
+// service provider configuration, announces supported features to SCIM clients
+func GetServiceProviderConfig(baseUrl string) interface{} {
+	type supported struct {
+		Supported bool `json:"supported"`
+	}
+	type filter struct {
+		Supported  bool `json:"supported"`
+		MaxResults int  `json:"maxResults"`
+	}
+	type bulk struct {
+		Supported      bool `json:"supported"`
+		MaxOperations  int  `json:"maxOperations"`
+		MaxPayloadSize int  `json:"maxPayloadSize"`
+	}
+	type authScheme struct {
+		Type        string `json:"type"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Primary     bool   `json:"primary"`
+	}
+	return struct {
+		Schemas               []string     `json:"schemas"`
+		Patch                 supported    `json:"patch"`
+		Bulk                  bulk         `json:"bulk"`
+		Filter                filter       `json:"filter"`
+		ChangePassword        supported    `json:"changePassword"`
+		Sort                  supported    `json:"sort"`
+		Etag                  supported    `json:"etag"`
+		AuthenticationSchemes []authScheme `json:"authenticationSchemes"`
+		Meta                  Meta         `json:"meta"`
+	}{
+		Schemas:        []string{SchemaSpConfig},
+		Patch:          supported{true},
+		Bulk:           bulk{false, 0, 0},
+		Filter:         filter{true, listCountMax},
+		ChangePassword: supported{true},
+		Sort:           supported{false},
+		Etag:           supported{false},
+		AuthenticationSchemes: []authScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "Bearer token",
+			Description: "Token generated for the SCIM connection in the admin UI",
+			Primary:     true,
+		}},
+		Meta: Meta{
+			ResourceType: "ServiceProviderConfig",
+			Location:     fmt.Sprintf("%s/scim/v2/ServiceProviderConfig", baseUrl),
+		},
+	}
+}
+
+// returns roles of login, based on its SCIM group memberships
+// group names are matched case insensitive, as identity providers differ in how they present them
+func getLoginRoleIds_tx(tx pgx.Tx, s types.Scim, loginId int64) ([]uuid.UUID, error) {
+	roleIds := make([]uuid.UUID, 0)
+	err := tx.QueryRow(db.Ctx, `
+		SELECT COALESCE(ARRAY_AGG(DISTINCT sr.role_id), '{}')
+		FROM instance.scim_group_member AS m
+		INNER JOIN instance.scim_group  AS g
+			ON  g.id      = m.scim_group_id
+			AND g.scim_id = $1
+		INNER JOIN instance.scim_role   AS sr
+			ON  sr.scim_id = g.scim_id
+			AND LOWER(sr.group_name) = LOWER(g.display_name)
+		WHERE m.login_id = $2
+	`, s.Id, loginId).Scan(&roleIds)
+	return roleIds, err
+}
+
+// updates roles of logins after group memberships changed, if SCIM connection assigns roles
+// returns IDs of logins with changed roles
+func setLoginRoles_tx(tx pgx.Tx, s types.Scim, loginIds []int64) ([]int64, error) {
+	loginIdsChanged := make([]int64, 0)
+	if !s.AssignRoles {
+		return loginIdsChanged, nil
+	}
+
+	for _, loginId := range loginIds {
+		roleIds, err := getLoginRoleIds_tx(tx, s, loginId)
+		if err != nil {
+			return loginIdsChanged, err
+		}
+		changed, err := login.SetScimLoginRoles_tx(tx, s.Id, loginId, roleIds)
+		if err != nil {
+			return loginIdsChanged, err
+		}
+		if changed {
+			loginIdsChanged = append(loginIdsChanged, loginId)
+		}
+	}
+	return loginIdsChanged, nil
+}
+
+// informs cluster about logins with changed access, after transaction was committed
+func notifyLoginsChanged(loginIds []int64) {
+	for _, loginId := range loginIds {
+		if err := cluster.LoginReauthorized(true, loginId); err != nil {
+			log.Warning("server", fmt.Sprintf("could not renew access permissions for login ID %d", loginId), err)
+		}
+	}
+}
+
+// helpers
+// start index is 1-based, negative count (not given) applies default
+func getListPage(startIndex int, count int) (int, int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = listCountDefault
+	}
+	if count > listCountMax {
+		count = listCountMax
+	}
+	return startIndex, count
+}
+func getLoginId(id string) (int64, error) {
+	v, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || v <= 0 {
+		return 0, errNotFound("user", id)
+	}
+	return v, nil
+}
@@ -0,0 +1,237 @@
+package scim_provision
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// requests as sent by common SCIM clients (Microsoft Entra ID, Okta, OneLogin)
+
+func TestClientFilters(t *testing.T) {
+	tests := []struct {
+		filter  string
+		columns map[string]filterColumn
+		cond    string
+		args    []interface{}
+	}{
+		// user lookup before creation
+		{`userName eq "john@example.org"`, userFilterColumns,
+			"LOWER(l.name) = LOWER($2)", []interface{}{"john@example.org"}},
+		{`externalId eq "4f3c1b2a"`, userFilterColumns,
+			"LOWER(l.scim_key) = LOWER($2)", []interface{}{"4f3c1b2a"}},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "john@example.org"`, userFilterColumns,
+			"LOWER(l.name) = LOWER($2)", []interface{}{"john@example.org"}},
+		{`emails[type eq "work" and value co "@example.org"]`, userFilterColumns,
+			"(LOWER(CASE WHEN l.mail IS NULL THEN NULL ELSE 'work' END) = LOWER($2) AND l.mail ILIKE $3)",
+			[]interface{}{"work", "%@example.org%"}},
+		{`active eq False`, userFilterColumns,
+			"l.active = $2", []interface{}{false}},
+		{`userName sw "j" and not (active eq true)`, userFilterColumns,
+			"(l.name ILIKE $2 AND NOT COALESCE((l.active = $3),FALSE))", []interface{}{"j%", true}},
+
+		// group lookup & membership check
+		{`displayName eq "Sales \"EMEA\""`, groupFilterColumns,
+			"LOWER(g.display_name) = LOWER($2)", []interface{}{`Sales "EMEA"`}},
+		{`externalId eq "g1" or displayName eq "g1"`, groupFilterColumns,
+			"(LOWER(g.external_id) = LOWER($2) OR LOWER(g.display_name) = LOWER($3))", []interface{}{"g1", "g1"}},
+	}
+
+	for _, test := range tests {
+		cond, args, err := parseFilter(test.filter, test.columns, 1)
+		if err != nil {
+			t.Fatalf("filter '%s': %s", test.filter, err)
+		}
+		if cond != test.cond {
+			t.Fatalf("filter '%s': expected condition '%s', got '%s'", test.filter, test.cond, cond)
+		}
+		if !slices.Equal(args, test.args) {
+			t.Fatalf("filter '%s': expected arguments %v, got %v", test.filter, test.args, args)
+		}
+	}
+
+	// membership check, member value is compared inside sub query
+	cond, _, err := parseFilter(`id eq "6e1b" and members[value eq "12"]`, groupFilterColumns, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cond != "(LOWER(g.id::TEXT) = LOWER($2) AND "+wrapMember("LOWER(m.login_id::TEXT) = LOWER($3)")+")" {
+		t.Fatalf("unexpected member condition '%s'", cond)
+	}
+
+	// invalid filters are rejected, values never become part of condition
+	for _, filter := range []string{
+		`userName eq`,
+		`userName xx "a"`,
+		`password eq "secret"`,
+		`userName eq "a" and`,
+		`(userName eq "a"`,
+		`userName eq "a`,
+		`active gt true`,
+		`active eq "true"`,
+		`userName eq "a") OR (1=1`,
+	} {
+		if _, _, err := parseFilter(filter, userFilterColumns, 1); err == nil {
+			t.Fatalf("accepted invalid filter '%s'", filter)
+		}
+	}
+}
+
+func TestClientUserPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		active   bool
+		expected User
+	}{
+		{"Entra ID, replace with string boolean", `{
+			"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations":[{"op":"Replace","path":"active","value":"False"}]
+		}`, false, User{UserName: "john", ExternalId: "ext1", Emails: []Email{{Value: "john@example.org", Type: "work", Primary: true}}}},
+
+		{"Entra ID, replace work mail and external ID", `{
+			"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations":[
+				{"op":"Replace","path":"emails[type eq \"work\"].value","value":"jane@example.org"},
+				{"op":"Add","path":"externalId","value":"ext2"},
+				{"op":"Add","path":"name.givenName","value":"Jane"},
+				{"op":"Replace","path":"urn:ietf:params:scim:schemas:core:2.0:User:userName","value":"jane"}
+			]
+		}`, true, User{UserName: "jane", ExternalId: "ext2", Emails: []Email{{Value: "jane@example.org", Type: "work", Primary: true}}}},
+
+		{"Okta, replace without path", `{
+			"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations":[{"op":"replace","value":{"active":false,"password":"new-secret"}}]
+		}`, false, User{UserName: "john", ExternalId: "ext1", Password: "new-secret", Emails: []Email{{Value: "john@example.org", Type: "work", Primary: true}}}},
+
+		{"remove active state deactivates", `{
+			"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations":[{"op":"remove","path":"active"}]
+		}`, false, User{UserName: "john", ExternalId: "ext1", Emails: []Email{{Value: "john@example.org", Type: "work", Primary: true}}}},
+
+		{"remove mail and external ID", `{
+			"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations":[{"op":"remove","path":"emails"},{"op":"remove","path":"externalId"}]
+		}`, true, User{UserName: "john"}},
+	}
+
+	for _, test := range tests {
+		var req PatchRequest
+		if err := json.Unmarshal([]byte(test.request), &req); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		u := getTestUser()
+		if err := applyUserPatch(&u, req.Operations); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		// users without active state are treated as active
+		if (u.Active == nil || *u.Active) != test.active {
+			t.Fatalf("%s: unexpected active state", test.name)
+		}
+		if u.UserName != test.expected.UserName || u.ExternalId != test.expected.ExternalId ||
+			u.Password != test.expected.Password || !slices.Equal(u.Emails, test.expected.Emails) {
+
+			t.Fatalf("%s: unexpected user %+v", test.name, u)
+		}
+	}
+
+	// invalid operations are rejected with SCIM error type
+	for request, scimType := range map[string]string{
+		`[{"op":"remove","path":"userName"}]`:             "mutability",
+		`[{"op":"remove"}]`:                               "noTarget",
+		`[{"op":"move","path":"active","value":true}]`:    "invalidSyntax",
+		`[{"op":"replace","path":"active","value":"no"}]`: "invalidValue",
+		`[{"op":"replace","value":"active"}]`:             "invalidValue",
+	} {
+		var ops []PatchOperation
+		if err := json.Unmarshal([]byte(request), &ops); err != nil {
+			t.Fatal(err)
+		}
+		u := getTestUser()
+		checkScimError(t, request, applyUserPatch(&u, ops), scimType)
+	}
+}
+
+func TestClientGroupPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		loginIds []int64
+	}{
+		{"Entra ID, add members", `{"Operations":[
+			{"op":"Add","path":"members","value":[{"value":"3"},{"value":"1"}]}
+		]}`, []int64{1, 2, 3}},
+
+		{"Entra ID, remove members", `{"Operations":[
+			{"op":"Remove","path":"members","value":[{"value":"1"}]}
+		]}`, []int64{2}},
+
+		{"Okta, remove member by filter", `{"Operations":[
+			{"op":"remove","path":"members[value eq \"2\"]"}
+		]}`, []int64{1}},
+
+		{"remove all members", `{"Operations":[
+			{"op":"remove","path":"members"}
+		]}`, []int64{}},
+
+		{"replace members and name without path", `{"Operations":[
+			{"op":"replace","value":{"displayName":"Sales","members":[{"value":"5"}]}}
+		]}`, []int64{5}},
+	}
+
+	for _, test := range tests {
+		var req PatchRequest
+		if err := json.Unmarshal([]byte(test.request), &req); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		g := groupSet{displayName: "Group", loginIds: []int64{1, 2}}
+		if err := applyGroupPatch(&g, req.Operations); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if !slices.Equal(g.loginIds, test.loginIds) {
+			t.Fatalf("%s: expected members %v, got %v", test.name, test.loginIds, g.loginIds)
+		}
+	}
+
+	for request, scimType := range map[string]string{
+		`[{"op":"remove","path":"displayName"}]`:                  "mutability",
+		`[{"op":"add","path":"members","value":[{"value":"x"}]}]`: "invalidValue",
+		`[{"op":"add","path":"members","value":{"value":"1"}}]`:   "invalidValue",
+		`[{"op":"replace","path":"members[value eq \"1\"]"}]`:     "invalidPath",
+		`[{"op":"remove","path":"members[value eq \"admin\"]"}]`:  "invalidValue",
+	} {
+		var ops []PatchOperation
+		if err := json.Unmarshal([]byte(request), &ops); err != nil {
+			t.Fatal(err)
+		}
+		g := groupSet{displayName: "Group", loginIds: []int64{1, 2}}
+		checkScimError(t, request, applyGroupPatch(&g, ops), scimType)
+	}
+}
+
+func checkScimError(t *testing.T, request string, err error, scimType string) {
+	var errScim ErrorScim
+	if !errors.As(err, &errScim) {
+		t.Fatalf("request '%s': expected SCIM error, got %v", request, err)
+	}
+	if errScim.Status != 400 || errScim.ScimType != scimType {
+		t.Fatalf("request '%s': expected error type '%s', got %d '%s'", request, scimType, errScim.Status, errScim.ScimType)
+	}
+}
+
+func getTestUser() User {
+	active := true
+	return User{
+		UserName:   "john",
+		ExternalId: "ext1",
+		Active:     &active,
+		Emails:     []Email{{Value: "john@example.org", Type: "work", Primary: true}},
+	}
+}
+
+func wrapMember(cond string) string {
+	return fmt.Sprintf(groupFilterColumns["members.value"].wrap, cond)
+}
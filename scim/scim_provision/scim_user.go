@@ -0,0 +1,334 @@
+package scim_provision
+
+import (
+	"fmt"
+	"r3/cluster"
+	"r3/db"
+	"r3/login"
+	"r3/types"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// SCIM user attributes available for filtering
+var userFilterColumns = map[string]filterColumn{
+	"id":           {expr: "l.id::TEXT"},
+	"username":     {expr: "l.name"},
+	"externalid":   {expr: "l.scim_key"},
+	"active":       {expr: "l.active", isBool: true},
+	"emails":       {expr: "l.mail"},
+	"emails.value": {expr: "l.mail"},
+	"emails.type":  {expr: "CASE WHEN l.mail IS NULL THEN NULL ELSE 'work' END"}, // single mail is presented as work address
+}
+
+func UserDel(s types.Scim, id string) error {
+	loginId, err := getLoginId(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	if err := userCheckExists_tx(tx, s, loginId); err != nil {
+		return err
+	}
+	if err := login.Del_tx(tx, loginId); err != nil {
+		return err
+	}
+	if err := tx.Commit(db.Ctx); err != nil {
+		return err
+	}
+	return cluster.LoginDisabled(true, loginId)
+}
+
+func UserGet(s types.Scim, id string, baseUrl string) (User, error) {
+	loginId, err := getLoginId(id)
+	if err != nil {
+		return User{}, err
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return User{}, err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	return userGet_tx(tx, s, loginId, baseUrl)
+}
+
+func UserList(s types.Scim, filter string, startIndex int, count int, baseUrl string) (ListResponse, error) {
+	startIndex, count = getListPage(startIndex, count)
+	res := ListResponse{
+		Schemas:    []string{SchemaListResponse},
+		StartIndex: startIndex,
+		Resources:  make([]interface{}, 0),
+	}
+
+	cond := "TRUE"
+	args := []interface{}{s.Id}
+	if filter != "" {
+		condFilter, argsFilter, err := parseFilter(filter, userFilterColumns, len(args))
+		if err != nil {
+			return res, errBadRequest("invalidFilter", "%s", err.Error())
+		}
+		cond = condFilter
+		args = append(args, argsFilter...)
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	loginIds := make([]int64, 0)
+	if err := tx.QueryRow(db.Ctx, fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM instance.login AS l
+		WHERE l.scim_id = $1
+		AND (%s)
+	`, cond), args...).Scan(&res.TotalResults); err != nil {
+		return res, err
+	}
+
+	if count != 0 {
+		if err := tx.QueryRow(db.Ctx, fmt.Sprintf(`
+			SELECT COALESCE(ARRAY_AGG(id), '{}')
+			FROM (
+				SELECT l.id
+				FROM instance.login AS l
+				WHERE l.scim_id = $1
+				AND (%s)
+				ORDER BY l.id ASC
+				LIMIT %d
+				OFFSET %d
+			) AS sub
+		`, cond, count, startIndex-1), args...).Scan(&loginIds); err != nil {
+			return res, err
+		}
+	}
+
+	for _, loginId := range loginIds {
+		u, err := userGet_tx(tx, s, loginId, baseUrl)
+		if err != nil {
+			return res, err
+		}
+		res.Resources = append(res.Resources, u)
+	}
+	res.ItemsPerPage = len(res.Resources)
+	return res, nil
+}
+
+func UserCreate(s types.Scim, u User, baseUrl string) (User, error) {
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return u, err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	loginId, _, err := userSet_tx(tx, s, 0, u)
+	if err != nil {
+		return u, err
+	}
+	u, err = userGet_tx(tx, s, loginId, baseUrl)
+	if err != nil {
+		return u, err
+	}
+	return u, tx.Commit(db.Ctx)
+}
+
+func UserReplace(s types.Scim, id string, u User, baseUrl string) (User, error) {
+	loginId, err := getLoginId(id)
+	if err != nil {
+		return u, err
+	}
+	return userUpdate(s, loginId, baseUrl, func(uEx User) (User, error) {
+		return u, nil
+	})
+}
+
+func UserPatch(s types.Scim, id string, req PatchRequest, baseUrl string) (User, error) {
+	loginId, err := getLoginId(id)
+	if err != nil {
+		return User{}, err
+	}
+	return userUpdate(s, loginId, baseUrl, func(u User) (User, error) {
+		return u, applyUserPatch(&u, req.Operations)
+	})
+}
+
+// applies changes to existing user, kicks or reauthorizes login if its access changed
+func userUpdate(s types.Scim, loginId int64, baseUrl string, apply func(User) (User, error)) (User, error) {
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return User{}, err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	u, err := userGet_tx(tx, s, loginId, baseUrl)
+	if err != nil {
+		return u, err
+	}
+	u, err = apply(u)
+	if err != nil {
+		return u, err
+	}
+	_, changed, err := userSet_tx(tx, s, loginId, u)
+	if err != nil {
+		return u, err
+	}
+	u, err = userGet_tx(tx, s, loginId, baseUrl)
+	if err != nil {
+		return u, err
+	}
+	if err := tx.Commit(db.Ctx); err != nil {
+		return u, err
+	}
+
+	if changed {
+		if !*u.Active {
+			return u, cluster.LoginDisabled(true, loginId)
+		}
+		notifyLoginsChanged([]int64{loginId})
+	}
+	return u, nil
+}
+
+func userCheckExists_tx(tx pgx.Tx, s types.Scim, loginId int64) error {
+	var exists bool
+	if err := tx.QueryRow(db.Ctx, `
+		SELECT EXISTS(
+			SELECT id
+			FROM instance.login
+			WHERE id      = $1
+			AND   scim_id = $2
+		)
+	`, loginId, s.Id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errNotFound("user", fmt.Sprintf("%d", loginId))
+	}
+	return nil
+}
+
+func userGet_tx(tx pgx.Tx, s types.Scim, loginId int64, baseUrl string) (User, error) {
+	var u User
+	var active bool
+	var key, mail pgtype.Text
+
+	err := tx.QueryRow(db.Ctx, `
+		SELECT name, scim_key, active, mail
+		FROM instance.login
+		WHERE id      = $1
+		AND   scim_id = $2
+	`, loginId, s.Id).Scan(&u.UserName, &key, &active, &mail)
+
+	if err == pgx.ErrNoRows {
+		return u, errNotFound("user", fmt.Sprintf("%d", loginId))
+	}
+	if err != nil {
+		return u, err
+	}
+
+	u.Schemas = []string{SchemaUser}
+	u.Id = fmt.Sprintf("%d", loginId)
+	u.ExternalId = key.String
+	u.Active = &active
+	u.Emails = make([]Email, 0)
+	u.Groups = make([]Ref, 0)
+	u.Meta = &Meta{
+		ResourceType: "User",
+		Location:     fmt.Sprintf("%s/scim/v2/Users/%d", baseUrl, loginId),
+	}
+	if mail.Valid {
+		u.Emails = append(u.Emails, Email{Value: mail.String, Type: "work", Primary: true})
+	}
+
+	rows, err := tx.Query(db.Ctx, `
+		SELECT g.id, g.display_name
+		FROM instance.scim_group AS g
+		INNER JOIN instance.scim_group_member AS m
+			ON  m.scim_group_id = g.id
+			AND m.login_id      = $1
+		WHERE g.scim_id = $2
+		ORDER BY g.display_name ASC
+	`, loginId, s.Id)
+	if err != nil {
+		return u, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupId uuid.UUID
+		var r Ref
+		if err := rows.Scan(&groupId, &r.Display); err != nil {
+			return u, err
+		}
+		r.Value = groupId.String()
+		r.Ref = fmt.Sprintf("%s/scim/v2/Groups/%s", baseUrl, r.Value)
+		u.Groups = append(u.Groups, r)
+	}
+	return u, nil
+}
+
+// creates or updates login from SCIM user, login ID 0 creates new login
+// roles are kept or assigned based on group memberships, groups of users are managed via groups
+// returns login ID and whether login access changed
+func userSet_tx(tx pgx.Tx, s types.Scim, loginId int64, u User) (int64, bool, error) {
+	u.UserName = strings.TrimSpace(u.UserName)
+	if u.UserName == "" {
+		return 0, false, errBadRequest("invalidValue", "userName must not be empty")
+	}
+
+	// user names are unique across all logins, regardless of their source
+	var exists bool
+	if err := tx.QueryRow(db.Ctx, `
+		SELECT EXISTS(
+			SELECT id
+			FROM instance.login
+			WHERE name = LOWER($1)
+			AND   id  <> $2
+		)
+	`, u.UserName, loginId).Scan(&exists); err != nil {
+		return 0, false, err
+	}
+	if exists {
+		return 0, false, errConflict("userName '%s' is already in use", u.UserName)
+	}
+
+	roleIds := make([]uuid.UUID, 0)
+	if loginId != 0 {
+		var err error
+		roleIds, err = getLoginRoleIds_tx(tx, s, loginId)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	active := u.Active == nil || *u.Active
+	key := pgtype.Text{String: u.ExternalId, Valid: u.ExternalId != ""}
+
+	loginId, changed, err := login.SetScimLogin_tx(tx, s.Id, loginId, key, u.UserName,
+		u.Password, active, roleIds, s.LoginTemplateId, s.AssignRoles)
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	// login stores a single mail address, primary one is preferred
+	var mail pgtype.Text
+	for _, e := range u.Emails {
+		if e.Value != "" && (!mail.Valid || e.Primary) {
+			mail = pgtype.Text{String: e.Value, Valid: true}
+		}
+	}
+	return loginId, changed, login.SetMail_tx(tx, loginId, mail)
+}
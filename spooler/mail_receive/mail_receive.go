@@ -1,11 +1,13 @@
 package mail_receive
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"r3/cache"
 	"r3/config"
 	"r3/db"
@@ -13,6 +15,7 @@ import (
 	"r3/tools"
 	"r3/types"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -20,13 +23,17 @@ import (
 	"github.com/emersion/go-imap/client"
 	_ "github.com/emersion/go-message/charset"
 	"github.com/emersion/go-message/mail"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 var (
 	accountMode   = "imap"
 	collectPerRun = uint32(50)
-	imapFolder    = "INBOX"
 	regexCid      = regexp.MustCompile(`<img[^>]*cid\:([^\"]*)`)
+
+	// attempts before a failing message is skipped
+	failedAttemptsMax = 3
 )
 
 func DoAll() error {
@@ -103,76 +110,173 @@ func do(ma types.MailAccount) error {
 		}
 	}
 
-	mbox, err := c.Select(imapFolder, false)
+	for _, folder := range ma.ImapFolders {
+		if err := doFolder(c, ma, folder); err != nil {
+			log.Error("mail", fmt.Sprintf("failed to retrieve from folder '%s' of '%s'", folder, ma.Name), err)
+		}
+	}
+	return nil
+}
+
+// retrieves messages newer than the UID watermark of the folder
+func doFolder(c *client.Client, ma types.MailAccount, folder string) error {
+
+	mbox, err := c.Select(folder, false)
 	if err != nil {
 		return err
 	}
 
 	log.Info("mail", fmt.Sprintf("found %d messages inside %s for account '%s'",
-		mbox.Messages, imapFolder, ma.Name))
+		mbox.Messages, folder, ma.Name))
 
 	if mbox.Messages == 0 {
 		return nil
 	}
 
-	log.Info("mail", fmt.Sprintf("is now fetching messages (at most %d per run)", collectPerRun))
+	// UIDs are only valid together with the UID validity of the folder
+	// if the folder was recreated, UIDs were reassigned and all messages are retrieved again
+	uidValidity, uidLast, err := getFolderWatermark(ma.Id, folder)
+	if err != nil {
+		return err
+	}
+	if uidValidity != mbox.UidValidity && uidLast != 0 {
+		log.Info("mail", fmt.Sprintf("UID validity of folder %s changed, retrieving all messages", folder))
+		uidLast = 0
+	}
+
+	// search for messages after watermark
+	// range 'n:*' always includes the message with the highest UID, even if it is below n
+	seqSearch := new(imap.SeqSet)
+	seqSearch.AddRange(uidLast+1, 0)
+	criteria := imap.NewSearchCriteria()
+	criteria.Uid = seqSearch
 
-	// fetch mails from mailbox
-	seqDel := new(imap.SeqSet) // messages to delete
-	seqGet := new(imap.SeqSet) // messages to fetch
+	uidsFound, err := c.UidSearch(criteria)
+	if err != nil {
+		return err
+	}
+	uids := make([]uint32, 0)
+	for _, uid := range uidsFound {
+		if uid > uidLast {
+			uids = append(uids, uid)
+		}
+	}
+	if len(uids) == 0 {
+		return nil
+	}
 
-	// fetch X last messages
-	offsetFrom := uint32(1)
-	offsetTo := mbox.Messages
-	if mbox.Messages > collectPerRun-1 {
-		offsetFrom = mbox.Messages - (collectPerRun - 1)
+	// fetch oldest messages first, for the watermark to move forward continuously
+	slices.Sort(uids)
+	if len(uids) > int(collectPerRun) {
+		uids = uids[:collectPerRun]
 	}
-	seqGet.AddRange(offsetFrom, offsetTo)
 
-	section := imap.BodySectionName{}
+	log.Info("mail", fmt.Sprintf("is now fetching %d new messages (at most %d per run)",
+		len(uids), collectPerRun))
+
+	seqDone := new(imap.SeqSet) // messages to apply post-processing action to
+	seqGet := new(imap.SeqSet)  // messages to fetch
+	seqGet.AddNum(uids...)
+	cntDone := 0
+
+	// peek to not set seen flag, it is only set for processed messages if configured
+	section := imap.BodySectionName{Peek: true}
 	messages := make(chan *imap.Message, 10)
 	doneErr := make(chan error, 1)
 
 	go func() {
-		doneErr <- c.Fetch(seqGet, []imap.FetchItem{section.FetchItem()}, messages)
+		doneErr <- c.UidFetch(seqGet, []imap.FetchItem{section.FetchItem(), imap.FetchUid}, messages)
 	}()
 
-	// process and then store messages to mail spooler
+	// process and then store messages to mail spooler, watermark is updated with each stored message
+	// after a failed message, remaining messages are skipped - the watermark must not pass the failed one
+	// messages failing repeatedly are skipped, to not block the folder - they stay on the server unchanged
+	// messages are retrieved in ascending UID order
+	failed := false
 	for msg := range messages {
-		if err := processMessage(ma.Id, msg, &section); err != nil {
-			// mail processing can fail because of many reasons, warn and move on
-			log.Warning("mail", "failed to process message - its not being deleted from the mailbox", err)
+		if failed {
+			continue // fetched messages must still be received
+		}
+		if err := processMessage(ma, msg, &section, folder, mbox.UidValidity); err != nil {
+			if msg == nil {
+				log.Warning("mail", "failed to process message - it and following messages are retried on next run", err)
+				failed = true
+				continue
+			}
+
+			attempts, errAttempt := setFolderFailure(ma.Id, folder, mbox.UidValidity, uidLast, msg.Uid)
+			if errAttempt != nil {
+				log.Error("mail", "failed to store failed message attempt", errAttempt)
+				failed = true
+				continue
+			}
+			if attempts < failedAttemptsMax {
+				// mail processing can fail because of many reasons, warn and retry on next run
+				log.Warning("mail", fmt.Sprintf("failed to process message UID %d (attempt %d of %d) - it and following messages are retried on next run",
+					msg.Uid, attempts, failedAttemptsMax), err)
+
+				failed = true
+				continue
+			}
+
+			// move watermark past message, no post-processing action is applied to it
+			log.Error("mail", fmt.Sprintf("failed to process message UID %d in folder '%s' of '%s' %d times, it is skipped",
+				msg.Uid, folder, ma.Name, attempts), err)
 
-		} else {
-			// add to deletion sequence if processed successfully
-			seqDel.AddNum(msg.SeqNum)
+			if err := setFolderWatermark(ma.Id, folder, mbox.UidValidity, msg.Uid); err != nil {
+				log.Error("mail", "failed to skip message", err)
+				failed = true
+				continue
+			}
+			uidLast = msg.Uid
+			continue
 		}
+
+		// add to post-processing sequence if processed successfully
+		seqDone.AddNum(msg.Uid)
+		uidLast = msg.Uid
+		cntDone++
 	}
 
 	// wait for fetch to complete
-	if err := <-doneErr; err != nil {
-		return err
+	// if it failed, messages processed so far are already stored with their watermark - post-processing still applies
+	fetchErr := <-doneErr
+
+	log.Info("mail", fmt.Sprintf("processed %d messages successfully, applying action '%s'",
+		cntDone, ma.ImapAction))
+
+	if cntDone == 0 {
+		return fetchErr
 	}
 
-	log.Info("mail", fmt.Sprintf("processed %d messages successfully, marking them for deletion",
-		len(seqDel.Set)))
+	if err := applyAction(c, ma, seqDone); err != nil {
+		return err
+	}
+	return fetchErr
+}
 
-	// if database update was successful, execute mail deletion
-	if len(seqDel.Set) != 0 {
+// applies post-processing action to successfully processed messages
+func applyAction(c *client.Client, ma types.MailAccount, seqDone *imap.SeqSet) error {
+	switch ma.ImapAction {
+	case "move":
+		return c.UidMove(seqDone, ma.ImapFolderMove.String)
+	case "seen":
+		item := imap.FormatFlagsOp(imap.AddFlags, true)
+		flags := []interface{}{imap.SeenFlag}
+		return c.UidStore(seqDone, item, flags, nil)
+	default:
 		item := imap.FormatFlagsOp(imap.AddFlags, true)
 		flags := []interface{}{imap.DeletedFlag}
-		if err := c.Store(seqDel, item, flags, nil); err != nil {
-			return err
-		}
-		if err := c.Expunge(nil); err != nil {
+		if err := c.UidStore(seqDone, item, flags, nil); err != nil {
 			return err
 		}
+		return c.Expunge(nil)
 	}
-	return nil
 }
 
-func processMessage(mailAccountId int32, msg *imap.Message,
-	section *imap.BodySectionName) error {
+// stores message and updates UID watermark of folder in the same transaction
+func processMessage(ma types.MailAccount, msg *imap.Message,
+	section *imap.BodySectionName, folder string, uidValidity uint32) error {
 
 	if msg == nil {
 		return errors.New("server did not return message")
@@ -189,31 +293,20 @@ func processMessage(mailAccountId int32, msg *imap.Message,
 	}
 
 	// parse header
-	var date time.Time
-	var subject string
-	var cc, from, to []*mail.Address
-
+	// optional headers are often malformed, invalid values must not keep the message from being stored
 	header := mr.Header
-	date, err = header.Date()
-	if err != nil {
-		return err
+	date, err := header.Date()
+	if err != nil || date.IsZero() {
+		log.Warning("mail", fmt.Sprintf("message UID %d has no valid date, using time of retrieval", msg.Uid), err)
+		date = time.Now()
 	}
-	from, err = header.AddressList("From")
+	subject, err := header.Subject()
 	if err != nil {
-		return err
-	}
-	to, err = header.AddressList("To")
-	if err != nil {
-		return err
-	}
-	cc, err = header.AddressList("Cc")
-	if err != nil {
-		return err
-	}
-	subject, err = header.Subject()
-	if err != nil {
-		return err
+		subject = header.Get("Subject")
 	}
+	fromList := getStringListFromHeader(header, "From")
+	toList := getStringListFromHeader(header, "To")
+	ccList := getStringListFromHeader(header, "Cc")
 
 	// parse body
 	type cid struct {
@@ -226,6 +319,11 @@ func processMessage(mailAccountId int32, msg *imap.Message,
 	var files []types.MailFile
 	var gotHtmlText bool = false
 
+	// delivery status notifications of sent mails are recognized by their report parts
+	// parts are still processed regularly, in case the report cannot be correlated with a sent mail
+	var isReport = isDeliveryReport(header)
+	var reportStatus, reportOriginal []byte
+
 	for {
 		p, err := mr.NextPart()
 		if err == io.EOF {
@@ -234,6 +332,22 @@ func processMessage(mailAccountId int32, msg *imap.Message,
 			return err
 		}
 
+		if isReport {
+			contentType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+			if isDeliveryStatus(contentType) || isDeliveryOriginal(contentType) {
+				b, err := io.ReadAll(p.Body)
+				if err != nil {
+					return err
+				}
+				if isDeliveryStatus(contentType) {
+					reportStatus = b
+				} else {
+					reportOriginal = b
+				}
+				p.Body = bytes.NewReader(b)
+			}
+		}
+
 		switch h := p.Header.(type) {
 		case *mail.InlineHeader:
 
@@ -332,10 +446,21 @@ func processMessage(mailAccountId int32, msg *imap.Message,
 		}
 	}
 
+	// first matching routing rule is applied, unmatched messages are only stored in spooler
+	rule, ruleMatched, err := getRuleMatch(ma.Rules, header, fromList, toList, subject)
+	if err != nil {
+		return err
+	}
+
 	tx, err := db.Pool.Begin(db.Ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback(db.Ctx)
+
+	if err := setFolderWatermark_tx(tx, ma.Id, folder, uidValidity, msg.Uid); err != nil {
+		return err
+	}
 
 	// log to mail traffic log
 	fileList := make([]string, 0)
@@ -346,24 +471,46 @@ func processMessage(mailAccountId int32, msg *imap.Message,
 		INSERT INTO instance.mail_traffic (from_list, to_list, cc_list,
 			subject, date, files, mail_account_id, outgoing)
 		VALUES ($1,$2,$3,$4,$5,$6,$7,FALSE)
-	`, getStringListFromAddress(from), getStringListFromAddress(to), getStringListFromAddress(cc),
-		subject, date.Unix(), fileList, mailAccountId); err != nil {
-
-		tx.Rollback(db.Ctx)
+	`, fromList, toList, ccList, subject, date.Unix(), fileList, ma.Id); err != nil {
 		return fmt.Errorf("%w, %s", errors.New("failed to store message in traffic log"), err)
 	}
 
+	// bounced mails are marked in traffic log, the delivery status notification itself is not spooled
+	if isReport {
+		correlated, err := bounceApply_tx(tx, ma, getBounces(reportStatus, reportOriginal), date.Unix())
+		if err != nil {
+			return fmt.Errorf("%w, %s", errors.New("failed to apply delivery status notification"), err)
+		}
+		if correlated {
+			return tx.Commit(db.Ctx)
+		}
+	}
+
+	// create record from message, attachments are stored to record by attach spooler
+	var recordId pgtype.Int8
+	var attributeId pgtype.UUID
+	if ruleMatched && rule.Action == "record" {
+		recordId.Int64, err = ruleCreateRecord_tx(tx, rule, fromList, toList, subject, body, date.Unix())
+		if err != nil {
+			return fmt.Errorf("%w, %s", fmt.Errorf("failed to create record for mail rule '%s'", rule.Name), err)
+		}
+		if len(files) == 0 || !rule.AttributeIdFiles.Valid {
+			return tx.Commit(db.Ctx)
+		}
+		recordId.Valid = true
+		attributeId = rule.AttributeIdFiles
+	}
+
 	// store message in spooler
 	var mailId int64
 	if err := tx.QueryRow(db.Ctx, `
-		INSERT INTO instance.mail_spool (from_list, to_list, cc_list,
-			subject, body, date, mail_account_id, outgoing)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,FALSE)
+		INSERT INTO instance.mail_spool (from_list, to_list, cc_list, subject,
+			body, date, mail_account_id, outgoing, record_id_wofk, attribute_id)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,FALSE,$8,$9)
 		RETURNING id
-	`, getStringListFromAddress(from), getStringListFromAddress(to), getStringListFromAddress(cc),
-		subject, body, date.Unix(), mailAccountId).Scan(&mailId); err != nil {
+	`, fromList, toList, ccList, subject, body, date.Unix(), ma.Id,
+		recordId, attributeId).Scan(&mailId); err != nil {
 
-		tx.Rollback(db.Ctx)
 		return fmt.Errorf("%w, %s", errors.New("failed to store message in spooler"), err)
 	}
 
@@ -374,16 +521,83 @@ func processMessage(mailAccountId int32, msg *imap.Message,
 				mail_id, position, file, file_name, file_size)
 			VALUES ($1,$2,$3,$4,$5)
 		`, mailId, i, file.File, file.Name, file.Size); err != nil {
-
-			tx.Rollback(db.Ctx)
 			return fmt.Errorf("%w, %s", errors.New("failed to store message attachment in spooler"), err)
 		}
 	}
+
+	if ruleMatched && rule.Action == "function" {
+		if err := ruleCallFunction_tx(tx, rule, mailId); err != nil {
+			return fmt.Errorf("%w, %s", fmt.Errorf("failed to call function for mail rule '%s'", rule.Name), err)
+		}
+	}
 	return tx.Commit(db.Ctx)
 }
 
 // helpers
-func getStringListFromAddress(list []*mail.Address) string {
+func getFolderWatermark(mailAccountId int32, folder string) (uint32, uint32, error) {
+	var uidValidity, uidLast int64
+	err := db.Pool.QueryRow(db.Ctx, `
+		SELECT uid_validity, uid_last
+		FROM instance.mail_account_imap_folder
+		WHERE mail_account_id = $1
+		AND   folder          = $2
+	`, mailAccountId, folder).Scan(&uidValidity, &uidLast)
+
+	if err == pgx.ErrNoRows {
+		return 0, 0, nil
+	}
+	return uint32(uidValidity), uint32(uidLast), err
+}
+func setFolderWatermark(mailAccountId int32, folder string, uidValidity uint32, uidLast uint32) error {
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	if err := setFolderWatermark_tx(tx, mailAccountId, folder, uidValidity, uidLast); err != nil {
+		return err
+	}
+	return tx.Commit(db.Ctx)
+}
+func setFolderWatermark_tx(tx pgx.Tx, mailAccountId int32, folder string, uidValidity uint32, uidLast uint32) error {
+	_, err := tx.Exec(db.Ctx, `
+		INSERT INTO instance.mail_account_imap_folder (
+			mail_account_id, folder, uid_validity, uid_last)
+		VALUES ($1,$2,$3,$4)
+		ON CONFLICT (mail_account_id, folder)
+		DO UPDATE SET uid_validity = $3, uid_last = $4,
+			uid_failed = NULL, uid_failed_attempts = 0
+	`, mailAccountId, folder, int64(uidValidity), int64(uidLast))
+	return err
+}
+
+// counts failed attempts to process the message with the given UID, returns attempts so far
+// attempts are counted for the first failing message after the watermark only
+func setFolderFailure(mailAccountId int32, folder string, uidValidity uint32, uidLast uint32, uidFailed uint32) (int, error) {
+	var attempts int
+	err := db.Pool.QueryRow(db.Ctx, `
+		INSERT INTO instance.mail_account_imap_folder AS f (
+			mail_account_id, folder, uid_validity, uid_last,
+			uid_failed, uid_failed_attempts)
+		VALUES ($1,$2,$3,$4,$5,1)
+		ON CONFLICT (mail_account_id, folder)
+		DO UPDATE SET uid_validity = $3, uid_last = $4, uid_failed = $5,
+			uid_failed_attempts = CASE
+				WHEN f.uid_failed = $5 AND f.uid_validity = $3
+				THEN f.uid_failed_attempts + 1
+				ELSE 1
+			END
+		RETURNING uid_failed_attempts
+	`, mailAccountId, folder, int64(uidValidity), int64(uidLast), int64(uidFailed)).Scan(&attempts)
+	return attempts, err
+}
+func getStringListFromHeader(header mail.Header, key string) string {
+	list, err := header.AddressList(key)
+	if err != nil {
+		// unparsable address lists are kept as they are
+		return strings.TrimSpace(header.Get(key))
+	}
 	out := make([]string, 0)
 	for _, a := range list {
 		if a.String() == "" {
@@ -0,0 +1,156 @@
+package mail_receive
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"r3/cache"
+	"r3/db"
+	"r3/log"
+	"r3/types"
+	"strings"
+
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// failed delivery of a sent mail to one of its recipients
+type bounce struct {
+	messageId  string // message ID of sent mail
+	recipient  string
+	status     string // enhanced status code (RFC 3463), e.g. 5.1.1
+	diagnostic string
+}
+
+// delivery status notifications (RFC 3464) are sent as multipart/report
+func isDeliveryReport(header mail.Header) bool {
+	mediaType, params, err := header.ContentType()
+	return err == nil && mediaType == "multipart/report" &&
+		strings.EqualFold(params["report-type"], "delivery-status")
+}
+
+// returns true if part of delivery status notification contains the delivery status
+func isDeliveryStatus(contentType string) bool {
+	return contentType == "message/delivery-status" || contentType == "message/global-delivery-status"
+}
+
+// returns true if part of delivery status notification contains the sent mail or its header
+func isDeliveryOriginal(contentType string) bool {
+	return contentType == "message/rfc822" || contentType == "message/global" ||
+		contentType == "text/rfc822-headers" || contentType == "message/global-headers"
+}
+
+// returns failed recipients from delivery status and the message ID from the header of the sent mail
+// delayed, delivered, relayed or expanded recipients are ignored
+func getBounces(status []byte, original []byte) []bounce {
+	bounces := make([]bounce, 0)
+
+	// original mail is optional in reports, without its message ID the report cannot be correlated
+	header, _ := textproto.ReadHeader(bufio.NewReader(bytes.NewReader(original)))
+	messageId := getMessageId(header.Get("Message-Id"))
+	if messageId == "" {
+		return bounces
+	}
+
+	// delivery status consists of header blocks: first per message, then one per recipient
+	status = bytes.ReplaceAll(status, []byte("\r\n"), []byte("\n"))
+	for i, block := range bytes.Split(status, []byte("\n\n")) {
+		if i == 0 {
+			continue
+		}
+		fields, _ := textproto.ReadHeader(bufio.NewReader(bytes.NewReader(block)))
+		if !strings.EqualFold(strings.TrimSpace(fields.Get("Action")), "failed") {
+			continue
+		}
+
+		// recipient is given as address type and address (rfc822; someone@example.com)
+		recipient := getStatusValue(fields.Get("Final-Recipient"))
+		if recipient == "" {
+			recipient = getStatusValue(fields.Get("Original-Recipient"))
+		}
+		bounces = append(bounces, bounce{
+			messageId:  messageId,
+			recipient:  recipient,
+			status:     strings.TrimSpace(fields.Get("Status")),
+			diagnostic: getStatusValue(fields.Get("Diagnostic-Code")),
+		})
+	}
+	return bounces
+}
+
+// adds failed recipients to sent mail in traffic log and calls bounce function of mail account, if set
+// failures for unknown message IDs are skipped, returns true if any failure could be correlated to a sent mail
+func bounceApply_tx(tx pgx.Tx, ma types.MailAccount, bounces []bounce, date int64) (bool, error) {
+
+	correlated := false
+	for _, b := range bounces {
+		tag, err := tx.Exec(db.Ctx, `
+			UPDATE instance.mail_traffic
+			SET bounce_date        = $1,
+				bounce_recipients  = ARRAY_APPEND(COALESCE(bounce_recipients,  '{}'), $2),
+				bounce_statuses    = ARRAY_APPEND(COALESCE(bounce_statuses,    '{}'), $3),
+				bounce_diagnostics = ARRAY_APPEND(COALESCE(bounce_diagnostics, '{}'), $4)
+			WHERE message_id = $5
+			AND   outgoing
+		`, date, b.recipient, b.status, b.diagnostic, b.messageId)
+		if err != nil {
+			return false, err
+		}
+		if tag.RowsAffected() == 0 {
+			log.Info("mail", fmt.Sprintf("ignoring bounce of unknown mail '%s' to '%s'",
+				b.messageId, b.recipient))
+
+			continue
+		}
+		correlated = true
+
+		log.Info("mail", fmt.Sprintf("mail '%s' to '%s' bounced with status '%s'",
+			b.messageId, b.recipient, b.status))
+
+		if ma.BouncePgFunctionId.Valid {
+			if err := bounceCallFunction_tx(tx, ma, b); err != nil {
+				return false, err
+			}
+		}
+	}
+	return correlated, nil
+}
+
+// calls bounce function of mail account with failed delivery (instance.mail_bounce)
+func bounceCallFunction_tx(tx pgx.Tx, ma types.MailAccount, b bounce) error {
+
+	cache.Schema_mx.RLock()
+	fnc, exists := cache.PgFunctionIdMap[ma.BouncePgFunctionId.Bytes]
+	if !exists {
+		cache.Schema_mx.RUnlock()
+		return fmt.Errorf("unknown bounce function '%s' of mail account '%s'",
+			uuid.UUID(ma.BouncePgFunctionId.Bytes), ma.Name)
+	}
+	mod := cache.ModuleIdMap[fnc.ModuleId]
+	cache.Schema_mx.RUnlock()
+
+	if _, err := tx.Exec(db.Ctx, fmt.Sprintf(`
+		SELECT "%s"."%s"(ROW(message_id, $1, $2, $3, to_list, subject, date)::instance.mail_bounce)
+		FROM instance.mail_traffic
+		WHERE message_id = $4
+		AND   outgoing
+	`, mod.Name, fnc.Name), b.recipient, b.status, b.diagnostic, b.messageId); err != nil {
+		return fmt.Errorf("failed to call bounce function of mail account '%s', %s", ma.Name, err)
+	}
+	return nil
+}
+
+// helpers
+func getMessageId(value string) string {
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+// removes type prefix from status field value (type; value)
+func getStatusValue(value string) string {
+	if pos := strings.Index(value, ";"); pos != -1 {
+		value = value[pos+1:]
+	}
+	return strings.TrimSpace(value)
+}
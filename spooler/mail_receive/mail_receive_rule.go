@@ -0,0 +1,144 @@
+package mail_receive
+
+import (
+	"fmt"
+	"r3/cache"
+	"r3/data"
+	"r3/db"
+	"r3/types"
+	"regexp"
+
+	"github.com/emersion/go-message/mail"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// returns first routing rule matching the received mail, false if none matches
+// empty expressions are not checked
+func getRuleMatch(rules []types.MailRule, header mail.Header,
+	fromList string, toList string, subject string) (types.MailRule, bool, error) {
+
+	for _, r := range rules {
+		type check struct {
+			regex string
+			value string
+		}
+		checks := []check{
+			{r.MatchFrom.String, fromList},
+			{r.MatchTo.String, toList},
+			{r.MatchSubject.String, subject},
+		}
+		for _, h := range r.MatchHeaders {
+			// decode header value if possible (RFC 2047), use raw value otherwise
+			value, err := header.Text(h.Name)
+			if err != nil {
+				value = header.Get(h.Name)
+			}
+			checks = append(checks, check{h.Match, value})
+		}
+
+		matched := true
+		for _, c := range checks {
+			if c.regex == "" {
+				continue
+			}
+			regex, err := regexp.Compile(c.regex)
+			if err != nil {
+				return r, false, fmt.Errorf("invalid expression in mail rule '%s', %s", r.Name, err)
+			}
+			if !regex.MatchString(c.value) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return r, true, nil
+		}
+	}
+	return types.MailRule{}, false, nil
+}
+
+// calls function of rule with mail from spooler
+// function can keep mail for attaching files via instance.mail_delete_after_attach(), otherwise mail is deleted
+func ruleCallFunction_tx(tx pgx.Tx, r types.MailRule, mailId int64) error {
+
+	cache.Schema_mx.RLock()
+	fnc, exists := cache.PgFunctionIdMap[r.PgFunctionId.Bytes]
+	if !exists {
+		cache.Schema_mx.RUnlock()
+		return fmt.Errorf("unknown function '%s' in mail rule '%s'", uuid.UUID(r.PgFunctionId.Bytes), r.Name)
+	}
+	mod := cache.ModuleIdMap[fnc.ModuleId]
+	cache.Schema_mx.RUnlock()
+
+	if _, err := tx.Exec(db.Ctx, fmt.Sprintf(`
+		SELECT "%s"."%s"(ROW(id, from_list, to_list, cc_list, subject, body)::instance.mail)
+		FROM instance.mail_spool
+		WHERE id = $1
+	`, mod.Name, fnc.Name), mailId); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(db.Ctx, `
+		DELETE FROM instance.mail_spool
+		WHERE id = $1
+		AND record_id_wofk IS NULL
+	`, mailId)
+	return err
+}
+
+// creates record in relation of rule with mail values as system, returns record ID
+func ruleCreateRecord_tx(tx pgx.Tx, r types.MailRule, fromList string,
+	toList string, subject string, body string, date int64) (int64, error) {
+
+	dataSet := types.DataSet{
+		RelationId: r.RelationId.Bytes,
+		RecordId:   0,
+		Attributes: make([]types.DataSetAttribute, 0),
+	}
+
+	cache.Schema_mx.RLock()
+	if _, exists := cache.RelationIdMap[r.RelationId.Bytes]; !exists {
+		cache.Schema_mx.RUnlock()
+		return 0, fmt.Errorf("unknown relation '%s' in mail rule '%s'", uuid.UUID(r.RelationId.Bytes), r.Name)
+	}
+
+	for _, v := range []struct {
+		atrId pgtype.UUID
+		value interface{}
+	}{
+		{r.AttributeIdFrom, fromList},
+		{r.AttributeIdTo, toList},
+		{r.AttributeIdSubject, subject},
+		{r.AttributeIdBody, body},
+		{r.AttributeIdDate, date},
+	} {
+		if !v.atrId.Valid {
+			continue
+		}
+		atr, exists := cache.AttributeIdMap[v.atrId.Bytes]
+		if !exists {
+			cache.Schema_mx.RUnlock()
+			return 0, fmt.Errorf("unknown attribute '%s' in mail rule '%s'", uuid.UUID(v.atrId.Bytes), r.Name)
+		}
+
+		value := v.value
+		if s, isString := value.(string); isString && atr.Content == "varchar" &&
+			atr.Length != 0 && len([]rune(s)) > atr.Length {
+
+			value = string([]rune(s)[:atr.Length])
+		}
+		dataSet.Attributes = append(dataSet.Attributes, types.DataSetAttribute{
+			AttributeId: atr.Id,
+			Value:       value,
+		})
+	}
+	cache.Schema_mx.RUnlock()
+
+	indexRecordIds, err := data.SetSystem_tx(db.Ctx, tx, map[int]types.DataSet{0: dataSet})
+	if err != nil {
+		return 0, err
+	}
+	return indexRecordIds[0], nil
+}
@@ -1,8 +1,6 @@
 package mail_send
 
 import (
-	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"os"
@@ -36,7 +34,8 @@ func DoAll() error {
 
 	rows, err := db.Pool.Query(db.Ctx, `
 		SELECT id, to_list, cc_list, bcc_list, subject, body, attempt_count,
-			mail_account_id, record_id_wofk, attribute_id
+			mail_account_id, record_id_wofk, attribute_id, mail_template_id,
+			mail_template_relation_id, mail_template_record_id, mail_template_render
 		FROM instance.mail_spool
 		WHERE outgoing
 		AND attempt_count < $1
@@ -51,7 +50,9 @@ func DoAll() error {
 
 		if err := rows.Scan(&m.Id, &m.ToList, &m.CcList, &m.BccList,
 			&m.Subject, &m.Body, &m.AttemptCount, &m.AccountId,
-			&m.RecordId, &m.AttributeId); err != nil {
+			&m.RecordId, &m.AttributeId, &m.MailTemplateId,
+			&m.MailTemplateRelationId, &m.MailTemplateRecordId,
+			&m.MailTemplateRender); err != nil {
 
 			return err
 		}
@@ -124,6 +125,14 @@ func do(m types.Mail) error {
 		}
 	}
 
+	// render subject and body from stored mail template captions, if used
+	if m.MailTemplateRender {
+		m.Subject, m.Body, err = renderTemplate(m)
+		if err != nil {
+			return err
+		}
+	}
+
 	// build mail
 	msg := mail.NewMsg()
 	msg.Subject(m.Subject)
@@ -149,8 +158,10 @@ func do(m types.Mail) error {
 
 	// dirty trick to assume body content by looking for beginning of HTML tag
 	// we should find a way to store our preference when sending mails
-	if strings.Contains(m.Body, "<") {
-		msg.SetBodyString(mail.TypeTextHTML, m.Body)
+	// HTML bodies are sent with plain text alternative
+	if m.MailTemplateRender || strings.Contains(m.Body, "<") {
+		msg.SetBodyString(mail.TypeTextPlain, getTextFromHtml(m.Body))
+		msg.AddAlternativeString(mail.TypeTextHTML, m.Body)
 	} else {
 		msg.SetBodyString(mail.TypeTextPlain, m.Body)
 	}
@@ -217,50 +228,30 @@ func do(m types.Mail) error {
 	log.Info("mail", fmt.Sprintf("sending message (%d attachments)",
 		len(msg.GetAttachments())))
 
-	client, err := mail.NewClient(ma.HostName, mail.WithPort(int(ma.HostPort)),
-		mail.WithUsername(ma.Username),
-		mail.WithPassword(ma.Password),
-		mail.WithTLSConfig(&tls.Config{ServerName: ma.HostName}))
-
+	from, err := msg.GetSender(false)
 	if err != nil {
 		return err
 	}
-
-	// use SSL if STARTTLS is disabled - otherwise STARTTLS is attempted
-	client.SetSSL(!ma.StartTls)
-
-	// apply authentication method
-	switch ma.AuthMethod {
-	case "login":
-		client.SetSMTPAuth(mail.SMTPAuthLogin)
-	case "plain":
-		client.SetSMTPAuth(mail.SMTPAuthPlain)
-	case "xoauth2":
-		client.SetSMTPAuth(mail.SMTPAuthXOAUTH2)
-	default:
-		return fmt.Errorf("unsupported authentication method '%s'", ma.AuthMethod)
-	}
-
-	// send message
-	if err := client.DialWithContext(context.Background()); err != nil {
+	// at least one recipient is required
+	if _, err := msg.GetRecipients(); err != nil {
 		return err
 	}
-	if err := client.Send(msg); err != nil {
+
+	// message ID is kept in traffic log, to correlate delivery status notifications with sent mail
+	msg.SetMessageID()
+	messageId := strings.Trim(msg.GetGenHeader(mail.HeaderMessageID)[0], "<>")
+
+	if err := send(ma, from, msg); err != nil {
 		return err
 	}
-	if err := client.Close(); err != nil {
-		// some mail services do not cleanly close their connections
-		// we should not care too much if the email was successfully sent - still warn as this is not correct behavior
-		log.Warning("mail", "failed to disconnect from SMTP server", err)
-	}
 
 	// add to mail traffic log
 	if _, err := db.Pool.Exec(db.Ctx, `
 		INSERT INTO instance.mail_traffic (from_list, to_list, cc_list,
-			subject, date, files, mail_account_id, outgoing)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,TRUE)
+			subject, date, files, mail_account_id, outgoing, message_id)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,TRUE,$8)
 	`, m.FromList, m.ToList, m.CcList, m.Subject,
-		tools.GetTimeUnix(), fileList, m.AccountId); err != nil {
+		tools.GetTimeUnix(), fileList, m.AccountId, messageId); err != nil {
 
 		return err
 	}
@@ -0,0 +1,108 @@
+package mail_send
+
+import (
+	"crypto/x509"
+	"fmt"
+	"path/filepath"
+	"r3/config"
+	"r3/tools/mailsign"
+	"r3/types"
+	"strings"
+
+	"github.com/wneessen/go-mail"
+)
+
+// applies S/MIME signature & encryption and DKIM signature to raw message, as configured for mail account
+// DKIM is applied last as it covers the final message
+func sign(ma types.MailAccount, msg []byte, recipients []string) ([]byte, error) {
+	var err error
+
+	if ma.SmimeSign {
+		cert, err := mailsign.LoadCertificate(getCertificatePath(ma.SmimeCert.String))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load S/MIME certificate, %s", err)
+		}
+		key, err := mailsign.LoadPrivateKey(getCertificatePath(ma.SmimeKey.String))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load S/MIME private key, %s", err)
+		}
+		msg, err = mailsign.SignSmime(msg, cert, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ma.SmimeEncrypt {
+		certs := make([]*x509.Certificate, 0)
+		for _, r := range recipients {
+			cert, err := mailsign.LoadCertificate(getCertificatePath(
+				filepath.Join("smime", fmt.Sprintf("%s.pem", strings.ToLower(r)))))
+
+			if err != nil {
+				return nil, fmt.Errorf("failed to load S/MIME certificate of recipient '%s', %s", r, err)
+			}
+			certs = append(certs, cert)
+		}
+
+		// encrypt for sender as well, if certificate is available
+		if ma.SmimeCert.String != "" {
+			cert, err := mailsign.LoadCertificate(getCertificatePath(ma.SmimeCert.String))
+			if err != nil {
+				return nil, fmt.Errorf("failed to load S/MIME certificate, %s", err)
+			}
+			certs = append(certs, cert)
+		}
+
+		msg, err = mailsign.EncryptSmime(msg, certs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ma.DkimDomain.String != "" {
+		key, err := mailsign.ParsePrivateKey([]byte(ma.DkimKey.String))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DKIM private key, %s", err)
+		}
+		msg, err = mailsign.SignDkim(msg, ma.DkimDomain.String, ma.DkimSelector.String, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// returns recipients grouped by the message copy they receive
+// encrypted messages list a recipient info for each recipient, Bcc recipients therefore receive a separately encrypted copy each
+func getRecipientGroups(ma types.MailAccount, msg *mail.Msg) [][]string {
+	groups := make([][]string, 0)
+	visible := make([]string, 0)
+	hidden := make([]string, 0)
+
+	for _, a := range msg.GetTo() {
+		visible = append(visible, a.Address)
+	}
+	for _, a := range msg.GetCc() {
+		visible = append(visible, a.Address)
+	}
+	for _, a := range msg.GetBcc() {
+		hidden = append(hidden, a.Address)
+	}
+
+	if !ma.SmimeEncrypt {
+		return append(groups, append(visible, hidden...))
+	}
+	if len(visible) != 0 {
+		groups = append(groups, visible)
+	}
+	for _, r := range hidden {
+		groups = append(groups, []string{r})
+	}
+	return groups
+}
+
+// S/MIME certificates and keys are stored in the certificates path
+// file names must not leave it
+func getCertificatePath(fileName string) string {
+	return filepath.Join(config.File.Paths.Certificates, filepath.Clean("/"+fileName))
+}
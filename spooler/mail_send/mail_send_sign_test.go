@@ -0,0 +1,174 @@
+package mail_send
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"r3/config"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wneessen/go-mail"
+)
+
+func TestSendEncryptedBcc(t *testing.T) {
+	s := newTestServer(t, false)
+
+	// recipient certificates are stored in certificates path
+	pathOrg := config.File.Paths.Certificates
+	config.File.Paths.Certificates = t.TempDir()
+	t.Cleanup(func() { config.File.Paths.Certificates = pathOrg })
+
+	if err := os.Mkdir(filepath.Join(config.File.Paths.Certificates, "smime"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	serials := make(map[string]*big.Int)
+	for i, r := range []string{"to@example.org", "bcc1@example.org", "bcc2@example.org"} {
+		serials[r] = big.NewInt(int64(i + 1))
+		writeTestCertificate(t, filepath.Join(config.File.Paths.Certificates, "smime", r+".pem"), serials[r])
+	}
+
+	ma := s.account()
+	ma.SmimeEncrypt = true
+
+	msg := mail.NewMsg()
+	msg.Subject("Test")
+	msg.SetBodyString(mail.TypeTextPlain, "Hello")
+	if err := msg.From(ma.SendAs); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.To("to@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Bcc("bcc1@example.org", "bcc2@example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := send(ma, ma.SendAs, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// each copy is encrypted only for the recipients it is delivered to
+	deliveries := s.getDeliveries()
+	if len(deliveries) != 3 {
+		t.Fatalf("expected 3 deliveries, got %d", len(deliveries))
+	}
+	for i, expected := range []string{"to@example.org", "bcc1@example.org", "bcc2@example.org"} {
+		d := deliveries[i]
+		if !slices.Equal(d.recipients, []string{expected}) {
+			t.Fatalf("delivery %d: expected recipient '%s', got %v", i, expected, d.recipients)
+		}
+		if strings.Contains(strings.ToLower(d.data), "bcc") {
+			t.Fatalf("delivery %d: message reveals Bcc recipients", i)
+		}
+
+		got := getRecipientInfoSerials(t, d.data)
+		if len(got) != 1 || got[0].Cmp(serials[expected]) != 0 {
+			t.Fatalf("delivery %d: expected recipient info for '%s' only, got serials %v", i, expected, got)
+		}
+	}
+}
+
+func TestSendUnencryptedBcc(t *testing.T) {
+	s := newTestServer(t, false)
+	ma := s.account()
+
+	msg := mail.NewMsg()
+	msg.Subject("Test")
+	msg.SetBodyString(mail.TypeTextPlain, "Hello")
+	if err := msg.From(ma.SendAs); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.To("to@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Bcc("bcc1@example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	// without encryption a single copy is sent to all recipients
+	if err := send(ma, ma.SendAs, msg); err != nil {
+		t.Fatal(err)
+	}
+	deliveries := s.getDeliveries()
+	if len(deliveries) != 1 || !slices.Equal(deliveries[0].recipients, []string{"to@example.org", "bcc1@example.org"}) {
+		t.Fatalf("unexpected deliveries %v", deliveries)
+	}
+}
+
+// returns certificate serial numbers of recipient infos of S/MIME encrypted message
+func getRecipientInfoSerials(t *testing.T, data string) []*big.Int {
+	parts := strings.SplitN(data, "\r\n\r\n", 2)
+	if len(parts) != 2 || !strings.Contains(parts[0], "application/pkcs7-mime") {
+		t.Fatal("message is not S/MIME encrypted")
+	}
+	p7m, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(parts[1], "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ci struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,tag:0"`
+	}
+	if _, err := asn1.Unmarshal(p7m, &ci); err != nil {
+		t.Fatal(err)
+	}
+	var ed struct {
+		Version        int
+		RecipientInfos asn1.RawValue
+		Rest           asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		t.Fatal(err)
+	}
+
+	serials := make([]*big.Int, 0)
+	rest := ed.RecipientInfos.Bytes
+	for len(rest) != 0 {
+		var ri struct {
+			Version int
+			Rid     struct {
+				Issuer       asn1.RawValue
+				SerialNumber *big.Int
+			}
+			KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+			EncryptedKey           []byte
+		}
+		if rest, err = asn1.Unmarshal(rest, &ri); err != nil {
+			t.Fatal(err)
+		}
+		serials = append(serials, ri.Rid.SerialNumber)
+	}
+	return serials
+}
+
+func writeTestCertificate(t *testing.T, filePath string, serial *big.Int) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: filepath.Base(filePath)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
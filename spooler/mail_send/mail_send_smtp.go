@@ -0,0 +1,134 @@
+package mail_send
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"r3/log"
+	"r3/types"
+	"strconv"
+	"time"
+
+	"github.com/wneessen/go-mail"
+	"github.com/wneessen/go-mail/smtp"
+)
+
+var (
+	connectTimeout = time.Second * 15
+	sessionTimeout = time.Minute * 5 // max. duration of SMTP session after connecting
+	tlsRootCAs     *x509.CertPool    // trusted CAs for SMTP server certificates, nil = system pool
+)
+
+// renders message, applies signatures & encryption and sends it via SMTP
+// each recipient group receives its own copy (see getRecipientGroups)
+func send(ma types.MailAccount, from string, msg *mail.Msg) error {
+	var raw bytes.Buffer
+	if _, err := msg.WriteTo(&raw); err != nil {
+		return err
+	}
+	for _, group := range getRecipientGroups(ma, msg) {
+		rawSigned, err := sign(ma, raw.Bytes(), group)
+		if err != nil {
+			return err
+		}
+		if err := sendRaw(ma, from, group, rawSigned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sends raw message via SMTP
+// message is sent as is, so that applied signatures stay intact
+func sendRaw(ma types.MailAccount, from string, recipients []string, msg []byte) error {
+
+	var auth smtp.Auth
+	switch ma.AuthMethod {
+	case "login":
+		auth = smtp.LoginAuth(ma.Username, ma.Password, ma.HostName)
+	case "plain":
+		auth = smtp.PlainAuth("", ma.Username, ma.Password, ma.HostName)
+	case "xoauth2":
+		auth = smtp.XOAuth2Auth(ma.Username, ma.Password)
+	default:
+		return fmt.Errorf("unsupported authentication method '%s'", ma.AuthMethod)
+	}
+
+	helo, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to read local hostname, %s", err)
+	}
+
+	// use SSL if STARTTLS is disabled - otherwise STARTTLS is required
+	var conn net.Conn
+	dialer := net.Dialer{Timeout: connectTimeout}
+	address := net.JoinHostPort(ma.HostName, strconv.FormatInt(ma.HostPort, 10))
+	tlsConfig := &tls.Config{ServerName: ma.HostName, RootCAs: tlsRootCAs}
+
+	if ma.StartTls {
+		conn, err = dialer.Dial("tcp", address)
+	} else {
+		conn, err = tls.DialWithDialer(&dialer, "tcp", address, tlsConfig)
+	}
+	if err != nil {
+		return err
+	}
+
+	// unresponsive servers must not block the spooler
+	if err := conn.SetDeadline(time.Now().Add(sessionTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, ma.HostName)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Hello(helo); err != nil {
+		return err
+	}
+	if ma.StartTls {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("STARTTLS is enabled, but target host does not support it")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if err := client.Auth(auth); err != nil {
+		return fmt.Errorf("SMTP AUTH failed, %s", err)
+	}
+
+	// send message
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, r := range recipients {
+		if err := client.Rcpt(r); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(msg); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	if err := client.Quit(); err != nil {
+		// some mail services do not cleanly close their connections
+		// we should not care too much if the email was successfully sent - still warn as this is not correct behavior
+		log.Warning("mail", "failed to disconnect from SMTP server", err)
+	}
+	return nil
+}
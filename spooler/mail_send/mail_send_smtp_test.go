@@ -0,0 +1,231 @@
+package mail_send
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"r3/types"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// message as received by SMTP stand-in
+type testDelivery struct {
+	from       string
+	recipients []string
+	data       string
+}
+
+// minimal SMTP server (EHLO, STARTTLS, AUTH PLAIN, MAIL, RCPT, DATA, QUIT)
+type testServer struct {
+	listener   net.Listener
+	tlsConfig  *tls.Config
+	stall      bool // stop responding after greeting
+	mx         sync.Mutex
+	deliveries []testDelivery
+}
+
+func newTestServer(t *testing.T, stall bool) *testServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// trust stand-in certificate for duration of test
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	tlsRootCAs = pool
+	t.Cleanup(func() { tlsRootCAs = nil })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{
+		listener: listener,
+		stall:    stall,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+		}}},
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) account() types.MailAccount {
+	return types.MailAccount{
+		Name:       "test",
+		Mode:       "smtp",
+		AuthMethod: "plain",
+		Username:   "user",
+		Password:   "secret",
+		StartTls:   true,
+		SendAs:     "sender@example.com",
+		HostName:   "127.0.0.1",
+		HostPort:   int64(s.listener.Addr().(*net.TCPAddr).Port),
+	}
+}
+
+func (s *testServer) getDeliveries() []testDelivery {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.deliveries
+}
+
+func (s *testServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		fmt.Fprintf(conn, "%s\r\n", line)
+	}
+	reply("220 127.0.0.1 ESMTP stand-in")
+	if s.stall {
+		reader.ReadString('\n')
+		time.Sleep(time.Minute)
+		return
+	}
+
+	var d testDelivery
+	isTls := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO":
+			if isTls {
+				fmt.Fprint(conn, "250-127.0.0.1\r\n250 AUTH PLAIN\r\n")
+			} else {
+				fmt.Fprint(conn, "250-127.0.0.1\r\n250 STARTTLS\r\n")
+			}
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			reader = bufio.NewReader(conn)
+			isTls = true
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+			if !isTls || string(credentials) != "\x00user\x00secret" {
+				reply("535 authentication failed")
+				continue
+			}
+			reply("235 authenticated")
+		case "MAIL":
+			d = testDelivery{from: strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")}
+			reply("250 OK")
+		case "RCPT":
+			d.recipients = append(d.recipients, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			d.data = data.String()
+			s.mx.Lock()
+			s.deliveries = append(s.deliveries, d)
+			s.mx.Unlock()
+			reply("250 OK queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSendRaw(t *testing.T) {
+	s := newTestServer(t, false)
+	msg := "From: sender@example.com\r\nTo: someone@example.org\r\nSubject: Test\r\n\r\nHello\r\n"
+
+	if err := sendRaw(s.account(), "sender@example.com", []string{"someone@example.org"}, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	deliveries := s.getDeliveries()
+	if len(deliveries) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(deliveries))
+	}
+	d := deliveries[0]
+	if d.from != "sender@example.com" || strings.Join(d.recipients, ",") != "someone@example.org" {
+		t.Fatalf("unexpected envelope from '%s' to %v", d.from, d.recipients)
+	}
+	if d.data != msg {
+		t.Fatalf("message was changed in transit, got '%s'", d.data)
+	}
+}
+
+func TestSendRawTimeout(t *testing.T) {
+	s := newTestServer(t, true)
+
+	timeoutOrg := sessionTimeout
+	sessionTimeout = time.Second
+	t.Cleanup(func() { sessionTimeout = timeoutOrg })
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sendRaw(s.account(), "sender@example.com", []string{"someone@example.org"}, []byte("\r\n"))
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected error from unresponsive server")
+		}
+	case <-time.After(time.Second * 10):
+		t.Fatal("SMTP session did not time out")
+	}
+}
@@ -0,0 +1,96 @@
+package mail_send
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	htmlTemplate "html/template"
+	"r3/cache"
+	"r3/db"
+	"r3/handler"
+	"r3/schema"
+	"r3/types"
+	"regexp"
+	"strings"
+	textTemplate "text/template"
+)
+
+var (
+	regexHtmlIgnore  = regexp.MustCompile(`(?is)<(style|script)[^>]*>.*?</(style|script)>`)
+	regexHtmlBreak   = regexp.MustCompile(`(?i)<br\s*/?>|</?(p|div|li|tr|h[1-6])(\s[^>]*)?>`)
+	regexHtmlTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+	regexBlankLines  = regexp.MustCompile(`\n\s*\n\s*\n+`)
+	regexLineSpacing = regexp.MustCompile(`[ \t]+`)
+)
+
+// renders subject and body of spooled mail, which contain the template captions stored when the mail was spooled
+// record values of the template relation are available as {{.Record.attribute_name}}
+// schema cache must be read locked by caller
+func renderTemplate(m types.Mail) (string, string, error) {
+
+	// get record values
+	values := struct {
+		Record map[string]interface{}
+	}{
+		Record: make(map[string]interface{}),
+	}
+	if m.MailTemplateRelationId.Valid && m.MailTemplateRecordId.Valid {
+		rel, exists := cache.RelationIdMap[m.MailTemplateRelationId.Bytes]
+		if !exists {
+			return "", "", handler.ErrSchemaUnknownRelation(m.MailTemplateRelationId.Bytes)
+		}
+		mod, exists := cache.ModuleIdMap[rel.ModuleId]
+		if !exists {
+			return "", "", handler.ErrSchemaUnknownModule(rel.ModuleId)
+		}
+
+		var recordJson []byte
+		if err := db.Pool.QueryRow(db.Ctx, fmt.Sprintf(`
+			SELECT row_to_json(r)
+			FROM "%s"."%s" AS r
+			WHERE r."%s" = $1
+		`, mod.Name, rel.Name, schema.PkName), m.MailTemplateRecordId.Int64).Scan(&recordJson); err != nil {
+			return "", "", fmt.Errorf("failed to retrieve record %d of relation '%s' for mail template, %s",
+				m.MailTemplateRecordId.Int64, rel.Name, err)
+		}
+		if err := json.Unmarshal(recordJson, &values.Record); err != nil {
+			return "", "", err
+		}
+	}
+
+	// subject is plain text, body is HTML with escaped record values
+	tmplSubject, err := textTemplate.New("subject").Parse(m.Subject)
+	if err != nil {
+		return "", "", err
+	}
+	tmplBody, err := htmlTemplate.New("body").Parse(m.Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	var bufSubject, bufBody bytes.Buffer
+	if err := tmplSubject.Execute(&bufSubject, values); err != nil {
+		return "", "", err
+	}
+	if err := tmplBody.Execute(&bufBody, values); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(bufSubject.String()), bufBody.String(), nil
+}
+
+// returns plain text version of HTML body, used as alternative for mail clients without HTML support
+func getTextFromHtml(body string) string {
+	body = regexHtmlIgnore.ReplaceAllString(body, "")
+	body = strings.NewReplacer("\r", "", "\n", " ").Replace(body)
+	body = regexHtmlBreak.ReplaceAllString(body, "\n")
+	body = regexHtmlTag.ReplaceAllString(body, "")
+	body = html.UnescapeString(body)
+	body = regexLineSpacing.ReplaceAllString(body, " ")
+
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(regexBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
@@ -5,20 +5,29 @@ package rest_send
 import (
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"r3/cache"
 	"r3/config"
 	"r3/db"
 	"r3/log"
+	"r3/tools"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 var (
-	attemptsAllow = 5   // how many attempts for each REST call before quitting
-	callLimit     = 100 // how many REST calls to retrieve per loop
+	backoffMaxSeconds = int64(86400) // max. delay between attempts of a REST call
+	callLimit         = 100          // how many REST calls to claim per loop
+	claimSeconds      = int64(300)   // how long a claimed REST call is reserved for the claiming node, before it is executed
+	responseBodyLimit = 65536        // how many bytes of a failed response body to keep
 )
 
 type restCall struct {
@@ -30,118 +39,318 @@ type restCall struct {
 	body                 pgtype.Text
 	callbackValue        pgtype.Text
 	skipVerify           bool
+	webhookDeliveryId    pgtype.Int8
+	attemptCount         int
+	timeoutSeconds       pgtype.Int4
+	successCodes         pgtype.Text
+	groupKey             pgtype.Text
+	requestDone          bool         // request was successful, only callback is left to execute
+	response             restResponse // response of successful request, if only callback is left
+}
+type restResponse struct {
+	code    pgtype.Int4
+	body    pgtype.Text
+	bodyRaw []byte // complete response body, only available right after the request
 }
 
+// executes due REST calls in parallel, limited by workers as well as concurrency & rate limits per target host
+// calls with the same group key are executed one after another, in the order they were added
 func DoAll() error {
+	attemptsAllow := int(config.GetUint64("restAttempts"))
+	if attemptsAllow < 1 {
+		attemptsAllow = 1
+	}
+	workers := int(config.GetUint64("restWorkers"))
+	if workers < 1 {
+		workers = 1
+	}
+	hostConcurrency := int(config.GetUint64("restHostConcurrency"))
+	if hostConcurrency < 1 {
+		hostConcurrency = 1
+	}
+	hostInterval := time.Duration(0)
+	if rateLimit := config.GetUint64("restHostRateLimit"); rateLimit != 0 {
+		hostInterval = time.Second / time.Duration(rateLimit)
+	}
+
+	hosts := make(map[string]*hostLimiter)
+	workerSlots := make(chan struct{}, workers)
+
 	for true {
-		anySuccess := false
-
-		// collect spooled REST calls
-		rows, err := db.Pool.Query(db.Ctx, `
-			SELECT id, pg_function_id_callback, method, headers,
-				url, body, callback_value, skip_verify
-			FROM instance.rest_spool
-			WHERE attempt_count < $1
-			ORDER BY date_added ASC
-			LIMIT $2
-		`, attemptsAllow, callLimit)
+		calls, err := claimCalls()
 		if err != nil {
 			return err
 		}
-		defer rows.Close()
 
-		calls := make([]restCall, 0)
-		for rows.Next() {
-			var c restCall
-			if err := rows.Scan(&c.id, &c.pgFunctionIdCallback, &c.method, &c.headers,
-				&c.url, &c.body, &c.callbackValue, &c.skipVerify); err != nil {
+		var anySuccess atomic.Bool
+		var anyGrouped bool
+		var wg sync.WaitGroup
 
-				return err
+		for _, c := range calls {
+			if c.groupKey.Valid {
+				anyGrouped = true
 			}
-			calls = append(calls, c)
-		}
 
-		for _, c := range calls {
-			if err := callExecute(c); err != nil {
-				log.Error("api", fmt.Sprintf("failed to execute REST call %s '%s'", c.method, c.url), err)
+			host := getHost(c.url)
+			if _, exists := hosts[host]; !exists {
+				hosts[host] = newHostLimiter(hostConcurrency, hostInterval)
+			}
 
-				_, err := db.Pool.Exec(db.Ctx, `
-					UPDATE instance.rest_spool
-					SET attempt_count = attempt_count + 1
-					WHERE id = $1
-				`, c.id)
+			wg.Add(1)
+			go func(c restCall, h *hostLimiter) {
+				defer wg.Done()
 
+				h.acquire()
+				defer h.release()
+
+				workerSlots <- struct{}{}
+				defer func() { <-workerSlots }()
+
+				// renew claim before execution, waiting for free slots might have taken too long
+				claimed, err := claimRenew(c)
 				if err != nil {
-					log.Error("api", "failed to update call attempt count", err)
+					log.Error("api", "failed to renew claim of REST call", err)
+					return
 				}
-				continue
-			}
-			anySuccess = true
+				if !claimed {
+					log.Info("api", fmt.Sprintf("skipped REST call %s '%s', claim expired", c.method, c.url))
+					return
+				}
+
+				// successful requests are final, they are never sent again - even if their callback fails
+				res := c.response
+				if !c.requestDone {
+					var errCall error
+					res, errCall = callExecute(c)
+					if errCall != nil {
+						log.Error("api", fmt.Sprintf("failed to execute REST call %s '%s'", c.method, c.url), errCall)
+
+						if err := callFailed(c, res, errCall, attemptsAllow); err != nil {
+							log.Error("api", "failed to update failed REST call", err)
+						}
+						return
+					}
+				}
+				anySuccess.Store(true)
+
+				if c.pgFunctionIdCallback.Valid {
+					if errCallback := callCallback(c, res); errCallback != nil {
+						log.Error("api", fmt.Sprintf("failed to execute callback of REST call %s '%s'", c.method, c.url), errCallback)
+
+						if err := callbackFailed(c, errCallback); err != nil {
+							log.Error("api", "failed to update REST call with failed callback", err)
+						}
+					}
+				}
+			}(c, hosts[host])
 		}
+		wg.Wait()
 
-		// exit if limit is not reached or no call was successful
-		if len(calls) < callLimit || !anySuccess {
+		// exit if no call was successful or no more calls are expected
+		// grouped calls are claimed one at a time, successors become due once their predecessor is done
+		if !anySuccess.Load() || (len(calls) < callLimit && !anyGrouped) {
 			break
 		}
 	}
 	return nil
 }
 
-func callExecute(c restCall) error {
+// claims due REST calls for this node, calls claimed by other nodes are skipped
+// of each group, only the oldest call can be claimed - dead letters block their group until they are retried or purged
+func claimCalls() ([]restCall, error) {
+	now := tools.GetTimeUnix()
+
+	rows, err := db.Pool.Query(db.Ctx, `
+		UPDATE instance.rest_spool
+		SET claim_node_id = $1, claim_until = $2
+		WHERE id IN (
+			SELECT s.id
+			FROM instance.rest_spool AS s
+			WHERE s.dead_letter  = false
+			AND   s.next_attempt <= $3
+			AND   s.claim_until  <  $3
+			AND (
+				s.group_key IS NULL
+				OR NOT EXISTS (
+					SELECT id
+					FROM instance.rest_spool
+					WHERE group_key = s.group_key
+					AND   position  < s.position
+				)
+			)
+			ORDER BY s.position ASC
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, pg_function_id_callback, method, headers, url, body,
+			callback_value, skip_verify, webhook_delivery_id, attempt_count,
+			timeout_seconds, success_codes, group_key, request_done,
+			response_code, response_body
+	`, cache.GetNodeId(), now+claimSeconds, now, callLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calls := make([]restCall, 0)
+	for rows.Next() {
+		var c restCall
+		if err := rows.Scan(&c.id, &c.pgFunctionIdCallback, &c.method, &c.headers,
+			&c.url, &c.body, &c.callbackValue, &c.skipVerify,
+			&c.webhookDeliveryId, &c.attemptCount, &c.timeoutSeconds,
+			&c.successCodes, &c.groupKey, &c.requestDone, &c.response.code,
+			&c.response.body); err != nil {
+
+			return nil, err
+		}
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
+
+// renews claim of REST call for the duration of its execution
+// returns false if claim expired and call was claimed by another node
+func claimRenew(c restCall) (bool, error) {
+	now := tools.GetTimeUnix()
+
+	tag, err := db.Pool.Exec(db.Ctx, `
+		UPDATE instance.rest_spool
+		SET claim_until = $1
+		WHERE id            = $2
+		AND   claim_node_id = $3
+		AND   claim_until  >= $4
+	`, now+getTimeout(c)+claimSeconds, c.id, cache.GetNodeId(), now)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func callExecute(c restCall) (restResponse, error) {
 	log.Info("api", fmt.Sprintf("is calling %s '%s'", c.method, c.url))
 
+	var res restResponse
+
 	httpReq, err := http.NewRequest(c.method, c.url, strings.NewReader(c.body.String))
 	if err != nil {
-		return fmt.Errorf("could not prepare request, %s", err)
+		return res, fmt.Errorf("could not prepare request, %s", err)
 	}
 
 	httpReq.Header.Set("User-Agent", "r3-application")
 	for k, v := range c.headers {
 		httpReq.Header.Set(k, v)
 	}
+	if c.webhookDeliveryId.Valid {
+		if err := setWebhookSignature(httpReq, c); err != nil {
+			return res, err
+		}
+	}
 
-	httpClient, err := config.GetHttpClient(c.skipVerify, 30)
+	httpClient, err := config.GetHttpClient(c.skipVerify, getTimeout(c))
 	if err != nil {
-		return err
+		return res, err
 	}
 
 	httpRes, err := httpClient.Do(httpReq)
 	if err != nil {
-		return err
+		return res, err
 	}
 	defer httpRes.Body.Close()
 
-	// successfully executed
+	bodyRaw, err := io.ReadAll(httpRes.Body)
+	if err != nil {
+		return res, fmt.Errorf("could not read response body, %s", err)
+	}
+	res.code = pgtype.Int4{Int32: int32(httpRes.StatusCode), Valid: true}
+	res.body = getResponseBodyText(bodyRaw)
+	res.bodyRaw = bodyRaw
+
+	// check status code of response
+	successCodes := config.GetString("restSuccessCodes")
+	if c.successCodes.Valid && c.successCodes.String != "" {
+		successCodes = c.successCodes.String
+	}
+	success, err := isSuccessCode(successCodes, httpRes.StatusCode)
+	if err != nil {
+		return res, err
+	}
+	if !success {
+		return res, fmt.Errorf("response status code %d is not in success codes '%s'",
+			httpRes.StatusCode, successCodes)
+	}
+
+	// successfully executed, request is done before callback is executed
 	tx, err := db.Pool.Begin(db.Ctx)
 	if err != nil {
-		return err
+		return res, err
 	}
 	defer tx.Rollback(db.Ctx)
 
-	// execute callback if enabled
-	if c.pgFunctionIdCallback.Valid {
-		bodyRaw, err := io.ReadAll(httpRes.Body)
-		if err != nil {
-			return fmt.Errorf("could not read response body, %s", err)
+	// update webhook delivery history
+	if c.webhookDeliveryId.Valid {
+		if _, err := tx.Exec(db.Ctx, `
+			UPDATE instance.webhook_delivery
+			SET attempt_count = attempt_count + 1, date_done = $1, response_code = $2, error = NULL
+			WHERE id = $3
+		`, tools.GetTimeUnix(), httpRes.StatusCode, c.webhookDeliveryId.Int64); err != nil {
+			return res, err
 		}
+	}
 
-		fnc, exists := cache.PgFunctionIdMap[c.pgFunctionIdCallback.Bytes]
-		if !exists {
-			return fmt.Errorf("unknown function '%s'", c.pgFunctionIdCallback.Bytes)
+	// delete REST call from spooler, calls with callback are kept until their callback is executed
+	if c.pgFunctionIdCallback.Valid {
+		if _, err := tx.Exec(db.Ctx, `
+			UPDATE instance.rest_spool
+			SET request_done = true, date_attempt = $1, response_code = $2,
+				response_body = $3, error = NULL
+			WHERE id = $4
+		`, tools.GetTimeUnix(), res.code, res.body, c.id); err != nil {
+			return res, err
 		}
-		mod, exists := cache.ModuleIdMap[fnc.ModuleId]
-		if !exists {
-			return fmt.Errorf("unknown module '%s'", fnc.ModuleId)
+	} else {
+		if _, err := tx.Exec(db.Ctx, `
+			DELETE FROM instance.rest_spool
+			WHERE id = $1
+		`, c.id); err != nil {
+			return res, err
 		}
+	}
+	return res, tx.Commit(db.Ctx)
+}
 
-		if _, err := tx.Exec(db.Ctx, fmt.Sprintf(`SELECT "%s"."%s"($1,$2,$3)`,
-			mod.Name, fnc.Name), httpRes.StatusCode, bodyRaw, c.callbackValue); err != nil {
+// executes callback function with response of successful REST call, then removes call from spooler
+func callCallback(c restCall, res restResponse) error {
+	cache.Schema_mx.RLock()
+	fnc, exists := cache.PgFunctionIdMap[c.pgFunctionIdCallback.Bytes]
+	if !exists {
+		cache.Schema_mx.RUnlock()
+		return fmt.Errorf("unknown function '%s'", uuid.UUID(c.pgFunctionIdCallback.Bytes))
+	}
+	mod, exists := cache.ModuleIdMap[fnc.ModuleId]
+	cache.Schema_mx.RUnlock()
 
-			return err
-		}
+	if !exists {
+		return fmt.Errorf("unknown module '%s'", fnc.ModuleId)
+	}
+
+	// on retry, the stored response body is used
+	body := res.bodyRaw
+	if body == nil {
+		body = []byte(res.body.String)
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.Ctx)
+
+	if _, err := tx.Exec(db.Ctx, fmt.Sprintf(`SELECT "%s"."%s"($1,$2,$3)`,
+		mod.Name, fnc.Name), res.code.Int32, body, c.callbackValue); err != nil {
+
+		return err
 	}
 
-	// delete REST call from spooler
 	if _, err := tx.Exec(db.Ctx, `
 		DELETE FROM instance.rest_spool
 		WHERE id = $1
@@ -150,3 +359,119 @@ func callExecute(c restCall) error {
 	}
 	return tx.Commit(db.Ctx)
 }
+
+// stores failed callback of successful REST call as dead letter
+// on retry, only the callback is executed again - the request is not sent again
+func callbackFailed(c restCall, errCallback error) error {
+	_, err := db.Pool.Exec(db.Ctx, `
+		UPDATE instance.rest_spool
+		SET dead_letter = true, error = $1, claim_node_id = NULL, claim_until = 0
+		WHERE id = $2
+	`, fmt.Sprintf("request was successful, callback failed: %s", errCallback.Error()), c.id)
+	return err
+}
+
+// stores failed attempt with its response and schedules the next attempt
+// after the last allowed attempt, the call is kept as dead letter
+func callFailed(c restCall, res restResponse, errCall error, attemptsAllow int) error {
+	now := tools.GetTimeUnix()
+	attemptCount := c.attemptCount + 1
+	deadLetter := attemptCount >= attemptsAllow
+
+	if deadLetter {
+		log.Warning("api", fmt.Sprintf("REST call %s '%s' failed %d times, it is moved to dead letters",
+			c.method, c.url, attemptCount), errCall)
+	}
+
+	if _, err := db.Pool.Exec(db.Ctx, `
+		UPDATE instance.rest_spool
+		SET attempt_count = $1, date_attempt = $2, next_attempt = $3, dead_letter = $4,
+			response_code = $5, response_body = $6, error = $7, claim_node_id = NULL,
+			claim_until = 0
+		WHERE id = $8
+	`, attemptCount, now, now+getBackoffSeconds(attemptCount), deadLetter,
+		res.code, res.body, errCall.Error(), c.id); err != nil {
+
+		return err
+	}
+
+	// update webhook delivery history
+	if c.webhookDeliveryId.Valid {
+		if _, err := db.Pool.Exec(db.Ctx, `
+			UPDATE instance.webhook_delivery
+			SET attempt_count = attempt_count + 1, response_code = $1, error = $2
+			WHERE id = $3
+		`, res.code, errCall.Error(), c.webhookDeliveryId.Int64); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns timeout in seconds for REST call
+func getTimeout(c restCall) int64 {
+	if c.timeoutSeconds.Valid && c.timeoutSeconds.Int32 > 0 {
+		return int64(c.timeoutSeconds.Int32)
+	}
+	return int64(config.GetUint64("restTimeoutSeconds"))
+}
+
+// returns delay until next attempt, doubling with each failed attempt
+func getBackoffSeconds(attemptCount int) int64 {
+	base := float64(config.GetUint64("restBackoffSeconds"))
+	delay := base * math.Pow(2, float64(attemptCount-1))
+
+	if delay > float64(backoffMaxSeconds) {
+		return backoffMaxSeconds
+	}
+	return int64(delay)
+}
+
+// returns host of REST call target, used to apply limits per host
+func getHost(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Host == "" {
+		return rawUrl
+	}
+	return strings.ToLower(u.Host)
+}
+
+// returns response body for storage, limited in size and reduced to valid text
+func getResponseBodyText(body []byte) pgtype.Text {
+	if len(body) == 0 {
+		return pgtype.Text{}
+	}
+	if len(body) > responseBodyLimit {
+		body = body[:responseBodyLimit]
+	}
+	text := strings.ToValidUTF8(string(body), "")
+	text = strings.ReplaceAll(text, "\x00", "")
+	return pgtype.Text{String: text, Valid: true}
+}
+
+// checks whether status code is within comma separated list of codes or code ranges, such as '200-299,304'
+func isSuccessCode(codes string, code int) (bool, error) {
+	for _, part := range strings.Split(codes, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		min, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return false, fmt.Errorf("invalid success status codes '%s'", codes)
+		}
+		max := min
+		if isRange {
+			max, err = strconv.Atoi(strings.TrimSpace(to))
+			if err != nil {
+				return false, fmt.Errorf("invalid success status codes '%s'", codes)
+			}
+		}
+		if code >= min && code <= max {
+			return true, nil
+		}
+	}
+	return false, nil
+}
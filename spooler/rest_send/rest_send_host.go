@@ -0,0 +1,46 @@
+package rest_send
+
+import (
+	"sync"
+	"time"
+)
+
+// limits REST calls to a single target host
+// limits apply per node, in a cluster each node can reach the limits individually
+type hostLimiter struct {
+	interval time.Duration // min. time between starts of calls (0 = unlimited)
+	mx       sync.Mutex
+	nextCall time.Time     // earliest start of next call
+	slots    chan struct{} // concurrently running calls
+}
+
+func newHostLimiter(concurrency int, interval time.Duration) *hostLimiter {
+	return &hostLimiter{
+		interval: interval,
+		slots:    make(chan struct{}, concurrency),
+	}
+}
+
+// blocks until a call can be started, by concurrency & rate limit
+func (h *hostLimiter) acquire() {
+	h.slots <- struct{}{}
+
+	if h.interval == 0 {
+		return
+	}
+
+	h.mx.Lock()
+	now := time.Now()
+	if h.nextCall.Before(now) {
+		h.nextCall = now
+	}
+	wait := h.nextCall.Sub(now)
+	h.nextCall = h.nextCall.Add(h.interval)
+	h.mx.Unlock()
+
+	time.Sleep(wait)
+}
+
+func (h *hostLimiter) release() {
+	<-h.slots
+}
@@ -0,0 +1,33 @@
+package rest_send
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"r3/db"
+	"r3/tools"
+)
+
+// signs webhook delivery with secret of its webhook
+// signature covers timestamp and body, it is created for each attempt so that receivers can reject old deliveries
+func setWebhookSignature(httpReq *http.Request, c restCall) error {
+	var secret string
+	if err := db.Pool.QueryRow(db.Ctx, `
+		SELECT w.secret
+		FROM instance.webhook_delivery AS d
+		INNER JOIN instance.webhook    AS w ON w.id = d.webhook_id
+		WHERE d.id = $1
+	`, c.webhookDeliveryId.Int64).Scan(&secret); err != nil {
+		return fmt.Errorf("failed to read webhook secret, %s", err)
+	}
+
+	timestamp := fmt.Sprintf("%d", tools.GetTimeUnix())
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%s.%s", timestamp, c.body.String)))
+
+	httpReq.Header.Set("X-Webhook-Signature", fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil))))
+	httpReq.Header.Set("X-Webhook-Timestamp", timestamp)
+	return nil
+}
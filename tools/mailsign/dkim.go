@@ -0,0 +1,120 @@
+package mailsign
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// header fields to sign, if present in message
+	dkimFieldNames = []string{"from", "to", "cc", "reply-to", "subject", "date",
+		"message-id", "mime-version", "content-type", "content-transfer-encoding"}
+
+	regexWsp = regexp.MustCompile(`[ \t]+`)
+)
+
+// adds DKIM-Signature header field to message (RFC 6376, relaxed/relaxed canonicalization)
+// supports RSA (rsa-sha256) and Ed25519 (ed25519-sha256, RFC 8463) keys
+func SignDkim(msg []byte, domain string, selector string, key crypto.Signer) ([]byte, error) {
+
+	var algorithm string
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		algorithm = "rsa-sha256"
+	case ed25519.PrivateKey:
+		algorithm = "ed25519-sha256"
+	default:
+		return nil, fmt.Errorf("unsupported DKIM key type %T", key)
+	}
+
+	fields, body, err := splitMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect fields to sign, bottom-most instance is used if field exists multiple times
+	names := make([]string, 0)
+	values := make([]string, 0)
+	for _, name := range dkimFieldNames {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if getFieldName(fields[i]) == name {
+				names = append(names, name)
+				values = append(values, fields[i])
+				break
+			}
+		}
+	}
+	if !slices.Contains(names, "from") {
+		return nil, fmt.Errorf("cannot DKIM sign message without From header")
+	}
+
+	bodyHash := sha256.Sum256(getDkimBodyRelaxed(body))
+
+	signatureField := fmt.Sprintf("DKIM-Signature: %s", strings.Join([]string{
+		"v=1",
+		fmt.Sprintf("a=%s", algorithm),
+		"c=relaxed/relaxed",
+		fmt.Sprintf("d=%s", domain),
+		fmt.Sprintf("s=%s", selector),
+		fmt.Sprintf("t=%d", time.Now().Unix()),
+		fmt.Sprintf("h=%s", strings.Join(names, ":")),
+		fmt.Sprintf("bh=%s", base64.StdEncoding.EncodeToString(bodyHash[:])),
+		"b=",
+	}, ";\r\n\t"))
+
+	// signature covers signed fields and signature field itself (with empty signature value)
+	var signed strings.Builder
+	for _, v := range values {
+		signed.WriteString(getDkimFieldRelaxed(v))
+		signed.WriteString("\r\n")
+	}
+	signed.WriteString(getDkimFieldRelaxed(signatureField))
+	hash := sha256.Sum256([]byte(signed.String()))
+
+	var signature []byte
+	if algorithm == "ed25519-sha256" {
+		signature, err = key.Sign(rand.Reader, hash[:], crypto.Hash(0))
+	} else {
+		signature, err = key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signatureField = fmt.Sprintf("%s%s", signatureField, base64.StdEncoding.EncodeToString(signature))
+	return joinMessage(append([]string{signatureField}, fields...), body), nil
+}
+
+// relaxed header canonicalization (RFC 6376, 3.4.2)
+func getDkimFieldRelaxed(field string) string {
+	pos := strings.Index(field, ":")
+	value := strings.ReplaceAll(field[pos+1:], "\r\n", "")
+	value = strings.TrimSpace(regexWsp.ReplaceAllString(value, " "))
+	return fmt.Sprintf("%s:%s", getFieldName(field), value)
+}
+
+// relaxed body canonicalization (RFC 6376, 3.4.4)
+func getDkimBodyRelaxed(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(regexWsp.ReplaceAllString(line, " "), " ")
+	}
+
+	// remove empty lines at end of body
+	for len(lines) != 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
@@ -0,0 +1,177 @@
+package mailsign
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var testMessage = []byte("From: Sender <sender@example.com>\r\n" +
+	"To: someone@example.org\r\n" +
+	"Subject:  Test   message\r\n" +
+	"Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n" +
+	"Message-ID: <test@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=UTF-8\r\n" +
+	"\r\n" +
+	"Hello  world, \r\n" +
+	"second line\r\n" +
+	"\r\n" +
+	"\r\n")
+
+func TestSignDkimRsa(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSignDkim(t, key, key.Public())
+}
+
+func TestSignDkimEd25519(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSignDkim(t, key, pub)
+}
+
+func testSignDkim(t *testing.T, key crypto.Signer, pub crypto.PublicKey) {
+	msg, err := SignDkim(testMessage, "example.com", "mail", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyDkim(msg, pub); err != nil {
+		t.Fatalf("failed to verify signed message, %s", err)
+	}
+
+	// relaxed canonicalization tolerates whitespace changes in transit
+	relaxed := strings.Replace(string(msg), "Subject:  Test   message", "Subject: Test message", 1)
+	if err := verifyDkim([]byte(relaxed), pub); err != nil {
+		t.Fatalf("failed to verify message with changed whitespace, %s", err)
+	}
+
+	// changes to signed header or body must fail verification
+	tampered := strings.Replace(string(msg), "Test   message", "Other message", 1)
+	if err := verifyDkim([]byte(tampered), pub); err == nil {
+		t.Fatal("verified message with changed subject")
+	}
+	tampered = strings.Replace(string(msg), "second line", "third line", 1)
+	if err := verifyDkim([]byte(tampered), pub); err == nil {
+		t.Fatal("verified message with changed body")
+	}
+}
+
+// verifies first DKIM-Signature header of message as a receiving server would (RFC 6376, 6.1)
+func verifyDkim(msg []byte, pub crypto.PublicKey) error {
+	parts := strings.SplitN(string(msg), "\r\n\r\n", 2)
+	if len(parts) != 2 {
+		return errors.New("message has no header/body separator")
+	}
+
+	// unfold header fields
+	fields := make([]string, 0)
+	for _, line := range strings.Split(parts[0], "\r\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	if !strings.HasPrefix(strings.ToLower(fields[0]), "dkim-signature:") {
+		return errors.New("no DKIM-Signature header found")
+	}
+	signatureField := fields[0]
+
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(signatureField[strings.Index(signatureField, ":")+1:], ";") {
+		name, value, _ := strings.Cut(tag, "=")
+		tags[strings.TrimSpace(name)] = strings.Join(strings.Fields(value), "")
+	}
+	if tags["v"] != "1" || tags["c"] != "relaxed/relaxed" {
+		return fmt.Errorf("unexpected signature tags v=%s, c=%s", tags["v"], tags["c"])
+	}
+
+	// body hash
+	lines := strings.Split(parts[1], "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(collapseWsp(line), " ")
+	}
+	for len(lines) != 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	body := ""
+	if len(lines) != 0 {
+		body = strings.Join(lines, "\r\n") + "\r\n"
+	}
+	bodyHash := sha256.Sum256([]byte(body))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != tags["bh"] {
+		return errors.New("body hash does not match")
+	}
+
+	// header hash, signed fields are taken bottom-up, signature field with empty b= last
+	relaxed := func(field string) string {
+		name, value, _ := strings.Cut(field, ":")
+		value = strings.ReplaceAll(value, "\r\n", "")
+		return fmt.Sprintf("%s:%s", strings.ToLower(strings.TrimSpace(name)),
+			strings.TrimSpace(collapseWsp(value)))
+	}
+	var signed strings.Builder
+	used := make(map[int]bool)
+	for _, name := range strings.Split(tags["h"], ":") {
+		for i := len(fields) - 1; i > 0; i-- {
+			if !used[i] && strings.EqualFold(strings.TrimSpace(strings.SplitN(fields[i], ":", 2)[0]), name) {
+				signed.WriteString(relaxed(fields[i]) + "\r\n")
+				used[i] = true
+				break
+			}
+		}
+	}
+	signed.WriteString(relaxed(signatureField[:strings.LastIndex(signatureField, "b=")+2]))
+	hash := sha256.Sum256([]byte(signed.String()))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return err
+	}
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if tags["a"] != "rsa-sha256" {
+			return fmt.Errorf("unexpected algorithm '%s'", tags["a"])
+		}
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], signature)
+	case ed25519.PublicKey:
+		if tags["a"] != "ed25519-sha256" {
+			return fmt.Errorf("unexpected algorithm '%s'", tags["a"])
+		}
+		if !ed25519.Verify(k, hash[:], signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported key type %T", pub)
+}
+
+// reduces sequences of spaces and tabs to a single space
+func collapseWsp(s string) string {
+	var b strings.Builder
+	inWsp := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' {
+			if !inWsp {
+				b.WriteRune(' ')
+			}
+			inWsp = true
+			continue
+		}
+		inWsp = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
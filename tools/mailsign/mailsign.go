@@ -0,0 +1,131 @@
+package mailsign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// parses PEM encoded private key (PKCS#8, PKCS#1 or SEC 1)
+func ParsePrivateKey(pemData []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case *ecdsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		}
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("failed to parse private key")
+}
+
+// parses first certificate from PEM encoded data
+func ParseCertificate(pemData []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			return nil, errors.New("no PEM encoded certificate found")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+func LoadPrivateKey(filePath string) (crypto.Signer, error) {
+	pemData, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(pemData)
+}
+func LoadCertificate(filePath string) (*x509.Certificate, error) {
+	pemData, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCertificate(pemData)
+}
+
+// helpers
+// splits message into header fields (unparsed, may be folded) and body
+func splitMessage(msg []byte) ([]string, []byte, error) {
+	pos := bytes.Index(msg, []byte("\r\n\r\n"))
+	if pos == -1 {
+		return nil, nil, errors.New("message has no header/body separator")
+	}
+
+	fields := make([]string, 0)
+	for _, line := range strings.Split(string(msg[:pos]), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(fields) == 0 {
+				return nil, nil, errors.New("message starts with folded header line")
+			}
+			fields[len(fields)-1] = fmt.Sprintf("%s\r\n%s", fields[len(fields)-1], line)
+			continue
+		}
+		if !strings.Contains(line, ":") {
+			return nil, nil, fmt.Errorf("invalid header line '%s'", line)
+		}
+		fields = append(fields, line)
+	}
+	return fields, msg[pos+4:], nil
+}
+
+// joins header fields and body into message
+func joinMessage(fields []string, body []byte) []byte {
+	var buf bytes.Buffer
+	for _, f := range fields {
+		buf.WriteString(f)
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func getFieldName(field string) string {
+	return strings.ToLower(strings.TrimSpace(field[:strings.Index(field, ":")]))
+}
+
+// returns base64 encoded data, split into lines of 76 characters
+func getBase64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
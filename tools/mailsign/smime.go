@@ -0,0 +1,333 @@
+package mailsign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	oidData              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidAttrContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidRsaEncryption     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidEcdsaWithSha256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSha256            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidAes256Cbc         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// CMS structures (RFC 5652)
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT
+}
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+type encapsulatedContentInfo struct {
+	ContentType asn1.ObjectIdentifier // content is detached
+}
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue // SET OF
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue // [0] IMPLICIT SET OF
+	SignerInfos      asn1.RawValue // SET OF
+}
+type signerInfo struct {
+	Version            int
+	Sid                issuerAndSerialNumber
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue // [0] IMPLICIT SET OF
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue // SET OF
+}
+type envelopedData struct {
+	Version              int
+	RecipientInfos       asn1.RawValue // SET OF
+	EncryptedContentInfo encryptedContentInfo
+}
+type keyTransRecipientInfo struct {
+	Version                int
+	Rid                    issuerAndSerialNumber
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue // [0] IMPLICIT
+}
+
+// signs message with S/MIME certificate as multipart/signed with detached signature (RFC 8551)
+// supports RSA and ECDSA keys
+func SignSmime(msg []byte, cert *x509.Certificate, key crypto.Signer) ([]byte, error) {
+
+	if pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(cert.PublicKey) {
+		return nil, errors.New("S/MIME certificate does not match private key")
+	}
+
+	var signatureAlgorithm pkix.AlgorithmIdentifier
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		signatureAlgorithm = pkix.AlgorithmIdentifier{Algorithm: oidRsaEncryption, Parameters: asn1.NullRawValue}
+	case *ecdsa.PrivateKey:
+		signatureAlgorithm = pkix.AlgorithmIdentifier{Algorithm: oidEcdsaWithSha256}
+	default:
+		return nil, fmt.Errorf("unsupported S/MIME key type %T", key)
+	}
+
+	fields, entity, err := getSmimeEntity(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// signed attributes
+	digest := sha256.Sum256(entity)
+	attrs := make([][]byte, 0)
+	for _, a := range []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oidAttrContentType, oidData},
+		{oidAttrSigningTime, time.Now().UTC()},
+		{oidAttrMessageDigest, digest[:]},
+	} {
+		value, err := asn1.Marshal(a.value)
+		if err != nil {
+			return nil, err
+		}
+		attr, err := asn1.Marshal(attribute{Type: a.oid, Values: getDerSet(value)})
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+
+	// signature is calculated over DER encoded SET OF signed attributes
+	attrsSet, err := asn1.Marshal(getDerSet(attrs...))
+	if err != nil {
+		return nil, err
+	}
+	attrsHash := sha256.Sum256(attrsSet)
+	signature, err := key.Sign(rand.Reader, attrsHash[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	digestAlgorithm := pkix.AlgorithmIdentifier{Algorithm: oidSha256, Parameters: asn1.NullRawValue}
+	signer, err := asn1.Marshal(signerInfo{
+		Version: 1,
+		Sid: issuerAndSerialNumber{
+			Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+			SerialNumber: cert.SerialNumber,
+		},
+		DigestAlgorithm: digestAlgorithm,
+		SignedAttrs: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0,
+			IsCompound: true, Bytes: getDerSet(attrs...).Bytes},
+		SignatureAlgorithm: signatureAlgorithm,
+		Signature:          signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+	digestAlgorithmDer, err := asn1.Marshal(digestAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	signedDer, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: getDerSet(digestAlgorithmDer),
+		EncapContentInfo: encapsulatedContentInfo{ContentType: oidData},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0,
+			IsCompound: true, Bytes: cert.Raw},
+		SignerInfos: getDerSet(signer),
+	})
+	if err != nil {
+		return nil, err
+	}
+	p7s, err := getContentInfo(oidSignedData, signedDer)
+	if err != nil {
+		return nil, err
+	}
+
+	// build multipart/signed body, first part is signed entity as is
+	boundary, err := getBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	body.WriteString("This is a cryptographically signed message in MIME format.\r\n\r\n")
+	body.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+	body.Write(entity)
+	body.WriteString(fmt.Sprintf("\r\n--%s\r\n", boundary))
+	body.WriteString("Content-Type: application/pkcs7-signature; name=\"smime.p7s\"\r\n")
+	body.WriteString("Content-Transfer-Encoding: base64\r\n")
+	body.WriteString("Content-Disposition: attachment; filename=\"smime.p7s\"\r\n\r\n")
+	body.Write(getBase64Lines(p7s))
+	body.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+
+	return joinMessage(append(fields, fmt.Sprintf("Content-Type: multipart/signed; "+
+		"protocol=\"application/pkcs7-signature\"; micalg=sha-256;\r\n boundary=\"%s\"",
+		boundary)), body.Bytes()), nil
+}
+
+// encrypts message for given recipient certificates as application/pkcs7-mime (RFC 8551)
+// content is encrypted with AES-256-CBC, content key is transported with RSA
+func EncryptSmime(msg []byte, certs []*x509.Certificate) ([]byte, error) {
+
+	if len(certs) == 0 {
+		return nil, errors.New("cannot encrypt message without recipient certificates")
+	}
+
+	fields, entity, err := getSmimeEntity(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// encrypt content with random key, PKCS#7 padding
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	padding := aes.BlockSize - len(entity)%aes.BlockSize
+	content := append(entity, bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := make([]byte, len(content))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, content)
+
+	// encrypt content key for each recipient
+	recipients := make([][]byte, 0)
+	for _, cert := range certs {
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported S/MIME recipient key type %T for '%s'",
+				cert.PublicKey, cert.Subject.CommonName)
+		}
+		encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+		if err != nil {
+			return nil, err
+		}
+		recipient, err := asn1.Marshal(keyTransRecipientInfo{
+			Version: 0,
+			Rid: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRsaEncryption, Parameters: asn1.NullRawValue},
+			EncryptedKey:           encryptedKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	ivDer, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	envelopedDer, err := asn1.Marshal(envelopedData{
+		Version:        0,
+		RecipientInfos: getDerSet(recipients...),
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidAes256Cbc, Parameters: asn1.RawValue{FullBytes: ivDer}},
+			EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: encrypted},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	p7m, err := getContentInfo(oidEnvelopedData, envelopedDer)
+	if err != nil {
+		return nil, err
+	}
+
+	return joinMessage(append(fields,
+		"Content-Type: application/pkcs7-mime; smime-type=enveloped-data; name=\"smime.p7m\"",
+		"Content-Transfer-Encoding: base64",
+		"Content-Disposition: attachment; filename=\"smime.p7m\""),
+		getBase64Lines(p7m)), nil
+}
+
+// helpers
+// splits message into outer header fields and MIME entity (content header fields & body) to sign/encrypt
+func getSmimeEntity(msg []byte) ([]string, []byte, error) {
+	fields, body, err := splitMessage(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fieldsOuter := make([]string, 0)
+	fieldsContent := make([]string, 0)
+	for _, f := range fields {
+		if strings.HasPrefix(getFieldName(f), "content-") {
+			fieldsContent = append(fieldsContent, f)
+		} else {
+			fieldsOuter = append(fieldsOuter, f)
+		}
+	}
+	if len(fieldsContent) == 0 {
+		fieldsContent = append(fieldsContent, "Content-Type: text/plain; charset=us-ascii")
+	}
+	return fieldsOuter, joinMessage(fieldsContent, body), nil
+}
+
+func getContentInfo(contentType asn1.ObjectIdentifier, content []byte) ([]byte, error) {
+	return asn1.Marshal(contentInfo{
+		ContentType: contentType,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+	})
+}
+
+// returns DER SET OF from encoded elements, sorted as required by DER
+func getDerSet(elements ...[]byte) asn1.RawValue {
+	sort.Slice(elements, func(i, j int) bool {
+		return bytes.Compare(elements[i], elements[j]) < 0
+	})
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet,
+		IsCompound: true, Bytes: bytes.Join(elements, nil)}
+}
+
+func getBoundary() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
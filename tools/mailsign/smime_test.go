@@ -0,0 +1,268 @@
+package mailsign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignSmime(t *testing.T) {
+	cert, key := getTestCertificate(t, "sender@example.com")
+
+	msg, err := SignSmime(testMessage, cert, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, body, err := splitMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// outer header fields are kept, content fields are replaced with multipart/signed
+	contentType := ""
+	for _, f := range fields {
+		switch getFieldName(f) {
+		case "subject":
+			if f != "Subject:  Test   message" {
+				t.Fatalf("subject changed to '%s'", f)
+			}
+		case "content-type":
+			contentType = strings.TrimSpace(strings.ReplaceAll(f[len("Content-Type:"):], "\r\n", ""))
+		}
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/signed" || params["protocol"] != "application/pkcs7-signature" {
+		t.Fatalf("unexpected content type '%s'", contentType)
+	}
+
+	// first part is the signed entity as is, second part the detached signature
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	entityPart, err := reader.NextRawPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entityPart.Header.Get("Content-Type") != "text/plain; charset=UTF-8" {
+		t.Fatalf("unexpected entity content type '%s'", entityPart.Header.Get("Content-Type"))
+	}
+	signaturePart, err := reader.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signatureB64 bytes.Buffer
+	signatureB64.ReadFrom(signaturePart)
+	p7s, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(signatureB64.String(), "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signed entity is the raw first part, including its header fields
+	entity := body[bytes.Index(body, []byte("Content-Type: text/plain")):]
+	entity = entity[:bytes.Index(entity, []byte("\r\n--"+params["boundary"]))]
+
+	if err := verifySmime(entity, p7s, cert); err != nil {
+		t.Fatalf("failed to verify signature, %s", err)
+	}
+	if err := verifySmime(append(entity, '!'), p7s, cert); err == nil {
+		t.Fatal("verified signature of changed entity")
+	}
+}
+
+func TestEncryptSmime(t *testing.T) {
+	cert1, key1 := getTestCertificate(t, "someone@example.org")
+	cert2, key2 := getTestCertificate(t, "other@example.org")
+	_, keyOther := getTestCertificate(t, "outsider@example.org")
+
+	msg, err := EncryptSmime(testMessage, []*x509.Certificate{cert1, cert2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, body, err := splitMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7m, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(body), "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(getSmimeRecipientSerials(t, p7m)); n != 2 {
+		t.Fatalf("expected 2 recipient infos, got %d", n)
+	}
+
+	// every recipient can decrypt the original entity, others cannot
+	for _, key := range []*rsa.PrivateKey{key1, key2} {
+		entity, err := decryptSmime(p7m, key)
+		if err != nil {
+			t.Fatalf("failed to decrypt message, %s", err)
+		}
+		if !bytes.Contains(entity, []byte("Content-Type: text/plain; charset=UTF-8\r\n\r\nHello  world, \r\n")) {
+			t.Fatalf("unexpected decrypted entity '%s'", entity)
+		}
+	}
+	if _, err := decryptSmime(p7m, keyOther); err == nil {
+		t.Fatal("decrypted message without being recipient")
+	}
+}
+
+// verifies detached CMS signature of entity with signer certificate
+func verifySmime(entity []byte, p7s []byte, cert *x509.Certificate) error {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(p7s, &ci); err != nil {
+		return err
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return errors.New("not signed data")
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return err
+	}
+	var si signerInfo
+	if _, err := asn1.Unmarshal(sd.SignerInfos.Bytes, &si); err != nil {
+		return err
+	}
+	if si.Sid.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		return errors.New("signer does not match certificate")
+	}
+
+	// message digest attribute must match entity
+	digest := sha256.Sum256(entity)
+	rest := si.SignedAttrs.Bytes
+	found := false
+	for len(rest) != 0 {
+		var a attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+			return err
+		}
+		if a.Type.Equal(oidAttrMessageDigest) {
+			var value []byte
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &value); err != nil {
+				return err
+			}
+			if !bytes.Equal(value, digest[:]) {
+				return errors.New("message digest does not match")
+			}
+			found = true
+		}
+	}
+	if !found {
+		return errors.New("message digest attribute missing")
+	}
+
+	// signature covers signed attributes as SET OF
+	attrs := si.SignedAttrs.FullBytes
+	attrs = append([]byte{0x31}, attrs[1:]...)
+	hash := sha256.Sum256(attrs)
+	return rsa.VerifyPKCS1v15(cert.PublicKey.(*rsa.PublicKey), crypto.SHA256, hash[:], si.Signature)
+}
+
+// decrypts CMS enveloped data with recipient key
+func decryptSmime(p7m []byte, key *rsa.PrivateKey) ([]byte, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(p7m, &ci); err != nil {
+		return nil, err
+	}
+	var ed envelopedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		return nil, err
+	}
+
+	var contentKey []byte
+	rest := ed.RecipientInfos.Bytes
+	for len(rest) != 0 && contentKey == nil {
+		var ri keyTransRecipientInfo
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &ri); err != nil {
+			return nil, err
+		}
+		contentKey, _ = rsa.DecryptPKCS1v15(rand.Reader, key, ri.EncryptedKey)
+	}
+	if len(contentKey) != 32 {
+		return nil, errors.New("no recipient info for key")
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(ed.EncryptedContentInfo.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := ed.EncryptedContentInfo.EncryptedContent.Bytes
+	content := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(content, encrypted)
+	return content[:len(content)-int(content[len(content)-1])], nil
+}
+
+// returns serial numbers of recipient certificates in CMS enveloped data
+func getSmimeRecipientSerials(t *testing.T, p7m []byte) []*big.Int {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(p7m, &ci); err != nil {
+		t.Fatal(err)
+	}
+	var ed envelopedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		t.Fatal(err)
+	}
+	serials := make([]*big.Int, 0)
+	rest := ed.RecipientInfos.Bytes
+	for len(rest) != 0 {
+		var ri keyTransRecipientInfo
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &ri); err != nil {
+			t.Fatal(err)
+		}
+		serials = append(serials, ri.Rid.SerialNumber)
+	}
+	return serials
+}
+
+// returns self-signed RSA certificate for mail address
+func getTestCertificate(t *testing.T, address string) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:   serial,
+		Subject:        pkix.Name{CommonName: address},
+		EmailAddresses: []string{address},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
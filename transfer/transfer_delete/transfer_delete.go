@@ -17,6 +17,7 @@ import (
 	"r3/schema/icon"
 	"r3/schema/jsFunction"
 	"r3/schema/loginForm"
+	"r3/schema/mailTemplate"
 	"r3/schema/menu"
 	"r3/schema/pgFunction"
 	"r3/schema/pgIndex"
@@ -110,6 +111,11 @@ func NotExisting_tx(tx pgx.Tx, module types.Module) error {
 		return err
 	}
 
+	// mail templates
+	if err := deleteMailTemplates_tx(tx, module.Id, module.MailTemplates); err != nil {
+		return err
+	}
+
 	// JS functions
 	if err := deleteJsFunctions_tx(tx, module.Id, module.JsFunctions); err != nil {
 		return err
@@ -534,6 +540,23 @@ func deleteWidgets_tx(tx pgx.Tx, moduleId uuid.UUID, widgets []types.Widget) err
 	}
 	return nil
 }
+func deleteMailTemplates_tx(tx pgx.Tx, moduleId uuid.UUID, mailTemplates []types.MailTemplate) error {
+	idsKeep := make([]uuid.UUID, 0)
+	for _, entity := range mailTemplates {
+		idsKeep = append(idsKeep, entity.Id)
+	}
+	idsDelete, err := importGetIdsToDeleteFromModule_tx(tx, "mail_template", moduleId, idsKeep)
+	if err != nil {
+		return err
+	}
+	for _, id := range idsDelete {
+		log.Info("transfer", fmt.Sprintf("del mail template %s", id.String()))
+		if err := mailTemplate.Del_tx(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 func deletePgFunctions_tx(tx pgx.Tx, moduleId uuid.UUID, pgFunctions []types.PgFunction) error {
 	idsKeep := make([]uuid.UUID, 0)
 	for _, entity := range pgFunctions {
@@ -576,8 +599,8 @@ func importGetIdsToDeleteFromModule_tx(tx pgx.Tx, entity string,
 	idsDelete := make([]uuid.UUID, 0)
 
 	if !slices.Contains([]string{"api", "article", "client_event", "collection",
-		"form", "icon", "js_function", "login_form", "menu", "pg_function",
-		"pg_trigger", "relation", "role", "widget"}, entity) {
+		"form", "icon", "js_function", "login_form", "mail_template", "menu",
+		"pg_function", "pg_trigger", "relation", "role", "widget"}, entity) {
 
 		return idsDelete, errors.New("unsupported type for delete check")
 	}
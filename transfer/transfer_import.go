@@ -24,6 +24,7 @@ import (
 	"r3/schema/icon"
 	"r3/schema/jsFunction"
 	"r3/schema/loginForm"
+	"r3/schema/mailTemplate"
 	"r3/schema/menu"
 	"r3/schema/module"
 	"r3/schema/pgFunction"
@@ -350,6 +351,22 @@ func importModule_tx(tx pgx.Tx, mod types.Module, firstRun bool, lastRun bool,
 		}
 	}
 
+	// mail templates, refer to relations
+	for _, e := range mod.MailTemplates {
+		run, err := importCheckRunAndSave(tx, firstRun, e.Id, idMapSkipped)
+		if err != nil {
+			return err
+		}
+		if !run {
+			continue
+		}
+		log.Info("transfer", fmt.Sprintf("set mail template %s", e.Id))
+
+		if err := importCheckResultAndApply(tx, mailTemplate.Set_tx(tx, e), e.Id, idMapSkipped); err != nil {
+			return err
+		}
+	}
+
 	// PG functions, refer to relations/attributes/pg_functions (self reference)
 	for _, e := range mod.PgFunctions {
 		run, err := importCheckRunAndSave(tx, firstRun, e.Id, idMapSkipped)
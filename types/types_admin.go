@@ -28,11 +28,16 @@ type LoginAdmin struct {
 	LdapId           pgtype.Int4        `json:"ldapId"`
 	LdapKey          pgtype.Text        `json:"ldapKey"`
 	Name             string             `json:"name"`
+	Mail             pgtype.Text        `json:"mail"` // mail address, used for password reset links
 	Active           bool               `json:"active"`
 	Admin            bool               `json:"admin"`
 	NoAuth           bool               `json:"noAuth"`
 	LanguageCode     string             `json:"languageCode"`
 	TokenExpiryHours pgtype.Int4        `json:"tokenExpiryHours"`
+	DateLockedUntil  pgtype.Int8        `json:"dateLockedUntil"`  // login is locked after failed authentication attempts, empty if not locked
+	DatePwChange     pgtype.Int8        `json:"datePwChange"`     // date of last password change, empty if login has no local password
+	PwChangeRequired bool               `json:"pwChangeRequired"` // password must be changed on next authentication
+	PwExpired        bool               `json:"pwExpired"`        // password has exceeded maximum age
 	Records          []LoginAdminRecord `json:"records"`
 	RoleIds          []uuid.UUID        `json:"roleIds"`
 }
@@ -56,30 +61,47 @@ type LoginTemplateAdmin struct {
 }
 
 type Ldap struct {
-	Id              int32       `json:"id"`
-	LoginTemplateId pgtype.Int8 `json:"loginTemplateId"` // template for new logins (applies login settings)
-	Name            string      `json:"name"`
-	Host            string      `json:"host"`
-	Port            int         `json:"port"`
-	BindUserDn      string      `json:"bindUserDn"`      // DN of bind user, example: 'CN=readonly,OU=User,DC=test,DC=local'
-	BindUserPw      string      `json:"bindUserPw"`      // password of bind user in clear text
-	SearchClass     string      `json:"searchClass"`     // object class to filter to, example: '(&(objectClass=user))'
-	SearchDn        string      `json:"searchDn"`        // root search DN, example: 'OU=User,DC=test,DC=local'
-	KeyAttribute    string      `json:"keyAttribute"`    // name of attribute used as key, example: 'objectGUID'
-	LoginAttribute  string      `json:"loginAttribute"`  // name of attribute used as login, example: 'sAMAccountName'
-	MemberAttribute string      `json:"memberAttribute"` // name of attribute used as membership, example: 'memberOf'
-	AssignRoles     bool        `json:"assignRoles"`     // assign roles from group membership (see member attribute)
-	MsAdExt         bool        `json:"msAdExt"`         // Microsoft AD extensions (nested group memberships, user account control)
-	Starttls        bool        `json:"starttls"`        // upgrade unencrypted LDAP connection with TLS (STARTTLS)
-	Tls             bool        `json:"tls"`             // connect to LDAP via SSL/TLS (LDAPS)
-	TlsVerify       bool        `json:"tlsVerify"`       // verify TLS connection, can be used to allow non-trusted certificates
-	Roles           []LdapRole  `json:"roles"`
+	Id              int32           `json:"id"`
+	LoginTemplateId pgtype.Int8     `json:"loginTemplateId"` // template for new logins (applies login settings)
+	Name            string          `json:"name"`
+	Host            string          `json:"host"`
+	Port            int             `json:"port"`
+	BindUserDn      string          `json:"bindUserDn"`      // DN of bind user, example: 'CN=readonly,OU=User,DC=test,DC=local'
+	BindUserPw      string          `json:"bindUserPw"`      // password of bind user in clear text
+	SearchClass     string          `json:"searchClass"`     // object class to filter to, example: '(&(objectClass=user))'
+	SearchDn        string          `json:"searchDn"`        // root search DN, example: 'OU=User,DC=test,DC=local'
+	KeyAttribute    string          `json:"keyAttribute"`    // name of attribute used as key, example: 'objectGUID'
+	LoginAttribute  string          `json:"loginAttribute"`  // name of attribute used as login, example: 'sAMAccountName'
+	MemberAttribute string          `json:"memberAttribute"` // name of attribute used as membership, example: 'memberOf'
+	AssignRoles     bool            `json:"assignRoles"`     // assign roles from group membership (see member attribute)
+	MsAdExt         bool            `json:"msAdExt"`         // Microsoft AD extensions (nested group memberships, user account control)
+	Starttls        bool            `json:"starttls"`        // upgrade unencrypted LDAP connection with TLS (STARTTLS)
+	Tls             bool            `json:"tls"`             // connect to LDAP via SSL/TLS (LDAPS)
+	TlsVerify       bool            `json:"tlsVerify"`       // verify TLS connection, can be used to allow non-trusted certificates
+	LoginFormId     pgtype.UUID     `json:"loginFormId"`     // login form, its relation receives records with mapped attribute values
+	SyncIncremental bool            `json:"syncIncremental"` // import only entries changed since last run (uSNChanged for MS AD, modifyTimestamp otherwise)
+	SyncFullDays    int             `json:"syncFullDays"`    // interval for full reconciliation in days, deactivates logins removed from directory
+	SyncMark        pgtype.Text     `json:"syncMark"`        // read only, high-water mark of last import (highest uSNChanged or modifyTimestamp)
+	DateSyncFull    pgtype.Int8     `json:"dateSyncFull"`    // read only, date of last full reconciliation
+	Attributes      []LdapAttribute `json:"attributes"`
+	Roles           []LdapRole      `json:"roles"`
+	Templates       []LdapTemplate  `json:"templates"`
+}
+type LdapAttribute struct {
+	LdapId        int32     `json:"ldapId"`
+	AttributeId   uuid.UUID `json:"attributeId"`   // attribute of login form relation, receives value
+	LdapAttribute string    `json:"ldapAttribute"` // name of LDAP attribute to read value from, example: 'department'
 }
 type LdapRole struct {
 	LdapId  int32     `json:"ldapId"`
 	RoleId  uuid.UUID `json:"roleId"`
 	GroupDn string    `json:"groupDn"`
 }
+type LdapTemplate struct {
+	LdapId          int32  `json:"ldapId"`
+	LoginTemplateId int64  `json:"loginTemplateId"`
+	GroupDn         string `json:"groupDn"` // first matching group DN selects template for new logins
+}
 type OauthClient struct {
 	Id           int32    `json:"id"`
 	Name         string   `json:"name"`
@@ -90,3 +112,101 @@ type OauthClient struct {
 	Tenant       string   `json:"tenant"`
 	TokenUrl     string   `json:"tokenUrl"`
 }
+type Oidc struct {
+	Id              int32       `json:"id"`
+	LoginTemplateId pgtype.Int8 `json:"loginTemplateId"` // template for new logins (applies login settings)
+	Name            string      `json:"name"`
+	IssuerUrl       string      `json:"issuerUrl"`     // issuer to discover endpoints from, example: 'https://idp.local/realms/company'
+	ClientId        string      `json:"clientId"`      // client ID registered at identity provider
+	ClientSecret    string      `json:"clientSecret"`  // client secret, empty for public clients
+	Scopes          []string    `json:"scopes"`        // requested scopes, example: ['openid','profile','email']
+	ClaimUsername   string      `json:"claimUsername"` // name of ID token claim used as login, example: 'preferred_username'
+	ClaimRoles      string      `json:"claimRoles"`    // name of ID token claim with group/role memberships, example: 'groups'
+	AssignRoles     bool        `json:"assignRoles"`   // assign roles from claim values (see claim roles)
+	Active          bool        `json:"active"`
+	Roles           []OidcRole  `json:"roles"`
+}
+type OidcRole struct {
+	OidcId     int32     `json:"oidcId"`
+	RoleId     uuid.UUID `json:"roleId"`
+	ClaimValue string    `json:"claimValue"`
+}
+type Saml struct {
+	Id                int32       `json:"id"`
+	LoginTemplateId   pgtype.Int8 `json:"loginTemplateId"` // template for new logins (applies login settings)
+	Name              string      `json:"name"`
+	IdpEntityId       string      `json:"idpEntityId"`       // entity ID of identity provider, expected as issuer of assertions
+	IdpSsoUrl         string      `json:"idpSsoUrl"`         // single sign-on URL of identity provider (HTTP-Redirect binding)
+	IdpCertificate    string      `json:"idpCertificate"`    // PEM certificate of identity provider, to verify assertion signatures
+	SpCertificate     string      `json:"spCertificate"`     // PEM certificate of service provider, published in metadata
+	SpPrivateKey      string      `json:"-"`                 // PEM private key of service provider, to sign authentication requests
+	AttributeUsername string      `json:"attributeUsername"` // name of assertion attribute used as login, NameID if empty
+	AttributeGroups   string      `json:"attributeGroups"`   // name of assertion attribute with group memberships, example: 'groups'
+	AssignRoles       bool        `json:"assignRoles"`       // assign roles from group membership (see attribute groups)
+	Active            bool        `json:"active"`
+	Roles             []SamlRole  `json:"roles"`
+}
+type SamlRole struct {
+	SamlId    int32     `json:"samlId"`
+	RoleId    uuid.UUID `json:"roleId"`
+	GroupName string    `json:"groupName"`
+}
+type Scim struct {
+	Id              int32       `json:"id"`
+	LoginTemplateId pgtype.Int8 `json:"loginTemplateId"` // template for new logins (applies login settings)
+	Name            string      `json:"name"`
+	HasToken        bool        `json:"hasToken"`    // read only, bearer token was generated (only its hash is stored)
+	AssignRoles     bool        `json:"assignRoles"` // assign roles from group membership (see roles)
+	Active          bool        `json:"active"`
+	Roles           []ScimRole  `json:"roles"`
+}
+type ScimRole struct {
+	ScimId    int32     `json:"scimId"`
+	RoleId    uuid.UUID `json:"roleId"`
+	GroupName string    `json:"groupName"` // display name of SCIM group
+}
+type RestSpool struct {
+	Id             uuid.UUID         `json:"id"`
+	PgFunctionId   pgtype.UUID       `json:"pgFunctionId"` // callback function
+	Method         string            `json:"method"`
+	Url            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	Body           pgtype.Text       `json:"body"`
+	SkipVerify     bool              `json:"skipVerify"`
+	DateAdded      int64             `json:"dateAdded"`
+	DateAttempt    int64             `json:"dateAttempt"` // date of last attempt
+	NextAttempt    int64             `json:"nextAttempt"` // earliest date of next attempt
+	AttemptCount   int               `json:"attemptCount"`
+	DeadLetter     bool              `json:"deadLetter"`     // attempt limit reached, call is not retried unless reset
+	TimeoutSeconds pgtype.Int4       `json:"timeoutSeconds"` // overwrites default timeout
+	SuccessCodes   pgtype.Text       `json:"successCodes"`   // overwrites default success status codes, such as '200-299,304'
+	ResponseCode   pgtype.Int4       `json:"responseCode"`   // status code of last response
+	ResponseBody   pgtype.Text       `json:"responseBody"`   // body of last response
+	Error          pgtype.Text       `json:"error"`          // error of last failed attempt
+	GroupKey       pgtype.Text       `json:"groupKey"`       // calls of the same group are executed in order
+	ClaimNodeId    pgtype.UUID       `json:"claimNodeId"`    // cluster node that claimed the call for execution
+	ClaimUntil     int64             `json:"claimUntil"`
+}
+type Webhook struct {
+	Id         int32     `json:"id"`
+	RelationId uuid.UUID `json:"relationId"` // relation whose record changes trigger the webhook
+	Name       string    `json:"name"`
+	Url        string    `json:"url"`        // URL to send payload to via POST
+	Secret     string    `json:"secret"`     // secret to sign payload with (HMAC-SHA256)
+	OnInsert   bool      `json:"onInsert"`   // trigger on record creation
+	OnUpdate   bool      `json:"onUpdate"`   // trigger on record update
+	OnDelete   bool      `json:"onDelete"`   // trigger on record deletion
+	SkipVerify bool      `json:"skipVerify"` // skip TLS verification of target
+	Active     bool      `json:"active"`
+}
+type WebhookDelivery struct {
+	Id           int64       `json:"id"`
+	WebhookId    int32       `json:"webhookId"`
+	Event        string      `json:"event"` // insert, update, delete
+	RecordId     int64       `json:"recordId"`
+	DateAdded    int64       `json:"dateAdded"`
+	DateDone     pgtype.Int8 `json:"dateDone"` // date of successful delivery
+	AttemptCount int         `json:"attemptCount"`
+	ResponseCode pgtype.Int4 `json:"responseCode"`
+	Error        pgtype.Text `json:"error"` // error of last failed attempt
+}
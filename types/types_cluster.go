@@ -61,7 +61,7 @@ type ClusterEventTarget struct {
 
 // cluster event to be processed by nodes and, in most cases, to be distributed to clients of cluster nodes
 type ClusterEvent struct {
-	Content string             `json:"content"` // collectionChanged, configChanged, kick, kickNoAdmin, renew, schemaLoading, schemaLoaded, ...
+	Content string             `json:"content"` // collectionChanged, configChanged, kick, kickNoAdmin, kickSession, renew, schemaLoading, schemaLoaded, ...
 	Payload interface{}        `json:"payload"` // content dependent payload
 	Target  ClusterEventTarget `json:"target"`  // target filter, to which clients this event is to be sent
 }
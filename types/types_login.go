@@ -34,6 +34,37 @@ type LoginRecord struct {
 	Id   int64  `json:"id"`   // ID of relation record
 	Name string `json:"name"` // name for relation record (based on lookup attribute)
 }
+type LoginSession struct {
+	Id           uuid.UUID `json:"id"` // session ID, used as JWT ID
+	LoginId      int64     `json:"loginId"`
+	LoginName    string    `json:"loginName"`
+	Address      string    `json:"address"`   // address of client, updated when session is used by websocket client
+	Device       string    `json:"device"`    // client device type (api, browser, fatClient)
+	UserAgent    string    `json:"userAgent"` // user agent of client, to identify device
+	DateCreate   int64     `json:"dateCreate"`
+	DateExpiry   int64     `json:"dateExpiry"`
+	DateLastSeen int64     `json:"dateLastSeen"`
+}
+type LoginSessionClient struct {
+	// client that a session is created for
+	Address   string
+	Device    string // api, browser, fatClient
+	UserAgent string
+}
+type LoginTokenApi struct {
+	// personal access token for REST API, token itself is only shown once on creation
+	Id          int64       `json:"id"`
+	LoginId     int64       `json:"loginId"`
+	LoginName   string      `json:"loginName"`
+	Name        string      `json:"name"`      // to identify token user/integration
+	ApiIds      []uuid.UUID `json:"apiIds"`    // APIs that token can access, empty = all APIs that login has access to
+	Methods     []string    `json:"methods"`   // allowed HTTP methods (DELETE, GET, POST - includes PATCH & PUT), empty = all
+	Addresses   []string    `json:"addresses"` // allowed client addresses or networks in CIDR notation, empty = all
+	DateCreate  int64       `json:"dateCreate"`
+	DateExpiry  int64       `json:"dateExpiry"`
+	DateUsed    pgtype.Int8 `json:"dateUsed"`    // date of last use
+	AddressUsed pgtype.Text `json:"addressUsed"` // client address of last use
+}
 type LoginTokenFixed struct {
 	Id         int64  `json:"id"`
 	Name       string `json:"name"`    // to identify token user/device
@@ -45,6 +76,45 @@ type LoginMfaToken struct {
 	Id   int64  `json:"id"`
 	Name string `json:"name"`
 }
+type LoginWebauthn struct {
+	Id         int64       `json:"id"`
+	Name       string      `json:"name"` // to identify authenticator
+	DateCreate int64       `json:"dateCreate"`
+	DateUsed   pgtype.Int8 `json:"dateUsed"` // date of last successful use
+}
+type LoginWebauthnAssertion struct {
+	// response of authenticator to navigator.credentials.get(), binary values are base64url encoded
+	CredentialId      string `json:"credentialId"`
+	AuthenticatorData string `json:"authenticatorData"`
+	ClientDataJson    string `json:"clientDataJson"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle"`
+}
+type LoginWebauthnCredential struct {
+	Type string `json:"type"` // always 'public-key'
+	Id   string `json:"id"`   // credential ID, base64url encoded
+}
+type LoginWebauthnCreationOptions struct {
+	// options for navigator.credentials.create(), binary values are base64url encoded
+	Challenge          string                    `json:"challenge"`
+	RpId               string                    `json:"rpId"`
+	RpName             string                    `json:"rpName"`
+	UserId             string                    `json:"userId"` // user handle, returned by authenticator on passwordless login
+	UserName           string                    `json:"userName"`
+	Algorithms         []int64                   `json:"algorithms"` // supported COSE algorithms
+	ExcludeCredentials []LoginWebauthnCredential `json:"excludeCredentials"`
+	ResidentKey        string                    `json:"residentKey"`      // discoverable credentials are required for passwordless login
+	UserVerification   string                    `json:"userVerification"` // required, preferred, discouraged
+	Timeout            int                       `json:"timeout"`          // in milliseconds
+}
+type LoginWebauthnRequestOptions struct {
+	// options for navigator.credentials.get(), binary values are base64url encoded
+	Challenge        string                    `json:"challenge"`
+	RpId             string                    `json:"rpId"`
+	AllowCredentials []LoginWebauthnCredential `json:"allowCredentials"` // empty for passwordless login (discoverable credentials)
+	UserVerification string                    `json:"userVerification"`
+	Timeout          int                       `json:"timeout"`
+}
 type LoginWidgetGroupItem struct {
 	WidgetId pgtype.UUID `json:"widgetId"` // ID of a module widget, empty if system widget is used
 	ModuleId pgtype.UUID `json:"moduleId"` // ID of a module, if relevant for widget (systemModuleMenu)
@@ -22,6 +22,12 @@ type Mail struct {
 	AccountId    pgtype.Int4 `json:"accountId"`   // mail account to send with / got mail from
 	RecordId     pgtype.Int8 `json:"recordId"`    // record to update/get attachment of/from
 	AttributeId  pgtype.UUID `json:"attributeId"` // file attribute to update/get attachment of/from
+
+	// outgoing mails from templates, subject & body contain template captions and are rendered when sent
+	MailTemplateId         pgtype.UUID `json:"mailTemplateId"`         // template mail was spooled from, unset if template was deleted
+	MailTemplateRelationId pgtype.UUID `json:"mailTemplateRelationId"` // relation to render record values from
+	MailTemplateRecordId   pgtype.Int8 `json:"mailTemplateRecordId"`   // record to render values from
+	MailTemplateRender     bool        `json:"mailTemplateRender"`
 }
 type MailAccount struct {
 	Id            int32       `json:"id"`
@@ -35,6 +41,47 @@ type MailAccount struct {
 	HostName      string      `json:"hostName"`
 	HostPort      int64       `json:"hostPort"`
 	OauthClientId pgtype.Int4 `json:"oauthClientId"` // oauth client, if authmethod XOAUTH2 is used
+
+	// SMTP only
+	DkimDomain   pgtype.Text `json:"dkimDomain"`   // signing domain (d=), messages are not DKIM signed if empty
+	DkimSelector pgtype.Text `json:"dkimSelector"` // selector of public key DNS record (s=)
+	DkimKey      pgtype.Text `json:"dkimKey"`      // PEM encoded private key (RSA or Ed25519)
+	SmimeCert    pgtype.Text `json:"smimeCert"`    // file name of PEM encoded certificate in certificates path
+	SmimeKey     pgtype.Text `json:"smimeKey"`     // file name of PEM encoded private key in certificates path
+	SmimeSign    bool        `json:"smimeSign"`    // sign messages with S/MIME certificate
+	SmimeEncrypt bool        `json:"smimeEncrypt"` // encrypt messages with recipient certificates (certificates path: smime/{address}.pem)
+
+	// IMAP only
+	ImapFolders        []string    `json:"imapFolders"`        // folders to retrieve messages from
+	ImapAction         string      `json:"imapAction"`         // action after message was retrieved: delete/move/seen
+	ImapFolderMove     pgtype.Text `json:"imapFolderMove"`     // folder to move retrieved messages to (action 'move')
+	Rules              []MailRule  `json:"rules"`              // routing rules for received mails, first matching rule applies
+	BouncePgFunctionId pgtype.UUID `json:"bouncePgFunctionId"` // function called for bounced outgoing mails (instance.mail_bounce), optional
+}
+type MailRule struct {
+	Id           uuid.UUID        `json:"id"`
+	Name         string           `json:"name"`
+	MatchFrom    pgtype.Text      `json:"matchFrom"`    // regex on sender address list, not checked if empty
+	MatchTo      pgtype.Text      `json:"matchTo"`      // regex on recipient address list, not checked if empty
+	MatchSubject pgtype.Text      `json:"matchSubject"` // regex on subject, not checked if empty
+	MatchHeaders []MailRuleHeader `json:"matchHeaders"` // regex on header values, all must match
+	Action       string           `json:"action"`       // function/record
+
+	// action 'function': function is called with mail (instance.mail), attachments can be kept via instance.mail_delete_after_attach()
+	PgFunctionId pgtype.UUID `json:"pgFunctionId"`
+
+	// action 'record': record is created in relation with mail values, attachments are stored in files attribute
+	RelationId         pgtype.UUID `json:"relationId"`
+	AttributeIdFrom    pgtype.UUID `json:"attributeIdFrom"`
+	AttributeIdTo      pgtype.UUID `json:"attributeIdTo"`
+	AttributeIdSubject pgtype.UUID `json:"attributeIdSubject"`
+	AttributeIdBody    pgtype.UUID `json:"attributeIdBody"`
+	AttributeIdDate    pgtype.UUID `json:"attributeIdDate"` // unix time
+	AttributeIdFiles   pgtype.UUID `json:"attributeIdFiles"`
+}
+type MailRuleHeader struct {
+	Name  string `json:"name"`
+	Match string `json:"match"` // regex
 }
 type MailFile struct {
 	Id   uuid.UUID `json:"id"`
@@ -53,4 +100,12 @@ type MailTraffic struct {
 	Files     []string    `json:"files"`
 	Outgoing  bool        `json:"outgoing"`
 	AccountId pgtype.Int4 `json:"accountId"`
+	MessageId pgtype.Text `json:"messageId"`
+
+	// delivery failures, reported by delivery status notifications (outgoing only)
+	// one entry per failed recipient, status & diagnostic share the index of their recipient
+	BounceDate        pgtype.Int8 `json:"bounceDate"` // date of last reported failure
+	BounceRecipients  []string    `json:"bounceRecipients"`
+	BounceStatuses    []string    `json:"bounceStatuses"`    // enhanced status codes (RFC 3463), e.g. 5.1.1
+	BounceDiagnostics []string    `json:"bounceDiagnostics"` // diagnostic codes of reporting server
 }
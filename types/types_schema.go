@@ -40,6 +40,7 @@ type Module struct {
 	Apis            []Api             `json:"apis"`
 	ClientEvents    []ClientEvent     `json:"clientEvents"`
 	Widgets         []Widget          `json:"widgets"`
+	MailTemplates   []MailTemplate    `json:"mailTemplates"`
 	ArticleIdsHelp  []uuid.UUID       `json:"articleIdsHelp"` // IDs of articles for primary module help, in order
 	Captions        CaptionMap        `json:"captions"`
 }
@@ -599,6 +600,13 @@ type Widget struct {
 	Collection CollectionConsumer `json:"collection"` // collection to display
 	Captions   CaptionMap         `json:"captions"`
 }
+type MailTemplate struct {
+	Id         uuid.UUID   `json:"id"`
+	ModuleId   uuid.UUID   `json:"moduleId"`
+	RelationId pgtype.UUID `json:"relationId"` // relation to render record values from
+	Name       string      `json:"name"`
+	Captions   CaptionMap  `json:"captions"` // subject & body, rendered as Go templates
+}
 type Deletion struct {
 	Id     uuid.UUID `json:"id"`
 	Entity string    `json:"entity"`
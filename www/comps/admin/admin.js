@@ -42,6 +42,12 @@ let MyAdmin = {
 				<span>{{ capApp.navigationLoginTemplates }}</span>
 			</router-link>
 			
+			<!-- API tokens -->
+			<router-link class="entry clickable" tag="div" to="/admin/api-tokens">
+				<img src="images/key.png" />
+				<span>{{ capApp.navigationApiTokens }}</span>
+			</router-link>
+			
 			<!-- modules -->
 			<router-link class="entry clickable" tag="div" to="/admin/modules">
 				<img src="images/builder.png" />
@@ -90,6 +96,12 @@ let MyAdmin = {
 				<span>{{ capApp.navigationLogs }}</span>
 			</router-link>
 			
+			<!-- webhooks -->
+			<router-link class="entry clickable" tag="div" to="/admin/webhooks">
+				<img src="images/globe.png" />
+				<span>{{ capApp.navigationWebhooks }}</span>
+			</router-link>
+			
 			<!-- scheduler -->
 			<router-link class="entry clickable" tag="div" to="/admin/scheduler">
 				<img src="images/clock.png" />
@@ -120,6 +132,24 @@ let MyAdmin = {
 				<span>{{ capApp.navigationLdaps }}</span>
 			</router-link>
 			
+			<!-- OpenID Connect -->
+			<router-link class="entry clickable" tag="div" to="/admin/oidcs" :class="{ inactive:!activated }">
+				<img src="images/lock.png" />
+				<span>{{ capApp.navigationOidcs }}</span>
+			</router-link>
+			
+			<!-- SAML -->
+			<router-link class="entry clickable" tag="div" to="/admin/samls" :class="{ inactive:!activated }">
+				<img src="images/lock.png" />
+				<span>{{ capApp.navigationSamls }}</span>
+			</router-link>
+			
+			<!-- SCIM -->
+			<router-link class="entry clickable" tag="div" to="/admin/scims" :class="{ inactive:!activated }">
+				<img src="images/hierarchy.png" />
+				<span>{{ capApp.navigationScims }}</span>
+			</router-link>
+			
 			<!-- OAuth clients -->
 			<router-link class="entry clickable" tag="div" to="/admin/oauth-clients" :class="{ inactive:!activated }">
 				<img src="images/lockCog.png" />
@@ -167,6 +197,7 @@ let MyAdmin = {
 	},
 	computed:{
 		contentTitle:(s) => {
+			if(s.$route.path.includes('api-tokens'))      return s.capApp.navigationApiTokens;
 			if(s.$route.path.includes('backups'))         return s.capApp.navigationBackups;
 			if(s.$route.path.includes('caption-map'))     return s.capApp.navigationCaptionMap;
 			if(s.$route.path.includes('cluster'))         return s.capApp.navigationCluster;
@@ -184,9 +215,13 @@ let MyAdmin = {
 			if(s.$route.path.includes('mail-traffic'))    return s.capApp.navigationMailTraffic;
 			if(s.$route.path.includes('modules'))         return s.capApp.navigationModules;
 			if(s.$route.path.includes('oauth-clients'))   return s.capApp.navigationOauthClients;
+			if(s.$route.path.includes('oidcs'))           return s.capApp.navigationOidcs;
 			if(s.$route.path.includes('repo'))            return s.capApp.navigationRepo;
 			if(s.$route.path.includes('roles'))           return s.capApp.navigationRoles;
+			if(s.$route.path.includes('samls'))           return s.capApp.navigationSamls;
 			if(s.$route.path.includes('scheduler'))       return s.capApp.navigationScheduler;
+			if(s.$route.path.includes('scims'))           return s.capApp.navigationScims;
+			if(s.$route.path.includes('webhooks'))        return s.capApp.navigationWebhooks;
 			return '';
 		},
 		licenseTitle:(s) => !s.activated
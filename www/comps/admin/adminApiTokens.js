@@ -0,0 +1,39 @@
+import MyLoginTokensApi from '../loginTokensApi.js';
+export {MyAdminApiTokens as default};
+
+let MyAdminApiTokens = {
+	name:'my-admin-api-tokens',
+	components:{ MyLoginTokensApi },
+	template:`<div class="admin-api-tokens contentBox grow">
+		<div class="top">
+			<div class="area">
+				<img class="icon" src="images/key.png" />
+				<h1>{{ menuTitle }}</h1>
+			</div>
+		</div>
+		<div class="top lower">
+			<div class="area">
+				<my-button image="refresh.png"
+					@trigger="$refs.tokens.get()"
+					:caption="capGen.button.refresh"
+				/>
+			</div>
+		</div>
+		
+		<div class="content">
+			<p>{{ capApp.intro }}</p>
+			<my-login-tokens-api ref="tokens" :isAdmin="true" />
+		</div>
+	</div>`,
+	props:{
+		menuTitle:{ type:String, required:true }
+	},
+	computed:{
+		// stores
+		capApp:(s) => s.$store.getters.captions.admin.apiTokens,
+		capGen:(s) => s.$store.getters.captions.generic
+	},
+	mounted() {
+		this.$store.commit('pageTitle',this.menuTitle);
+	}
+};
@@ -121,6 +121,15 @@ let MyAdminConfig = {
 							</div>
 						</td>
 					</tr>
+					<tr>
+						<td>{{ capApp.webauthnPasswordless }}</td>
+						<td>
+							<div class="row gap">
+								<my-bool-string-number v-model="configInput.webauthnPasswordless" />
+								<my-button image="question.png" @trigger="showHelp(capApp.webauthnPasswordlessHint)" />
+							</div>
+						</td>
+					</tr>
 					<tr>
 						<td colspan="2"><br /><h3>{{ capApp.pwTitle }}</h3></td>
 					</tr>
@@ -144,6 +153,49 @@ let MyAdminConfig = {
 						<td>{{ capApp.pwForceSpecial }}</td>
 						<td><my-bool-string-number v-model="configInput.pwForceSpecial" /></td>
 					</tr>
+					<tr>
+						<td>{{ capApp.pwMaxAgeDays }}</td>
+						<td>
+							<div class="row gap">
+								<input v-model="configInput.pwMaxAgeDays" />
+								<my-button image="question.png" @trigger="showHelp(capApp.pwMaxAgeDaysHint)" />
+							</div>
+						</td>
+					</tr>
+					<tr>
+						<td>{{ capApp.pwHistoryCount }}</td>
+						<td><input v-model="configInput.pwHistoryCount" /></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.pwArgon2Memory }}</td>
+						<td>
+							<div class="row gap">
+								<input v-model="configInput.pwArgon2Memory" />
+								<my-button image="question.png" @trigger="showHelp(capApp.pwArgon2Hint)" />
+							</div>
+						</td>
+					</tr>
+					<tr>
+						<td>{{ capApp.pwArgon2Iterations }}</td>
+						<td><input v-model="configInput.pwArgon2Iterations" /></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.pwArgon2Threads }}</td>
+						<td><input v-model="configInput.pwArgon2Threads" /></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.pwResetEnable }}</td>
+						<td>
+							<div class="row gap">
+								<my-bool-string-number v-model="configInput.pwResetEnable" />
+								<my-button image="question.png" @trigger="showHelp(capApp.pwResetEnableHint)" />
+							</div>
+						</td>
+					</tr>
+					<tr v-if="configInput.pwResetEnable === '1'">
+						<td>{{ capApp.pwResetExpiryMinutes }}</td>
+						<td><input v-model="configInput.pwResetExpiryMinutes" /></td>
+					</tr>
 				</table>
 			</div>
 			
@@ -269,6 +321,45 @@ let MyAdminConfig = {
 							:placeholder="capApp.dbTimeoutHint"
 						/></td>
 					</tr>
+					<tr>
+						<td>{{ capApp.dbTimeoutDataStream }}</td>
+						<td><input class="short"
+							v-model="configInput.dbTimeoutDataStream"
+							:placeholder="capApp.dbTimeoutHint"
+						/></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.apiIdempotencyHours }}</td>
+						<td><input class="short" v-model="configInput.apiIdempotencyHours" /></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.restAttempts }}</td>
+						<td><input class="short" v-model="configInput.restAttempts" /></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.restBackoffSeconds }}</td>
+						<td><input class="short" v-model="configInput.restBackoffSeconds" /></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.restTimeoutSeconds }}</td>
+						<td><input class="short" v-model="configInput.restTimeoutSeconds" /></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.restWorkers }}</td>
+						<td><input class="short" v-model="configInput.restWorkers" /></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.restHostConcurrency }}</td>
+						<td><input class="short" v-model="configInput.restHostConcurrency" /></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.restHostRateLimit }}</td>
+						<td><input class="short" v-model="configInput.restHostRateLimit" /></td>
+					</tr>
+					<tr>
+						<td>{{ capApp.restSuccessCodes }}</td>
+						<td><input class="short" v-model="configInput.restSuccessCodes" placeholder="200-299" /></td>
+					</tr>
 					<tr>
 						<td>{{ capApp.dbTimeoutCsv }}</td>
 						<td><input class="short"
@@ -333,6 +424,19 @@ let MyAdminConfig = {
 						<td>{{ capApp.bruteforceAttempts }}</td>
 						<td><input v-model="configInput.bruteforceAttempts" /></td>
 					</tr>
+					<tr>
+						<td>{{ capApp.loginLockAttempts }}</td>
+						<td>
+							<div class="row gap">
+								<input v-model="configInput.loginLockAttempts" />
+								<my-button image="question.png" @trigger="showHelp(capApp.loginLockAttemptsHint)" />
+							</div>
+						</td>
+					</tr>
+					<tr v-if="configInput.loginLockAttempts !== '0'">
+						<td>{{ capApp.loginLockMinutes }}</td>
+						<td><input v-model="configInput.loginLockMinutes" /></td>
+					</tr>
 					<tr>
 						<td>{{ capApp.bruteforceCountTracked }}</td>
 						<td>{{ bruteforceCountTracked }}</td>
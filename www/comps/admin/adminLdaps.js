@@ -1,4 +1,5 @@
 import {hasAnyAssignableRole} from '../shared/access.js';
+import {getUnixFormat}        from '../shared/time.js';
 export {MyAdminLdaps as default};
 
 let MyAdminLdaps = {
@@ -96,12 +97,24 @@ let MyAdminLdaps = {
 							<td>{{ capApp.template }}</td>
 							<td>
 								<select v-model="loginTemplateId">
-									<option v-for="t in templates" :title="t.comment" :value="t.id">
+									<option v-for="t in loginTemplates" :title="t.comment" :value="t.id">
 										{{ t.name }}
 									</option>
 								</select>
 							</td>
 						</tr>
+						<tr>
+							<td>{{ capApp.loginForm }}</td>
+							<td>
+								<select v-model="loginFormId">
+									<option :value="null">-</option>
+									<optgroup v-for="m in modules.filter(v => v.loginForms.length !== 0)" :label="m.name">
+										<option v-for="lf in m.loginForms" :value="lf.id">{{ lf.name }}</option>
+									</optgroup>
+								</select>
+								<span>{{ capApp.loginFormHint }}</span>
+							</td>
+						</tr>
 						<tr>
 							<td>{{ capApp.host }}</td>
 							<td><input v-model="host" :placeholder="capApp.hostHint" /></td>
@@ -165,11 +178,27 @@ let MyAdminLdaps = {
 								<span>{{ capApp.msAdExtHint }}</span>
 							</td>
 						</tr>
+						<tr>
+							<td>{{ capApp.syncIncremental }}</td>
+							<td>
+								<my-bool v-model="syncIncremental" />
+								<span>{{ capApp.syncIncrementalHint }}</span>
+							</td>
+						</tr>
+						<tr v-if="syncIncremental">
+							<td>{{ capApp.syncFullDays }}</td>
+							<td>
+								<input v-model.number="syncFullDays" type="number" min="1" />
+								<span v-if="dateSyncFull !== null">
+									{{ capApp.dateSyncFull.replace('{DATE}',getUnixFormat(dateSyncFull,'Y-m-d H:i')) }}
+								</span>
+							</td>
+						</tr>
 						<tr>
 							<td><span v-html="capApp.assignRoles" /></td>
 							<td><my-bool v-model="assignRoles" /></td>
 						</tr>
-						<tr v-if="showExpert && assignRoles">
+						<tr v-if="showExpert && (assignRoles || templates.length !== 0)">
 							<td>{{ capApp.memberAttribute }}</td>
 							<td>
 								<input v-model="memberAttribute"
@@ -180,6 +209,94 @@ let MyAdminLdaps = {
 					</tbody>
 				</table>
 				
+				<h2 class="roles-title">{{ capApp.titleTemplates }}</h2>
+				<span>{{ capApp.templatesHint }}</span>
+				<div>
+					<my-button image="add.png"
+						@trigger="templateAdd()"
+						:caption="capGen.button.add"
+					/>
+				</div>
+				<br />
+				
+				<table v-if="templates.length !== 0">
+					<thead>
+						<tr>
+							<th>{{ capApp.groupDn }}</th>
+							<th>{{ capApp.template }}</th>
+							<th></th>
+						</tr>
+					</thead>
+					<tbody>
+						<tr v-for="(t,i) in templates" class="default-inputs">
+							<td>
+								<input v-model="t.groupDn"
+									:placeholder="capApp.groupDnHint"
+								/>
+							</td>
+							<td>
+								<select v-model="t.loginTemplateId">
+									<option v-for="lt in loginTemplates" :title="lt.comment" :value="lt.id">
+										{{ lt.name }}
+									</option>
+								</select>
+							</td>
+							<td>
+								<my-button image="delete.png"
+									@trigger="templateRemove(i)"
+									:cancel="true"
+								/>
+							</td>
+						</tr>
+					</tbody>
+				</table>
+				
+				<template v-if="loginFormId !== null">
+				
+					<h2 class="roles-title">{{ capApp.titleAttributes }}</h2>
+					<span>{{ capApp.attributesHint }}</span>
+					<div>
+						<my-button image="add.png"
+							@trigger="attributeAdd()"
+							:caption="capGen.button.add"
+						/>
+					</div>
+					<br />
+					
+					<table v-if="attributes.length !== 0">
+						<thead>
+							<tr>
+								<th>{{ capApp.ldapAttribute }}</th>
+								<th>{{ capApp.attribute }}</th>
+								<th></th>
+							</tr>
+						</thead>
+						<tbody>
+							<tr v-for="(a,i) in attributes" class="default-inputs">
+								<td>
+									<input v-model="a.ldapAttribute"
+										:placeholder="capApp.ldapAttributeHint"
+									/>
+								</td>
+								<td>
+									<select v-model="a.attributeId">
+										<option :value="null">-</option>
+										<option v-for="atr in attributesValid" :value="atr.id">
+											{{ atr.name + (atr.content === 'n:1' ? ' (' + capApp.attributeReference + ')' : '') }}
+										</option>
+									</select>
+								</td>
+								<td>
+									<my-button image="delete.png"
+										@trigger="attributeRemove(i)"
+										:cancel="true"
+									/>
+								</td>
+							</tr>
+						</tbody>
+					</table>
+				</template>
+				
 				<template v-if="assignRoles">
 				
 					<h2 class="roles-title">{{ capApp.titleRoles }}</h2>
@@ -255,18 +372,25 @@ let MyAdminLdaps = {
 			starttls:'',
 			tls:'',
 			tlsVerify:'',
+			loginFormId:'',
+			syncIncremental:'',
+			syncFullDays:'',
+			attributes:'',
 			roles:'',
+			templates:'',
 			
 			// states
+			dateSyncFull:null, // date of last full import, read only
 			idEdit:-1,         // ID of LDAP connection being edited (0 = new)
 			inputKeys:['name','host','port','bindUserDn','bindUserPw',
 				'keyAttribute','loginAttribute','loginTemplateId',
 				'memberAttribute','searchClass','searchDn','assignRoles',
-				'msAdExt','starttls','tls','tlsVerify','roles'],
+				'msAdExt','starttls','tls','tlsVerify','loginFormId','syncIncremental',
+				'syncFullDays','attributes','roles','templates'],
 			inputsOrg:{},      // map of original input values, key = input key
 			ldaps:[],
-			showExpert:false,
-			templates:[]
+			loginTemplates:[],
+			showExpert:false
 		};
 	},
 	mounted() {
@@ -285,11 +409,33 @@ let MyAdminLdaps = {
 			return false;
 		},
 		
+		// attributes that can receive LDAP values: text or references to other login records (e.g. manager)
+		attributesValid:(s) => {
+			if(s.loginFormId === null)
+				return [];
+			
+			for(const m of s.modules) {
+				for(const lf of m.loginForms) {
+					if(lf.id !== s.loginFormId)
+						continue;
+					
+					const rel = s.relationIdMap[s.attributeIdMap[lf.attributeIdLogin].relationId];
+					return rel.attributes.filter(v => !v.encrypted && (
+						['text','varchar'].includes(v.content) ||
+						(v.content === 'n:1' && v.relationshipId === rel.id)
+					));
+				}
+			}
+			return [];
+		},
+		
 		// simple
 		isNew:(s) => s.idEdit === 0,
 		
 		// stores
-		modules:     (s) => s.$store.getters['schema/modules'],
+		modules:       (s) => s.$store.getters['schema/modules'],
+		attributeIdMap:(s) => s.$store.getters['schema/attributeIdMap'],
+		relationIdMap: (s) => s.$store.getters['schema/relationIdMap'],
 		roleIdMap:   (s) => s.$store.getters['schema/roleIdMap'],
 		capApp:      (s) => s.$store.getters.captions.admin.ldaps,
 		capGen:      (s) => s.$store.getters.captions.generic,
@@ -297,6 +443,7 @@ let MyAdminLdaps = {
 	},
 	methods:{
 		// externals
+		getUnixFormat,
 		hasAnyAssignableRole,
 		
 		// actions
@@ -321,7 +468,13 @@ let MyAdminLdaps = {
 				starttls:false,
 				tls:true,
 				tlsVerify:true,
-				roles:[]
+				loginFormId:null,
+				syncIncremental:false,
+				syncFullDays:1,
+				dateSyncFull:null,
+				attributes:[],
+				roles:[],
+				templates:[]
 			};
 			
 			if(id > 0) {
@@ -334,14 +487,25 @@ let MyAdminLdaps = {
 			}
 			
 			// apply global template if empty
-			if(ldap.loginTemplateId === null && this.templates.length > 0)
-				ldap.loginTemplateId = this.templates[0].id;
+			if(ldap.loginTemplateId === null && this.loginTemplates.length > 0)
+				ldap.loginTemplateId = this.loginTemplates[0].id;
 			
 			for(let k of this.inputKeys) {
 				this[k]           = JSON.parse(JSON.stringify(ldap[k]));
 				this.inputsOrg[k] = JSON.parse(JSON.stringify(ldap[k]));
 			}
-			this.idEdit = id;
+			this.dateSyncFull = ldap.dateSyncFull;
+			this.idEdit       = id;
+		},
+		attributeAdd() {
+			this.attributes.push({
+				ldapId:this.idEdit,
+				attributeId:null,
+				ldapAttribute:''
+			});
+		},
+		attributeRemove(i) {
+			this.attributes.splice(i,1);
 		},
 		roleAdd() {
 			this.roles.push({
@@ -353,6 +517,16 @@ let MyAdminLdaps = {
 		roleRemove(i) {
 			this.roles.splice(i,1);
 		},
+		templateAdd() {
+			this.templates.push({
+				ldapId:this.idEdit,
+				loginTemplateId:this.loginTemplates.length > 0 ? this.loginTemplates[0].id : null,
+				groupDn:''
+			});
+		},
+		templateRemove(i) {
+			this.templates.splice(i,1);
+		},
 		
 		// backend calls
 		runImport(id) {
@@ -411,8 +585,8 @@ let MyAdminLdaps = {
 				ws.prepare('loginTemplate','get',{byId:0})
 			],true).then(
 				res => {
-					this.ldaps     = res[0].payload;
-					this.templates = res[1].payload;
+					this.ldaps          = res[0].payload;
+					this.loginTemplates = res[1].payload;
 				},
 				this.$root.genericError
 			);
@@ -436,7 +610,12 @@ let MyAdminLdaps = {
 				starttls:this.starttls,
 				tls:this.tls,
 				tlsVerify:this.tlsVerify,
-				roles:this.roles
+				loginFormId:this.loginFormId,
+				syncIncremental:this.syncIncremental,
+				syncFullDays:this.syncFullDays,
+				attributes:this.loginFormId === null ? [] : this.attributes.filter(v => v.attributeId !== null && v.ldapAttribute !== ''),
+				roles:this.roles,
+				templates:this.templates.filter(v => v.groupDn !== '' && v.loginTemplateId !== null)
 			},true).then(
 				() => {
 					this.idEdit = -1;
@@ -1,9 +1,11 @@
 import MyForm           from '../form.js';
+import MyLoginSessions  from '../loginSessions.js';
 import MyTabs           from '../tabs.js';
 import MyInputSelect    from '../inputSelect.js';
 import {dialogCloseAsk} from '../shared/dialog.js';
 import srcBase64Icon    from '../shared/image.js';
 import {getCaption}     from '../shared/language.js';
+import {getUnixFormat}  from '../shared/time.js';
 export {MyAdminLogin as default};
 
 let MyAdminLoginRole = {
@@ -37,6 +39,7 @@ let MyAdminLogin = {
 		MyAdminLoginRole,
 		MyForm,
 		MyInputSelect,
+		MyLoginSessions,
 		MyTabs
 	},
 	template:`<div class="app-sub-window under-header at-top with-margin" @mousedown.self="closeAsk">
@@ -93,7 +96,7 @@ let MyAdminLogin = {
 					/>
 					<my-button image="warning.png"
 						v-if="!isNew"
-						@trigger="resetTotpAsk"
+						@trigger="resetMfaAsk"
 						:active="!noAuth"
 						:cancel="true"
 						:caption="capApp.button.resetMfa"
@@ -109,9 +112,9 @@ let MyAdminLogin = {
 			
 			<my-tabs
 				v-model="tabTarget"
-				:entries="['properties','roles']"
-				:entriesIcon="['images/edit.png','images/personMultiple.png']"
-				:entriesText="[capGen.properties,capApp.roles.replace('{COUNT}',roleTotalNonHidden)]"
+				:entries="isNew ? ['properties','roles'] : ['properties','roles','sessions']"
+				:entriesIcon="['images/edit.png','images/personMultiple.png','images/logoff.png']"
+				:entriesText="[capGen.properties,capApp.roles.replace('{COUNT}',roleTotalNonHidden),capApp.sessions]"
 			/>
 			
 			<div class="content" :class="{ 'no-padding':tabTarget === 'roles' }">
@@ -126,6 +129,16 @@ let MyAdminLogin = {
 						<td class="default-inputs"><input v-model="name" v-focus :disabled="isLdap" /></td>
 						<td>{{ capApp.hint.name }}</td>
 					</tr>
+					<tr>
+						<td>
+							<div class="title-cell">
+								<img src="images/mail.png" />
+								<span>{{ capApp.mail }}</span>
+							</div>
+						</td>
+						<td class="default-inputs"><input v-model="mail" :disabled="isLdap" /></td>
+						<td>{{ capApp.hint.mail }}</td>
+					</tr>
 					<tr>
 						<td>
 							<div class="title-cell">
@@ -224,6 +237,42 @@ let MyAdminLogin = {
 						<td class="default-inputs"><input v-model="pass" :placeholder="capGen.threeDots" /></td>
 						<td>{{ capApp.hint.password }}</td>
 					</tr>
+					<tr v-if="!isLdap">
+						<td>
+							<div class="title-cell">
+								<img src="images/lock.png" />
+								<span>{{ capApp.pwChangeRequired }}</span>
+							</div>
+						</td>
+						<td>
+							<div class="column gap">
+								<my-bool v-model="pwChangeRequired" :readonly="noAuth" />
+								<span v-if="datePwChange !== null">
+									{{ capApp.datePwChange.replace('{DATE}',getUnixFormat(datePwChange,'Y-m-d H:i')) }}
+								</span>
+								<span class="admin-login-warning" v-if="pwExpired">{{ capApp.pwExpired }}</span>
+							</div>
+						</td>
+						<td>{{ capApp.hint.pwChangeRequired }}</td>
+					</tr>
+					<tr v-if="dateLockedUntil !== null">
+						<td>
+							<div class="title-cell">
+								<img src="images/warning.png" />
+								<span>{{ capApp.locked }}</span>
+							</div>
+						</td>
+						<td>
+							<div class="row gap centered">
+								<span class="admin-login-warning">{{ capApp.lockedUntil.replace('{DATE}',getUnixFormat(dateLockedUntil,'Y-m-d H:i')) }}</span>
+								<my-button image="lockOpen.png"
+									@trigger="unlock"
+									:caption="capApp.button.unlock"
+								/>
+							</div>
+						</td>
+						<td>{{ capApp.hint.locked }}</td>
+					</tr>
 					<tr v-if="isLdap">
 						<td>
 							<div class="title-cell">
@@ -283,6 +332,12 @@ let MyAdminLogin = {
 						</tr>
 					</tbody>
 				</table>
+				
+				<my-login-sessions
+					v-if="tabTarget === 'sessions'"
+					:isAdmin="true"
+					:loginId="id"
+				/>
 			</div>
 		</div>
 	</div>`,
@@ -300,19 +355,24 @@ let MyAdminLogin = {
 			ldapId:null,
 			ldapKey:null,
 			name:'',
+			mail:'',
 			active:true,
 			admin:false,
 			pass:'',
 			noAuth:false,
 			tokenExpiryHours:'',
+			pwChangeRequired:false,
 			records:[],
 			roleIds:[],
 			templateId:null,
 			
 			// states
-			inputKeys:['name','active','admin','pass','noAuth','tokenExpiryHours','records','roleIds'],
+			dateLockedUntil:null, // login is locked after failed authentication attempts
+			datePwChange:null,    // date of last password change
+			inputKeys:['name','mail','active','admin','pass','noAuth','tokenExpiryHours','pwChangeRequired','records','roleIds'],
 			inputsOrg:{},      // map of original input values, key = input key
 			inputsReady:false, // inputs have been loaded
+			pwExpired:false,   // password has exceeded maximum age
 			recordInput:'',    // record lookup input
 			recordList:[],     // record lookup dropdown values
 			roleFilter:'',     // filter for role selection
@@ -393,6 +453,7 @@ let MyAdminLogin = {
 		// externals
 		dialogCloseAsk,
 		getCaption,
+		getUnixFormat,
 		srcBase64Icon,
 		
 		handleHotkeys(e) {
@@ -505,10 +566,15 @@ let MyAdminLogin = {
 					this.ldapId           = login.ldapId;
 					this.ldapKey          = login.ldapKey;
 					this.name             = login.name;
+					this.mail             = login.mail !== null ? login.mail : '';
 					this.active           = login.active;
 					this.admin            = login.admin;
 					this.noAuth           = login.noAuth;
 					this.tokenExpiryHours = login.tokenExpiryHours;
+					this.pwChangeRequired = login.pwChangeRequired;
+					this.dateLockedUntil  = login.dateLockedUntil;
+					this.datePwChange     = login.datePwChange;
+					this.pwExpired        = login.pwExpired;
 					this.records          = login.records;
 					this.roleIds          = login.roleIds;
 					this.pass             = '';
@@ -562,11 +628,13 @@ let MyAdminLogin = {
 				ldapId:this.ldapId,
 				ldapKey:this.ldapKey,
 				name:this.name,
+				mail:this.mail !== '' ? this.mail : null,
 				pass:this.pass,
 				active:this.active,
 				admin:this.admin,
 				noAuth:this.noAuth,
 				tokenExpiryHours:/^(0|[1-9]\d*)$/.test(this.tokenExpiryHours) ? parseInt(this.tokenExpiryHours) : null,
+				pwChangeRequired:this.pwChangeRequired,
 				roleIds:this.roleIds,
 				records:records,
 				templateId:this.templateId
@@ -584,16 +652,22 @@ let MyAdminLogin = {
 				this.$root.genericError
 			);
 		},
+		unlock() {
+			ws.send('login','unlock',{id:this.id},true).then(
+				this.get,
+				this.$root.genericError
+			);
+		},
 		
 		// MFA calls
-		resetTotpAsk() {
+		resetMfaAsk() {
 			this.$store.commit('dialog',{
 				captionBody:this.capApp.dialog.resetTotp,
 				image:'warning.png',
 				buttons:[{
 					cancel:true,
 					caption:this.capGen.button.reset,
-					exec:this.resetTotp,
+					exec:this.resetMfa,
 					keyEnter:true,
 					image:'refresh.png'
 				},{
@@ -603,8 +677,11 @@ let MyAdminLogin = {
 				}]
 			});
 		},
-		resetTotp() {
-			ws.send('login','resetTotp',{id:this.id},true).then(
+		resetMfa() {
+			ws.sendMultiple([
+				ws.prepare('login','resetTotp',{id:this.id}),
+				ws.prepare('login','resetWebauthn',{id:this.id})
+			],true).then(
 				res => {},this.$root.genericError
 			);
 		}
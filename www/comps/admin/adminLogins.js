@@ -83,6 +83,18 @@ let MyAdminLogins = {
 						<span>{{ l.name }}</span>
 					</div>
 					<div class="row">
+						<my-button image="warning.png"
+							v-if="l.dateLockedUntil !== null"
+							:active="false"
+							:captionTitle="capApp.hint.isLocked"
+							:naked="true"
+						/>
+						<my-button image="key_locked.png"
+							v-if="l.pwChangeRequired || l.pwExpired"
+							:active="false"
+							:captionTitle="capApp.hint.isPwChangeRequired"
+							:naked="true"
+						/>
 						<my-button image="globe.png"
 							v-if="l.noAuth"
 							:active="false"
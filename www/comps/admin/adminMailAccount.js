@@ -1,8 +1,177 @@
 import {dialogCloseAsk} from '../shared/dialog.js';
 export {MyAdminMailAccount as default};
 
+let MyAdminMailAccountRule = {
+	name:'my-admin-mail-account-rule',
+	template:`<div class="admin-mail-account-rule">
+		<div class="row centered gap">
+			<input
+				@input="set('name',$event.target.value)"
+				:placeholder="capApp.ruleNameHint"
+				:value="modelValue.name"
+			/>
+			<my-button image="arrowUp.png"
+				@trigger="$emit('moveUp')"
+				:active="!isFirst"
+				:naked="true"
+			/>
+			<my-button image="delete.png"
+				@trigger="$emit('remove')"
+				:cancel="true"
+				:naked="true"
+			/>
+		</div>
+		<table class="generic-table generic-table-vertical fullWidth">
+			<tr>
+				<td>{{ capApp.ruleMatchFrom }}</td>
+				<td><input :value="modelValue.matchFrom ?? ''" @input="setText('matchFrom',$event.target.value)" /></td>
+				<td rowspan="3">{{ capApp.ruleMatchHint }}</td>
+			</tr>
+			<tr>
+				<td>{{ capApp.ruleMatchTo }}</td>
+				<td><input :value="modelValue.matchTo ?? ''" @input="setText('matchTo',$event.target.value)" /></td>
+			</tr>
+			<tr>
+				<td>{{ capApp.ruleMatchSubject }}</td>
+				<td><input :value="modelValue.matchSubject ?? ''" @input="setText('matchSubject',$event.target.value)" /></td>
+			</tr>
+			<tr>
+				<td>{{ capApp.ruleMatchHeaders }}</td>
+				<td>
+					<div class="column gap">
+						<div class="row centered gap" v-for="(h,i) in modelValue.matchHeaders">
+							<input :placeholder="capApp.ruleHeaderName" :value="h.name" @input="setHeader(i,'name',$event.target.value)" />
+							<input :placeholder="capApp.ruleHeaderMatch" :value="h.match" @input="setHeader(i,'match',$event.target.value)" />
+							<my-button image="cancel.png"
+								@trigger="removeHeader(i)"
+								:naked="true"
+							/>
+						</div>
+						<div>
+							<my-button image="add.png"
+								@trigger="addHeader"
+								:caption="capGen.button.add"
+							/>
+						</div>
+					</div>
+				</td>
+				<td>{{ capApp.ruleMatchHeadersHint }}</td>
+			</tr>
+			<tr>
+				<td>{{ capApp.ruleAction }}*</td>
+				<td>
+					<select :value="modelValue.action" @change="set('action',$event.target.value)">
+						<option value="function">{{ capApp.option.ruleAction.function }}</option>
+						<option value="record">{{ capApp.option.ruleAction.record }}</option>
+					</select>
+				</td>
+				<td>{{ modelValue.action === 'function' ? capApp.ruleActionHintFunction : capApp.ruleActionHintRecord }}</td>
+			</tr>
+			<tr v-if="modelValue.action === 'function'">
+				<td>{{ capApp.rulePgFunction }}*</td>
+				<td>
+					<select :value="modelValue.pgFunctionId" @change="set('pgFunctionId',$event.target.value !== '' ? $event.target.value : null)">
+						<option value="">-</option>
+						<optgroup v-for="m in modules.filter(v => v.pgFunctions.some(f => !f.isTrigger))" :label="m.name">
+							<option v-for="f in m.pgFunctions.filter(v => !v.isTrigger)" :value="f.id">{{ f.name }}</option>
+						</optgroup>
+					</select>
+				</td>
+				<td></td>
+			</tr>
+			<template v-if="modelValue.action === 'record'">
+				<tr>
+					<td>{{ capApp.ruleRelation }}*</td>
+					<td>
+						<select :value="modelValue.relationId" @change="setRelation($event.target.value !== '' ? $event.target.value : null)">
+							<option value="">-</option>
+							<optgroup v-for="m in modules.filter(v => v.relations.length !== 0)" :label="m.name">
+								<option v-for="r in m.relations" :value="r.id">{{ r.name }}</option>
+							</optgroup>
+						</select>
+					</td>
+					<td></td>
+				</tr>
+				<tr v-if="modelValue.relationId !== null" v-for="a in attributeInputs">
+					<td>{{ capApp.ruleAttribute[a.name] }}</td>
+					<td>
+						<select :value="modelValue[a.key]" @change="set(a.key,$event.target.value !== '' ? $event.target.value : null)">
+							<option value="">-</option>
+							<option v-for="atr in attributes.filter(a.filter)" :value="atr.id">{{ atr.name }}</option>
+						</select>
+					</td>
+					<td></td>
+				</tr>
+			</template>
+		</table>
+	</div>`,
+	props:{
+		isFirst:   { type:Boolean, required:true },
+		modelValue:{ type:Object,  required:true }
+	},
+	emits:['moveUp','remove','update:modelValue'],
+	data() {
+		return {
+			attributeInputs:[
+				{ key:'attributeIdFrom',    name:'from',    filter:(v) => ['text','varchar'].includes(v.content) },
+				{ key:'attributeIdTo',      name:'to',      filter:(v) => ['text','varchar'].includes(v.content) },
+				{ key:'attributeIdSubject', name:'subject', filter:(v) => ['text','varchar'].includes(v.content) },
+				{ key:'attributeIdBody',    name:'body',    filter:(v) => ['text','varchar'].includes(v.content) },
+				{ key:'attributeIdDate',    name:'date',    filter:(v) => ['integer','bigint'].includes(v.content) },
+				{ key:'attributeIdFiles',   name:'files',   filter:(v) => v.content === 'files' }
+			]
+		};
+	},
+	computed:{
+		// unencrypted attributes of selected relation
+		attributes:(s) => s.modelValue.relationId === null
+			? [] : s.relationIdMap[s.modelValue.relationId].attributes.filter(v => !v.encrypted),
+		
+		// stores
+		modules:      (s) => s.$store.getters['schema/modules'],
+		relationIdMap:(s) => s.$store.getters['schema/relationIdMap'],
+		capApp:       (s) => s.$store.getters.captions.admin.mails,
+		capGen:       (s) => s.$store.getters.captions.generic
+	},
+	methods:{
+		addHeader() {
+			let headers = JSON.parse(JSON.stringify(this.modelValue.matchHeaders));
+			headers.push({ name:'', match:'' });
+			this.set('matchHeaders',headers);
+		},
+		removeHeader(i) {
+			let headers = JSON.parse(JSON.stringify(this.modelValue.matchHeaders));
+			headers.splice(i,1);
+			this.set('matchHeaders',headers);
+		},
+		set(name,value) {
+			let v = JSON.parse(JSON.stringify(this.modelValue));
+			v[name] = value;
+			this.$emit('update:modelValue',v);
+		},
+		setHeader(i,name,value) {
+			let headers = JSON.parse(JSON.stringify(this.modelValue.matchHeaders));
+			headers[i][name] = value;
+			this.set('matchHeaders',headers);
+		},
+		setRelation(id) {
+			// attributes are relation specific, reset them
+			let v = JSON.parse(JSON.stringify(this.modelValue));
+			v.relationId = id;
+			for(const a of this.attributeInputs) {
+				v[a.key] = null;
+			}
+			this.$emit('update:modelValue',v);
+		},
+		setText(name,value) {
+			this.set(name,value !== '' ? value : null);
+		}
+	}
+};
+
 let MyAdminMailAccount = {
 	name:'my-admin-mail-account',
+	components:{ MyAdminMailAccountRule },
 	template:`<div class="app-sub-window under-header at-top with-margin" @mousedown.self="closeAsk">
 		
 		<div class="contentBox float">
@@ -110,6 +279,54 @@ let MyAdminMailAccount = {
 						<td><input v-model="inputs.sendAs" /></td>
 						<td>{{ capApp.accountSendAsHint }}</td>
 					</tr>
+					<template v-if="!isSmtp">
+						<tr>
+							<td>{{ capApp.accountImapFolders }}*</td>
+							<td>
+								<input
+									@change="inputs.imapFolders = $event.target.value.split(',').map(v => v.trim()).filter(v => v !== '')"
+									:value="inputs.imapFolders.join(', ')"
+								/>
+							</td>
+							<td>{{ capApp.accountImapFoldersHint }}</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.accountImapAction }}*</td>
+							<td>
+								<select v-model="inputs.imapAction">
+									<option value="delete">{{ capApp.option.imapAction.delete }}</option>
+									<option value="move">{{ capApp.option.imapAction.move }}</option>
+									<option value="seen">{{ capApp.option.imapAction.seen }}</option>
+								</select>
+							</td>
+							<td>{{ capApp.accountImapActionHint }}</td>
+						</tr>
+						<tr v-if="inputs.imapAction === 'move'">
+							<td>{{ capApp.accountImapFolderMove }}*</td>
+							<td>
+								<input
+									@input="inputs.imapFolderMove = $event.target.value !== '' ? $event.target.value : null"
+									:value="inputs.imapFolderMove !== null ? inputs.imapFolderMove : ''"
+								/>
+							</td>
+							<td>{{ capApp.accountImapFolderMoveHint }}</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.accountBounceFunction }}</td>
+							<td>
+								<select
+									@change="inputs.bouncePgFunctionId = $event.target.value !== '' ? $event.target.value : null"
+									:value="inputs.bouncePgFunctionId !== null ? inputs.bouncePgFunctionId : ''"
+								>
+									<option value="">-</option>
+									<optgroup v-for="m in modules.filter(v => v.pgFunctions.some(f => !f.isTrigger))" :label="m.name">
+										<option v-for="f in m.pgFunctions.filter(v => !v.isTrigger)" :value="f.id">{{ f.name }}</option>
+									</optgroup>
+								</select>
+							</td>
+							<td>{{ capApp.accountBounceFunctionHint }}</td>
+						</tr>
+					</template>
 					<tr>
 						<td>{{ capApp.accountStartTls }}*</td>
 						<td><my-bool v-model="inputs.startTls" /></td>
@@ -126,6 +343,95 @@ let MyAdminMailAccount = {
 						<td></td>
 					</tr>
 				</table>
+				
+				<template v-if="isSmtp">
+					<h2>{{ capApp.titleSigning }}</h2>
+					<table class="generic-table generic-table-vertical fullWidth">
+						<tr>
+							<td>{{ capApp.accountDkimDomain }}</td>
+							<td>
+								<input
+									@input="inputs.dkimDomain = $event.target.value !== '' ? $event.target.value : null"
+									:value="inputs.dkimDomain !== null ? inputs.dkimDomain : ''"
+								/>
+							</td>
+							<td>{{ capApp.accountDkimDomainHint }}</td>
+						</tr>
+						<template v-if="inputs.dkimDomain !== null">
+							<tr>
+								<td>{{ capApp.accountDkimSelector }}*</td>
+								<td>
+									<input
+										@input="inputs.dkimSelector = $event.target.value !== '' ? $event.target.value : null"
+										:value="inputs.dkimSelector !== null ? inputs.dkimSelector : ''"
+									/>
+								</td>
+								<td>{{ capApp.accountDkimSelectorHint }}</td>
+							</tr>
+							<tr>
+								<td>{{ capApp.accountDkimKey }}*</td>
+								<td>
+									<textarea
+										@input="inputs.dkimKey = $event.target.value !== '' ? $event.target.value : null"
+										:value="inputs.dkimKey !== null ? inputs.dkimKey : ''"
+									></textarea>
+								</td>
+								<td>{{ capApp.accountDkimKeyHint }}</td>
+							</tr>
+						</template>
+						<tr>
+							<td>{{ capApp.accountSmimeSign }}</td>
+							<td><my-bool v-model="inputs.smimeSign" /></td>
+							<td>{{ capApp.accountSmimeSignHint }}</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.accountSmimeEncrypt }}</td>
+							<td><my-bool v-model="inputs.smimeEncrypt" /></td>
+							<td>{{ capApp.accountSmimeEncryptHint }}</td>
+						</tr>
+						<template v-if="inputs.smimeSign || inputs.smimeEncrypt">
+							<tr>
+								<td>{{ capApp.accountSmimeCert }}<span v-if="inputs.smimeSign">*</span></td>
+								<td>
+									<input
+										@input="inputs.smimeCert = $event.target.value !== '' ? $event.target.value : null"
+										:value="inputs.smimeCert !== null ? inputs.smimeCert : ''"
+									/>
+								</td>
+								<td>{{ capApp.accountSmimeCertHint }}</td>
+							</tr>
+							<tr>
+								<td>{{ capApp.accountSmimeKey }}<span v-if="inputs.smimeSign">*</span></td>
+								<td>
+									<input
+										@input="inputs.smimeKey = $event.target.value !== '' ? $event.target.value : null"
+										:value="inputs.smimeKey !== null ? inputs.smimeKey : ''"
+									/>
+								</td>
+								<td>{{ capApp.accountSmimeKeyHint }}</td>
+							</tr>
+						</template>
+					</table>
+				</template>
+				
+				<template v-if="!isSmtp">
+					<h2>{{ capApp.titleRules }}</h2>
+					<p>{{ capApp.rulesHint }}</p>
+					<div>
+						<my-button image="add.png"
+							@trigger="ruleAdd"
+							:caption="capGen.button.add"
+						/>
+					</div>
+					<my-admin-mail-account-rule
+						v-for="(r,i) in inputs.rules"
+						v-model="inputs.rules[i]"
+						@moveUp="ruleMoveUp(i)"
+						@remove="inputs.rules.splice(i,1)"
+						:isFirst="i === 0"
+						:key="i"
+					/>
+				</template>
 			</div>
 		</div>
 	</div>`,
@@ -166,7 +472,19 @@ let MyAdminMailAccount = {
 			sendAs:'',
 			hostName:'',
 			hostPort:465,
-			oauthClientId:null
+			oauthClientId:null,
+			imapFolders:['INBOX'],
+			imapAction:'delete',
+			imapFolderMove:null,
+			rules:[],
+			bouncePgFunctionId:null,
+			dkimDomain:null,
+			dkimSelector:null,
+			dkimKey:null,
+			smimeCert:null,
+			smimeKey:null,
+			smimeSign:false,
+			smimeEncrypt:false
 		} : s.mailAccountIdMap[s.id],
 		
 		// simple states
@@ -184,14 +502,29 @@ let MyAdminMailAccount = {
 					s.inputs.authMethod !== 'xoauth2' &&
 					s.inputs.password   !== ''
 				)
+			) && (
+				!s.isSmtp || (
+					(s.inputs.dkimDomain === null || (s.inputs.dkimSelector !== null && s.inputs.dkimKey !== null)) &&
+					(!s.inputs.smimeSign || (s.inputs.smimeCert !== null && s.inputs.smimeKey !== null))
+				)
+			) && (
+				s.isSmtp || (
+					s.inputs.imapFolders.length !== 0 &&
+					(s.inputs.imapAction !== 'move' || s.inputs.imapFolderMove !== null) &&
+					s.inputs.rules.every(v => v.name !== '' && (
+						(v.action === 'function' && v.pgFunctionId !== null) ||
+						(v.action === 'record'   && v.relationId   !== null)
+					))
+				)
 			),
 		isNew:  (s) => s.id                === 0,
 		isOauth:(s) => s.inputs.authMethod === 'xoauth2',
 		isSmtp: (s) => s.inputs.mode       === 'smtp',
 		
 		// stores
-		capApp:(s) => s.$store.getters.captions.admin.mails,
-		capGen:(s) => s.$store.getters.captions.generic
+		capApp: (s) => s.$store.getters.captions.admin.mails,
+		capGen: (s) => s.$store.getters.captions.generic,
+		modules:(s) => s.$store.getters['schema/modules']
 	},
 	mounted() {
 		window.addEventListener('keydown',this.handleHotkeys);
@@ -229,6 +562,28 @@ let MyAdminMailAccount = {
 				this.$root.genericError
 			);
 		},
+		ruleAdd() {
+			this.inputs.rules.push({
+				id:null,
+				name:'',
+				matchFrom:null,
+				matchTo:null,
+				matchSubject:null,
+				matchHeaders:[],
+				action:'record',
+				pgFunctionId:null,
+				relationId:null,
+				attributeIdFrom:null,
+				attributeIdTo:null,
+				attributeIdSubject:null,
+				attributeIdBody:null,
+				attributeIdDate:null,
+				attributeIdFiles:null
+			});
+		},
+		ruleMoveUp(i) {
+			this.inputs.rules.splice(i-1,0,this.inputs.rules.splice(i,1)[0]);
+		},
 		reset() {
 			this.inputs  = JSON.parse(JSON.stringify(this.inputsOrg));
 			this.isReady = true;
@@ -269,7 +624,19 @@ let MyAdminMailAccount = {
 				sendAs:this.inputs.sendAs,
 				hostName:this.inputs.hostName,
 				hostPort:this.inputs.hostPort,
-				oauthClientId:this.inputs.oauthClientId
+				oauthClientId:this.inputs.oauthClientId,
+				imapFolders:this.inputs.imapFolders,
+				imapAction:this.inputs.imapAction,
+				imapFolderMove:this.inputs.imapFolderMove,
+				rules:this.inputs.rules,
+				bouncePgFunctionId:this.inputs.bouncePgFunctionId,
+				dkimDomain:this.inputs.dkimDomain,
+				dkimSelector:this.inputs.dkimSelector,
+				dkimKey:this.inputs.dkimKey,
+				smimeCert:this.inputs.smimeCert,
+				smimeKey:this.inputs.smimeKey,
+				smimeSign:this.inputs.smimeSign,
+				smimeEncrypt:this.inputs.smimeEncrypt
 			},true).then(
 				this.reloadAndClose,
 				this.$root.genericError
@@ -89,6 +89,7 @@ let MyAdminMailTraffic = {
 						<th>{{ capApp.files }}</th>
 						<th>{{ capGen.date }}</th>
 						<th>{{ capApp.account }}</th>
+						<th>{{ capApp.bounce }}</th>
 					</tr>
 				</thead>
 				<tbody>
@@ -102,6 +103,8 @@ let MyAdminMailTraffic = {
 						<td v-else><my-button image="visible1.png" @trigger="showFiles(m.files)" :caption="String(m.files.length)" /></td>
 						<td>{{ getUnixFormat(m.date,settings.dateFormat+' H:i') }}</td>
 						<td>{{ typeof accountIdMap[m.accountId] !== 'undefined' ? accountIdMap[m.accountId].name : '-' }}</td>
+						<td v-if="m.bounceDate === null">-</td>
+						<td v-else><my-button image="warning.png" @trigger="showBounce(m)" :caption="m.bounceStatuses.join(', ')" /></td>
 					</tr>
 				</tbody>
 			</table>
@@ -156,6 +159,22 @@ let MyAdminMailTraffic = {
 			else    this.offset -= this.limit;
 			this.get();
 		},
+		showBounce(m) {
+			let lines = [getUnixFormat(m.bounceDate,this.settings.dateFormat+' H:i')];
+			for(let i = 0, j = m.bounceRecipients.length; i < j; i++) {
+				lines.push(
+					'',
+					`${this.capApp.bounceRecipient}: ${m.bounceRecipients[i]}`,
+					`${this.capApp.bounceStatus}: ${m.bounceStatuses[i]}`,
+					`${this.capApp.bounceDiagnostic}: ${m.bounceDiagnostics[i]}`
+				);
+			}
+			this.$store.commit('dialog',{
+				captionTop:this.capApp.bounce,
+				captionBody:lines.join('\n'),
+				textDisplay:'textarea'
+			});
+		},
 		showFiles(files) {
 			this.$store.commit('dialog',{
 				captionBody:files.join('<br />')
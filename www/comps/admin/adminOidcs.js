@@ -0,0 +1,367 @@
+import {hasAnyAssignableRole} from '../shared/access.js';
+export {MyAdminOidcs as default};
+
+let MyAdminOidcs = {
+	name:'my-admin-oidcs',
+	template:`<div class="admin-oidcs contentBox grow">
+		
+		<div class="top">
+			<div class="area">
+				<img class="icon" src="images/lock.png" />
+				<h1>{{ menuTitle }}</h1>
+			</div>
+		</div>
+		<div class="top lower">
+			<div class="area">
+				<my-button image="add.png"
+					@trigger="open(0)"
+					:active="true"
+					:caption="capApp.button.new"
+				/>
+			</div>
+		</div>
+		
+		<div class="content no-padding">
+		
+			<div class="contentPart long">
+				<span v-html="capApp.description"></span>
+				<br /><br />
+				
+				<table class="default-inputs" v-if="oidcs.length !== 0">
+					<tbody>
+						<tr v-for="o in oidcs">
+							<td>{{ o.name }}</td>
+							<td>{{ o.issuerUrl }}</td>
+							<td><my-bool :modelValue="o.active" :readonly="true" /></td>
+							<td>
+								<my-button image="edit.png"
+									@trigger="open(o.id)"
+									:active="true"
+								/>
+							</td>
+						</tr>
+					</tbody>
+				</table>
+			</div>
+			
+			<div class="contentPart long" v-if="idEdit !== -1">
+				
+				<div class="contentPartHeader">
+					<img class="icon" src="images/edit.png" />
+					<h1>{{ capApp.title }}</h1>
+				</div>
+				
+				<div class="entry-actions">
+					<my-button image="save.png"
+						@trigger="set"
+						:active="hasChanges"
+						:caption="capGen.button.save"
+					/>
+					<my-button image="delete.png"
+						v-if="!isNew"
+						@trigger="delAsk"
+						:cancel="true"
+						:caption="capGen.button.delete"
+					/>
+					<my-button image="cancel.png"
+						@trigger="close"
+						:cancel="true"
+						:caption="capGen.button.close"
+					/>
+				</div>
+				
+				<table class="default-inputs">
+					<tbody>
+						<tr>
+							<td>{{ capGen.name }}</td>
+							<td><input v-model="name" :placeholder="capApp.nameHint" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.active }}</td>
+							<td><my-bool v-model="active" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.template }}</td>
+							<td>
+								<select v-model="loginTemplateId">
+									<option v-for="t in templates" :title="t.comment" :value="t.id">
+										{{ t.name }}
+									</option>
+								</select>
+							</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.issuerUrl }}</td>
+							<td><input v-model="issuerUrl" :placeholder="capApp.issuerUrlHint" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.redirectUri }}</td>
+							<td>
+								<input disabled="disabled" :value="redirectUri" />
+								<span>{{ capApp.redirectUriHint }}</span>
+							</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.clientId }}</td>
+							<td><input v-model="clientId" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.clientSecret }}</td>
+							<td>
+								<input v-model="clientSecret" type="password" />
+								<span>{{ capApp.clientSecretHint }}</span>
+							</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.scopes }}</td>
+							<td><input v-model="scopesInput" :placeholder="capApp.scopesHint" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.claimUsername }}</td>
+							<td><input v-model="claimUsername" :placeholder="capApp.claimUsernameHint" /></td>
+						</tr>
+						<tr>
+							<td><span v-html="capApp.assignRoles" /></td>
+							<td><my-bool v-model="assignRoles" /></td>
+						</tr>
+						<tr v-if="assignRoles">
+							<td>{{ capApp.claimRoles }}</td>
+							<td><input v-model="claimRoles" :placeholder="capApp.claimRolesHint" /></td>
+						</tr>
+					</tbody>
+				</table>
+				
+				<template v-if="assignRoles">
+				
+					<h2 class="roles-title">{{ capApp.titleRoles }}</h2>
+					<div>
+						<my-button image="add.png"
+							@trigger="roleAdd()"
+							:caption="capGen.button.add"
+						/>
+					</div>
+					<br />
+					
+					<table v-if="roles.length !== 0">
+						<thead>
+							<tr>
+								<th>{{ capApp.claimValue }}</th>
+								<th>{{ capApp.role }}</th>
+								<th></th>
+							</tr>
+						</thead>
+						<tbody>
+							<tr v-for="(r,i) in roles" class="default-inputs">
+								<td>
+									<input v-model="r.claimValue"
+										:placeholder="capApp.claimValueHint"
+									/>
+								</td>
+								<td>
+									<select v-model="r.roleId">
+										<option :value="null">-</option>
+										<optgroup
+											v-for="m in modules.filter(v => !v.hidden && hasAnyAssignableRole(v.roles))"
+											:label="m.name"
+										>
+											<option
+												v-for="rr in m.roles.filter(v => v.assignable && v.name !== 'everyone')"
+												:value="rr.id"
+											>{{ rr.name }}</option>
+										</optgroup>
+									</select>
+								</td>
+								<td>
+									<my-button image="delete.png"
+										@trigger="roleRemove(i)"
+										:cancel="true"
+									/>
+								</td>
+							</tr>
+						</tbody>
+					</table>
+				</template>
+			</div>
+		</div>
+	</div>`,
+	props:{
+		menuTitle:{ type:String, required:true }
+	},
+	data() {
+		return {
+			// inputs
+			name:'',
+			active:'',
+			assignRoles:'',
+			claimRoles:'',
+			claimUsername:'',
+			clientId:'',
+			clientSecret:'',
+			issuerUrl:'',
+			loginTemplateId:'',
+			roles:'',
+			scopes:'',
+			
+			// states
+			idEdit:-1,         // ID of OpenID Connect provider being edited (0 = new)
+			inputKeys:['name','active','assignRoles','claimRoles','claimUsername',
+				'clientId','clientSecret','issuerUrl','loginTemplateId','roles','scopes'],
+			inputsOrg:{},      // map of original input values, key = input key
+			oidcs:[],
+			templates:[]
+		};
+	},
+	mounted() {
+		this.get();
+		this.$store.commit('pageTitle',this.menuTitle);
+	},
+	computed:{
+		hasChanges:(s) => {
+			if(s.idEdit === -1)
+				return false;
+			
+			for(let k of s.inputKeys) {
+				if(JSON.stringify(s.inputsOrg[k]) !== JSON.stringify(s[k]))
+					return true;
+			}
+			return false;
+		},
+		
+		// inputs
+		scopesInput:{
+			get()  { return this.scopes.join(' '); },
+			set(v) { this.scopes = v.split(' ').filter(v => v !== ''); }
+		},
+		
+		// simple
+		isNew:      (s) => s.idEdit === 0,
+		redirectUri:(s) => `${window.location.origin}/oidc/callback`,
+		
+		// stores
+		modules:(s) => s.$store.getters['schema/modules'],
+		capApp: (s) => s.$store.getters.captions.admin.oidcs,
+		capGen: (s) => s.$store.getters.captions.generic
+	},
+	methods:{
+		// externals
+		hasAnyAssignableRole,
+		
+		// actions
+		close() {
+			this.idEdit = -1;
+		},
+		open(id) {
+			let oidc = {
+				name:'',
+				active:true,
+				assignRoles:false,
+				claimRoles:'groups',
+				claimUsername:'preferred_username',
+				clientId:'',
+				clientSecret:'',
+				issuerUrl:'',
+				loginTemplateId:null,
+				roles:[],
+				scopes:['openid','profile','email']
+			};
+			
+			if(id > 0) {
+				for(let o of this.oidcs) {
+					if(o.id === id) {
+						oidc = o;
+						break;
+					}
+				}
+			}
+			
+			// apply global template if empty
+			if(oidc.loginTemplateId === null && this.templates.length > 0)
+				oidc.loginTemplateId = this.templates[0].id;
+			
+			for(let k of this.inputKeys) {
+				this[k]           = JSON.parse(JSON.stringify(oidc[k]));
+				this.inputsOrg[k] = JSON.parse(JSON.stringify(oidc[k]));
+			}
+			this.idEdit = id;
+		},
+		roleAdd() {
+			this.roles.push({
+				oidcId:this.idEdit,
+				roleId:null,
+				claimValue:''
+			});
+		},
+		roleRemove(i) {
+			this.roles.splice(i,1);
+		},
+		
+		// backend calls
+		reloadBackendCache() {
+			ws.send('oidc','reload',{},false).then(
+				() => {},
+				this.$root.genericError
+			);
+		},
+		delAsk() {
+			this.$store.commit('dialog',{
+				captionBody:this.capApp.dialog.delete,
+				buttons:[{
+					cancel:true,
+					caption:this.capGen.button.delete,
+					exec:this.del,
+					image:'delete.png'
+				},{
+					caption:this.capGen.button.cancel,
+					image:'cancel.png'
+				}]
+			});
+		},
+		del() {
+			ws.send('oidc','del',{id:this.idEdit},true).then(
+				() => {
+					this.close();
+					this.get();
+					this.reloadBackendCache();
+				},
+				this.$root.genericError
+			);
+		},
+		get() {
+			ws.sendMultiple([
+				ws.prepare('oidc','get',{}),
+				ws.prepare('loginTemplate','get',{byId:0})
+			],true).then(
+				res => {
+					this.oidcs     = res[0].payload;
+					this.templates = res[1].payload;
+				},
+				this.$root.genericError
+			);
+		},
+		set() {
+			if(!this.hasChanges) return;
+			
+			ws.send('oidc','set',{
+				id:this.idEdit,
+				name:this.name,
+				active:this.active,
+				assignRoles:this.assignRoles,
+				claimRoles:this.claimRoles,
+				claimUsername:this.claimUsername,
+				clientId:this.clientId,
+				clientSecret:this.clientSecret,
+				issuerUrl:this.issuerUrl,
+				loginTemplateId:this.loginTemplateId,
+				roles:this.roles,
+				scopes:this.scopes
+			},true).then(
+				() => {
+					this.idEdit = -1;
+					this.get();
+					this.reloadBackendCache();
+				},
+				this.$root.genericError
+			);
+		}
+	}
+};
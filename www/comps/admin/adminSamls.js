@@ -0,0 +1,355 @@
+import {hasAnyAssignableRole} from '../shared/access.js';
+export {MyAdminSamls as default};
+
+let MyAdminSamls = {
+	name:'my-admin-samls',
+	template:`<div class="admin-samls contentBox grow">
+		
+		<div class="top">
+			<div class="area">
+				<img class="icon" src="images/lock.png" />
+				<h1>{{ menuTitle }}</h1>
+			</div>
+		</div>
+		<div class="top lower">
+			<div class="area">
+				<my-button image="add.png"
+					@trigger="open(0)"
+					:active="true"
+					:caption="capApp.button.new"
+				/>
+			</div>
+		</div>
+		
+		<div class="content no-padding">
+		
+			<div class="contentPart long">
+				<span v-html="capApp.description"></span>
+				<br /><br />
+				
+				<table class="default-inputs" v-if="samls.length !== 0">
+					<tbody>
+						<tr v-for="s in samls">
+							<td>{{ s.name }}</td>
+							<td>{{ s.idpEntityId }}</td>
+							<td><my-bool :modelValue="s.active" :readonly="true" /></td>
+							<td>
+								<my-button image="edit.png"
+									@trigger="open(s.id)"
+									:active="true"
+								/>
+							</td>
+						</tr>
+					</tbody>
+				</table>
+			</div>
+			
+			<div class="contentPart long" v-if="idEdit !== -1">
+				
+				<div class="contentPartHeader">
+					<img class="icon" src="images/edit.png" />
+					<h1>{{ capApp.title }}</h1>
+				</div>
+				
+				<div class="entry-actions">
+					<my-button image="save.png"
+						@trigger="set"
+						:active="hasChanges"
+						:caption="capGen.button.save"
+					/>
+					<my-button image="delete.png"
+						v-if="!isNew"
+						@trigger="delAsk"
+						:cancel="true"
+						:caption="capGen.button.delete"
+					/>
+					<my-button image="cancel.png"
+						@trigger="close"
+						:cancel="true"
+						:caption="capGen.button.close"
+					/>
+				</div>
+				
+				<table class="default-inputs">
+					<tbody>
+						<tr>
+							<td>{{ capGen.name }}</td>
+							<td><input v-model="name" :placeholder="capApp.nameHint" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.active }}</td>
+							<td><my-bool v-model="active" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.template }}</td>
+							<td>
+								<select v-model="loginTemplateId">
+									<option v-for="t in templates" :title="t.comment" :value="t.id">
+										{{ t.name }}
+									</option>
+								</select>
+							</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.idpEntityId }}</td>
+							<td><input v-model="idpEntityId" :placeholder="capApp.idpEntityIdHint" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.idpSsoUrl }}</td>
+							<td><input v-model="idpSsoUrl" :placeholder="capApp.idpSsoUrlHint" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.idpCertificate }}</td>
+							<td>
+								<textarea v-model="idpCertificate" :placeholder="capApp.idpCertificateHint"></textarea>
+							</td>
+						</tr>
+						<tr v-if="!isNew">
+							<td>{{ capApp.spMetadataUrl }}</td>
+							<td>
+								<input disabled="disabled" :value="spMetadataUrl" />
+								<span>{{ capApp.spMetadataUrlHint }}</span>
+							</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.attributeUsername }}</td>
+							<td>
+								<input v-model="attributeUsername" :placeholder="capApp.attributeUsernameHint" />
+							</td>
+						</tr>
+						<tr>
+							<td><span v-html="capApp.assignRoles" /></td>
+							<td><my-bool v-model="assignRoles" /></td>
+						</tr>
+						<tr v-if="assignRoles">
+							<td>{{ capApp.attributeGroups }}</td>
+							<td><input v-model="attributeGroups" :placeholder="capApp.attributeGroupsHint" /></td>
+						</tr>
+					</tbody>
+				</table>
+				
+				<template v-if="assignRoles">
+				
+					<h2 class="roles-title">{{ capApp.titleRoles }}</h2>
+					<div>
+						<my-button image="add.png"
+							@trigger="roleAdd()"
+							:caption="capGen.button.add"
+						/>
+					</div>
+					<br />
+					
+					<table v-if="roles.length !== 0">
+						<thead>
+							<tr>
+								<th>{{ capApp.groupName }}</th>
+								<th>{{ capApp.role }}</th>
+								<th></th>
+							</tr>
+						</thead>
+						<tbody>
+							<tr v-for="(r,i) in roles" class="default-inputs">
+								<td>
+									<input v-model="r.groupName"
+										:placeholder="capApp.groupNameHint"
+									/>
+								</td>
+								<td>
+									<select v-model="r.roleId">
+										<option :value="null">-</option>
+										<optgroup
+											v-for="m in modules.filter(v => !v.hidden && hasAnyAssignableRole(v.roles))"
+											:label="m.name"
+										>
+											<option
+												v-for="rr in m.roles.filter(v => v.assignable && v.name !== 'everyone')"
+												:value="rr.id"
+											>{{ rr.name }}</option>
+										</optgroup>
+									</select>
+								</td>
+								<td>
+									<my-button image="delete.png"
+										@trigger="roleRemove(i)"
+										:cancel="true"
+									/>
+								</td>
+							</tr>
+						</tbody>
+					</table>
+				</template>
+			</div>
+		</div>
+	</div>`,
+	props:{
+		menuTitle:{ type:String, required:true }
+	},
+	data() {
+		return {
+			// inputs
+			name:'',
+			active:'',
+			assignRoles:'',
+			attributeGroups:'',
+			attributeUsername:'',
+			idpCertificate:'',
+			idpEntityId:'',
+			idpSsoUrl:'',
+			loginTemplateId:'',
+			roles:'',
+			
+			// states
+			idEdit:-1,         // ID of SAML identity provider being edited (0 = new)
+			inputKeys:['name','active','assignRoles','attributeGroups','attributeUsername',
+				'idpCertificate','idpEntityId','idpSsoUrl','loginTemplateId','roles'],
+			inputsOrg:{},      // map of original input values, key = input key
+			samls:[],
+			templates:[]
+		};
+	},
+	mounted() {
+		this.get();
+		this.$store.commit('pageTitle',this.menuTitle);
+	},
+	computed:{
+		hasChanges:(s) => {
+			if(s.idEdit === -1)
+				return false;
+			
+			for(let k of s.inputKeys) {
+				if(JSON.stringify(s.inputsOrg[k]) !== JSON.stringify(s[k]))
+					return true;
+			}
+			return false;
+		},
+		
+		// simple
+		isNew:        (s) => s.idEdit === 0,
+		spMetadataUrl:(s) => `${window.location.origin}/saml/metadata/${s.idEdit}`,
+		
+		// stores
+		modules:(s) => s.$store.getters['schema/modules'],
+		capApp: (s) => s.$store.getters.captions.admin.samls,
+		capGen: (s) => s.$store.getters.captions.generic
+	},
+	methods:{
+		// externals
+		hasAnyAssignableRole,
+		
+		// actions
+		close() {
+			this.idEdit = -1;
+		},
+		open(id) {
+			let saml = {
+				name:'',
+				active:true,
+				assignRoles:false,
+				attributeGroups:'groups',
+				attributeUsername:'',
+				idpCertificate:'',
+				idpEntityId:'',
+				idpSsoUrl:'',
+				loginTemplateId:null,
+				roles:[]
+			};
+			
+			if(id > 0) {
+				for(let s of this.samls) {
+					if(s.id === id) {
+						saml = s;
+						break;
+					}
+				}
+			}
+			
+			// apply global template if empty
+			if(saml.loginTemplateId === null && this.templates.length > 0)
+				saml.loginTemplateId = this.templates[0].id;
+			
+			for(let k of this.inputKeys) {
+				this[k]           = JSON.parse(JSON.stringify(saml[k]));
+				this.inputsOrg[k] = JSON.parse(JSON.stringify(saml[k]));
+			}
+			this.idEdit = id;
+		},
+		roleAdd() {
+			this.roles.push({
+				samlId:this.idEdit,
+				roleId:null,
+				groupName:''
+			});
+		},
+		roleRemove(i) {
+			this.roles.splice(i,1);
+		},
+		
+		// backend calls
+		reloadBackendCache() {
+			ws.send('saml','reload',{},false).then(
+				() => {},
+				this.$root.genericError
+			);
+		},
+		delAsk() {
+			this.$store.commit('dialog',{
+				captionBody:this.capApp.dialog.delete,
+				buttons:[{
+					cancel:true,
+					caption:this.capGen.button.delete,
+					exec:this.del,
+					image:'delete.png'
+				},{
+					caption:this.capGen.button.cancel,
+					image:'cancel.png'
+				}]
+			});
+		},
+		del() {
+			ws.send('saml','del',{id:this.idEdit},true).then(
+				() => {
+					this.close();
+					this.get();
+					this.reloadBackendCache();
+				},
+				this.$root.genericError
+			);
+		},
+		get() {
+			ws.sendMultiple([
+				ws.prepare('saml','get',{}),
+				ws.prepare('loginTemplate','get',{byId:0})
+			],true).then(
+				res => {
+					this.samls     = res[0].payload;
+					this.templates = res[1].payload;
+				},
+				this.$root.genericError
+			);
+		},
+		set() {
+			if(!this.hasChanges) return;
+			
+			ws.send('saml','set',{
+				id:this.idEdit,
+				name:this.name,
+				active:this.active,
+				assignRoles:this.assignRoles,
+				attributeGroups:this.attributeGroups,
+				attributeUsername:this.attributeUsername,
+				idpCertificate:this.idpCertificate,
+				idpEntityId:this.idpEntityId,
+				idpSsoUrl:this.idpSsoUrl,
+				loginTemplateId:this.loginTemplateId,
+				roles:this.roles
+			},true).then(
+				() => {
+					this.idEdit = -1;
+					this.get();
+					this.reloadBackendCache();
+				},
+				this.$root.genericError
+			);
+		}
+	}
+};
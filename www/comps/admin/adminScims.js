@@ -0,0 +1,353 @@
+import {hasAnyAssignableRole} from '../shared/access.js';
+export {MyAdminScims as default};
+
+let MyAdminScims = {
+	name:'my-admin-scims',
+	template:`<div class="admin-scims contentBox grow">
+		
+		<div class="top">
+			<div class="area">
+				<img class="icon" src="images/hierarchy.png" />
+				<h1>{{ menuTitle }}</h1>
+			</div>
+		</div>
+		<div class="top lower">
+			<div class="area">
+				<my-button image="add.png"
+					@trigger="open(0)"
+					:active="true"
+					:caption="capApp.button.new"
+				/>
+			</div>
+		</div>
+		
+		<div class="content no-padding">
+		
+			<div class="contentPart long">
+				<span v-html="capApp.description"></span>
+				<br /><br />
+				
+				<table class="default-inputs" v-if="scims.length !== 0">
+					<tbody>
+						<tr v-for="s in scims">
+							<td>{{ s.name }}</td>
+							<td>{{ s.hasToken ? capApp.tokenSet : capApp.tokenNotSet }}</td>
+							<td><my-bool :modelValue="s.active" :readonly="true" /></td>
+							<td>
+								<my-button image="edit.png"
+									@trigger="open(s.id)"
+									:active="true"
+								/>
+							</td>
+						</tr>
+					</tbody>
+				</table>
+			</div>
+			
+			<div class="contentPart long" v-if="idEdit !== -1">
+				
+				<div class="contentPartHeader">
+					<img class="icon" src="images/edit.png" />
+					<h1>{{ capApp.title }}</h1>
+				</div>
+				
+				<div class="entry-actions">
+					<my-button image="save.png"
+						@trigger="set"
+						:active="hasChanges"
+						:caption="capGen.button.save"
+					/>
+					<my-button image="delete.png"
+						v-if="!isNew"
+						@trigger="delAsk"
+						:cancel="true"
+						:caption="capGen.button.delete"
+					/>
+					<my-button image="cancel.png"
+						@trigger="close"
+						:cancel="true"
+						:caption="capGen.button.close"
+					/>
+				</div>
+				
+				<table class="default-inputs">
+					<tbody>
+						<tr>
+							<td>{{ capGen.name }}</td>
+							<td><input v-model="name" :placeholder="capApp.nameHint" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.active }}</td>
+							<td><my-bool v-model="active" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.template }}</td>
+							<td>
+								<select v-model="loginTemplateId">
+									<option v-for="t in templates" :title="t.comment" :value="t.id">
+										{{ t.name }}
+									</option>
+								</select>
+							</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.endpointUrl }}</td>
+							<td>
+								<input disabled="disabled" :value="endpointUrl" />
+								<span>{{ capApp.endpointUrlHint }}</span>
+							</td>
+						</tr>
+						<tr v-if="!isNew">
+							<td>{{ capApp.token }}</td>
+							<td>
+								<div class="row gap centered">
+									<span v-if="token === ''">{{ hasToken ? capApp.tokenSet : capApp.tokenNotSet }}</span>
+									<input v-if="token !== ''" disabled="disabled" :value="token" />
+									<my-button image="refresh.png"
+										@trigger="tokenAsk"
+										:caption="capApp.button.token"
+									/>
+								</div>
+								<span v-if="token !== ''">{{ capApp.tokenHint }}</span>
+							</td>
+						</tr>
+						<tr>
+							<td><span v-html="capApp.assignRoles" /></td>
+							<td><my-bool v-model="assignRoles" /></td>
+						</tr>
+					</tbody>
+				</table>
+				
+				<template v-if="assignRoles">
+				
+					<h2 class="roles-title">{{ capApp.titleRoles }}</h2>
+					<div>
+						<my-button image="add.png"
+							@trigger="roleAdd()"
+							:caption="capGen.button.add"
+						/>
+					</div>
+					<br />
+					
+					<table v-if="roles.length !== 0">
+						<thead>
+							<tr>
+								<th>{{ capApp.groupName }}</th>
+								<th>{{ capApp.role }}</th>
+								<th></th>
+							</tr>
+						</thead>
+						<tbody>
+							<tr v-for="(r,i) in roles" class="default-inputs">
+								<td>
+									<input v-model="r.groupName"
+										:placeholder="capApp.groupNameHint"
+									/>
+								</td>
+								<td>
+									<select v-model="r.roleId">
+										<option :value="null">-</option>
+										<optgroup
+											v-for="m in modules.filter(v => !v.hidden && hasAnyAssignableRole(v.roles))"
+											:label="m.name"
+										>
+											<option
+												v-for="rr in m.roles.filter(v => v.assignable && v.name !== 'everyone')"
+												:value="rr.id"
+											>{{ rr.name }}</option>
+										</optgroup>
+									</select>
+								</td>
+								<td>
+									<my-button image="delete.png"
+										@trigger="roleRemove(i)"
+										:cancel="true"
+									/>
+								</td>
+							</tr>
+						</tbody>
+					</table>
+				</template>
+			</div>
+		</div>
+	</div>`,
+	props:{
+		menuTitle:{ type:String, required:true }
+	},
+	data() {
+		return {
+			// inputs
+			name:'',
+			active:'',
+			assignRoles:'',
+			loginTemplateId:'',
+			roles:'',
+			
+			// states
+			hasToken:false,
+			idEdit:-1,         // ID of SCIM connection being edited (0 = new)
+			inputKeys:['name','active','assignRoles','loginTemplateId','roles'],
+			inputsOrg:{},      // map of original input values, key = input key
+			scims:[],
+			templates:[],
+			token:''           // newly generated bearer token, only shown once
+		};
+	},
+	mounted() {
+		this.get();
+		this.$store.commit('pageTitle',this.menuTitle);
+	},
+	computed:{
+		hasChanges:(s) => {
+			if(s.idEdit === -1)
+				return false;
+			
+			for(let k of s.inputKeys) {
+				if(JSON.stringify(s.inputsOrg[k]) !== JSON.stringify(s[k]))
+					return true;
+			}
+			return false;
+		},
+		
+		// simple
+		endpointUrl:(s) => `${window.location.origin}/scim/v2`,
+		isNew:      (s) => s.idEdit === 0,
+		
+		// stores
+		modules:(s) => s.$store.getters['schema/modules'],
+		capApp: (s) => s.$store.getters.captions.admin.scims,
+		capGen: (s) => s.$store.getters.captions.generic
+	},
+	methods:{
+		// externals
+		hasAnyAssignableRole,
+		
+		// actions
+		close() {
+			this.idEdit = -1;
+		},
+		open(id) {
+			let scim = {
+				name:'',
+				active:true,
+				assignRoles:false,
+				hasToken:false,
+				loginTemplateId:null,
+				roles:[]
+			};
+			
+			if(id > 0) {
+				for(let s of this.scims) {
+					if(s.id === id) {
+						scim = s;
+						break;
+					}
+				}
+			}
+			
+			// apply global template if empty
+			if(scim.loginTemplateId === null && this.templates.length > 0)
+				scim.loginTemplateId = this.templates[0].id;
+			
+			for(let k of this.inputKeys) {
+				this[k]           = JSON.parse(JSON.stringify(scim[k]));
+				this.inputsOrg[k] = JSON.parse(JSON.stringify(scim[k]));
+			}
+			this.hasToken = scim.hasToken;
+			this.idEdit   = id;
+			this.token    = '';
+		},
+		roleAdd() {
+			this.roles.push({
+				scimId:this.idEdit,
+				roleId:null,
+				groupName:''
+			});
+		},
+		roleRemove(i) {
+			this.roles.splice(i,1);
+		},
+		tokenAsk() {
+			if(!this.hasToken)
+				return this.setToken();
+			
+			this.$store.commit('dialog',{
+				captionBody:this.capApp.dialog.token,
+				buttons:[{
+					cancel:true,
+					caption:this.capApp.button.token,
+					exec:this.setToken,
+					image:'refresh.png'
+				},{
+					caption:this.capGen.button.cancel,
+					image:'cancel.png'
+				}]
+			});
+		},
+		
+		// backend calls
+		delAsk() {
+			this.$store.commit('dialog',{
+				captionBody:this.capApp.dialog.delete,
+				buttons:[{
+					cancel:true,
+					caption:this.capGen.button.delete,
+					exec:this.del,
+					image:'delete.png'
+				},{
+					caption:this.capGen.button.cancel,
+					image:'cancel.png'
+				}]
+			});
+		},
+		del() {
+			ws.send('scim','del',{id:this.idEdit},true).then(
+				() => {
+					this.close();
+					this.get();
+				},
+				this.$root.genericError
+			);
+		},
+		get() {
+			ws.sendMultiple([
+				ws.prepare('scim','get',{}),
+				ws.prepare('loginTemplate','get',{byId:0})
+			],true).then(
+				res => {
+					this.scims     = res[0].payload;
+					this.templates = res[1].payload;
+				},
+				this.$root.genericError
+			);
+		},
+		set() {
+			if(!this.hasChanges) return;
+			
+			ws.send('scim','set',{
+				id:this.idEdit,
+				name:this.name,
+				active:this.active,
+				assignRoles:this.assignRoles,
+				loginTemplateId:this.loginTemplateId,
+				roles:this.roles
+			},true).then(
+				() => {
+					this.idEdit = -1;
+					this.get();
+				},
+				this.$root.genericError
+			);
+		},
+		setToken() {
+			ws.send('scim','setToken',{id:this.idEdit},true).then(
+				res => {
+					this.hasToken = true;
+					this.token    = res.payload;
+					this.get();
+				},
+				this.$root.genericError
+			);
+		}
+	}
+};
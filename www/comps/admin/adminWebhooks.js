@@ -0,0 +1,424 @@
+import {getUnixFormat} from '../shared/time.js';
+export {MyAdminWebhooks as default};
+
+let MyAdminWebhooks = {
+	name:'my-admin-webhooks',
+	template:`<div class="admin-webhooks contentBox grow">
+		
+		<div class="top">
+			<div class="area">
+				<img class="icon" src="images/globe.png" />
+				<h1>{{ menuTitle }}</h1>
+			</div>
+		</div>
+		<div class="top lower">
+			<div class="area">
+				<my-button image="add.png"
+					@trigger="open(0)"
+					:active="true"
+					:caption="capApp.button.new"
+				/>
+			</div>
+		</div>
+		
+		<div class="content no-padding">
+			
+			<div class="contentPart long">
+				<span v-html="capApp.description"></span>
+				<br /><br />
+				
+				<table class="default-inputs" v-if="webhooks.length !== 0">
+					<tbody>
+						<tr v-for="w in webhooks">
+							<td>{{ w.name }}</td>
+							<td>{{ getRelationName(w.relationId) }}</td>
+							<td>{{ w.url }}</td>
+							<td><my-bool :modelValue="w.active" :readonly="true" /></td>
+							<td>
+								<div class="row gap">
+									<my-button image="edit.png"
+										@trigger="open(w.id)"
+										:active="true"
+									/>
+									<my-button image="fileText.png"
+										@trigger="deliveriesShow(w.id)"
+										:caption="capApp.button.deliveries"
+									/>
+								</div>
+							</td>
+						</tr>
+					</tbody>
+				</table>
+			</div>
+			
+			<!-- webhook -->
+			<div class="contentPart long" v-if="idEdit !== -1">
+				
+				<div class="contentPartHeader">
+					<img class="icon" src="images/edit.png" />
+					<h1>{{ capApp.title }}</h1>
+				</div>
+				
+				<div class="entry-actions">
+					<my-button image="save.png"
+						@trigger="set"
+						:active="hasChanges && isValid"
+						:caption="capGen.button.save"
+					/>
+					<my-button image="delete.png"
+						v-if="!isNew"
+						@trigger="delAsk"
+						:cancel="true"
+						:caption="capGen.button.delete"
+					/>
+					<my-button image="cancel.png"
+						@trigger="close"
+						:cancel="true"
+						:caption="capGen.button.close"
+					/>
+				</div>
+				
+				<table class="default-inputs">
+					<tbody>
+						<tr>
+							<td>{{ capGen.name }}*</td>
+							<td><input v-model="name" :placeholder="capApp.nameHint" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.active }}</td>
+							<td><my-bool v-model="active" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.relation }}*</td>
+							<td>
+								<select :value="relationId" @change="relationId = $event.target.value !== '' ? $event.target.value : null">
+									<option value="">-</option>
+									<optgroup v-for="m in modules.filter(v => v.relations.length !== 0)" :label="m.name">
+										<option v-for="r in m.relations" :value="r.id">{{ r.name }}</option>
+									</optgroup>
+								</select>
+							</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.url }}*</td>
+							<td><input v-model="url" :placeholder="capApp.urlHint" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.secret }}</td>
+							<td>
+								<input v-model="secret" />
+								<span>{{ capApp.secretHint }}</span>
+							</td>
+						</tr>
+						<tr>
+							<td>{{ capApp.onInsert }}</td>
+							<td><my-bool v-model="onInsert" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.onUpdate }}</td>
+							<td><my-bool v-model="onUpdate" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.onDelete }}</td>
+							<td><my-bool v-model="onDelete" /></td>
+						</tr>
+						<tr>
+							<td>{{ capApp.skipVerify }}</td>
+							<td><my-bool v-model="skipVerify" /></td>
+						</tr>
+					</tbody>
+				</table>
+			</div>
+			
+			<!-- delivery history -->
+			<div class="contentPart long" v-if="deliveriesWebhookId !== -1">
+				
+				<div class="contentPartHeader">
+					<img class="icon" src="images/fileText.png" />
+					<h1>{{ capApp.titleDeliveries + ' (' + deliveriesTotal + ')' }}</h1>
+				</div>
+				
+				<div class="entry-actions default-inputs">
+					<my-button image="refresh.png"
+						@trigger="getDeliveries"
+						:caption="capGen.button.refresh"
+					/>
+					<my-button image="triangleLeft.png"
+						@trigger="offsetSet(false)"
+						:active="offset-limit >= 0"
+						:naked="true"
+					/>
+					<span>{{ String((offset / limit) + 1) + ' / ' + Math.max(pages,1) }}</span>
+					<my-button image="triangleRight.png"
+						@trigger="offsetSet(true)"
+						:active="offset+limit < deliveriesTotal"
+						:naked="true"
+					/>
+					<select v-model.number="limit" @change="startAtPageFirst">
+						<option>10</option>
+						<option>25</option>
+						<option>50</option>
+						<option>100</option>
+					</select>
+					<my-button
+						@trigger="failedOnly = !failedOnly;startAtPageFirst()"
+						:caption="capApp.failedOnly"
+						:image="failedOnly ? 'checkbox1.png' : 'checkbox0.png'"
+					/>
+					<my-button image="cancel.png"
+						@trigger="deliveriesWebhookId = -1"
+						:cancel="true"
+						:caption="capGen.button.close"
+					/>
+				</div>
+				
+				<span v-if="deliveriesTotal === 0"><i>{{ capApp.noDeliveries }}</i></span>
+				
+				<table class="generic-table bright" v-if="deliveriesTotal !== 0">
+					<thead>
+						<tr>
+							<th>{{ capApp.dateAdded }}</th>
+							<th>{{ capApp.event }}</th>
+							<th>{{ capApp.recordId }}</th>
+							<th>{{ capApp.attemptCount }}</th>
+							<th>{{ capApp.responseCode }}</th>
+							<th>{{ capApp.dateDone }}</th>
+							<th></th>
+						</tr>
+					</thead>
+					<tbody>
+						<tr v-for="d in deliveries">
+							<td>{{ getUnixFormat(d.dateAdded,settings.dateFormat+' H:i:S') }}</td>
+							<td>{{ d.event }}</td>
+							<td>{{ d.recordId }}</td>
+							<td>{{ d.attemptCount }}</td>
+							<td>{{ d.responseCode !== null ? d.responseCode : '-' }}</td>
+							<td>{{ d.dateDone !== null ? getUnixFormat(d.dateDone,settings.dateFormat+' H:i:S') : '-' }}</td>
+							<td>
+								<div class="row gap">
+									<my-button image="warning.png"
+										v-if="d.error !== null"
+										@trigger="showError(d)"
+										:caption="capApp.error"
+									/>
+									<my-button image="delete.png"
+										@trigger="delDelivery(d.id)"
+										:cancel="true"
+									/>
+								</div>
+							</td>
+						</tr>
+					</tbody>
+				</table>
+			</div>
+		</div>
+	</div>`,
+	props:{
+		menuTitle:{ type:String, required:true }
+	},
+	data() {
+		return {
+			// inputs
+			name:'',
+			active:'',
+			relationId:'',
+			url:'',
+			secret:'',
+			onInsert:'',
+			onUpdate:'',
+			onDelete:'',
+			skipVerify:'',
+			
+			// states
+			idEdit:-1,         // ID of webhook being edited (0 = new)
+			inputKeys:['name','active','relationId','url','secret','onInsert','onUpdate','onDelete','skipVerify'],
+			inputsOrg:{},      // map of original input values, key = input key
+			webhooks:[],
+			
+			// delivery history
+			deliveries:[],
+			deliveriesTotal:0,
+			deliveriesWebhookId:-1, // ID of webhook whose deliveries are shown (-1 = none)
+			failedOnly:false,
+			limit:25,
+			offset:0
+		};
+	},
+	mounted() {
+		this.get();
+		this.$store.commit('pageTitle',this.menuTitle);
+	},
+	computed:{
+		hasChanges:(s) => {
+			if(s.idEdit === -1)
+				return false;
+			
+			for(let k of s.inputKeys) {
+				if(JSON.stringify(s.inputsOrg[k]) !== JSON.stringify(s[k]))
+					return true;
+			}
+			return false;
+		},
+		
+		// simple
+		isNew:  (s) => s.idEdit === 0,
+		isValid:(s) => s.name !== '' && s.relationId !== null && s.url !== '',
+		pages:  (s) => Math.ceil(s.deliveriesTotal / s.limit),
+		
+		// stores
+		modules:      (s) => s.$store.getters['schema/modules'],
+		moduleIdMap:  (s) => s.$store.getters['schema/moduleIdMap'],
+		relationIdMap:(s) => s.$store.getters['schema/relationIdMap'],
+		capApp:       (s) => s.$store.getters.captions.admin.webhooks,
+		capGen:       (s) => s.$store.getters.captions.generic,
+		settings:     (s) => s.$store.getters.settings
+	},
+	methods:{
+		// externals
+		getUnixFormat,
+		
+		// presentation
+		getRelationName(relationId) {
+			if(typeof this.relationIdMap[relationId] === 'undefined')
+				return '-';
+			
+			const r = this.relationIdMap[relationId];
+			return `${this.moduleIdMap[r.moduleId].name}: ${r.name}`;
+		},
+		
+		// actions
+		close() {
+			this.idEdit = -1;
+		},
+		deliveriesShow(webhookId) {
+			this.deliveriesWebhookId = webhookId;
+			this.startAtPageFirst();
+		},
+		offsetSet(add) {
+			if(add) this.offset += this.limit;
+			else    this.offset -= this.limit;
+			this.getDeliveries();
+		},
+		open(id) {
+			let webhook = {
+				name:'',
+				active:true,
+				relationId:null,
+				url:'',
+				secret:'',
+				onInsert:true,
+				onUpdate:true,
+				onDelete:true,
+				skipVerify:false
+			};
+			
+			if(id > 0) {
+				for(let w of this.webhooks) {
+					if(w.id === id) {
+						webhook = w;
+						break;
+					}
+				}
+			}
+			
+			for(let k of this.inputKeys) {
+				this[k]           = JSON.parse(JSON.stringify(webhook[k]));
+				this.inputsOrg[k] = JSON.parse(JSON.stringify(webhook[k]));
+			}
+			this.idEdit = id;
+		},
+		showError(d) {
+			this.$store.commit('dialog',{
+				captionTop:this.capApp.error,
+				captionBody:d.error,
+				textDisplay:'textarea'
+			});
+		},
+		startAtPageFirst() {
+			this.offset = 0;
+			this.getDeliveries();
+		},
+		
+		// backend calls
+		delAsk() {
+			this.$store.commit('dialog',{
+				captionBody:this.capApp.dialog.delete,
+				buttons:[{
+					cancel:true,
+					caption:this.capGen.button.delete,
+					exec:this.del,
+					image:'delete.png'
+				},{
+					caption:this.capGen.button.cancel,
+					image:'cancel.png'
+				}]
+			});
+		},
+		del() {
+			ws.send('webhook','del',{id:this.idEdit},true).then(
+				() => {
+					if(this.deliveriesWebhookId === this.idEdit)
+						this.deliveriesWebhookId = -1;
+					
+					this.close();
+					this.reload();
+				},
+				this.$root.genericError
+			);
+		},
+		delDelivery(id) {
+			ws.send('webhookDelivery','del',{ids:[id]},true).then(
+				this.getDeliveries,
+				this.$root.genericError
+			);
+		},
+		get() {
+			ws.send('webhook','get',{},true).then(
+				res => this.webhooks = Object.values(res.payload).sort((a,b) => a.name.localeCompare(b.name)),
+				this.$root.genericError
+			);
+		},
+		getDeliveries() {
+			ws.send('webhookDelivery','get',{
+				limit:this.limit,
+				offset:this.offset,
+				webhookId:this.deliveriesWebhookId,
+				failed:this.failedOnly
+			},true).then(
+				res => {
+					this.deliveries      = res.payload.deliveries;
+					this.deliveriesTotal = res.payload.total;
+				},
+				this.$root.genericError
+			);
+		},
+		reload() {
+			ws.send('webhook','reload',{},true).then(
+				this.get,
+				this.$root.genericError
+			);
+		},
+		set() {
+			if(!this.hasChanges || !this.isValid) return;
+			
+			ws.send('webhook','set',{
+				id:this.idEdit,
+				name:this.name,
+				active:this.active,
+				relationId:this.relationId,
+				url:this.url,
+				secret:this.secret,
+				onInsert:this.onInsert,
+				onUpdate:this.onUpdate,
+				onDelete:this.onDelete,
+				skipVerify:this.skipVerify
+			},true).then(
+				() => {
+					this.idEdit = -1;
+					this.reload();
+				},
+				this.$root.genericError
+			);
+		}
+	}
+};
\ No newline at end of file
@@ -573,11 +573,15 @@ let MyApp = {
 					this.$store.commit('captionMapCustom',res.payload.captionMapCustom);
 					this.$store.commit('clusterNodeName',res.payload.clusterNodeName);
 					this.$store.commit('moduleIdMapMeta',res.payload.moduleIdMapMeta);
+					this.$store.commit('oidcProviders',res.payload.oidcProviders);
 					this.$store.commit('productionMode',res.payload.productionMode === 1);
 					this.$store.commit('pageTitleRefresh'); // update page title with new app name
 					this.$store.commit('pwaDomainMap',res.payload.pwaDomainMap);
+					this.$store.commit('pwResetEnable',res.payload.pwResetEnable);
+					this.$store.commit('samlProviders',res.payload.samlProviders);
 					this.$store.commit('searchDictionaries',res.payload.searchDictionaries);
 					this.$store.commit('tokenKeepEnable',res.payload.tokenKeepEnable);
+					this.$store.commit('webauthnPasswordless',res.payload.webauthnPasswordless);
 					
 					if(!res.payload.tokenKeepEnable)
 						this.$store.commit('local/tokenKeep',false);
@@ -163,6 +163,13 @@ let MyBuilder = {
 						<span>{{ capGen.articles }}</span>
 					</router-link>
 					
+					<router-link class="entry clickable"
+						:to="'/builder/mail-templates/'+module.id"
+					>
+						<img src="images/mail2.png" />
+						<span>{{ capGen.mailTemplates }}</span>
+					</router-link>
+					
 					<router-link class="entry clickable"
 						:to="'/builder/apis/'+module.id"
 					>
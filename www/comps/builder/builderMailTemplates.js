@@ -0,0 +1,283 @@
+import MyBuilderCaption  from './builderCaption.js';
+import {copyValueDialog} from '../shared/generic.js';
+export {MyBuilderMailTemplates as default};
+
+let MyBuilderMailTemplatesItem = {
+	name:'my-builder-mail-templates-item',
+	components:{MyBuilderCaption},
+	template:`<tbody>
+		<tr>
+			<td>
+				<div class="row gap">
+					<my-button image="save.png"
+						@trigger="set"
+						:active="!readonly && hasChanges"
+						:caption="isNew ? capGen.button.create : ''"
+						:captionTitle="isNew ? capGen.button.create : capGen.button.save"
+					/>
+					<my-button image="delete.png"
+						v-if="!isNew"
+						@trigger="delAsk"
+						:active="!readonly"
+						:cancel="true"
+						:captionTitle="capGen.button.delete"
+					/>
+				</div>
+			</td>
+			<td><input class="long" v-model="name" :disabled="readonly" :placeholder="isNew ? capApp.new : ''" /></td>
+			<td>
+				<my-button image="visible1.png"
+					@trigger="copyValueDialog(template.name,template.id,template.id)"
+					:active="!isNew"
+				/>
+			</td>
+			<td>
+				<select v-model="relationId" :disabled="readonly">
+					<option :value="null">-</option>
+					<option v-for="r in module.relations" :value="r.id">{{ r.name }}</option>
+				</select>
+			</td>
+			<td>
+				<my-builder-caption
+					v-model="captions.mailTemplateSubject"
+					:language="builderLanguage"
+					:readonly="readonly"
+				/>
+			</td>
+			<td>
+				<my-button image="edit.png"
+					@trigger="showContent = !showContent"
+					:caption="capGen.button.edit"
+				/>
+			</td>
+			<td>
+				<!-- mail template body pop up window -->
+				<div class="app-sub-window under-header" v-if="showContent" @mousedown.self="showContent = false">
+					<div class="contentBox builder-mail-templates-body shade popUp">
+						<div class="top lower">
+							<div class="area">
+								<img class="icon" src="images/mail2.png" />
+								<h1>{{ template.name }}</h1>
+							</div>
+							
+							<div class="area">
+								<span>{{ capApp.subject }}</span>
+								<my-builder-caption
+									v-model="captions.mailTemplateSubject"
+									:language="builderLanguage"
+									:readonly="readonly"
+								/>
+								<my-button image="languages.png"
+									@trigger="$emit('nextLanguage')"
+									:active="module.languages.length > 1"
+									:caption="builderLanguage"
+								/>
+							</div>
+							
+							<div class="area">
+								<my-button image="save.png"
+									@trigger="set"
+									:active="hasChanges"
+									:caption="capGen.button.save"
+								/>
+								<my-button image="cancel.png"
+									@trigger="showContent = false"
+									:cancel="true"
+									:captionTitle="capGen.button.close"
+								/>
+							</div>
+						</div>
+						<div class="builder-mail-templates-placeholders" v-if="relationId !== null">
+							<span>{{ capApp.placeholders }}</span>
+							<code v-for="a in relationIdMap[relationId].attributes" v-text="'{{.Record.' + a.name + '}}'"></code>
+						</div>
+						<div class="content grow no-padding builder-mail-templates-body-richtext">
+							<my-builder-caption
+								v-model="captions.mailTemplateBody"
+								@hotkey="handleHotkeys"
+								:contentName="''"
+								:language="builderLanguage"
+								:readonly="readonly"
+								:richtext="true"
+							/>
+						</div>
+					</div>
+				</div>
+			</td>
+		</tr>
+	</tbody>`,
+	props:{
+		builderLanguage:{ type:String,  required:true },
+		module:         { type:Object,  required:true },
+		readonly:       { type:Boolean, required:true },
+		template:       { type:Object,  required:false,
+			default() { return{
+				id:null,
+				relationId:null,
+				name:'',
+				captions:{
+					mailTemplateSubject:{},
+					mailTemplateBody:{}
+				}
+			}}
+		}
+	},
+	emits:['nextLanguage'],
+	data() {
+		return {
+			captions:JSON.parse(JSON.stringify(this.template.captions)),
+			name:this.template.name,
+			relationId:this.template.relationId,
+			
+			// states
+			showContent:false
+		};
+	},
+	computed:{
+		hasChanges:(s) => s.name !== s.template.name
+			|| s.relationId !== s.template.relationId
+			|| JSON.stringify(s.captions) !== JSON.stringify(s.template.captions),
+		
+		// simple states
+		isNew:(s) => s.template.id === null,
+		
+		// stores
+		relationIdMap:(s) => s.$store.getters['schema/relationIdMap'],
+		capApp:       (s) => s.$store.getters.captions.builder.mailTemplates,
+		capGen:       (s) => s.$store.getters.captions.generic
+	},
+	mounted() {
+		window.addEventListener('keydown',this.handleHotkeys);
+	},
+	unmounted() {
+		window.removeEventListener('keydown',this.handleHotkeys);
+	},
+	methods:{
+		// externals
+		copyValueDialog,
+		
+		// actions
+		handleHotkeys(e) {
+			if(e.key === 'Escape' && this.showContent)
+				this.showContent = false;
+			
+			if(e.ctrlKey && e.key === 's') {
+				e.preventDefault();
+				
+				if(this.hasChanges)
+					this.set();
+			}
+			
+			if(e.ctrlKey && e.key === 'q') {
+				e.preventDefault();
+				
+				this.$emit('nextLanguage');
+			}
+		},
+		
+		// backend calls
+		delAsk() {
+			this.$store.commit('dialog',{
+				captionBody:this.capApp.dialog.delete,
+				buttons:[{
+					cancel:true,
+					caption:this.capGen.button.delete,
+					exec:this.del,
+					image:'delete.png'
+				},{
+					caption:this.capGen.button.cancel,
+					image:'cancel.png'
+				}]
+			});
+		},
+		del() {
+			ws.send('mailTemplate','del',{id:this.template.id},true).then(
+				() => this.$root.schemaReload(this.module.id),
+				this.$root.genericError
+			);
+		},
+		set() {
+			ws.send('mailTemplate','set',{
+				id:this.template.id,
+				moduleId:this.module.id,
+				relationId:this.relationId,
+				name:this.name,
+				captions:this.captions
+			},true).then(
+				() => {
+					if(this.isNew) {
+						this.name       = '';
+						this.relationId = null;
+						this.captions   = {
+							mailTemplateSubject:{},
+							mailTemplateBody:{}
+						};
+					}
+					this.$root.schemaReload(this.module.id);
+				},
+				this.$root.genericError
+			);
+		}
+	}
+};
+
+let MyBuilderMailTemplates = {
+	name:'my-builder-mail-templates',
+	components:{MyBuilderMailTemplatesItem},
+	template:`<div class="builder-mail-templates contentBox grow">
+		<div class="top lower">
+			<div class="area nowrap">
+				<img class="icon" src="images/mail2.png" />
+				<h1 class="title">{{ capApp.title }}</h1>
+			</div>
+		</div>
+		
+		<div class="content default-inputs" v-if="module">
+			<p class="builder-mail-templates-help">{{ capApp.help }}</p>
+			<table>
+				<thead>
+					<tr>
+						<th>{{ capGen.actions }}</th>
+						<th>{{ capGen.name }}</th>
+						<th>{{ capGen.id }}</th>
+						<th>{{ capGen.relation }}</th>
+						<th>{{ capApp.subject }}</th>
+						<th colspan="2">{{ capApp.body }}</th>
+					</tr>
+				</thead>
+				
+				<!-- new mail template -->
+				<my-builder-mail-templates-item
+					@nextLanguage="$emit('nextLanguage')"
+					:builderLanguage="builderLanguage"
+					:module="module"
+					:readonly="readonly"
+				/>
+				
+				<!-- existing mail templates -->
+				<my-builder-mail-templates-item
+					v-for="t in module.mailTemplates"
+					@nextLanguage="$emit('nextLanguage')"
+					:builderLanguage="builderLanguage"
+					:key="t.id"
+					:module="module"
+					:readonly="readonly"
+					:template="t"
+				/>
+			</table>
+		</div>
+	</div>`,
+	emits:['nextLanguage'],
+	props:{
+		builderLanguage:{ type:String,  required:true },
+		id:             { type:String,  required:true },
+		readonly:       { type:Boolean, required:true }
+	},
+	computed:{
+		// stores
+		module:     (s) => typeof s.moduleIdMap[s.id] === 'undefined' ? false : s.moduleIdMap[s.id],
+		moduleIdMap:(s) => s.$store.getters['schema/moduleIdMap'],
+		capApp:     (s) => s.$store.getters.captions.builder.mailTemplates,
+		capGen:     (s) => s.$store.getters.captions.generic
+	}
+};
@@ -276,7 +276,7 @@ let MyCaptionMapItemValue = {
 	</td>`,
 	data() {
 		return {
-			contentRichtext:['articleBody'],
+			contentRichtext:['articleBody','mailTemplateBody'],
 			showRichtextContent:null
 		};
 	},
@@ -614,6 +614,16 @@ let MyCaptionMap = {
 							:name="capGen.articles"
 							:readonly="readonly"
 						/>
+						<!-- mail templates -->
+						<my-caption-map-items icon="mail2.png"
+							@update="storeChange"
+							:isCustom="isCustom"
+							:items="captionsMailTemplates"
+							:languages="showLanguageCodes"
+							:languagesCustom="languagesCustom"
+							:name="capGen.mailTemplates"
+							:readonly="readonly"
+						/>
 						<!-- APIs -->
 						<my-caption-map-items icon="api.png"
 							@update="storeChange"
@@ -835,6 +845,7 @@ let MyCaptionMap = {
 		captionsArticles:   (s) => s.makeSortedItemList(s.captionMap.articleIdMap,s.articleIdMap),
 		captionsJsFunctions:(s) => s.makeSortedItemList(s.captionMap.jsFunctionIdMap,s.jsFunctionIdMap),
 		captionsLoginForms: (s) => s.makeSortedItemList(s.captionMap.loginFormIdMap,s.loginFormIdMap),
+		captionsMailTemplates:(s) => s.makeSortedItemList(s.captionMap.mailTemplateIdMap,s.mailTemplateIdMap),
 		captionsPgFunctions:(s) => s.makeSortedItemList(s.captionMap.pgFunctionIdMap,s.pgFunctionIdMap),
 		captionsRoles:      (s) => s.makeSortedItemList(s.captionMap.roleIdMap,s.roleIdMap),
 		captionsWidgets:    (s) => s.makeSortedItemList(s.captionMap.widgetIdMap,s.widgetIdMap),
@@ -854,6 +865,7 @@ let MyCaptionMap = {
 		formIdMap:      (s) => s.$store.getters['schema/formIdMap'],
 		jsFunctionIdMap:(s) => s.$store.getters['schema/jsFunctionIdMap'],
 		loginFormIdMap: (s) => s.$store.getters['schema/loginFormIdMap'],
+		mailTemplateIdMap:(s) => s.$store.getters['schema/mailTemplateIdMap'],
 		pgFunctionIdMap:(s) => s.$store.getters['schema/pgFunctionIdMap'],
 		relationIdMap:  (s) => s.$store.getters['schema/relationIdMap'],
 		roleIdMap:      (s) => s.$store.getters['schema/roleIdMap'],
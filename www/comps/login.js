@@ -1,5 +1,7 @@
 import {consoleError} from './shared/error.js';
 import {
+	aesGcmDecryptBase64,
+	aesGcmEncryptBase64,
 	aesGcmExportBase64,
 	pbkdf2PassToAesGcmKey
 } from './shared/crypto.js';
@@ -7,6 +9,10 @@ import {
 	getLineBreaksParsedToHtml,
 	openLink
 } from './shared/generic.js';
+import {
+	webauthnAvailable,
+	webauthnGet
+} from './shared/webauthn.js';
 export {MyLogin as default};
 
 let MyLogin = {
@@ -58,12 +64,74 @@ let MyLogin = {
 				<span>{{ message.error[language] }}</span>
 			</div>
 			
+			<!-- external identity provider error message -->
+			<div class="message warning" v-if="idpErr">
+				<img src="images/warning.png" />
+				<span>{{ message.idpErr[language] }}</span>
+			</div>
+			
 			<!-- license error message -->
 			<div class="message warning" v-if="licenseErrCode !== null">
 				<img src="images/warning.png" />
 				<span>{{ message.license[licenseErrCode][language] }}</span>
 			</div>
 			
+			<!-- password reset messages -->
+			<div class="message" v-if="pwResetState === 'sent'">
+				<img src="images/mail.png" />
+				<span>{{ message.pwResetSent[language] }}</span>
+			</div>
+			<div class="message" v-if="pwResetState === 'done'">
+				<img src="images/ok.png" />
+				<span>{{ message.pwResetDone[language] }}</span>
+			</div>
+			<div class="message warning" v-if="pwResetState === 'failed'">
+				<img src="images/warning.png" />
+				<span>{{ message.pwResetFailed[language] }}</span>
+			</div>
+			
+			<!-- password reset, request link via mail or set new password with link -->
+			<template v-if="pwResetMode !== null">
+				<h3>{{ message.pwReset[language] }}</h3>
+				<div class="credentials">
+					<input autocomplete="username" type="text" spellcheck="false"
+						v-if="pwResetMode === 'request'"
+						@keyup.enter="pwResetSend"
+						v-model="username"
+						v-focus
+						:placeholder="message.username[language]"
+					/>
+					<template v-if="pwResetMode === 'set'">
+						<input autocomplete="new-password" type="password"
+							@keyup="pwResetState = null"
+							@keyup.enter="pwResetSend"
+							v-model="pwNew0"
+							v-focus
+							:placeholder="message.pwNew0[language]"
+						/>
+						<input autocomplete="new-password" type="password"
+							@keyup="pwResetState = null"
+							@keyup.enter="pwResetSend"
+							v-model="pwNew1"
+							:placeholder="message.pwNew1[language]"
+						/>
+					</template>
+				</div>
+				<div class="row centered space-between">
+					<button class="clickable"
+						@click="pwResetClose"
+						@keyup.enter="pwResetClose"
+					>{{ message.pwResetCancel[language] }}</button>
+					<button
+						@click="pwResetSend"
+						@keyup.enter="pwResetSend"
+						:class="{ active:isValidPwReset, clickable:isValidPwReset }"
+					>{{ message.pwResetSend[pwResetMode][language] }}</button>
+				</div>
+			</template>
+			
+			<template v-if="pwResetMode === null">
+			
 			<!-- credentials input -->
 			<div class="credentials" v-if="!showMfa">
 				<input autocomplete="username" type="text" spellcheck="false"
@@ -82,21 +150,51 @@ let MyLogin = {
 				/>
 			</div>
 			
+			<!-- password change, required by admin or after password expired -->
+			<template v-if="pwChange && !showMfa">
+				<div class="message warning">
+					<img src="images/warning.png" />
+					<span>{{ pwChangeError === null ? message.pwChange[language] : displayPwChangeError(pwChangeError) }}</span>
+				</div>
+				<div class="credentials">
+					<input autocomplete="new-password" type="password"
+						@keyup="badAuth = false"
+						@keyup.enter="authenticate"
+						v-model="pwNew0"
+						v-focus
+						:placeholder="message.pwNew0[language]"
+					/>
+					<input autocomplete="new-password" type="password"
+						@keyup="badAuth = false"
+						@keyup.enter="authenticate"
+						v-model="pwNew1"
+						:placeholder="message.pwNew1[language]"
+					/>
+				</div>
+			</template>
+			
 			<!-- MFA input -->
 			<template v-if="showMfa">
 				<h3>{{ message.mfa[language] }}</h3>
-				<select v-model.number="mfaTokenId">
-					<option v-for="t in mfaTokens" :value="t.id">
-						{{ t.name }}
-					</option>
-				</select>
-				<input autocomplete="one-time-code" type="text" maxlength="6"
-					@keyup="badAuth = false"
-					@keyup.enter="authenticate"
-					v-model="mfaTokenPin"
-					v-focus
-					:placeholder="message.mfaHint[language]"
-				/>
+				<template v-if="mfaTokens.length !== 0">
+					<select v-model.number="mfaTokenId">
+						<option v-for="t in mfaTokens" :value="t.id">
+							{{ t.name }}
+						</option>
+					</select>
+					<input autocomplete="one-time-code" type="text" maxlength="6"
+						@keyup="badAuth = false"
+						@keyup.enter="authenticate"
+						v-model="mfaTokenPin"
+						v-focus
+						:placeholder="message.mfaHint[language]"
+					/>
+				</template>
+				<button class="active clickable"
+					v-if="mfaWebauthn !== null"
+					@click="authenticateMfaWebauthn"
+					@keyup.enter="authenticateMfaWebauthn"
+				>{{ message.mfaWebauthn[language] }}</button>
 			</template>
 			
 			<div class="row centered space-between">
@@ -113,6 +211,31 @@ let MyLogin = {
 					:class="{ active:isValid, clickable:isValid }"
 				>{{ message.login[language] }}</button>
 			</div>
+			
+			<!-- password reset via mail -->
+			<div class="pw-reset" v-if="!showMfa && pwResetEnable">
+				<span class="clickable" @click="pwResetOpen">{{ message.pwResetOpen[language] }}</span>
+			</div>
+			
+			<!-- external identity providers (OpenID Connect, SAML) & passwordless login (WebAuthn) -->
+			<div class="idp-providers" v-if="!showMfa && (oidcProviders.length !== 0 || samlProviders.length !== 0 || showWebauthn)">
+				<button class="active clickable"
+					v-if="showWebauthn"
+					@click="authenticateWebauthn"
+					@keyup.enter="authenticateWebauthn"
+				>{{ message.loginWebauthn[language] }}</button>
+				<button class="active clickable"
+					v-for="p in oidcProviders"
+					@click="authenticateIdpStart('oidc',p.id)"
+					@keyup.enter="authenticateIdpStart('oidc',p.id)"
+				>{{ message.loginWith[language] + p.name }}</button>
+				<button class="active clickable"
+					v-for="p in samlProviders"
+					@click="authenticateIdpStart('saml',p.id)"
+					@keyup.enter="authenticateIdpStart('saml',p.id)"
+				>{{ message.loginWith[language] + p.name }}</button>
+			</div>
+			</template>
 		</template>
 		
 		<!-- not ready for login yet (downloading schema/public data/...) -->
@@ -149,7 +272,10 @@ let MyLogin = {
 			mfaTokens:[],     // list of TOTP tokens to choose from, [{id:12,name:'My Phone'},{...}]
 			mfaTokenId:null,  // selected TOTP token
 			mfaTokenPin:null, // entered TOTP PIN (6 digit code)
+			mfaWebauthn:null, // options for WebAuthn assertion, null if no WebAuthn credential is available
 			password:'',
+			pwNew0:'',        // new password, entered for password reset or required password change
+			pwNew1:'',        // new password, repeated
 			username:'',
 			
 			// states
@@ -157,6 +283,12 @@ let MyLogin = {
 			badAuth:false,       // authentication failed
 			licenseErrCode:null, // error with system license
 			loading:false,
+			idpErr:false,        // authentication via external identity provider failed
+			pwChange:false,      // password must be changed before login can complete
+			pwChangeError:null,  // reason why new password was rejected, null if not rejected
+			pwResetMode:null,    // password reset: null (inactive), 'request' (request link via mail), 'set' (set new password with link)
+			pwResetState:null,   // password reset result: null, 'sent' (link sent if login exists), 'done' (password was set), 'failed'
+			pwResetToken:null,   // password reset token from link
 			showError:false,
 			
 			// default messages
@@ -185,6 +317,10 @@ let MyLogin = {
 						en_US:'Concurrent login count reached - please contact your system administrator'
 					}
 				},
+				idpErr:{
+					de:'Anmeldung über Identitätsanbieter fehlgeschlagen',
+					en_US:'Login via identity provider failed'
+				},
 				loading:{
 					de:'Am Laden...',
 					en_US:'Loading...'
@@ -193,6 +329,14 @@ let MyLogin = {
 					de:'Anmelden',
 					en_US:'Login'
 				},
+				loginWebauthn:{
+					de:'Mit Passkey anmelden',
+					en_US:'Login with passkey'
+				},
+				loginWith:{
+					de:'Anmelden mit ',
+					en_US:'Login with '
+				},
 				maintenanceMode:{
 					de:'Wartungsmodus ist aktiv',
 					en_US:'Maintenance mode is active'
@@ -205,10 +349,70 @@ let MyLogin = {
 					de:'6-stelliger Validierungs-Code',
 					en_US:'6 digit validation code'
 				},
+				mfaWebauthn:{
+					de:'Sicherheitsschlüssel verwenden',
+					en_US:'Use security key'
+				},
 				password:{
 					de:'Passwort',
 					en_US:'Password'
 				},
+				pwChange:{
+					de:'Das Passwort muss geändert werden - bitte ein neues Passwort wählen',
+					en_US:'Your password must be changed - please choose a new password'
+				},
+				pwChangeErrors:{
+					PW_USED_BEFORE:{
+						de:'Das neue Passwort wurde bereits verwendet - bitte ein anderes Passwort wählen',
+						en_US:'The new password was used before - please choose a different password'
+					},
+					other:{
+						de:'Das neue Passwort erfüllt nicht die Anforderungen (Länge, Zeichen) - bitte ein anderes Passwort wählen',
+						en_US:'The new password does not meet the requirements (length, characters) - please choose a different password'
+					}
+				},
+				pwNew0:{
+					de:'Neues Passwort',
+					en_US:'New password'
+				},
+				pwNew1:{
+					de:'Neues Passwort wiederholen',
+					en_US:'Repeat new password'
+				},
+				pwReset:{
+					de:'Passwort zurücksetzen',
+					en_US:'Reset password'
+				},
+				pwResetCancel:{
+					de:'Zurück',
+					en_US:'Back'
+				},
+				pwResetDone:{
+					de:'Das Passwort wurde geändert - bitte mit dem neuen Passwort anmelden',
+					en_US:'Your password was changed - please login with your new password'
+				},
+				pwResetFailed:{
+					de:'Das Passwort konnte nicht geändert werden - der Link ist abgelaufen oder das Passwort erfüllt nicht die Anforderungen',
+					en_US:'Your password could not be changed - the link has expired or the password does not meet the requirements'
+				},
+				pwResetOpen:{
+					de:'Passwort vergessen?',
+					en_US:'Forgot password?'
+				},
+				pwResetSend:{
+					request:{
+						de:'Link anfordern',
+						en_US:'Request link'
+					},
+					set:{
+						de:'Passwort setzen',
+						en_US:'Set password'
+					}
+				},
+				pwResetSent:{
+					de:'Wenn eine passende Anmeldung mit Mail-Adresse existiert, wurde ein Link zum Zurücksetzen des Passworts versendet',
+					en_US:'If a matching login with a mail address exists, a link to reset the password was sent'
+				},
 				stayLoggedIn:{
 					de:'Angemeldet bleiben',
 					en_US:'Stay logged in'
@@ -237,30 +441,44 @@ let MyLogin = {
 			border-color:${s.colorLogin.darken(30).toString()};
 		`,
 		isValid: (s) => {
+			if(!s.showMfa && s.pwChange)
+				return !s.badAuth && s.username !== '' && s.password !== '' && s.pwNew0 !== '' && s.pwNew0 === s.pwNew1;
+			
 			if(!s.showMfa)
 				return !s.badAuth && s.username !== '' && s.password !== '';
 			
 			return !s.badAuth && s.mfaTokenId !== null && s.mfaTokenPin !== null;
 		},
-		showCustom:(s) => s.activated && (s.companyName !== '' || s.companyWelcome !== ''),
-		showMfa:   (s) => s.mfaTokens.length !== 0,
+		isValidPwReset:(s) => {
+			if(s.pwResetMode === 'request')
+				return s.username !== '';
+			
+			return s.pwResetState === null && s.pwNew0 !== '' && s.pwNew0 === s.pwNew1;
+		},
+		showCustom:  (s) => s.activated && (s.companyName !== '' || s.companyWelcome !== ''),
+		showMfa:     (s) => s.mfaTokens.length !== 0 || s.mfaWebauthn !== null,
+		showWebauthn:(s) => s.webauthnPasswordless && s.webauthnAvailable(),
 		
 		// stores
-		activated:         (s) => s.$store.getters['local/activated'],
-		appName:           (s) => s.$store.getters['local/appName'],
-		appVersion:        (s) => s.$store.getters['local/appVersion'],
-		companyName:       (s) => s.$store.getters['local/companyName'],
-		companyWelcome:    (s) => s.$store.getters['local/companyWelcome'],
-		customLogo:        (s) => s.$store.getters['local/customLogo'],
-		customLogoUrl:     (s) => s.$store.getters['local/customLogoUrl'],
-		token:             (s) => s.$store.getters['local/token'],
-		tokenKeep:         (s) => s.$store.getters['local/tokenKeep'],
-		clusterNodeName:   (s) => s.$store.getters.clusterNodeName,
-		colorLogin:        (s) => s.$store.getters.colorLogin,
-		cryptoApiAvailable:(s) => s.$store.getters.cryptoApiAvailable,
-		kdfIterations:     (s) => s.$store.getters.constants.kdfIterations,
-		productionMode:    (s) => s.$store.getters.productionMode,
-		tokenKeepEnable:   (s) => s.$store.getters.tokenKeepEnable
+		activated:           (s) => s.$store.getters['local/activated'],
+		appName:             (s) => s.$store.getters['local/appName'],
+		appVersion:          (s) => s.$store.getters['local/appVersion'],
+		companyName:         (s) => s.$store.getters['local/companyName'],
+		companyWelcome:      (s) => s.$store.getters['local/companyWelcome'],
+		customLogo:          (s) => s.$store.getters['local/customLogo'],
+		customLogoUrl:       (s) => s.$store.getters['local/customLogoUrl'],
+		token:               (s) => s.$store.getters['local/token'],
+		tokenKeep:           (s) => s.$store.getters['local/tokenKeep'],
+		clusterNodeName:     (s) => s.$store.getters.clusterNodeName,
+		colorLogin:          (s) => s.$store.getters.colorLogin,
+		cryptoApiAvailable:  (s) => s.$store.getters.cryptoApiAvailable,
+		kdfIterations:       (s) => s.$store.getters.constants.kdfIterations,
+		oidcProviders:       (s) => s.$store.getters.oidcProviders,
+		productionMode:      (s) => s.$store.getters.productionMode,
+		pwResetEnable:       (s) => s.$store.getters.pwResetEnable,
+		samlProviders:       (s) => s.$store.getters.samlProviders,
+		tokenKeepEnable:     (s) => s.$store.getters.tokenKeepEnable,
+		webauthnPasswordless:(s) => s.$store.getters.webauthnPasswordless
 	},
 	watch:{
 		loginReady(v) {
@@ -276,6 +494,31 @@ let MyLogin = {
 					this.$router.replace(`${window.location.hash.substring(0,pos)}?${params.toString()}`);
 					return;
 				}
+				
+				// set new password via password reset link
+				if(params.has('pwReset')) {
+					this.pwResetMode  = 'set';
+					this.pwResetToken = params.get('pwReset');
+					params.delete('pwReset');
+					this.$router.replace(`${window.location.hash.substring(0,pos)}?${params.toString()}`);
+					return;
+				}
+				
+				// authenticate via one-time code from external identity provider (OpenID Connect, SAML)
+				for(const provider of ['oidc','saml']) {
+					if(!params.has(provider) && !params.has(`${provider}Error`))
+						continue;
+					
+					if(params.has(provider))
+						this.authenticateIdp(provider,params.get(provider));
+					else
+						this.idpErr = true;
+					
+					params.delete(provider);
+					params.delete(`${provider}Error`);
+					this.$router.replace(`${window.location.hash.substring(0,pos)}?${params.toString()}`);
+					return;
+				}
 			}
 			
 			// attempt authentication if token is available
@@ -300,13 +543,22 @@ let MyLogin = {
 	},
 	methods:{
 		// externals
+		aesGcmDecryptBase64,
+		aesGcmEncryptBase64,
 		aesGcmExportBase64,
 		consoleError,
 		getLineBreaksParsedToHtml,
 		pbkdf2PassToAesGcmKey,
 		openLink,
+		webauthnAvailable,
+		webauthnGet,
 		
 		// misc
+		displayPwChangeError(code) {
+			return this.message.pwChangeErrors[code] !== undefined
+				? this.message.pwChangeErrors[code][this.language]
+				: this.message.pwChangeErrors.other[this.language];
+		},
 		handleError(action,msg) {
 			if(msg.startsWith('{ERR_LIC'))
 				this.licenseErrCode = msg;
@@ -315,12 +567,54 @@ let MyLogin = {
 			
 			switch(action) {
 				case 'aesExport': break;                      // very unexpected, should not happen
+				case 'authIdp':   this.idpErr = true; break;  // one-time code of identity provider invalid or expired
 				case 'authToken': break;                      // token auth failed, to be expected, can expire
 				case 'authUser':  this.badAuth = true; break; // user authorization failed, mark inputs invalid
 				case 'kdfCreate': break;                      // very unexpected, should not happen
+				case 'keyUpdate': break;                      // private key could not be re-encrypted after password change
+				case 'webauthn':  break;                      // ceremony aborted by user or authenticator
 			}
 			this.loading = false;
 		},
+		pwResetClose() {
+			this.pwResetMode  = null;
+			this.pwResetState = null;
+			this.pwResetToken = null;
+			this.pwNew0       = '';
+			this.pwNew1       = '';
+		},
+		pwResetOpen() {
+			this.pwResetMode  = 'request';
+			this.pwResetState = null;
+		},
+		pwResetSend() {
+			if(!this.isValidPwReset) return;
+			
+			const done = state => {
+				if(state !== 'failed')
+					this.pwResetClose();
+				
+				this.pwResetState = state;
+				this.loading      = false;
+			};
+			
+			if(this.pwResetMode === 'request') {
+				ws.send('loginPassword','resetRequest',{username:this.username},true).then(
+					() => done('sent'),
+					() => done('failed')
+				);
+			} else {
+				ws.send('loginPassword','reset',{
+					token:this.pwResetToken,
+					pwNew0:this.pwNew0,
+					pwNew1:this.pwNew1
+				},true).then(
+					() => done('done'),
+					() => done('failed')
+				);
+			}
+			this.loading = true;
+		},
 		parentError() {
 			// stop loading, when parent caught error
 			this.loading    = false;
@@ -330,20 +624,35 @@ let MyLogin = {
 		// authenticate by username/password with/without MFA
 		authenticate() {
 			if(!this.isValid) return;
-			
+			this.authenticateUser(null);
+		},
+		authenticateUser(mfaWebauthnAssertion) {
 			ws.send('auth','user',{
 				username:this.username,
 				password:this.password,
+				passwordNew:this.pwChange ? this.pwNew0 : '',
 				mfaTokenId:this.mfaTokenId,
-				mfaTokenPin:this.mfaTokenPin
+				mfaTokenPin:this.mfaTokenPin,
+				mfaWebauthn:mfaWebauthnAssertion
 			},true).then(
 				res => {
-					// MFA token list returned, MFA is required
-					if(res.payload.mfaTokens.length !== 0) {
+					// password must be changed first, new password is sent with next attempt
+					if(res.payload.pwChangeRequired) {
+						this.pwChange      = true;
+						this.pwChangeError = res.payload.pwChangeError !== '' ? res.payload.pwChangeError : null;
+						this.loading       = false;
+						return;
+					}
+					
+					// MFA token list or WebAuthn options returned, MFA is required
+					if(res.payload.mfaTokens.length !== 0 || res.payload.mfaWebauthn !== null) {
 						this.mfaTokens   = res.payload.mfaTokens;
-						this.mfaTokenId  = res.payload.mfaTokens[0].id;
-						this.mfaTokenPin = '';
-						this.loading     = false;
+						this.mfaWebauthn = res.payload.mfaWebauthn;
+						if(this.mfaTokens.length !== 0) {
+							this.mfaTokenId  = this.mfaTokens[0].id;
+							this.mfaTokenPin = '';
+						}
+						this.loading = false;
 						return;
 					}
 					
@@ -358,6 +667,19 @@ let MyLogin = {
 			);
 			this.loading = true;
 		},
+		authenticateMfaWebauthn() {
+			this.webauthnGet(this.mfaWebauthn).then(
+				assertion => this.authenticateUser(assertion),
+				err => this.handleError('webauthn',err.message)
+			);
+			
+			// challenge is single use, new one is received on next attempt
+			this.mfaTokens   = [];
+			this.mfaTokenId  = null;
+			this.mfaTokenPin = null;
+			this.mfaWebauthn = null;
+			this.loading     = true;
+		},
 		authenticatePublic(username) {
 			// keep token as public user is not asked
 			this.$store.commit('local/tokenKeep',true);
@@ -373,6 +695,41 @@ let MyLogin = {
 			);
 			this.loading = true;
 		},
+		authenticateIdp(provider,code) {
+			ws.send('auth',provider,{code:code},true).then(
+				res => this.authenticatedByUser(
+					res.payload.loginId,
+					res.payload.loginName,
+					res.payload.token,
+					null
+				),
+				err => this.handleError('authIdp',err)
+			);
+			this.loading = true;
+		},
+		authenticateIdpStart(provider,id) {
+			// authentication continues at identity provider, returns via callback with one-time code
+			this.loading = true;
+			window.location.href = `/${provider}/login/${id}`;
+		},
+		authenticateWebauthn() {
+			ws.send('auth','webauthnOptions',{},true).then(
+				res => this.webauthnGet(res.payload).then(
+					assertion => ws.send('auth','webauthn',{assertion:assertion},true).then(
+						res => this.authenticatedByUser(
+							res.payload.loginId,
+							res.payload.loginName,
+							res.payload.token,
+							null
+						),
+						err => this.handleError('authUser',err)
+					),
+					err => this.handleError('webauthn',err.message)
+				),
+				err => this.handleError('authUser',err)
+			);
+			this.loading = true;
+		},
 		authenticateByToken() {
 			ws.send('auth','token',{token:this.token},true).then(
 				res => this.appEnable(
@@ -393,6 +750,10 @@ let MyLogin = {
 			if(saltKdf === null || !this.cryptoApiAvailable)
 				return this.appEnable(loginId,loginName);
 			
+			// password was changed during authentication, re-encrypt private key with new login key
+			if(this.pwChange)
+				return this.authenticatedByUserPwChange(loginId,loginName,saltKdf);
+			
 			// generate AES key from credentials and login private key salt
 			this.pbkdf2PassToAesGcmKey(this.password,saltKdf,this.kdfIterations,true).then(
 				key => {
@@ -409,6 +770,39 @@ let MyLogin = {
 				() => this.handleError('kdfCreate','')
 			);
 		},
+		async authenticatedByUserPwChange(loginId,loginName,saltKdf) {
+			let newLoginKey;
+			try {
+				// generate login keys from old and new password
+				const keys = await Promise.all([
+					this.pbkdf2PassToAesGcmKey(this.password,saltKdf,this.kdfIterations,true),
+					this.pbkdf2PassToAesGcmKey(this.pwNew0,saltKdf,this.kdfIterations,true)
+				]);
+				newLoginKey = keys[1];
+				
+				// re-encrypt private key with new login key, if login has one
+				const res = await ws.send('lookup','get',{name:'loginKeys'},true);
+				if(res.payload.privateEnc !== null) {
+					const privateKeyPem = await this.aesGcmDecryptBase64(res.payload.privateEnc,keys[0]);
+					const privateKeyEnc = await this.aesGcmEncryptBase64(privateKeyPem,newLoginKey);
+					await ws.send('loginKeys','storePrivate',{privateKeyEnc:privateKeyEnc},true);
+				}
+			} catch(e) {
+				// login is authenticated, E2EE stays unavailable until private key is restored via backup code
+				this.consoleError(e);
+				this.handleError('keyUpdate','');
+				return this.appEnable(loginId,loginName);
+			}
+			
+			this.aesGcmExportBase64(newLoginKey).then(
+				keyBase64 => {
+					this.$store.commit('local/loginKeyAes',keyBase64);
+					this.$store.commit('local/loginKeySalt',saltKdf);
+					this.appEnable(loginId,loginName);
+				},
+				() => this.handleError('aesExport','')
+			);
+		},
 		
 		// authentication successful, prepare application load
 		appEnable(loginId,loginName) {
@@ -0,0 +1,120 @@
+import {getUnixFormat} from './shared/time.js';
+export {MyLoginSessions as default};
+
+let MyLoginSessions = {
+	name:'my-login-sessions',
+	template:`<div class="login-sessions">
+		<p>{{ capApp.intro }}</p>
+
+		<div class="login-sessions-list">
+			<table class="generic-table sticky-top bright default-inputs">
+				<thead>
+					<tr>
+						<th>{{ capApp.device }}</th>
+						<th>{{ capApp.address }}</th>
+						<th>{{ capApp.dateCreate }}</th>
+						<th>{{ capApp.dateLastSeen }}</th>
+						<th colspan="2">{{ capApp.dateExpiry }}</th>
+					</tr>
+				</thead>
+				<tbody>
+					<tr v-for="s in sessions" :key="s.id">
+						<td :title="s.userAgent">
+							{{ displayDevice(s.device) }}
+							<i v-if="s.id === sessionIdCurrent">({{ capApp.current }})</i>
+						</td>
+						<td>{{ s.address }}</td>
+						<td>{{ getUnixFormat(s.dateCreate,'Y-m-d H:i') }}</td>
+						<td>{{ getUnixFormat(s.dateLastSeen,'Y-m-d H:i') }}</td>
+						<td>{{ getUnixFormat(s.dateExpiry,'Y-m-d H:i') }}</td>
+						<td>
+							<div class="row">
+								<my-button image="logoff.png"
+									@trigger="delAsk(s.id)"
+									:cancel="true"
+									:caption="capApp.button.revoke"
+								/>
+							</div>
+						</td>
+					</tr>
+					<tr v-if="sessions.length === 0">
+						<td colspan="6">{{ capApp.empty }}</td>
+					</tr>
+				</tbody>
+			</table>
+		</div>
+	</div>`,
+	props:{
+		isAdmin:{ type:Boolean, required:false, default:false }, // manage sessions of any login via admin requests
+		loginId:{ type:Number,  required:false, default:0 }      // login to show sessions of, admin only
+	},
+	data() {
+		return {
+			sessions:[]
+		};
+	},
+	computed:{
+		// session ID of this client is stored as JWT ID in its token
+		sessionIdCurrent:(s) => {
+			if(s.token === '') return null;
+
+			const payload = s.token.split('.')[1].replace(/-/g,'+').replace(/_/g,'/');
+			return JSON.parse(atob(payload)).jti;
+		},
+
+		// stores
+		token: (s) => s.$store.getters['local/token'],
+		capApp:(s) => s.$store.getters.captions.settings.sessions,
+		capGen:(s) => s.$store.getters.captions.generic
+	},
+	mounted() {
+		this.get();
+	},
+	methods:{
+		// externals
+		getUnixFormat,
+
+		// presentation
+		displayDevice(v) {
+			switch(v) {
+				case 'api':       return this.capApp.deviceApi;       break;
+				case 'browser':   return this.capApp.deviceBrowser;   break;
+				case 'fatClient': return this.capApp.deviceFatClient; break;
+			}
+			return '-';
+		},
+
+		// backend calls
+		delAsk(id) {
+			this.$store.commit('dialog',{
+				captionBody:this.capApp.dialog.revoke,
+				image:'warning.png',
+				buttons:[{
+					cancel:true,
+					caption:this.capApp.button.revoke,
+					exec:() => this.del(id),
+					keyEnter:true,
+					image:'logoff.png'
+				},{
+					caption:this.capGen.button.cancel,
+					keyEscape:true,
+					image:'cancel.png'
+				}]
+			});
+		},
+		del(id) {
+			const action = this.isAdmin ? 'delSessionAdmin' : 'delSession';
+			ws.send('login',action,{id:id},true).then(
+				this.get,
+				this.$root.genericError
+			);
+		},
+		get() {
+			const action = this.isAdmin ? 'getSessionsAdmin' : 'getSessions';
+			ws.send('login',action,{id:this.loginId},true).then(
+				res => this.sessions = res.payload,
+				this.$root.genericError
+			);
+		}
+	}
+};
@@ -0,0 +1,219 @@
+import {getUnixFormat} from './shared/time.js';
+export {MyLoginTokensApi as default};
+
+let MyLoginTokensApi = {
+	name:'my-login-tokens-api',
+	template:`<div class="login-tokens-api">
+		<p v-if="!isAdmin">{{ capApp.intro }}</p>
+
+		<div class="login-tokens-api-list">
+			<table class="generic-table sticky-top bright default-inputs">
+				<thead>
+					<tr>
+						<th v-if="isAdmin">{{ capApp.login }}</th>
+						<th>{{ capApp.name }}</th>
+						<th>{{ capApp.apis }}</th>
+						<th>{{ capApp.methods }}</th>
+						<th>{{ capApp.addresses }}</th>
+						<th>{{ capApp.dateExpiry }}</th>
+						<th colspan="2">{{ capApp.dateUsed }}</th>
+					</tr>
+				</thead>
+				<tbody>
+					<tr v-for="t in tokens" :key="t.id">
+						<td v-if="isAdmin">{{ t.loginName }}</td>
+						<td>{{ t.name }}</td>
+						<td>{{ displayApis(t.apiIds) }}</td>
+						<td>{{ t.methods.length === 0 ? capApp.all : t.methods.join(', ') }}</td>
+						<td>{{ t.addresses.length === 0 ? capApp.all : t.addresses.join(', ') }}</td>
+						<td>{{ getUnixFormat(t.dateExpiry,'Y-m-d') }}</td>
+						<td>
+							<template v-if="t.dateUsed !== null">
+								{{ getUnixFormat(t.dateUsed,'Y-m-d H:i') + ' (' + t.addressUsed + ')' }}
+							</template>
+							<template v-else>-</template>
+						</td>
+						<td>
+							<div class="row">
+								<my-button image="delete.png"
+									@trigger="delAsk(t.id)"
+									:cancel="true"
+								/>
+							</div>
+						</td>
+					</tr>
+					<tr v-if="tokens.length === 0">
+						<td :colspan="isAdmin ? 8 : 7">{{ capApp.empty }}</td>
+					</tr>
+				</tbody>
+			</table>
+		</div>
+
+		<!-- new token -->
+		<div class="login-tokens-api-new column gap default-inputs" v-if="!isAdmin">
+			<template v-if="tokenNew === ''">
+				<div class="row gap centered">
+					<span>{{ capApp.name }}</span>
+					<input v-model="name" :placeholder="capApp.nameHint" />
+				</div>
+				<div class="row gap centered">
+					<span>{{ capApp.expiryDays }}</span>
+					<input v-model.number="expiryDays" type="number" min="1" />
+				</div>
+				<div class="column">
+					<span>{{ capApp.apis }}</span>
+					<i v-if="apisAccessible.length === 0">{{ capApp.apisNone }}</i>
+					<label class="row gap centered" v-for="a in apisAccessible" :key="a.id">
+						<input type="checkbox" :checked="apiIds.includes(a.id)" @change="toggle(apiIds,a.id)" />
+						<span>{{ displayApi(a) }}</span>
+					</label>
+				</div>
+				<div class="row gap centered">
+					<span>{{ capApp.methods }}</span>
+					<label class="row gap centered" v-for="m in methodsValid">
+						<input type="checkbox" :checked="methods.includes(m)" @change="toggle(methods,m)" />
+						<span>{{ m }}</span>
+					</label>
+				</div>
+				<div class="row gap centered">
+					<span>{{ capApp.addresses }}</span>
+					<input v-model="addresses" :placeholder="capApp.addressesHint" />
+				</div>
+				<span><i>{{ capApp.emptyIsAll }}</i></span>
+				<div class="row">
+					<my-button image="add.png"
+						@trigger="set"
+						:active="name !== '' && expiryDays > 0"
+						:caption="capApp.button.create"
+					/>
+				</div>
+			</template>
+
+			<template v-if="tokenNew !== ''">
+				<span>{{ capApp.tokenNewHint }}</span>
+				<input class="login-tokens-api-token" :value="tokenNew" readonly @focus="$event.target.select()" />
+				<div class="row">
+					<my-button image="ok.png"
+						@trigger="tokenNew = ''"
+						:caption="capGen.button.ok"
+					/>
+				</div>
+			</template>
+		</div>
+	</div>`,
+	props:{
+		isAdmin:{ type:Boolean, required:false, default:false } // manage tokens of all logins via admin requests
+	},
+	data() {
+		return {
+			tokens:[],
+			tokenNew:'', // newly created token, only shown once
+
+			// inputs for new token
+			addresses:'',
+			apiIds:[],
+			expiryDays:90,
+			methods:[],
+			methodsValid:['DELETE','GET','POST'],
+			name:''
+		};
+	},
+	computed:{
+		// APIs that the current login has access to
+		apisAccessible:(s) => {
+			let out = [];
+			for(const id in s.access.api) {
+				if(s.apiIdMap[id] !== undefined)
+					out.push(s.apiIdMap[id]);
+			}
+			return out;
+		},
+
+		// stores
+		access:     (s) => s.$store.getters.access,
+		apiIdMap:   (s) => s.$store.getters['schema/apiIdMap'],
+		moduleIdMap:(s) => s.$store.getters['schema/moduleIdMap'],
+		capApp:     (s) => s.$store.getters.captions.settings.tokensApi,
+		capGen:     (s) => s.$store.getters.captions.generic
+	},
+	mounted() {
+		this.get();
+	},
+	methods:{
+		// externals
+		getUnixFormat,
+
+		// presentation
+		displayApi(api) {
+			const mod = this.moduleIdMap[api.moduleId];
+			return `${mod !== undefined ? mod.name : '-'}/${api.name} (v${api.version})`;
+		},
+		displayApis(apiIds) {
+			if(apiIds.length === 0)
+				return this.capApp.all;
+
+			return apiIds.map(id => this.apiIdMap[id] !== undefined
+				? this.displayApi(this.apiIdMap[id]) : id).join(', ');
+		},
+
+		// actions
+		toggle(list,value) {
+			const pos = list.indexOf(value);
+			if(pos === -1) list.push(value);
+			else           list.splice(pos,1);
+		},
+
+		// backend calls
+		delAsk(id) {
+			this.$store.commit('dialog',{
+				captionBody:this.capApp.dialog.delete,
+				image:'delete.png',
+				buttons:[{
+					cancel:true,
+					caption:this.capGen.button.delete,
+					exec:() => this.del(id),
+					keyEnter:true,
+					image:'delete.png'
+				},{
+					caption:this.capGen.button.cancel,
+					keyEscape:true,
+					image:'cancel.png'
+				}]
+			});
+		},
+		del(id) {
+			const action = this.isAdmin ? 'delTokenApiAdmin' : 'delTokenApi';
+			ws.send('login',action,{id:id},true).then(
+				this.get,
+				this.$root.genericError
+			);
+		},
+		get() {
+			const action = this.isAdmin ? 'getTokensApiAdmin' : 'getTokensApi';
+			ws.send('login',action,{},true).then(
+				res => this.tokens = res.payload,
+				this.$root.genericError
+			);
+		},
+		set() {
+			ws.send('login','setTokenApi',{
+				name:this.name,
+				dateExpiry:Math.floor(Date.now() / 1000) + (this.expiryDays * 86400),
+				apiIds:this.apiIds,
+				methods:this.methods,
+				addresses:this.addresses.split(',').map(v => v.trim()).filter(v => v !== '')
+			},true).then(
+				res => {
+					this.tokenNew   = res.payload;
+					this.addresses  = '';
+					this.apiIds     = [];
+					this.expiryDays = 90;
+					this.methods    = [];
+					this.name       = '';
+					this.get();
+				},
+				this.$root.genericError
+			);
+		}
+	}
+};
@@ -4,6 +4,8 @@ import {set as setSetting} from './shared/settings.js';
 import {getUnixFormat}     from './shared/time.js';
 import MyInputColor        from './inputColor.js';
 import MyInputHotkey       from './inputHotkey.js';
+import MyLoginSessions     from './loginSessions.js';
+import MyLoginTokensApi    from './loginTokensApi.js';
 import MyTabs              from './tabs.js';
 import {
 	aesGcmDecryptBase64,
@@ -17,6 +19,10 @@ import {
 	pemImport,
 	rsaGenerateKeys
 } from './shared/crypto.js';
+import {
+	webauthnAvailable,
+	webauthnCreate
+} from './shared/webauthn.js';
 export {MySettings as default};
 
 let MySettingsEncryption = {
@@ -694,7 +700,7 @@ let MySettingsFixedTokens = {
 	name:'my-settings-fixed-tokens',
 	components:{MyTabs},
 	template:`<div>
-		<div class="settings-tokens" v-if="tokensFixed.length !== 0">
+		<div class="settings-tokens" v-if="tokensFixed.length !== 0 || webauthn.length !== 0">
 			<table class="generic-table sticky-top bright default-inputs">
 				<thead>
 					<tr>
@@ -711,7 +717,20 @@ let MySettingsFixedTokens = {
 						<td>
 							<div class="row">
 								<my-button image="delete.png"
-									@trigger="delAsk(t.id)"
+									@trigger="delAsk(t.id,false)"
+									:cancel="true"
+								/>
+							</div>
+						</td>
+					</tr>
+					<tr v-for="w in webauthn">
+						<td>{{ w.name }}</td>
+						<td>{{ capApp.context.webauthn }}</td>
+						<td><span :title="getUnixFormat(w.dateCreate,'Y-m-d H:i:S')">{{ getUnixFormat(w.dateCreate,'Y-m-d') }}</span></td>
+						<td>
+							<div class="row">
+								<my-button image="delete.png"
+									@trigger="delAsk(w.id,true)"
 									:cancel="true"
 								/>
 							</div>
@@ -730,6 +749,56 @@ let MySettingsFixedTokens = {
 				@trigger="showSubWindow('mfa')"
 				:caption="capApp.titleMfa"
 			/>
+			<my-button image="key.png"
+				v-if="webauthnAvailable()"
+				@trigger="showSubWindow('webauthn')"
+				:caption="capApp.titleWebauthn"
+			/>
+		</div>
+		
+		<!-- WebAuthn sub window -->
+		<div class="app-sub-window" v-if="showWebauthn">
+			<div class="contentBox float settings-mfa">
+				<div class="top lower">
+					<div class="area">
+						<img class="icon" src="images/key.png" />
+						<div class="caption">{{ capApp.titleWebauthn }}</div>
+					</div>
+					<div class="area">
+						<my-button
+							@trigger="showWebauthn = false" image="cancel.png"
+							:cancel="true"
+						/>
+					</div>
+				</div>
+				
+				<div class="content">
+					<div class="column">
+						<span>{{ capApp.webauthn.intro }}</span>
+						<br />
+						
+						<div class="row gap centered default-inputs">
+							<span>{{ capApp.webauthn.name }}</span>
+							<div class="settings-mfa-input">
+								<input class="dynamic"
+									v-model="tokenName"
+									v-focus
+									:placeholder="capApp.webauthn.nameHint"
+								/>
+							</div>
+						</div>
+						
+						<br />
+						<div>
+							<my-button image="ok.png"
+								@trigger="setWebauthn"
+								:active="tokenName !== ''"
+								:caption="capGen.button.ok"
+							/>
+						</div>
+					</div>
+				</div>
+			</div>
 		</div>
 		
 		<!-- MFA sub window -->
@@ -918,15 +987,18 @@ let MySettingsFixedTokens = {
 		return {
 			tabTarget:"install",
 			tokensFixed:[],
+			webauthn:[],
 			showInstall:false,
 			showMfa:false,
 			showMfaText:false,
+			showWebauthn:false,
 			
 			// inputs
 			deviceOs:'amd64_windows',
 			tokenFixed:'',
 			tokenFixedB32:'',
 			tokenIdDel:null, // ID of token to delete (dialog)
+			tokenIsWebauthnDel:false,
 			tokenName:''
 		};
 	},
@@ -971,6 +1043,8 @@ let MySettingsFixedTokens = {
 	methods:{
 		// externals
 		getUnixFormat,
+		webauthnAvailable,
+		webauthnCreate,
 		
 		// actions
 		loadApp() {
@@ -1004,8 +1078,9 @@ let MySettingsFixedTokens = {
 			this.tokenFixedB32 = '';
 			this.tokenName     = '';
 			switch(target) {
-				case 'install': this.showInstall = true; break;
-				case 'mfa':     this.showMfa     = true; break;
+				case 'install':  this.showInstall  = true; break;
+				case 'mfa':      this.showMfa      = true; break;
+				case 'webauthn': this.showWebauthn = true; break;
 			}
 		},
 		
@@ -1020,8 +1095,9 @@ let MySettingsFixedTokens = {
 		},
 		
 		// backend calls
-		delAsk(id) {
-			this.tokenIdDel = id;
+		delAsk(id,isWebauthn) {
+			this.tokenIdDel         = id;
+			this.tokenIsWebauthnDel = isWebauthn;
 			this.$store.commit('dialog',{
 				captionBody:this.capApp.message.delete,
 				image:'warning.png',
@@ -1039,14 +1115,21 @@ let MySettingsFixedTokens = {
 			});
 		},
 		del() {
-			ws.send('login','delTokenFixed',{id:this.tokenIdDel},true).then(
+			const action = this.tokenIsWebauthnDel ? 'delWebauthn' : 'delTokenFixed';
+			ws.send('login',action,{id:this.tokenIdDel},true).then(
 				this.get,
 				this.$root.genericError
 			);
 		},
 		get() {
-			ws.send('login','getTokensFixed',{},true).then(
-				res => this.tokensFixed = res.payload,
+			ws.sendMultiple([
+				ws.prepare('login','getTokensFixed',{}),
+				ws.prepare('login','getWebauthn',{})
+			],true).then(
+				res => {
+					this.tokensFixed = res[0].payload;
+					this.webauthn    = res[1].payload;
+				},
 				this.$root.genericError
 			);
 		},
@@ -1062,6 +1145,26 @@ let MySettingsFixedTokens = {
 				},
 				this.$root.genericError
 			);
+		},
+		setWebauthn() {
+			// authenticator creates new credential, validated & stored by server
+			ws.send('login','getWebauthnOptions',{},true).then(
+				res => this.webauthnCreate(res.payload).then(
+					credential => ws.send('login','setWebauthn',{
+						name:this.tokenName,
+						attestationObject:credential.attestationObject,
+						clientDataJson:credential.clientDataJson
+					},true).then(
+						() => {
+							this.showWebauthn = false;
+							this.get();
+						},
+						this.$root.genericError
+					),
+					err => this.$root.genericError(err.message)
+				),
+				this.$root.genericError
+			);
 		}
 	}
 };
@@ -1070,6 +1173,8 @@ let MySettings = {
 	name:'my-settings',
 	components:{
 		MyInputColor,
+		MyLoginSessions,
+		MyLoginTokensApi,
 		MySettingsAccount,
 		MySettingsClientEvents,
 		MySettingsEncryption,
@@ -1330,6 +1435,24 @@ let MySettings = {
 				/>
 			</div>
 			
+			<!-- login sessions -->
+			<div class="contentPart short">
+				<div class="contentPartHeader">
+					<img class="icon" src="images/logoff.png" />
+					<h1>{{ capApp.titleSessions }}</h1>
+				</div>
+				<my-login-sessions />
+			</div>
+			
+			<!-- personal access tokens (REST API) -->
+			<div class="contentPart short">
+				<div class="contentPartHeader">
+					<img class="icon" src="images/key.png" />
+					<h1>{{ capApp.titleTokensApi }}</h1>
+				</div>
+				<my-login-tokens-api />
+			</div>
+			
 			<!-- client events (global hotkeys) -->
 			<div class="contentPart short">
 				<div class="contentPartHeader">
@@ -28,6 +28,8 @@ export function getCaptionMapName(content) {
 		case 'formTitle':        return 'formIdMap';        break;
 		case 'jsFunctionTitle':  return 'jsFunctionIdMap';  break;
 		case 'loginFormTitle':   return 'loginFormIdMap';   break;
+		case 'mailTemplateBody':    // fallthrough
+		case 'mailTemplateSubject': return 'mailTemplateIdMap'; break;
 		case 'menuTitle':        return 'menuIdMap';        break;
 		case 'moduleTitle':      return 'moduleIdMap';      break;
 		case 'pgFunctionTitle':  return 'pgFunctionIdMap';  break;
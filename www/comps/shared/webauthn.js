@@ -0,0 +1,74 @@
+// WebAuthn ceremonies, options are created by the server
+// binary values are exchanged with the server as base64url strings
+
+export function webauthnAvailable() {
+	return typeof window.PublicKeyCredential !== 'undefined';
+};
+
+// registration: creates new credential, returns attestation to be validated by server
+export function webauthnCreate(options) {
+	return new Promise((resolve,reject) => {
+		navigator.credentials.create({publicKey:{
+			attestation:'none',
+			authenticatorSelection:{
+				residentKey:options.residentKey,
+				userVerification:options.userVerification
+			},
+			challenge:base64UrlToArrayBuffer(options.challenge),
+			excludeCredentials:options.excludeCredentials.map(c => {
+				return { type:c.type, id:base64UrlToArrayBuffer(c.id) };
+			}),
+			pubKeyCredParams:options.algorithms.map(alg => {
+				return { type:'public-key', alg:alg };
+			}),
+			rp:{ id:options.rpId, name:options.rpName },
+			timeout:options.timeout,
+			user:{
+				displayName:options.userName,
+				id:base64UrlToArrayBuffer(options.userId),
+				name:options.userName
+			}
+		}}).then(
+			credential => resolve({
+				attestationObject:arrayBufferToBase64Url(credential.response.attestationObject),
+				clientDataJson:arrayBufferToBase64Url(credential.response.clientDataJSON)
+			}),
+			reject
+		);
+	});
+};
+
+// authentication: signs challenge with existing credential, returns assertion to be validated by server
+export function webauthnGet(options) {
+	return new Promise((resolve,reject) => {
+		navigator.credentials.get({publicKey:{
+			allowCredentials:options.allowCredentials.map(c => {
+				return { type:c.type, id:base64UrlToArrayBuffer(c.id) };
+			}),
+			challenge:base64UrlToArrayBuffer(options.challenge),
+			rpId:options.rpId,
+			timeout:options.timeout,
+			userVerification:options.userVerification
+		}}).then(
+			credential => resolve({
+				authenticatorData:arrayBufferToBase64Url(credential.response.authenticatorData),
+				clientDataJson:arrayBufferToBase64Url(credential.response.clientDataJSON),
+				credentialId:arrayBufferToBase64Url(credential.rawId),
+				signature:arrayBufferToBase64Url(credential.response.signature),
+				userHandle:credential.response.userHandle === null
+					? '' : arrayBufferToBase64Url(credential.response.userHandle)
+			}),
+			reject
+		);
+	});
+};
+
+// helpers
+function arrayBufferToBase64Url(arrayBuffer) {
+	const v = Array.from(new Uint8Array(arrayBuffer)).map(b => String.fromCharCode(b)).join('');
+	return btoa(v).replace(/\+/g,'-').replace(/\//g,'_').replace(/=+$/,'');
+};
+function base64UrlToArrayBuffer(v) {
+	const s = atob(v.replace(/-/g,'+').replace(/_/g,'/'));
+	return Uint8Array.from(s,ch => ch.charCodeAt(0)).buffer;
+};
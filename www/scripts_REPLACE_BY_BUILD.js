@@ -15,6 +15,7 @@ import {
 
 // admin
 import MyAdmin               from './comps/admin/admin.js';
+import MyAdminApiTokens      from './comps/admin/adminApiTokens.js';
 import MyAdminBackups        from './comps/admin/adminBackups.js';
 import MyAdminCaptionMap     from './comps/admin/adminCaptionMap.js';
 import MyAdminCluster        from './comps/admin/adminCluster.js';
@@ -31,35 +32,40 @@ import MyAdminMailSpooler    from './comps/admin/adminMailSpooler.js';
 import MyAdminMailTraffic    from './comps/admin/adminMailTraffic.js';
 import MyAdminModules        from './comps/admin/adminModules.js';
 import MyAdminOauthClients   from './comps/admin/adminOauthClients.js';
+import MyAdminOidcs          from './comps/admin/adminOidcs.js';
 import MyAdminRepo           from './comps/admin/adminRepo.js';
 import MyAdminRoles          from './comps/admin/adminRoles.js';
+import MyAdminSamls          from './comps/admin/adminSamls.js';
 import MyAdminScheduler      from './comps/admin/adminScheduler.js';
+import MyAdminScims          from './comps/admin/adminScims.js';
+import MyAdminWebhooks       from './comps/admin/adminWebhooks.js';
 
 // builder
-import MyBuilder            from './comps/builder/builder.js';
-import MyBuilderApi         from './comps/builder/builderApi.js';
-import MyBuilderApis        from './comps/builder/builderApis.js';
-import MyBuilderArticles    from './comps/builder/builderArticles.js';
-import MyBuilderCaptionMap  from './comps/builder/builderCaptionMap.js';
-import MyBuilderCollection  from './comps/builder/builderCollection.js';
-import MyBuilderCollections from './comps/builder/builderCollections.js';
-import MyBuilderForm        from './comps/builder/builderForm.js';
-import MyBuilderForms       from './comps/builder/builderForms.js';
-import MyBuilderIcons       from './comps/builder/builderIcons.js';
-import MyBuilderJsFunction  from './comps/builder/builderJsFunction.js';
-import MyBuilderJsFunctions from './comps/builder/builderJsFunctions.js';
-import MyBuilderLoginForms  from './comps/builder/builderLoginForms.js';
-import MyBuilderMenu        from './comps/builder/builderMenu.js';
-import MyBuilderModule      from './comps/builder/builderModule.js';
-import MyBuilderModules     from './comps/builder/builderModules.js';
-import MyBuilderPgFunction  from './comps/builder/builderPgFunction.js';
-import MyBuilderPgFunctions from './comps/builder/builderPgFunctions.js';
-import MyBuilderRelation    from './comps/builder/builderRelation.js';
-import MyBuilderRelations   from './comps/builder/builderRelations.js';
-import MyBuilderRole        from './comps/builder/builderRole.js';
-import MyBuilderRoles       from './comps/builder/builderRoles.js';
-import MyBuilderStart       from './comps/builder/builderStart.js';
-import MyBuilderWidgets     from './comps/builder/builderWidgets.js';
+import MyBuilder              from './comps/builder/builder.js';
+import MyBuilderApi           from './comps/builder/builderApi.js';
+import MyBuilderApis          from './comps/builder/builderApis.js';
+import MyBuilderArticles      from './comps/builder/builderArticles.js';
+import MyBuilderCaptionMap    from './comps/builder/builderCaptionMap.js';
+import MyBuilderCollection    from './comps/builder/builderCollection.js';
+import MyBuilderCollections   from './comps/builder/builderCollections.js';
+import MyBuilderForm          from './comps/builder/builderForm.js';
+import MyBuilderForms         from './comps/builder/builderForms.js';
+import MyBuilderIcons         from './comps/builder/builderIcons.js';
+import MyBuilderJsFunction    from './comps/builder/builderJsFunction.js';
+import MyBuilderJsFunctions   from './comps/builder/builderJsFunctions.js';
+import MyBuilderLoginForms    from './comps/builder/builderLoginForms.js';
+import MyBuilderMailTemplates from './comps/builder/builderMailTemplates.js';
+import MyBuilderMenu          from './comps/builder/builderMenu.js';
+import MyBuilderModule        from './comps/builder/builderModule.js';
+import MyBuilderModules       from './comps/builder/builderModules.js';
+import MyBuilderPgFunction    from './comps/builder/builderPgFunction.js';
+import MyBuilderPgFunctions   from './comps/builder/builderPgFunctions.js';
+import MyBuilderRelation      from './comps/builder/builderRelation.js';
+import MyBuilderRelations     from './comps/builder/builderRelations.js';
+import MyBuilderRole          from './comps/builder/builderRole.js';
+import MyBuilderRoles         from './comps/builder/builderRoles.js';
+import MyBuilderStart         from './comps/builder/builderStart.js';
+import MyBuilderWidgets       from './comps/builder/builderWidgets.js';
 
 // router
 const MyRouterPositions = Object.create(null);
@@ -83,6 +89,7 @@ const MyRouter = VueRouter.createRouter({
 		redirect:'/admin/config',
 		component:MyAdmin,
 		children:[
+			{ path:'api-tokens',      component:MyAdminApiTokens },
 			{ path:'backups',         component:MyAdminBackups },
 			{ path:'caption-map',     component:MyAdminCaptionMap },
 			{ path:'cluster',         component:MyAdminCluster },
@@ -99,9 +106,13 @@ const MyRouter = VueRouter.createRouter({
 			{ path:'mail-traffic',    component:MyAdminMailTraffic },
 			{ path:'modules',         component:MyAdminModules },
 			{ path:'oauth-clients',   component:MyAdminOauthClients },
+			{ path:'oidcs',           component:MyAdminOidcs },
 			{ path:'repo',            component:MyAdminRepo },
 			{ path:'roles',           component:MyAdminRoles },
-			{ path:'scheduler',       component:MyAdminScheduler }
+			{ path:'samls',           component:MyAdminSamls },
+			{ path:'scheduler',       component:MyAdminScheduler },
+			{ path:'scims',           component:MyAdminScims },
+			{ path:'webhooks',        component:MyAdminWebhooks }
 		]
 	},{
 		path:'/builder',
@@ -201,6 +212,11 @@ const MyRouter = VueRouter.createRouter({
 				meta:{ nav:'articles', target:'module' },
 				component:MyBuilderArticles,
 				props:true
+			},{
+				path:'mail-templates/:id',
+				meta:{ nav:'mail-templates', target:'module' },
+				component:MyBuilderMailTemplates,
+				props:true
 			},{
 				path:'apis/:id',
 				meta:{ nav:'apis', target:'module' },
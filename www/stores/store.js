@@ -60,18 +60,22 @@ const MyStore = Vuex.createStore({
 		moduleEntries:[],     // module entries for header/home page
 		moduleIdLast:null,    // module ID of last active module
 		moduleIdMapMeta:{},   // module ID map of module meta data (is owner, hidden, position, date change, custom languages)
+		oidcProviders:[],     // active OpenID Connect providers to login with, [{id:1,name:'Company SSO'}, ...]
 		pageTitle:'',         // web page title, set by app/form depending on navigation
 		pageTitleFull:'',     // web page title + instance name
 		popUpFormGlobal:null, // configuration of global pop-up form
 		productionMode:false, // system in production mode, false if maintenance
 		pwaDomainMap:{},      // map of modules per PWA sub domain, key: sub domain, value: module ID
+		pwResetEnable:false,  // allow users to reset their password via mail
 		routingGuards:[],     // functions to call before routing, abort if any returns falls
+		samlProviders:[],     // active SAML identity providers to login with, [{id:1,name:'Company SSO'}, ...]
 		searchDictionaries:[],// dictionaries used for full text search for this login, ['english', 'german', ...]
 		settings:{},          // setting values for logged in user, key: settings name
 		sessionTimerStore:{}, // user session timer store for frontent functions,     { moduleId1:{ timerName1:{ id:jsTimerId, isInterval:true }, ... }, ... }
 		sessionValueStore:{}, // user session key-value store for frontend functions, { moduleId1:{ key1:value1, key2:value2 }, moduleId2:{ ... } }
 		system:{},            // system details (admin only)
-		tokenKeepEnable:false // allow users to keep token to 'stay logged in'
+		tokenKeepEnable:false,     // allow users to keep token to 'stay logged in'
+		webauthnPasswordless:false // allow users to login with passkeys (WebAuthn) without password
 	},
 	mutations:{
 		config:(state,payload) => {
@@ -238,13 +242,17 @@ const MyStore = Vuex.createStore({
 		moduleEntries:           (state,payload) => state.moduleEntries            = payload,
 		moduleIdLast:            (state,payload) => state.moduleIdLast             = payload,
 		moduleIdMapMeta:         (state,payload) => state.moduleIdMapMeta          = payload,
+		oidcProviders:           (state,payload) => state.oidcProviders            = payload,
 		popUpFormGlobal:         (state,payload) => state.popUpFormGlobal          = payload,
 		productionMode:          (state,payload) => state.productionMode           = payload,
 		pwaDomainMap:            (state,payload) => state.pwaDomainMap             = payload,
+		pwResetEnable:           (state,payload) => state.pwResetEnable            = payload,
+		samlProviders:           (state,payload) => state.samlProviders            = payload,
 		searchDictionaries:      (state,payload) => state.searchDictionaries       = payload,
 		settings:                (state,payload) => state.settings                 = payload,
 		system:                  (state,payload) => state.system                   = payload,
-		tokenKeepEnable:         (state,payload) => state.tokenKeepEnable          = payload
+		tokenKeepEnable:         (state,payload) => state.tokenKeepEnable          = payload,
+		webauthnPasswordless:    (state,payload) => state.webauthnPasswordless     = payload
 	},
 	getters:{
 		colorHeaderAccent:(state,payload) => {
@@ -378,15 +386,19 @@ const MyStore = Vuex.createStore({
 		moduleEntries:           (state) => state.moduleEntries,
 		moduleIdLast:            (state) => state.moduleIdLast,
 		moduleIdMapMeta:         (state) => state.moduleIdMapMeta,
+		oidcProviders:           (state) => state.oidcProviders,
 		pageTitleFull:           (state) => state.pageTitleFull,
 		popUpFormGlobal:         (state) => state.popUpFormGlobal,
 		productionMode:          (state) => state.productionMode,
 		pwaDomainMap:            (state) => state.pwaDomainMap,
+		pwResetEnable:           (state) => state.pwResetEnable,
 		routingGuards:           (state) => state.routingGuards,
+		samlProviders:           (state) => state.samlProviders,
 		searchDictionaries:      (state) => state.searchDictionaries,
 		sessionValueStore:       (state) => state.sessionValueStore,
 		settings:                (state) => state.settings,
 		system:                  (state) => state.system,
-		tokenKeepEnable:         (state) => state.tokenKeepEnable
+		tokenKeepEnable:         (state) => state.tokenKeepEnable,
+		webauthnPasswordless:    (state) => state.webauthnPasswordless
 	}
 });
\ No newline at end of file
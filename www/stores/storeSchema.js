@@ -17,6 +17,7 @@ const MyStoreSchema = {
 		indexIdMap:{},
 		jsFunctionIdMap:{},
 		loginFormIdMap:{},
+		mailTemplateIdMap:{},
 		moduleIdMap:{},
 		moduleNameMap:{},
 		pgFunctionIdMap:{},
@@ -148,6 +149,11 @@ const MyStoreSchema = {
 				for(const loginForm of mod.loginForms) {
 					state.loginFormIdMap[loginForm.id] = loginForm;
 				}
+				
+				// process mail templates
+				for(const mailTemplate of mod.mailTemplates) {
+					state.mailTemplateIdMap[mailTemplate.id] = mailTemplate;
+				}
 			}
 		},
 		languageCodes:      (state,payload) => state.languageCodes       = payload,
@@ -165,6 +171,7 @@ const MyStoreSchema = {
 		indexIdMap:         (state) => state.indexIdMap,
 		jsFunctionIdMap:    (state) => state.jsFunctionIdMap,
 		loginFormIdMap:     (state) => state.loginFormIdMap,
+		mailTemplateIdMap:  (state) => state.mailTemplateIdMap,
 		moduleIdMap:        (state) => state.moduleIdMap,
 		moduleNameMap:      (state) => state.moduleNameMap,
 		pgFunctionIdMap:    (state) => state.pgFunctionIdMap,